@@ -0,0 +1,19 @@
+package main
+
+import (
+	"yoopass-api/internal/http-server/handlers/fetch"
+	"yoopass-api/internal/http-server/handlers/save"
+)
+
+// Hooks run custom logic on every save and reveal, see save.PreSaveHook and
+// fetch.PostFetchHook. None ship by default. Operators register their own
+// from an init func in a file of their own in this package, which keeps
+// upgrades free of conflicts:
+//
+//	func init() {
+//		preSaveHooks = append(preSaveHooks, save.PreSaveHookFunc(scanForCardNumbers))
+//	}
+var (
+	preSaveHooks   []save.PreSaveHook
+	postFetchHooks []fetch.PostFetchHook
+)
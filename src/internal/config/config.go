@@ -1,8 +1,10 @@
 package config
 
 import (
+	"errors"
 	"log/slog"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
@@ -12,14 +14,120 @@ type HTTPServer struct {
 	Address     string        `yaml:"address" env-default:"localhost:8082"`
 	Timeout     time.Duration `yaml:"timeout" env-default:"4s"`
 	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60s"`
-	User        string        `yaml:"user" env-required:"true"`
-	Password    string        `yaml:"password" env-required:"true" env:"HTTP_SERVER_PASSWORD"`
+	// ShutdownTimeout is how long in-flight requests may drain on SIGINT or
+	// SIGTERM before their connections are cut
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env-default:"10s"`
+	User            string        `yaml:"user" env-required:"true"`
+	Password        string        `yaml:"password" env-required:"true" env:"HTTP_SERVER_PASSWORD"`
+	AllowedHosts    []string      `yaml:"allowed_hosts" env:"HTTP_SERVER_ALLOWED_HOSTS" env-separator:","`
+	MaxBodyBytes    int64         `yaml:"max_body_bytes" env-default:"1048576"`
+	// AuthenticatedMaxBodyBytes replaces MaxBodyBytes for requests with
+	// valid credentials, zero keeps MaxBodyBytes
+	AuthenticatedMaxBodyBytes int64 `yaml:"authenticated_max_body_bytes" env-default:"0"`
+	MaxConnPerIP              int   `yaml:"max_conn_per_ip" env-default:"0"`
+	// TLSCertFile and TLSKeyFile serve HTTPS directly instead of plain HTTP
+	TLSCertFile   string   `yaml:"tls_cert_file"`
+	TLSKeyFile    string   `yaml:"tls_key_file"`
+	MinTLSVersion string   `yaml:"min_tls_version" env-default:"1.2"`
+	CipherSuites  []string `yaml:"cipher_suites" env-separator:","`
+}
+
+type SMTP struct {
+	Host            string        `yaml:"host" env:"SMTP_HOST"`
+	Port            int           `yaml:"port" env:"SMTP_PORT" env-default:"587"`
+	Username        string        `yaml:"username" env:"SMTP_USERNAME"`
+	Password        string        `yaml:"password" env:"SMTP_PASSWORD"`
+	From            string        `yaml:"from" env:"SMTP_FROM"`
+	Timeout         time.Duration `yaml:"timeout" env-default:"10s"`
+	IncludeClientIP bool          `yaml:"include_client_ip" env-default:"false"`
+}
+
+type Webhook struct {
+	URL            string        `yaml:"url" env:"WEBHOOK_URL"`
+	Timeout        time.Duration `yaml:"timeout" env-default:"5s"`
+	MaxConcurrent  int           `yaml:"max_concurrent" env-default:"4"`
+	QueueSize      int           `yaml:"queue_size" env-default:"100"`
+	OverflowPolicy string        `yaml:"overflow_policy" env-default:"drop_new"`
+	// DeadLetters is how many failed deliveries are kept, zero keeps none
+	DeadLetters int `yaml:"dead_letters" env-default:"0"`
+}
+
+// Tracing configures OpenTelemetry traces, exported over OTLP/HTTP.
+type Tracing struct {
+	Enabled     bool    `yaml:"enabled" env:"TRACING_ENABLED" env-default:"false"`
+	Endpoint    string  `yaml:"endpoint" env:"TRACING_ENDPOINT" env-default:"localhost:4318"`
+	Insecure    bool    `yaml:"insecure" env-default:"false"`
+	SampleRatio float64 `yaml:"sample_ratio" env-default:"1"`
+	ServiceName string  `yaml:"service_name" env-default:"yoopass-api"`
 }
 
 type Config struct {
-	Env         string `yaml:"env" env-default:"local"`
-	StoragePath string `yaml:"storage_path" env-required:"true"`
-	HTTPServer  `yaml:"http_server"`
+	Env                      string            `yaml:"env" env-default:"local"`
+	StorageDriver            string            `yaml:"storage_driver" env-default:"redis"`
+	StoragePath              string            `yaml:"storage_path"`
+	StorageReplicaPath       string            `yaml:"storage_replica_path"`
+	MaxAliasLength           int               `yaml:"max_alias_length" env-default:"128"`
+	MaxKeyLength             int               `yaml:"max_key_length" env-default:"128"`
+	KeyEncoding              string            `yaml:"key_encoding" env-default:"auto"`
+	RotateNonce              bool              `yaml:"rotate_nonce_on_view" env-default:"false"`
+	ErrorFormat              string            `yaml:"error_format" env-default:"simple"`
+	ServerSecret             string            `yaml:"server_secret" env:"YOOPASS_SERVER_SECRET"`
+	EnableUI                 bool              `yaml:"enable_ui" env-default:"false"`
+	HashAliasesInLogs        bool              `yaml:"hash_aliases_in_logs" env-default:"false"`
+	ExpiryInterval           time.Duration     `yaml:"expiry_interval" env-default:"30s"`
+	TombstoneTTL             time.Duration     `yaml:"tombstone_ttl" env-default:"168h"`
+	ForceHTTPSURLs           bool              `yaml:"force_https_urls" env-default:"false"`
+	RequestHostURLs          bool              `yaml:"request_host_urls" env-default:"false"`
+	MaxAbuseTagLength        int               `yaml:"max_abuse_tag_length" env-default:"0"`
+	MaxExpirationHours       int               `yaml:"max_expiration_hours" env-default:"720"`
+	DownloadContentTypes     []string          `yaml:"download_content_types" env-separator:","`
+	TrimMessages             bool              `yaml:"trim_messages" env-default:"false"`
+	ApprovalWindow           time.Duration     `yaml:"approval_window" env-default:"15m"`
+	ReadHistoryLength        int               `yaml:"read_history_length" env-default:"0"`
+	ExtendRequireIfMatch     bool              `yaml:"extend_require_if_match" env-default:"false"`
+	ServerManagedKeys        bool              `yaml:"server_managed_keys" env-default:"true"`
+	URLTitles                bool              `yaml:"url_titles" env-default:"false"`
+	SaveDedupeWindow         time.Duration     `yaml:"save_dedupe_window" env-default:"0s"`
+	AliasSignatures          string            `yaml:"alias_signatures" env-default:"off"`
+	MaxSavesPerMinute        int               `yaml:"max_saves_per_minute" env-default:"0"`
+	HighEntropyThreshold     float64           `yaml:"high_entropy_threshold" env-default:"0"`
+	RejectHighEntropy        bool              `yaml:"reject_high_entropy" env-default:"false"`
+	RevealWindows            bool              `yaml:"reveal_windows" env-default:"false"`
+	MaxDecodeAttempts        int               `yaml:"max_decode_attempts" env-default:"4"`
+	PasswordAttemptsPerHour  int               `yaml:"password_attempts_per_hour" env-default:"10"`
+	VerboseValidationErrors  bool              `yaml:"verbose_validation_errors" env-default:"true"`
+	ContentSignals           bool              `yaml:"content_signals" env-default:"false"`
+	ContentSignalPatterns    map[string]string `yaml:"content_signal_patterns"`
+	MinimalInternalErrors    bool              `yaml:"minimal_internal_errors" env-default:"true"`
+	ImportCreatedAt          bool              `yaml:"import_created_at" env-default:"false"`
+	CreatedAtSkew            time.Duration     `yaml:"created_at_skew" env-default:"5m"`
+	Keyring                  map[string]string `yaml:"keyring"`
+	KeyringActive            string            `yaml:"keyring_active"`
+	OneTimeGracePeriod       time.Duration     `yaml:"one_time_grace_period" env-default:"0s"`
+	OneTimeGraceSession      bool              `yaml:"one_time_grace_session" env-default:"false"`
+	RequireRequestID         bool              `yaml:"require_request_id" env-default:"false"`
+	MaxMetadataBytes         int               `yaml:"max_metadata_bytes" env-default:"0"`
+	UUIDAliasesOnly          bool              `yaml:"uuid_aliases_only" env-default:"false"`
+	ExplicitNoExpiry         bool              `yaml:"explicit_no_expiry" env-default:"false"`
+	DefaultExpirationHours   int               `yaml:"default_expiration_hours" env-default:"24"`
+	MaxSecretBytes           int               `yaml:"max_secret_bytes" env-default:"0"`
+	PrivilegedMaxSecretBytes map[string]int    `yaml:"privileged_max_secret_bytes"`
+	ContentQR                bool              `yaml:"content_qr" env-default:"false"`
+	ContentQRMaxBytes        int               `yaml:"content_qr_max_bytes" env-default:"512"`
+	TenantScope              string            `yaml:"tenant_scope" env-default:"off"`
+	Tenants                  map[string]string `yaml:"tenants"`
+	UICSRF                   bool              `yaml:"ui_csrf" env-default:"false"`
+	AbsoluteMaxLifetimeHours int               `yaml:"absolute_max_lifetime_hours" env-default:"0"`
+	ShortCodes               bool              `yaml:"short_codes" env-default:"false"`
+	StorageRetryAttempts     int               `yaml:"storage_retry_attempts" env-default:"1"`
+	StorageRetryBackoff      time.Duration     `yaml:"storage_retry_backoff" env-default:"50ms"`
+	KeySize                  int               `yaml:"key_size" env-default:"16"`
+	PrometheusMetrics        bool              `yaml:"prometheus_metrics" env-default:"false"`
+	PublicBaseURL            string            `yaml:"public_base_url"`
+	HTTPServer               `yaml:"http_server"`
+	SMTP                     SMTP    `yaml:"smtp"`
+	Webhook                  Webhook `yaml:"webhook"`
+	Tracing                  Tracing `yaml:"tracing"`
 }
 
 func MustLoad(log *slog.Logger) *Config {
@@ -40,3 +148,22 @@ func MustLoad(log *slog.Logger) *Config {
 
 	return &cfg
 }
+
+// ErrMissingServerSecret is returned by Validate when a production config has
+// no server secret.
+var ErrMissingServerSecret = errors.New("server secret is required in prod")
+
+// ErrInsecurePublicBaseURL is returned by Validate when a production config
+// builds share URLs, which carry the key, under a base URL that isn't https.
+var ErrInsecurePublicBaseURL = errors.New("public base URL must use https in prod")
+
+// Validate checks settings that can't be expressed with struct tags.
+func (c *Config) Validate() error {
+	if c.Env == "prod" && c.ServerSecret == "" {
+		return ErrMissingServerSecret
+	}
+	if c.Env == "prod" && c.PublicBaseURL != "" && !strings.HasPrefix(strings.ToLower(c.PublicBaseURL), "https://") {
+		return ErrInsecurePublicBaseURL
+	}
+	return nil
+}
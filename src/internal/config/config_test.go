@@ -0,0 +1,54 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateServerSecret(t *testing.T) {
+	testCases := []struct {
+		name        string
+		cfg         Config
+		expectedErr error
+	}{
+		{name: "Prod Without Secret", cfg: Config{Env: "prod"}, expectedErr: ErrMissingServerSecret},
+		{name: "Prod With Secret", cfg: Config{Env: "prod", ServerSecret: "pepper"}},
+		{name: "Local Without Secret", cfg: Config{Env: "local"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cfg.Validate()
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestValidatePublicBaseURL(t *testing.T) {
+	testCases := []struct {
+		name        string
+		cfg         Config
+		expectedErr error
+	}{
+		{name: "Prod With HTTP Base URL", cfg: Config{Env: "prod", ServerSecret: "pepper", PublicBaseURL: "http://share.example.org"}, expectedErr: ErrInsecurePublicBaseURL},
+		{name: "Prod With HTTPS Base URL", cfg: Config{Env: "prod", ServerSecret: "pepper", PublicBaseURL: "HTTPS://share.example.org"}},
+		{name: "Prod Without Base URL", cfg: Config{Env: "prod", ServerSecret: "pepper"}},
+		{name: "Local With HTTP Base URL", cfg: Config{Env: "local", PublicBaseURL: "http://localhost:8082"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cfg.Validate()
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -1,6 +1,32 @@
 package dto
 
+import "time"
+
 type Secret struct {
 	Message string `json:"message"`
 	OneTime bool   `json:"one_time,omitempty"`
+	// NotifyEmail receives a read receipt, it is kept inside the encrypted
+	// payload so storage never sees it in clear text
+	NotifyEmail string `json:"notify_email,omitempty"`
+	// ExpiresAt is the logical expiry, honoured even while storage still
+	// holds the key. Zero means the secret never expires.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	// ContentType is what the secret is downloaded as, when allowed
+	ContentType string `json:"content_type,omitempty"`
+	// RequireApproval withholds the secret until an operator approves a
+	// reveal, see storage.Storage.Approve
+	RequireApproval bool `json:"require_approval,omitempty"`
+	// PasswordSalt is set for a password-protected secret. Message then holds
+	// the base64 of the message sealed with a key derived from the password
+	// and this salt, see cipher.SealWithPassword.
+	PasswordSalt []byte `json:"password_salt,omitempty"`
+	// MaxViews is how many reads the secret survives, 0 means no limit
+	MaxViews int `json:"max_views,omitempty"`
+	// NotBefore and NotAfter bound when the secret may be revealed, zero
+	// values leave the window open on that side
+	NotBefore time.Time `json:"not_before,omitzero"`
+	NotAfter  time.Time `json:"not_after,omitzero"`
+	// CreatedAt is when the secret was saved, or first saved in the system
+	// it was migrated from
+	CreatedAt time.Time `json:"created_at,omitzero"`
 }
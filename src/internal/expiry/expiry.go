@@ -0,0 +1,96 @@
+package expiry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+	"yoopass-api/internal/health"
+)
+
+// batchSize bounds how many expired keys one pass claims, so a backlog is
+// worked off over several ticks instead of one long storage call.
+const batchSize = 100
+
+// Store is the part of storage the processor needs.
+type Store interface {
+	// this matches call in storage
+	ClaimExpired(now time.Time, limit int) ([]string, error)
+	SetTombstone(key string, ttl time.Duration) error
+}
+
+// Processor reads due entries off the storage expiry index, leaves a
+// tombstone for each so fetches can answer 410 Gone, and reports them to
+// OnExpired. It works the same on every backend since it never depends on
+// keyspace notifications.
+type Processor struct {
+	log          *slog.Logger
+	store        Store
+	tombstoneTTL time.Duration
+	now          func() time.Time
+
+	// OnExpired, when set, is called once for every expired alias.
+	OnExpired func(alias string)
+	// Heartbeat, when set, beats after every pass of Run, failed or not.
+	Heartbeat *health.Heartbeat
+}
+
+func New(log *slog.Logger, store Store, tombstoneTTL time.Duration) *Processor {
+	return &Processor{
+		log:          log.With(slog.String("op", "expiry.Processor")),
+		store:        store,
+		tombstoneTTL: tombstoneTTL,
+		now:          time.Now,
+	}
+}
+
+// Run processes due entries every interval until ctx is done.
+func (p *Processor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := p.ProcessDue(); err != nil {
+				p.log.Error("Failed to process expired secrets", slog.Any("error", err))
+			}
+			p.Heartbeat.Beat()
+		}
+	}
+}
+
+// ProcessDue handles every entry due by now and returns how many it handled.
+func (p *Processor) ProcessDue() (int, error) {
+	const op = "expiry.ProcessDue"
+
+	now := p.now()
+	processed := 0
+
+	for {
+		aliases, err := p.store.ClaimExpired(now, batchSize)
+		if err != nil {
+			return processed, fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, alias := range aliases {
+			// The entry is already claimed, so a failed tombstone only turns a
+			// later 410 into a 404
+			if err := p.store.SetTombstone(alias, p.tombstoneTTL); err != nil {
+				p.log.Warn("Failed to set tombstone", slog.String("alias", alias), slog.Any("error", err))
+			}
+
+			p.log.Info("Secret expired", slog.String("alias", alias))
+			if p.OnExpired != nil {
+				p.OnExpired(alias)
+			}
+			processed++
+		}
+
+		if len(aliases) < batchSize {
+			return processed, nil
+		}
+	}
+}
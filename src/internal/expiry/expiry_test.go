@@ -0,0 +1,61 @@
+package expiry
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+	redisstore "yoopass-api/internal/storage/redis"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessorHandlesDueEntriesOnce(t *testing.T) {
+	server := miniredis.RunT(t)
+	store, err := redisstore.New(server.Addr())
+	require.NoError(t, err)
+
+	require.NoError(t, store.Set("short", []byte("cipher"), time.Hour))
+	require.NoError(t, store.Set("long", []byte("cipher"), 3*time.Hour))
+	require.NoError(t, store.Set("forever", []byte("cipher"), 0))
+	require.NoError(t, store.Set("burned", []byte("cipher"), time.Hour))
+	_, err = store.Consume("burned")
+	require.NoError(t, err)
+
+	var expired []string
+	p := New(slog.New(slog.NewTextHandler(io.Discard, nil)), store, 24*time.Hour)
+	p.OnExpired = func(alias string) { expired = append(expired, alias) }
+
+	clock := time.Now()
+	p.now = func() time.Time { return clock }
+
+	// Nothing is due yet
+	n, err := p.ProcessDue()
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	// Two hours on only the short lived secret is due, and only once
+	clock = clock.Add(2 * time.Hour)
+	server.FastForward(2 * time.Hour)
+	for range 2 {
+		_, err = p.ProcessDue()
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"short"}, expired)
+
+	// Burned and never-expiring secrets are never reported
+	clock = clock.Add(48 * time.Hour)
+	server.FastForward(48 * time.Hour)
+	_, err = p.ProcessDue()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"short", "long"}, expired)
+
+	// The tombstone of "short" has run out by now as well
+	for alias, want := range map[string]bool{"short": false, "long": true, "forever": false, "burned": false} {
+		tombstoned, err := store.Tombstoned(alias)
+		require.NoError(t, err)
+		assert.Equal(t, want, tombstoned, alias)
+	}
+}
@@ -0,0 +1,91 @@
+// Package health tracks the liveness of background workers through
+// heartbeats, so a worker that got stuck can be told from one that is idle.
+package health
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Registry holds the last heartbeat of every registered worker.
+type Registry struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	workers map[string]*worker
+}
+
+type worker struct {
+	maxAge time.Duration
+	last   time.Time
+}
+
+// Status is the liveness of one worker as reported by Registry.Status.
+type Status struct {
+	Name     string    `json:"name"`
+	LastBeat time.Time `json:"last_beat"`
+	Healthy  bool      `json:"healthy"`
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		now:     time.Now,
+		workers: make(map[string]*worker),
+	}
+}
+
+// Register adds a worker that must beat at least every maxAge and returns
+// its Heartbeat. Registering counts as a beat, so a worker isn't reported
+// stuck before its first run. Registering a name again replaces it.
+func (r *Registry) Register(name string, maxAge time.Duration) *Heartbeat {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.workers[name] = &worker{maxAge: maxAge, last: r.now()}
+	return &Heartbeat{registry: r, name: name}
+}
+
+// Status reports every registered worker, sorted by name.
+func (r *Registry) Status() []Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	statuses := make([]Status, 0, len(r.workers))
+	for name, w := range r.workers {
+		statuses = append(statuses, Status{
+			Name:     name,
+			LastBeat: w.last,
+			Healthy:  now.Sub(w.last) <= w.maxAge,
+		})
+	}
+	slices.SortFunc(statuses, func(a, b Status) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return statuses
+}
+
+func (r *Registry) beat(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if w, ok := r.workers[name]; ok {
+		w.last = r.now()
+	}
+}
+
+// Heartbeat is how a worker reports it is alive.
+type Heartbeat struct {
+	registry *Registry
+	name     string
+}
+
+// Beat records that the worker made progress. A nil Heartbeat does nothing,
+// so workers can beat unconditionally.
+func (h *Heartbeat) Beat() {
+	if h == nil {
+		return
+	}
+	h.registry.beat(h.name)
+}
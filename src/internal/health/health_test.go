@@ -0,0 +1,34 @@
+package health
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryStatus(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	registry := NewRegistry()
+	registry.now = func() time.Time { return now }
+
+	expiry := registry.Register("expiry", time.Minute)
+	webhooks := registry.Register("webhooks", time.Minute)
+
+	now = now.Add(50 * time.Second)
+	webhooks.Beat()
+	now = now.Add(30 * time.Second)
+
+	assert.Equal(t, []Status{
+		{Name: "expiry", LastBeat: now.Add(-80 * time.Second), Healthy: false},
+		{Name: "webhooks", LastBeat: now.Add(-30 * time.Second), Healthy: true},
+	}, registry.Status())
+
+	expiry.Beat()
+	for _, status := range registry.Status() {
+		assert.True(t, status.Healthy, status.Name)
+	}
+
+	var none *Heartbeat
+	assert.NotPanics(t, none.Beat)
+}
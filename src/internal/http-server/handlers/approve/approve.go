@@ -0,0 +1,81 @@
+package approve
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+	"yoopass-api/internal/http-server/handlers/response"
+	resp "yoopass-api/internal/http-server/handlers/response"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+)
+
+type Response struct {
+	response.Response
+	Alias string `json:"alias"`
+	// ExpiresIn is how many seconds the recipient has to reveal the secret
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+type Approver interface {
+	// this matches call in storage
+	TTL(key string) (time.Duration, error)
+	Approve(key string, window time.Duration) error
+}
+
+// New serves POST /{alias}/approve, the second party of a dual-control
+// reveal. It lets a secret saved with require_approval be revealed once
+// within window. The route must sit behind authentication.
+func New(log *slog.Logger, approver Approver, window time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.approve.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.Info("Alias parameter is missing")
+			resp.RenderError(w, r, http.StatusBadRequest, "Alias parameter is missing")
+			return
+		}
+
+		// Only approve what exists, a stale approval must not outlive its
+		// secret and apply to a later one
+		_, err := approver.TTL(alias)
+		if status, msg, ok := resp.FromStorageError(err); ok {
+			log.Info("Failed to look up secret", slog.String("alias", alias), slog.Any("error", err))
+			resp.RenderError(w, r, status, msg)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to look up secret", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to approve secret")
+			return
+		}
+
+		err = approver.Approve(alias, window)
+		if status, msg, ok := resp.FromStorageError(err); ok {
+			log.Error("Failed to approve secret", slog.Any("error", err))
+			resp.RenderError(w, r, status, msg)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to approve secret", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to approve secret")
+			return
+		}
+
+		log.Warn("Secret reveal approved", slog.String("alias", alias), slog.Duration("window", window))
+
+		render.JSON(w, r, Response{
+			Response:  resp.OK(),
+			Alias:     alias,
+			ExpiresIn: int64(window / time.Second),
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package approve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/storage"
+	"yoopass-api/internal/storage/storagemock"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApproveHandler(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "approve"))
+
+	const alias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+
+	testCases := []struct {
+		name           string
+		setupMock      func(m *storagemock.Storage)
+		expectedStatus int
+		expectedBody   interface{}
+	}{
+		{
+			name: "Success Approve",
+			setupMock: func(m *storagemock.Storage) {
+				m.On("TTL", alias).Return(time.Hour, nil).Once()
+				m.On("Approve", alias, 15*time.Minute).Return(nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   Response{Response: resp.OK(), Alias: alias, ExpiresIn: 900},
+		},
+		{
+			name: "Error Secret Not Found",
+			setupMock: func(m *storagemock.Storage) {
+				m.On("TTL", alias).Return(time.Duration(0), storage.ErrNotFound).Once()
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   resp.Error("Secret not found"),
+		},
+		{
+			name: "Error Approve Fails",
+			setupMock: func(m *storagemock.Storage) {
+				m.On("TTL", alias).Return(time.Hour, nil).Once()
+				m.On("Approve", alias, 15*time.Minute).Return(errors.New("boom")).Once()
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   resp.Error("Failed to approve secret"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStorage := new(storagemock.Storage)
+			tc.setupMock(mockStorage)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("alias", alias)
+			req := httptest.NewRequest(http.MethodPost, "/"+alias+"/approve", nil)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			rr := httptest.NewRecorder()
+
+			New(log, mockStorage, 15*time.Minute).ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			expectedJson, err := json.Marshal(tc.expectedBody)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(expectedJson), rr.Body.String())
+			mockStorage.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+	"yoopass-api/internal/http-server/handlers/response"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/storage"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+)
+
+// contentType is the media type of backups, one storage.Record per line.
+const contentType = "application/x-ndjson"
+
+type ImportResponse struct {
+	response.Response
+	Imported int `json:"imported"`
+	// Skipped counts records that expired since the backup was taken
+	Skipped int `json:"skipped"`
+}
+
+type Exporter interface {
+	// this matches call in storage
+	Export(fn func(storage.Record) error) error
+}
+
+type Importer interface {
+	// this matches call in storage
+	Set(key string, value []byte, ttl time.Duration) error
+	Update(key string, value []byte, ttl time.Duration, version int64) (int64, error)
+	SetMetadata(key string, md storage.Metadata, ttl time.Duration) error
+}
+
+// NewExport serves GET /admin/export, which streams every secret as NDJSON
+// with its ciphertext, expiry and plaintext metadata. Secrets stay
+// encrypted, the keys are never stored. The route must sit behind
+// authentication.
+func NewExport(log *slog.Logger, exporter Exporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.backup.NewExport"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", "no-store")
+
+		// Nothing is written before the first record, so a failing storage
+		// can still be reported properly
+		var exported int
+		buf := bufio.NewWriter(w)
+		enc := json.NewEncoder(buf)
+		err := exporter.Export(func(record storage.Record) error {
+			exported++
+			return enc.Encode(record)
+		})
+		if err == nil {
+			err = buf.Flush()
+		}
+		if err != nil && exported == 0 {
+			w.Header().Del("Content-Type")
+			if status, msg, ok := resp.FromStorageError(err); ok {
+				log.Error("Failed to export secrets", slog.Any("error", err))
+				resp.RenderError(w, r, status, msg)
+				return
+			}
+			log.Error("Failed to export secrets", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to export secrets")
+			return
+		}
+		if err != nil {
+			// The status is already sent, a cut off export is all that's left
+			log.Error("Export aborted", slog.Int("exported", exported), slog.Any("error", err))
+			return
+		}
+
+		log.Warn("Secrets exported", slog.Int("exported", exported))
+	}
+}
+
+// NewImport serves POST /admin/import, which restores an export made by
+// NewExport. Existing aliases are overwritten, so restoring also brings back
+// secrets read or deleted since the backup was taken. The whole body is
+// checked before anything is written. The route must sit behind
+// authentication.
+func NewImport(log *slog.Logger, importer Importer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.backup.NewImport"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		records, line, err := decode(r.Body)
+		if err != nil {
+			log.Info("Invalid backup", slog.Int("line", line), slog.Any("error", err))
+			resp.RenderErrorf(w, r, http.StatusBadRequest, "Invalid backup record on line %d", line)
+			return
+		}
+
+		var imported, skipped int
+		now := time.Now()
+		for _, record := range records {
+			var ttl time.Duration
+			if !record.ExpiresAt.IsZero() {
+				ttl = record.ExpiresAt.Sub(now)
+				if ttl <= 0 {
+					skipped++
+					continue
+				}
+			}
+
+			// Metadata first, as on save, so the secret is never served
+			// without it
+			var err error
+			if record.Metadata != nil {
+				err = importer.SetMetadata(record.Alias, *record.Metadata, ttl)
+			}
+			if err == nil {
+				err = importer.Set(record.Alias, record.Ciphertext, ttl)
+			}
+			// Set never overwrites, an alias that is still there is replaced
+			if errors.Is(err, storage.ErrConflict) {
+				_, err = importer.Update(record.Alias, record.Ciphertext, ttl, -1)
+			}
+			if status, msg, ok := resp.FromStorageError(err); ok {
+				log.Error("Failed to import secret", slog.Int("imported", imported), slog.Any("error", err))
+				resp.RenderError(w, r, status, msg)
+				return
+			}
+			if err != nil {
+				log.Error("Failed to import secret", slog.Int("imported", imported), slog.Any("error", err))
+				resp.RenderError(w, r, http.StatusInternalServerError, "Failed to import secrets")
+				return
+			}
+			imported++
+		}
+
+		log.Warn("Secrets imported", slog.Int("imported", imported), slog.Int("skipped", skipped))
+
+		render.JSON(w, r, ImportResponse{
+			Response: resp.OK(),
+			Imported: imported,
+			Skipped:  skipped,
+		})
+	}
+}
+
+// decode reads the NDJSON records of body. On failure it returns the number
+// of the offending line.
+func decode(body io.Reader) ([]storage.Record, int, error) {
+	var records []storage.Record
+
+	scanner := bufio.NewScanner(body)
+	// Lines are as long as their ciphertext, the body limit bounds them
+	scanner.Buffer(nil, 64<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var record storage.Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, line, err
+		}
+		if record.Alias == "" || len(record.Ciphertext) == 0 {
+			return nil, line, errors.New("alias and ciphertext are required")
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, line + 1, err
+	}
+
+	return records, 0, nil
+}
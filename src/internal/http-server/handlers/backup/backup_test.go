@@ -0,0 +1,113 @@
+package backup
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/storage"
+	"yoopass-api/internal/storage/storagemock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportHandler(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "backup"))
+
+	record := func(alias string, expiresAt time.Time, md *storage.Metadata) string {
+		line, err := json.Marshal(storage.Record{Alias: alias, Ciphertext: []byte("blob"), ExpiresAt: expiresAt, Metadata: md})
+		require.NoError(t, err)
+		return string(line)
+	}
+
+	testCases := []struct {
+		name           string
+		body           string
+		setupMock      func(m *storagemock.Storage)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "Success",
+			body: record("a", time.Now().Add(time.Hour), &storage.Metadata{AbuseTag: "phishing"}) + "\n\n" + record("b", time.Time{}, nil) + "\n",
+			setupMock: func(m *storagemock.Storage) {
+				m.On("SetMetadata", "a", storage.Metadata{AbuseTag: "phishing"}, mock.Anything).Return(nil).Once()
+				m.On("Set", "a", []byte("blob"), mock.MatchedBy(func(ttl time.Duration) bool {
+					return ttl > 59*time.Minute && ttl <= time.Hour
+				})).Return(nil).Once()
+				m.On("Set", "b", []byte("blob"), time.Duration(0)).Return(nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"OK","imported":2,"skipped":0}`,
+		},
+		{
+			name:           "Expired Record Skipped",
+			body:           record("a", time.Now().Add(-time.Minute), nil),
+			setupMock:      func(m *storagemock.Storage) {},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"OK","imported":0,"skipped":1}`,
+		},
+		{
+			name:           "Invalid Line Writes Nothing",
+			body:           record("a", time.Time{}, nil) + "\n{not json}\n",
+			setupMock:      func(m *storagemock.Storage) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   mustJSON(t, resp.Error("Invalid backup record on line 2")),
+		},
+		{
+			name:           "Missing Ciphertext",
+			body:           `{"alias":"a"}`,
+			setupMock:      func(m *storagemock.Storage) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   mustJSON(t, resp.Error("Invalid backup record on line 1")),
+		},
+		{
+			name: "Existing Alias Overwritten",
+			body: record("a", time.Time{}, nil),
+			setupMock: func(m *storagemock.Storage) {
+				m.On("Set", "a", []byte("blob"), time.Duration(0)).Return(storage.ErrConflict).Once()
+				m.On("Update", "a", []byte("blob"), time.Duration(0), int64(-1)).Return(int64(1), nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"OK","imported":1,"skipped":0}`,
+		},
+		{
+			name: "Storage Unavailable",
+			body: record("a", time.Time{}, nil),
+			setupMock: func(m *storagemock.Storage) {
+				m.On("Set", "a", []byte("blob"), time.Duration(0)).Return(storage.ErrUnavailable).Once()
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStorage := new(storagemock.Storage)
+			tc.setupMock(mockStorage)
+
+			rr := httptest.NewRecorder()
+			NewImport(log, mockStorage).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/import", strings.NewReader(tc.body)))
+
+			assert.Equal(t, tc.expectedStatus, rr.Code, rr.Body.String())
+			if tc.expectedBody != "" {
+				assert.JSONEq(t, tc.expectedBody, rr.Body.String())
+			}
+			mockStorage.AssertExpectations(t)
+		})
+	}
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	return string(b)
+}
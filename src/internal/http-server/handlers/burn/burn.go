@@ -0,0 +1,67 @@
+package burn
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/storage"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+)
+
+type SecretBurner interface {
+	// this matches call in storage
+	Fetch(key string) ([]byte, error)
+	Delete(key string) error
+}
+
+// New serves DELETE /{alias}, which lets whoever holds the link burn the
+// secret before it is read. No key is needed, only the stored blob is
+// removed. It answers 204, or 404 when there is nothing left to burn.
+func New(log *slog.Logger, secretBurner SecretBurner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.burn.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.Info("Alias parameter is missing")
+			resp.RenderError(w, r, http.StatusBadRequest, "Alias parameter is missing")
+			return
+		}
+
+		// Delete succeeds on a missing key, so existence is checked first
+		cipherObject, err := secretBurner.Fetch(alias)
+		if err == nil && cipherObject == nil {
+			err = storage.ErrNotFound
+		}
+		if err == nil {
+			err = secretBurner.Delete(alias)
+		}
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Info("Secret not found", slog.String("alias", alias))
+			resp.RenderError(w, r, http.StatusNotFound, "Secret not found")
+			return
+		}
+		if status, msg, ok := resp.FromStorageError(err); ok {
+			log.Error("Failed to burn secret", slog.String("alias", alias), slog.Any("error", err))
+			resp.RenderError(w, r, status, msg)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to burn secret", slog.String("alias", alias), slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to delete secret")
+			return
+		}
+
+		log.Info("Secret burned", slog.String("alias", alias))
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,99 @@
+package burn
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/storage"
+	"yoopass-api/internal/storage/storagemock"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBurnHandler(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "burn"))
+
+	const alias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+
+	testCases := []struct {
+		name           string
+		setupMock      func(m *storagemock.Storage)
+		expectedStatus int
+		expectedBody   interface{}
+	}{
+		{
+			name: "Success",
+			setupMock: func(m *storagemock.Storage) {
+				m.On("Fetch", alias).Return([]byte("sealed"), nil).Once()
+				m.On("Delete", alias).Return(nil).Once()
+			},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name: "Error Not Found",
+			setupMock: func(m *storagemock.Storage) {
+				m.On("Fetch", alias).Return(nil, storage.ErrNotFound).Once()
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   resp.Error("Secret not found"),
+		},
+		{
+			name: "Error Nil Blob",
+			setupMock: func(m *storagemock.Storage) {
+				m.On("Fetch", alias).Return(nil, nil).Once()
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   resp.Error("Secret not found"),
+		},
+		{
+			name: "Error Delete Fails",
+			setupMock: func(m *storagemock.Storage) {
+				m.On("Fetch", alias).Return([]byte("sealed"), nil).Once()
+				m.On("Delete", alias).Return(errors.New("redis connection error")).Once()
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   resp.Error("Failed to delete secret"),
+		},
+		{
+			name: "Error Storage Unavailable",
+			setupMock: func(m *storagemock.Storage) {
+				m.On("Fetch", alias).Return(nil, storage.ErrUnavailable).Once()
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   resp.Error("Storage is unavailable"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStorage := new(storagemock.Storage)
+			tc.setupMock(mockStorage)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("alias", alias)
+			req := httptest.NewRequest(http.MethodDelete, "/"+alias, nil)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			rr := httptest.NewRecorder()
+
+			New(log, mockStorage).ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			if tc.expectedBody == nil {
+				assert.Empty(t, rr.Body.String())
+			} else {
+				expectedJson, err := json.Marshal(tc.expectedBody)
+				require.NoError(t, err)
+				assert.JSONEq(t, string(expectedJson), rr.Body.String())
+			}
+			mockStorage.AssertExpectations(t)
+		})
+	}
+}
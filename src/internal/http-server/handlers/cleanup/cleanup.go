@@ -0,0 +1,55 @@
+package cleanup
+
+import (
+	"log/slog"
+	"net/http"
+	"yoopass-api/internal/http-server/handlers/response"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/storage"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+)
+
+type Response struct {
+	response.Response
+	Removed storage.PurgeStats `json:"removed"`
+}
+
+type Purger interface {
+	// this matches call in storage
+	PurgeOrphans() (storage.PurgeStats, error)
+}
+
+// New serves POST /admin/cleanup, which removes whatever expired or deleted
+// secrets left behind right away instead of leaving it to their TTLs. The
+// route must sit behind authentication.
+func New(log *slog.Logger, purger Purger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.cleanup.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		stats, err := purger.PurgeOrphans()
+		if status, msg, ok := resp.FromStorageError(err); ok {
+			log.Error("Failed to clean up storage", slog.Any("error", err), slog.Any("removed", stats))
+			resp.RenderError(w, r, status, msg)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to clean up storage", slog.Any("error", err), slog.Any("removed", stats))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to clean up storage")
+			return
+		}
+
+		log.Warn("Storage cleaned up", slog.Any("removed", stats))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Removed:  stats,
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package cleanup
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/storage"
+	"yoopass-api/internal/storage/storagemock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanupHandler(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "cleanup"))
+
+	stats := storage.PurgeStats{AuxKeys: 3, IndexEntries: 2, GroupMembers: 1, Groups: 1}
+
+	testCases := []struct {
+		name           string
+		setupMock      func(m *storagemock.Storage)
+		expectedStatus int
+		expectedBody   interface{}
+	}{
+		{
+			name: "Success Reports Counts",
+			setupMock: func(m *storagemock.Storage) {
+				m.On("PurgeOrphans").Return(stats, nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   Response{Response: resp.OK(), Removed: stats},
+		},
+		{
+			name: "Error Storage Unavailable",
+			setupMock: func(m *storagemock.Storage) {
+				m.On("PurgeOrphans").Return(storage.PurgeStats{}, storage.ErrUnavailable).Once()
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   resp.Error("Storage is unavailable"),
+		},
+		{
+			name: "Error Purge Fails",
+			setupMock: func(m *storagemock.Storage) {
+				m.On("PurgeOrphans").Return(storage.PurgeStats{AuxKeys: 1}, errors.New("boom")).Once()
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   resp.Error("Failed to clean up storage"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStorage := new(storagemock.Storage)
+			tc.setupMock(mockStorage)
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/cleanup", nil)
+			rr := httptest.NewRecorder()
+
+			New(log, mockStorage).ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			expectedJson, err := json.Marshal(tc.expectedBody)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(expectedJson), rr.Body.String())
+			mockStorage.AssertExpectations(t)
+		})
+	}
+}
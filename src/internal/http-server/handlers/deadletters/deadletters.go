@@ -0,0 +1,53 @@
+package deadletters
+
+import (
+	"log/slog"
+	"net/http"
+	"yoopass-api/internal/http-server/handlers/response"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/storage"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+)
+
+type Response struct {
+	response.Response
+	DeadLetters []storage.DeadLetter `json:"dead_letters"`
+}
+
+type DeadLetterLister interface {
+	// this matches call in storage
+	DeadLetters() ([]storage.DeadLetter, error)
+}
+
+// New serves GET /admin/webhooks/dead-letters, the webhook events that
+// failed to deliver, newest first. The route must sit behind
+// authentication.
+func New(log *slog.Logger, deadLetterLister DeadLetterLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.deadletters.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		letters, err := deadLetterLister.DeadLetters()
+		if status, msg, ok := resp.FromStorageError(err); ok {
+			log.Error("Failed to list dead letters", slog.Any("error", err))
+			resp.RenderError(w, r, status, msg)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to list dead letters", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to list dead letters")
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Response:    resp.OK(),
+			DeadLetters: letters,
+		})
+	}
+}
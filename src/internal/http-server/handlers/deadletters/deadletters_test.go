@@ -0,0 +1,56 @@
+package deadletters
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/storage"
+	"yoopass-api/internal/storage/storagemock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeadLettersHandler(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "deadletters"))
+
+	failedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	letters := []storage.DeadLetter{{Type: "secret.read", Alias: "alias", OccurredAt: failedAt, FailedAt: failedAt, Error: "unexpected status 502"}}
+
+	testCases := []struct {
+		name           string
+		letters        []storage.DeadLetter
+		err            error
+		expectedStatus int
+		expectedError  string
+	}{
+		{name: "Success", letters: letters, expectedStatus: http.StatusOK},
+		{name: "Empty", letters: []storage.DeadLetter{}, expectedStatus: http.StatusOK},
+		{name: "Unexpected Error", err: errors.New("boom"), expectedStatus: http.StatusInternalServerError, expectedError: "Failed to list dead letters"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStorage := new(storagemock.Storage)
+			mockStorage.On("DeadLetters").Return(tc.letters, tc.err).Once()
+
+			rr := httptest.NewRecorder()
+			New(log, mockStorage).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/webhooks/dead-letters", nil))
+
+			require.Equal(t, tc.expectedStatus, rr.Code)
+			var expected any = Response{Response: resp.OK(), DeadLetters: tc.letters}
+			if tc.expectedError != "" {
+				expected = resp.Error(tc.expectedError)
+			}
+			expectedJson, err := json.Marshal(expected)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(expectedJson), rr.Body.String())
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package diagnose
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+	"yoopass-api/internal/dto"
+	"yoopass-api/internal/http-server/handlers/response"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/storage"
+	cipher "yoopass-api/internal/tools/cipher"
+	"yoopass-api/internal/tools/shortcode"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+)
+
+type Request struct {
+	Alias string `json:"alias"`
+	// ShortCode locates the secret instead of Alias, see WithShortCodes
+	ShortCode string `json:"short_code,omitempty"`
+	Key       string `json:"key"`
+}
+
+type Response struct {
+	response.Response
+	Alias string `json:"alias"`
+	// KeyValid reports whether the key decrypts the secret
+	KeyValid bool `json:"key_valid"`
+	// ClientEncrypted secrets can't be checked, the server never had a key
+	ClientEncrypted bool `json:"client_encrypted,omitempty"`
+	// OneTime is only known once the key decrypted the secret
+	OneTime bool `json:"one_time,omitempty"`
+	// Views counts the recorded reads, it stays zero without read history
+	Views     int   `json:"views"`
+	Expires   bool  `json:"expires"`
+	ExpiresIn int64 `json:"expires_in,omitempty"`
+}
+
+// Option configures optional behaviour of the diagnose handler.
+type Option func(*options)
+
+type options struct {
+	keyEncoding cipher.KeyEncoding
+	shortCoder  ShortCoder
+}
+
+// WithKeyEncoding sets which key encodings are accepted, see
+// fetch.WithKeyEncoding.
+func WithKeyEncoding(encoding cipher.KeyEncoding) Option {
+	return func(o *options) {
+		o.keyEncoding = encoding
+	}
+}
+
+// ShortCoder derives the support reference code of an alias, see shortcode.
+type ShortCoder interface {
+	Code(alias string) string
+}
+
+// WithShortCodes accepts the short code handed out on save in place of the
+// alias. Codes are never stored, so finding one walks every secret: fine
+// for support staff, but not for a hot path.
+func WithShortCodes(coder ShortCoder) Option {
+	return func(o *options) {
+		o.shortCoder = coder
+	}
+}
+
+type SecretInspector interface {
+	// this matches call in storage
+	Metadata(key string) (storage.Metadata, error)
+	Fetch(key string) ([]byte, error)
+	TTL(key string) (time.Duration, error)
+	Reads(key string) ([]storage.Read, error)
+	Export(fn func(storage.Record) error) error
+}
+
+// New serves POST /admin/diagnose, which tells support staff whether a
+// user's key opens a secret without showing them the secret. The message is
+// never returned or logged and one-time secrets are not burned. The route
+// must sit behind authentication.
+func New(log *slog.Logger, secretInspector SecretInspector, opts ...Option) http.HandlerFunc {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.diagnose.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if err != nil {
+			log.Info("Failed to decode request", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusBadRequest, "Failed to read or decode request body.")
+			return
+		}
+
+		alias := req.Alias
+		if alias == "" && req.ShortCode != "" && o.shortCoder != nil {
+			alias, err = resolve(secretInspector, o.shortCoder, shortcode.Normalize(req.ShortCode))
+			if errors.Is(err, errAmbiguousCode) {
+				log.Info("Short code matches several secrets", slog.String("short_code", req.ShortCode))
+				resp.RenderError(w, r, http.StatusConflict, "Short code matches several secrets, use the alias")
+				return
+			}
+			if status, msg, ok := resp.FromStorageError(err); ok {
+				log.Info("Failed to resolve short code", slog.String("short_code", req.ShortCode), slog.Any("error", err))
+				resp.RenderError(w, r, status, msg)
+				return
+			}
+			if err != nil {
+				log.Error("Failed to resolve short code", slog.Any("error", err))
+				resp.RenderError(w, r, http.StatusInternalServerError, "Failed to inspect secret")
+				return
+			}
+		}
+
+		if alias == "" {
+			log.Info("Alias parameter is missing")
+			resp.RenderError(w, r, http.StatusBadRequest, "Alias parameter is missing")
+			return
+		}
+
+		keyBytes, err := cipher.DecodeKey(req.Key, o.keyEncoding)
+		if err != nil {
+			log.Info("Invalid key format", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusBadRequest, "Invalid key format")
+			return
+		}
+
+		md, err := secretInspector.Metadata(alias)
+		// Only some secrets carry metadata, TTL tells whether the secret exists
+		if errors.Is(err, storage.ErrNotFound) {
+			md, err = storage.Metadata{}, nil
+		}
+		var ttl time.Duration
+		if err == nil {
+			ttl, err = secretInspector.TTL(alias)
+		}
+		var reads []storage.Read
+		if err == nil {
+			reads, err = secretInspector.Reads(alias)
+		}
+		if status, msg, ok := resp.FromStorageError(err); ok {
+			log.Info("Failed to inspect secret", slog.String("alias", alias), slog.Any("error", err))
+			resp.RenderError(w, r, status, msg)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to inspect secret", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to inspect secret")
+			return
+		}
+
+		response := Response{
+			Response:        resp.OK(),
+			Alias:           alias,
+			ClientEncrypted: md.ClientEncrypted,
+			Views:           len(reads),
+			Expires:         ttl > 0,
+			ExpiresIn:       int64(ttl / time.Second),
+		}
+		if md.ClientEncrypted {
+			log.Info("Client encrypted secret can't be diagnosed", slog.String("alias", alias))
+			render.JSON(w, r, response)
+			return
+		}
+
+		// Fetch, never Consume: checking a key must not burn the secret
+		cipherObject, err := secretInspector.Fetch(alias)
+		if status, msg, ok := resp.FromStorageError(err); ok {
+			log.Info("Failed to fetch secret", slog.String("alias", alias), slog.Any("error", err))
+			resp.RenderError(w, r, status, msg)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to fetch secret", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to fetch secret")
+			return
+		}
+
+		if err := cipher.Spend(r.Context()); err != nil {
+			log.Warn("Decode budget exhausted", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusBadRequest, "Too many decode attempts")
+			return
+		}
+
+		object, err := cipher.DecodeWithKey(cipherObject, keyBytes)
+		if err == nil {
+			var secret dto.Secret
+			if err := json.Unmarshal(object, &secret); err != nil {
+				log.Warn("Secret unmarshalling failed", slog.Any("error", err))
+				resp.RenderError(w, r, http.StatusUnprocessableEntity, "Secret unmarshalling failed")
+				return
+			}
+			response.KeyValid = true
+			response.OneTime = secret.OneTime
+		} else {
+			// Any failure to open the secret means the key doesn't fit it
+			log.Info("Key doesn't decrypt secret", slog.Any("error", err))
+		}
+
+		log.Warn("Secret key diagnosed", slog.String("alias", alias), slog.Bool("key_valid", response.KeyValid))
+
+		render.JSON(w, r, response)
+	}
+}
+
+// errAmbiguousCode is returned by resolve for a code shared by several
+// secrets.
+var errAmbiguousCode = errors.New("short code matches several secrets")
+
+// resolve returns the alias of the only stored secret whose short code is
+// code, or storage.ErrNotFound when there is none.
+func resolve(secretInspector SecretInspector, coder ShortCoder, code string) (string, error) {
+	var matches []string
+	err := secretInspector.Export(func(record storage.Record) error {
+		if coder.Code(record.Alias) == code {
+			matches = append(matches, record.Alias)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", storage.ErrNotFound
+	case 1:
+		return matches[0], nil
+	default:
+		return "", errAmbiguousCode
+	}
+}
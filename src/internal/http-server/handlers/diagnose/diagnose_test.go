@@ -0,0 +1,208 @@
+package diagnose
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"yoopass-api/internal/dto"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/storage"
+	"yoopass-api/internal/storage/storagemock"
+	"yoopass-api/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testAlias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+	testKey   = "46da5d3577209271242b42882a034c3d"
+	wrongKey  = "00da5d3577209271242b42882a034c3d"
+)
+
+func TestDiagnoseHandler(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "diagnose"))
+
+	const message = "do not show me"
+
+	testCases := []struct {
+		name             string
+		key              string
+		setupMock        func(m *storagemock.Storage)
+		expectedStatus   int
+		expectedResponse *Response
+		expectedError    string
+	}{
+		{
+			name: "Correct Key",
+			key:  testKey,
+			setupMock: func(m *storagemock.Storage) {
+				m.On("Metadata", testAlias).Return(storage.Metadata{}, nil).Once()
+				m.On("TTL", testAlias).Return(2*time.Hour, nil).Once()
+				m.On("Reads", testAlias).Return([]storage.Read{{}, {}}, nil).Once()
+				m.On("Fetch", testAlias).Return(testutil.BuildCiphertext(t, dto.Secret{Message: message, OneTime: true}, testKey), nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedResponse: &Response{
+				Response:  resp.OK(),
+				Alias:     testAlias,
+				KeyValid:  true,
+				OneTime:   true,
+				Views:     2,
+				Expires:   true,
+				ExpiresIn: 7200,
+			},
+		},
+		{
+			name: "Wrong Key",
+			key:  wrongKey,
+			setupMock: func(m *storagemock.Storage) {
+				m.On("Metadata", testAlias).Return(storage.Metadata{}, nil).Once()
+				m.On("TTL", testAlias).Return(time.Duration(0), nil).Once()
+				m.On("Reads", testAlias).Return([]storage.Read(nil), nil).Once()
+				m.On("Fetch", testAlias).Return(testutil.BuildCiphertext(t, dto.Secret{Message: message, OneTime: true}, testKey), nil).Once()
+			},
+			expectedStatus:   http.StatusOK,
+			expectedResponse: &Response{Response: resp.OK(), Alias: testAlias},
+		},
+		{
+			name: "Client Encrypted",
+			key:  testKey,
+			setupMock: func(m *storagemock.Storage) {
+				m.On("Metadata", testAlias).Return(storage.Metadata{ClientEncrypted: true}, nil).Once()
+				m.On("TTL", testAlias).Return(time.Duration(0), nil).Once()
+				m.On("Reads", testAlias).Return([]storage.Read(nil), nil).Once()
+			},
+			expectedStatus:   http.StatusOK,
+			expectedResponse: &Response{Response: resp.OK(), Alias: testAlias, ClientEncrypted: true},
+		},
+		{
+			name: "Without Metadata",
+			key:  testKey,
+			setupMock: func(m *storagemock.Storage) {
+				m.On("Metadata", testAlias).Return(storage.Metadata{}, storage.ErrNotFound).Once()
+				m.On("TTL", testAlias).Return(time.Duration(0), nil).Once()
+				m.On("Reads", testAlias).Return([]storage.Read(nil), nil).Once()
+				m.On("Fetch", testAlias).Return(testutil.BuildCiphertext(t, dto.Secret{Message: message}, testKey), nil).Once()
+			},
+			expectedStatus:   http.StatusOK,
+			expectedResponse: &Response{Response: resp.OK(), Alias: testAlias, KeyValid: true},
+		},
+		{
+			name: "Secret Not Found",
+			key:  testKey,
+			setupMock: func(m *storagemock.Storage) {
+				m.On("Metadata", testAlias).Return(storage.Metadata{}, nil).Once()
+				m.On("TTL", testAlias).Return(time.Duration(0), storage.ErrNotFound).Once()
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedError:  "Secret not found",
+		},
+		{
+			name:           "Invalid Key Format",
+			key:            "zz",
+			setupMock:      func(m *storagemock.Storage) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Invalid key format",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStorage := new(storagemock.Storage)
+			tc.setupMock(mockStorage)
+
+			body, err := json.Marshal(Request{Alias: testAlias, Key: tc.key})
+			require.NoError(t, err)
+			req := httptest.NewRequest(http.MethodPost, "/admin/diagnose", bytes.NewReader(body))
+			rr := httptest.NewRecorder()
+
+			New(log, mockStorage).ServeHTTP(rr, req)
+
+			require.Equal(t, tc.expectedStatus, rr.Code, rr.Body.String())
+			assert.NotContains(t, rr.Body.String(), message)
+			if tc.expectedResponse != nil {
+				expectedJson, err := json.Marshal(tc.expectedResponse)
+				require.NoError(t, err)
+				assert.JSONEq(t, string(expectedJson), rr.Body.String())
+			}
+			if tc.expectedError != "" {
+				expectedJson, err := json.Marshal(resp.Error(tc.expectedError))
+				require.NoError(t, err)
+				assert.JSONEq(t, string(expectedJson), rr.Body.String())
+			}
+			// A one-time secret must survive the check
+			mockStorage.AssertNotCalled(t, "Consume", testAlias)
+			mockStorage.AssertExpectations(t)
+		})
+	}
+}
+
+// aliasCoder codes an alias by its first six characters, so tests can pick
+// colliding aliases.
+type aliasCoder struct{}
+
+func (aliasCoder) Code(alias string) string { return strings.ToUpper(alias[:6]) }
+
+func TestDiagnoseHandlerShortCode(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "diagnose"))
+
+	const otherAlias = "00ab603e-fbae-4182-8379-8763d9327d51"
+
+	testCases := []struct {
+		name           string
+		shortCode      string
+		stored         []string
+		expectedStatus int
+		expectedError  string
+	}{
+		{name: "Code Located", shortCode: "f7ab60", stored: []string{otherAlias, testAlias}, expectedStatus: http.StatusOK},
+		{name: "Unknown Code", shortCode: "ZZZZZZ", stored: []string{otherAlias, testAlias}, expectedStatus: http.StatusNotFound, expectedError: "Secret not found"},
+		{name: "Ambiguous Code", shortCode: "F7AB60", stored: []string{testAlias, "f7ab60ff-fbae-4182-8379-8763d9327d51"}, expectedStatus: http.StatusConflict, expectedError: "Short code matches several secrets, use the alias"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStorage := new(storagemock.Storage)
+			mockStorage.On("Export", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+				fn := args.Get(0).(func(storage.Record) error)
+				for _, alias := range tc.stored {
+					require.NoError(t, fn(storage.Record{Alias: alias}))
+				}
+			}).Once()
+			if tc.expectedStatus == http.StatusOK {
+				mockStorage.On("Metadata", testAlias).Return(storage.Metadata{}, nil).Once()
+				mockStorage.On("TTL", testAlias).Return(time.Duration(0), nil).Once()
+				mockStorage.On("Reads", testAlias).Return([]storage.Read(nil), nil).Once()
+				mockStorage.On("Fetch", testAlias).Return(testutil.BuildCiphertext(t, dto.Secret{Message: "m"}, testKey), nil).Once()
+			}
+
+			body, err := json.Marshal(Request{ShortCode: tc.shortCode, Key: testKey})
+			require.NoError(t, err)
+			req := httptest.NewRequest(http.MethodPost, "/admin/diagnose", bytes.NewReader(body))
+			rr := httptest.NewRecorder()
+
+			New(log, mockStorage, WithShortCodes(aliasCoder{})).ServeHTTP(rr, req)
+
+			require.Equal(t, tc.expectedStatus, rr.Code, rr.Body.String())
+			if tc.expectedStatus == http.StatusOK {
+				expectedJson, err := json.Marshal(Response{Response: resp.OK(), Alias: testAlias, KeyValid: true})
+				require.NoError(t, err)
+				assert.JSONEq(t, string(expectedJson), rr.Body.String())
+			}
+			if tc.expectedError != "" {
+				expectedJson, err := json.Marshal(resp.Error(tc.expectedError))
+				require.NoError(t, err)
+				assert.JSONEq(t, string(expectedJson), rr.Body.String())
+			}
+			mockStorage.AssertExpectations(t)
+		})
+	}
+}
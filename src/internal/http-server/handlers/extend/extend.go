@@ -0,0 +1,269 @@
+package extend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"yoopass-api/internal/dto"
+	"yoopass-api/internal/http-server/handlers/response"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/i18n"
+	"yoopass-api/internal/storage"
+	cipher "yoopass-api/internal/tools/cipher"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+)
+
+// Request sets a new expiration, counted in hours from now.
+type Request struct {
+	Expiration int `json:"expiration"`
+}
+
+type Response struct {
+	response.Response
+	// Version is the secret's version after the update, it is also sent as
+	// a weak ETag for use in If-Match
+	Version   int64     `json:"version"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+// Option configures optional behaviour of the extend handler.
+type Option func(*options)
+
+type options struct {
+	keyEncoding        cipher.KeyEncoding
+	maxExpirationHours int
+	requireIfMatch     bool
+	maxLifetime        time.Duration
+}
+
+// WithKeyEncoding sets which key encodings are accepted, see
+// fetch.WithKeyEncoding.
+func WithKeyEncoding(encoding cipher.KeyEncoding) Option {
+	return func(o *options) {
+		o.keyEncoding = encoding
+	}
+}
+
+// WithMaxExpiration caps the new expiration. A zero value means no cap.
+func WithMaxExpiration(hours int) Option {
+	return func(o *options) {
+		o.maxExpirationHours = hours
+	}
+}
+
+// WithMaxLifetime rejects extensions that would keep a secret past hours
+// after its creation, however often it is extended. Secrets saved without a
+// creation time are not capped. A zero value means no cap.
+func WithMaxLifetime(hours int) Option {
+	return func(o *options) {
+		o.maxLifetime = time.Duration(hours) * time.Hour
+	}
+}
+
+// WithRequireIfMatch rejects updates without an If-Match header with 428, so
+// no client can overwrite a change it hasn't seen.
+func WithRequireIfMatch() Option {
+	return func(o *options) {
+		o.requireIfMatch = true
+	}
+}
+
+type SecretUpdater interface {
+	// this matches call in storage
+	Fetch(key string) ([]byte, error)
+	Update(key string, value []byte, ttl time.Duration, version int64) (int64, error)
+}
+
+// New serves POST /{alias}/{key}/extend. The key is needed because the
+// logical expiry lives inside the encrypted payload. Concurrent updates are
+// settled optimistically: a client sending If-Match with a version that is
+// no longer current gets 409 with the current version as ETag. Every secret
+// starts at version 0.
+func New(log *slog.Logger, secretUpdater SecretUpdater, opts ...Option) http.HandlerFunc {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.extend.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.Info("Alias parameter is missing")
+			resp.RenderError(w, r, http.StatusBadRequest, "Alias parameter is missing")
+			return
+		}
+
+		keyBytes, err := cipher.DecodeKey(chi.URLParam(r, "key"), o.keyEncoding)
+		if err != nil {
+			log.Info("Invalid key format", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusBadRequest, "Invalid key format")
+			return
+		}
+
+		version, err := parseIfMatch(r.Header.Get("If-Match"))
+		if err != nil {
+			log.Info("Invalid If-Match header", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusBadRequest, "Invalid If-Match header")
+			return
+		}
+		if version < 0 && o.requireIfMatch {
+			log.Info("If-Match header is missing")
+			resp.RenderError(w, r, http.StatusPreconditionRequired, "If-Match header is required")
+			return
+		}
+
+		var req Request
+
+		err = render.DecodeJSON(r.Body, &req)
+		if err != nil {
+			log.Info("Failed to decode request", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusBadRequest, "Failed to read or decode request body.")
+			return
+		}
+
+		if msg := checkExpiration(i18n.FromRequest(r), req.Expiration, o.maxExpirationHours); msg != "" {
+			log.Info("Invalid expiration", slog.Int("expiration", req.Expiration))
+			resp.RenderValidationError(w, r, []resp.ValidationError{{Field: "expiration", Error: msg}})
+			return
+		}
+
+		cipherObject, err := secretUpdater.Fetch(alias)
+		if status, msg, ok := resp.FromStorageError(err); ok {
+			log.Info("Failed to fetch secret", slog.String("alias", alias), slog.Any("error", err))
+			resp.RenderError(w, r, status, msg)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to fetch secret", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to fetch secret")
+			return
+		}
+
+		if err := cipher.Spend(r.Context()); err != nil {
+			log.Warn("Decode budget exhausted", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusBadRequest, "Too many decode attempts")
+			return
+		}
+
+		object, err := cipher.DecodeWithKey(cipherObject, keyBytes)
+		if err != nil {
+			log.Error("Failed to decode secret", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to decode secret")
+			return
+		}
+
+		var secret dto.Secret
+
+		err = json.Unmarshal(object, &secret)
+		if err != nil {
+			log.Warn("Secret unmarshalling failed", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusUnprocessableEntity, "Secret unmarshalling failed")
+			return
+		}
+
+		if !secret.ExpiresAt.IsZero() && !time.Now().Before(secret.ExpiresAt) {
+			log.Info("Secret has expired", slog.String("alias", alias))
+			resp.RenderError(w, r, http.StatusGone, "Secret has expired")
+			return
+		}
+
+		ttl := time.Duration(req.Expiration) * time.Hour
+		if o.maxLifetime > 0 && !secret.CreatedAt.IsZero() && time.Now().Add(ttl).After(secret.CreatedAt.Add(o.maxLifetime)) {
+			log.Info("Expiration exceeds maximum lifetime", slog.Int("expiration", req.Expiration), slog.Time("created_at", secret.CreatedAt))
+			resp.RenderValidationError(w, r, []resp.ValidationError{{
+				Field: "expiration",
+				Error: i18n.Translate(i18n.FromRequest(r), "Exceeds the maximum lifetime of the secret"),
+			}})
+			return
+		}
+		secret.ExpiresAt = time.Now().Add(ttl).UTC()
+
+		object, err = json.Marshal(secret)
+		if err != nil {
+			log.Error("Failed to marshal secret", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to marshal secret")
+			return
+		}
+
+		cipherObject, err = cipher.EncodeWithKey(object, keyBytes)
+		if err != nil {
+			log.Error("Failed to encode secret", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to encode secret")
+			return
+		}
+
+		version, err = secretUpdater.Update(alias, cipherObject, ttl, version)
+		if errors.Is(err, storage.ErrStale) {
+			// The current version lets the client re-read and retry
+			w.Header().Set("ETag", etag(version))
+		}
+		if status, msg, ok := resp.FromStorageError(err); ok {
+			log.Info("Failed to update secret", slog.String("alias", alias), slog.Any("error", err))
+			resp.RenderError(w, r, status, msg)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to update secret", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to update secret")
+			return
+		}
+
+		log.Info("Secret extended", slog.String("alias", alias), slog.Int64("version", version))
+
+		w.Header().Set("ETag", etag(version))
+		render.JSON(w, r, Response{
+			Response:  resp.OK(),
+			Version:   version,
+			ExpiresAt: secret.ExpiresAt,
+		})
+	}
+}
+
+// etag formats version as a weak ETag. Equal versions may differ in their
+// ciphertext, since every write uses a fresh nonce.
+func etag(version int64) string {
+	return `W/"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// parseIfMatch returns the version in an If-Match header, or -1 when the
+// header is missing or "*".
+func parseIfMatch(header string) (int64, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return -1, nil
+	}
+
+	value := strings.Trim(strings.TrimPrefix(header, "W/"), `"`)
+	version, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("unexpected If-Match %q", header)
+	}
+	return version, nil
+}
+
+// checkExpiration returns a translated validation message for hours, or an
+// empty string when it is acceptable.
+func checkExpiration(lang string, hours, maxHours int) string {
+	if hours < 1 {
+		return fmt.Sprintf(i18n.Translate(lang, "Value must be greater than or equal to %s"), "1")
+	}
+	if maxHours > 0 && hours > maxHours {
+		return fmt.Sprintf(i18n.Translate(lang, "Value must be less than or equal to %s"), strconv.Itoa(maxHours))
+	}
+	return ""
+}
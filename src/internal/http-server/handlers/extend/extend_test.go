@@ -0,0 +1,174 @@
+package extend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+	"yoopass-api/internal/dto"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/storage"
+	"yoopass-api/internal/storage/storagemock"
+	"yoopass-api/internal/testutil"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testAlias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+	testKey   = "46da5d3577209271242b42882a034c3d"
+)
+
+func TestExtendHandler(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "extend"))
+
+	testCases := []struct {
+		name           string
+		ifMatch        string
+		body           string
+		opts           []Option
+		setupMock      func(m *storagemock.Storage)
+		expectedStatus int
+		expectedETag   string
+		expectedError  string
+	}{
+		{
+			name:    "Success Versioned Update",
+			ifMatch: `W/"0"`,
+			body:    `{"expiration": 2}`,
+			setupMock: func(m *storagemock.Storage) {
+				m.On("Fetch", testAlias).Return(testutil.BuildCiphertext(t, dto.Secret{Message: "s"}, testKey), nil).Once()
+				m.On("Update", testAlias, mock.Anything, 2*time.Hour, int64(0)).Return(int64(1), nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedETag:   `W/"1"`,
+		},
+		{
+			name: "Success Unconditional Update",
+			body: `{"expiration": 2}`,
+			setupMock: func(m *storagemock.Storage) {
+				m.On("Fetch", testAlias).Return(testutil.BuildCiphertext(t, dto.Secret{Message: "s"}, testKey), nil).Once()
+				m.On("Update", testAlias, mock.Anything, 2*time.Hour, int64(-1)).Return(int64(4), nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedETag:   `W/"4"`,
+		},
+		{
+			name:    "Error Stale Version",
+			ifMatch: `W/"0"`,
+			body:    `{"expiration": 2}`,
+			setupMock: func(m *storagemock.Storage) {
+				m.On("Fetch", testAlias).Return(testutil.BuildCiphertext(t, dto.Secret{Message: "s"}, testKey), nil).Once()
+				m.On("Update", testAlias, mock.Anything, 2*time.Hour, int64(0)).Return(int64(1), storage.ErrStale).Once()
+			},
+			expectedStatus: http.StatusConflict,
+			expectedETag:   `W/"1"`,
+			expectedError:  "Secret was modified concurrently",
+		},
+		{
+			name:           "Error If-Match Required",
+			body:           `{"expiration": 2}`,
+			opts:           []Option{WithRequireIfMatch()},
+			setupMock:      func(m *storagemock.Storage) {},
+			expectedStatus: http.StatusPreconditionRequired,
+			expectedError:  "If-Match header is required",
+		},
+		{
+			name:           "Error Invalid If-Match",
+			ifMatch:        `"abc"`,
+			body:           `{"expiration": 2}`,
+			setupMock:      func(m *storagemock.Storage) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Invalid If-Match header",
+		},
+		{
+			name:    "Error Secret Not Found",
+			ifMatch: `W/"0"`,
+			body:    `{"expiration": 2}`,
+			setupMock: func(m *storagemock.Storage) {
+				m.On("Fetch", testAlias).Return(nil, storage.ErrNotFound).Once()
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedError:  "Secret not found",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStorage := new(storagemock.Storage)
+			tc.setupMock(mockStorage)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("alias", testAlias)
+			rctx.URLParams.Add("key", testKey)
+			req := httptest.NewRequest(http.MethodPost, "/"+testAlias+"/"+testKey+"/extend", bytes.NewBufferString(tc.body))
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+			rr := httptest.NewRecorder()
+
+			New(log, mockStorage, tc.opts...).ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			assert.Equal(t, tc.expectedETag, rr.Header().Get("ETag"))
+			if tc.expectedError != "" {
+				expectedJson, err := json.Marshal(resp.Error(tc.expectedError))
+				require.NoError(t, err)
+				assert.JSONEq(t, string(expectedJson), rr.Body.String())
+			}
+			mockStorage.AssertExpectations(t)
+		})
+	}
+}
+
+func TestExtendHandlerMaxLifetime(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "extend"))
+
+	testCases := []struct {
+		name           string
+		createdAt      time.Time
+		body           string
+		expectedStatus int
+	}{
+		{name: "Within Cap", createdAt: time.Now().Add(-time.Hour), body: `{"expiration": 2}`, expectedStatus: http.StatusOK},
+		{name: "Past Cap", createdAt: time.Now().Add(-3 * time.Hour), body: `{"expiration": 2}`, expectedStatus: http.StatusBadRequest},
+		{name: "Without Creation Time", body: `{"expiration": 6}`, expectedStatus: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStorage := new(storagemock.Storage)
+			mockStorage.On("Fetch", testAlias).Return(testutil.BuildCiphertext(t, dto.Secret{Message: "s", CreatedAt: tc.createdAt}, testKey), nil).Once()
+			if tc.expectedStatus == http.StatusOK {
+				mockStorage.On("Update", testAlias, mock.Anything, mock.Anything, int64(-1)).Return(int64(1), nil).Once()
+			}
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("alias", testAlias)
+			rctx.URLParams.Add("key", testKey)
+			req := httptest.NewRequest(http.MethodPost, "/"+testAlias+"/"+testKey+"/extend", bytes.NewBufferString(tc.body))
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			rr := httptest.NewRecorder()
+
+			New(log, mockStorage, WithMaxLifetime(4)).ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code, rr.Body.String())
+			if tc.expectedStatus == http.StatusBadRequest {
+				expected := resp.ValidationErrorResponse([]resp.ValidationError{{Field: "expiration", Error: "Exceeds the maximum lifetime of the secret"}})
+				expectedJson, err := json.Marshal(expected)
+				require.NoError(t, err)
+				assert.JSONEq(t, string(expectedJson), rr.Body.String())
+			}
+			mockStorage.AssertExpectations(t)
+		})
+	}
+}
@@ -1,31 +1,359 @@
 package fetch
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"io"
 	"log/slog"
+	"math"
+	"mime"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 	"yoopass-api/internal/dto"
 	"yoopass-api/internal/http-server/handlers/response"
 	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/http-server/middleware/identity"
+	"yoopass-api/internal/i18n"
+	"yoopass-api/internal/notify"
+	"yoopass-api/internal/storage"
 	cipher "yoopass-api/internal/tools/cipher"
+	"yoopass-api/internal/tools/netutil"
+	"yoopass-api/internal/tools/redact"
+	"yoopass-api/internal/tools/shamir"
+	"yoopass-api/internal/tools/tenant"
+	"yoopass-api/internal/webhook"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/render"
+	"github.com/gofrs/uuid"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
 )
 
+// Request is the body of POST /fetch. Sending the key in the body keeps it
+// out of URLs and therefore out of proxy and server access logs.
+type Request struct {
+	Alias string `json:"alias"`
+	Key   string `json:"key"`
+	// Shares open a secret saved with a split key instead of Key
+	Shares []string `json:"shares,omitempty"`
+	// Password opens a password-protected secret, GET routes take it in
+	// PasswordHeader
+	Password string `json:"password,omitempty"`
+}
+
+// PasswordHeader carries the password of a password-protected secret.
+const PasswordHeader = "X-Secret-Password"
+
+type passwordKey struct{}
+
+// password returns the password sent with r, from the body of POST /fetch or
+// from PasswordHeader.
+func password(r *http.Request) string {
+	if p, ok := r.Context().Value(passwordKey{}).(string); ok && p != "" {
+		return p
+	}
+	return r.Header.Get(PasswordHeader)
+}
+
 type Response struct {
 	response.Response
 	Message string `json:"message,omitempty"`
+	// Encoding is set when Message is not the raw secret, see encodingBase64
+	Encoding string `json:"encoding,omitempty"`
+	// Consumed is true when this read left nothing to read again
+	Consumed bool `json:"consumed"`
+}
+
+// encodingBase64 asks for the message as standard base64, so content with
+// control characters survives any JSON client untouched.
+const encodingBase64 = "base64"
+
+// defaultDownloadContentType is served for downloads whose stored content
+// type is missing or not allowed.
+const defaultDownloadContentType = "application/octet-stream"
+
+// defaultStreamChunkSize is how many bytes of the message each stream event
+// carries unless WithStreamChunkSize says otherwise.
+const defaultStreamChunkSize = 16 << 10
+
+// Option configures optional behaviour of the fetch handler.
+type Option func(*options)
+
+type options struct {
+	maxAliasLength int
+	maxKeyLength   int
+	keyEncoding    cipher.KeyEncoding
+	rotateNonce    bool
+	notifier       *notify.Notifier
+	tombstones     bool
+	events         EventPublisher
+	// downloadContentTypes are the media types downloads may be served as
+	downloadContentTypes []string
+	readHistoryLength    int
+	readHistoryKey       []byte
+	streamChunkSize      int
+	aliasVerifier        AliasVerifier
+	postFetchHooks       []PostFetchHook
+	graceWindow          time.Duration
+	graceKey             []byte
+	graceSession         bool
+	metrics              MetricsRecorder
+	tracer               trace.Tracer
+	uuidAliases          bool
+	tenantChecker        TenantChecker
+	contentQRMaxBytes    int
+	passwordLimiter      RateLimiter
+	passwordAttempts     int
+	passwordWindow       time.Duration
+}
+
+// WithMaxSegmentLength limits the length of the alias and key path segments.
+// A zero value disables the corresponding check.
+func WithMaxSegmentLength(alias, key int) Option {
+	return func(o *options) {
+		o.maxAliasLength = alias
+		o.maxKeyLength = key
+	}
+}
+
+// WithKeyEncoding sets which key encodings are accepted. The default,
+// cipher.KeyEncodingAuto, accepts both hex and base64url keys.
+func WithKeyEncoding(encoding cipher.KeyEncoding) Option {
+	return func(o *options) {
+		o.keyEncoding = encoding
+	}
+}
+
+// WithNonceRotation re-encrypts multi-view secrets under a fresh nonce after
+// every view, so the same ciphertext is never served twice.
+func WithNonceRotation() Option {
+	return func(o *options) {
+		o.rotateNonce = true
+	}
+}
+
+// WithReadNotifier sends a read receipt to secrets saved with a notify_email.
+func WithReadNotifier(notifier *notify.Notifier) Option {
+	return func(o *options) {
+		o.notifier = notifier
+	}
+}
+
+// WithTombstones answers 410 Gone instead of 404 for secrets the expiry
+// processor has marked as expired.
+func WithTombstones() Option {
+	return func(o *options) {
+		o.tombstones = true
+	}
+}
+
+// EventPublisher queues webhook events without blocking the request.
+type EventPublisher interface {
+	Publish(event webhook.Event) bool
+}
+
+// WithEventPublisher publishes a secret.read event for every revealed secret.
+func WithEventPublisher(events EventPublisher) Option {
+	return func(o *options) {
+		o.events = events
+	}
+}
+
+// WithDownloadContentTypes allows downloads to be served with their stored
+// content type when its media type is one of types. Any other download is
+// served as application/octet-stream.
+func WithDownloadContentTypes(types ...string) Option {
+	return func(o *options) {
+		for _, t := range types {
+			o.downloadContentTypes = append(o.downloadContentTypes, strings.ToLower(strings.TrimSpace(t)))
+		}
+	}
+}
+
+// WithReadHistory records when multi-view secrets are read, keeping the last
+// length reads. Reader IPs are stored as HMACs under ipKey.
+func WithReadHistory(length int, ipKey []byte) Option {
+	return func(o *options) {
+		o.readHistoryLength = length
+		o.readHistoryKey = ipKey
+	}
+}
+
+// WithStreamChunkSize sets how many bytes of the message each event of a
+// streamed reveal carries, see NewStream.
+func WithStreamChunkSize(size int) Option {
+	return func(o *options) {
+		o.streamChunkSize = size
+	}
+}
+
+// PostFetchHook runs custom logic, such as audit logging, after every
+// successful reveal. It can't change the response, which is already written.
+type PostFetchHook interface {
+	PostFetch(ctx context.Context, alias string)
+}
+
+// PostFetchHookFunc adapts a function to a PostFetchHook.
+type PostFetchHookFunc func(ctx context.Context, alias string)
+
+func (f PostFetchHookFunc) PostFetch(ctx context.Context, alias string) {
+	f(ctx, alias)
+}
+
+// WithPostFetchHooks runs hooks in order after every reveal, see
+// PostFetchHook.
+func WithPostFetchHooks(hooks ...PostFetchHook) Option {
+	return func(o *options) {
+		o.postFetchHooks = append(o.postFetchHooks, hooks...)
+	}
+}
+
+// graceCookie carries the token that, together with the client's IP, lets
+// a client re-read a one-time secret it just burned, see WithGracePeriod.
+const graceCookie = "yoopass_grace"
+
+// WithGracePeriod keeps a burned one-time secret readable for window by the
+// client that burned it, so a browser fetching the page twice doesn't lose
+// the secret to itself. The client is told apart by a cookie together with
+// its IP, keyed with holderKey, and nobody else can read the secret. Streamed
+// reveals get no grace period, their headers are gone before the burn.
+func WithGracePeriod(window time.Duration, holderKey []byte) Option {
+	return func(o *options) {
+		o.graceWindow = window
+		o.graceKey = holderKey
+	}
+}
+
+// WithSessionGrace binds the grace period to the browser session instead of
+// the client's IP: the cookie is a Secure session cookie and the token alone
+// identifies the client, so a reader whose IP changes, say on a mobile
+// network, can still re-read the secret. Needs WithGracePeriod.
+func WithSessionGrace() Option {
+	return func(o *options) {
+		o.graceSession = true
+	}
+}
+
+// MetricsRecorder counts reveals, misses and decode failures and times
+// reveals, see metrics.Metrics.
+type MetricsRecorder interface {
+	SecretRead(burned bool)
+	DecodeFailed()
+	FetchNotFound()
+	ObserveLatency(handler string, elapsed time.Duration)
+}
+
+// WithUUIDAliases rejects aliases that aren't UUIDs with 400 before storage
+// is asked about them and normalizes the rest, for deployments where every
+// alias is generated by save.
+func WithUUIDAliases() Option {
+	return func(o *options) {
+		o.uuidAliases = true
+	}
+}
+
+// WithMetrics counts every reveal and every secret that failed to decrypt
+// with recorder. Re-reads within a grace period aren't counted again.
+func WithMetrics(recorder MetricsRecorder) Option {
+	return func(o *options) {
+		o.metrics = recorder
+	}
+}
+
+// AliasVerifier checks a signed alias and returns the bare one, see aliassig.
+type AliasVerifier interface {
+	Verify(signed string) (string, error)
+}
+
+// WithAliasVerifier checks the signature of aliases sent to POST /fetch
+// before storage is asked about them. Routes taking the alias from the path
+// are checked by the aliascheck middleware instead.
+func WithAliasVerifier(verifier AliasVerifier) Option {
+	return func(o *options) {
+		o.aliasVerifier = verifier
+	}
+}
+
+// TenantChecker decides whether user may look up alias, see tenant.
+type TenantChecker interface {
+	Check(alias, user string) error
+}
+
+// WithTenantChecker keeps POST /fetch away from the aliases of other
+// tenants, answering 404 as if the secret didn't exist. Routes taking the
+// alias from the path are checked by the tenantcheck middleware instead.
+func WithTenantChecker(checker TenantChecker) Option {
+	return func(o *options) {
+		o.tenantChecker = checker
+	}
+}
+
+// RateLimiter counts events against a limit shared by all instances, see
+// storage.Storage.
+type RateLimiter interface {
+	Allow(name string, limit int, window time.Duration) (bool, time.Duration, error)
+}
+
+// passwordRateLimitPrefix prefixes the alias in the name of its password
+// attempt limit in storage.
+const passwordRateLimitPrefix = "password:"
+
+// WithPasswordAttemptLimit allows at most attempts password tries per alias
+// within window, across every client and instance, so a password can't be
+// guessed online however many addresses the guesses come from. Tries past
+// the limit get 429 before any key is derived. Storage can't look at a limit
+// without counting, so correct passwords count as well.
+func WithPasswordAttemptLimit(limiter RateLimiter, attempts int, window time.Duration) Option {
+	return func(o *options) {
+		o.passwordLimiter = limiter
+		o.passwordAttempts = attempts
+		o.passwordWindow = window
+	}
 }
 
 type SecretFetcher interface {
 	// this matches call in storage
 	Fetch(key string) ([]byte, error)
+	Consume(key string) ([]byte, error)
+	Replace(key string, old, value []byte) error
+	Tombstoned(key string) (bool, error)
+	ConsumeApproval(key string) (bool, error)
+	RecordRead(key string, read storage.Read, max int) error
+	SetGrace(key, holder string, value []byte, ttl time.Duration) error
+	Grace(key, holder string) ([]byte, error)
+	CountView(key string) (int, error)
 	Delete(key string) error
 }
 
-func New(log *slog.Logger, secretFetcher SecretFetcher) http.HandlerFunc {
+// handler holds what every fetch route needs to reveal a secret.
+type handler struct {
+	secretFetcher SecretFetcher
+	opts          options
+	// reads coalesces concurrent storage reads of the same alias
+	reads *singleflight.Group
+}
+
+func newHandler(secretFetcher SecretFetcher, opts []Option) *handler {
+	h := &handler{secretFetcher: secretFetcher, reads: new(singleflight.Group)}
+	for _, opt := range opts {
+		opt(&h.opts)
+	}
+	return h
+}
+
+// New serves GET /{alias}/{key}.
+func New(log *slog.Logger, secretFetcher SecretFetcher, opts ...Option) http.HandlerFunc {
+	h := newHandler(secretFetcher, opts)
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.url.fetch.New"
 
@@ -34,75 +362,763 @@ func New(log *slog.Logger, secretFetcher SecretFetcher) http.HandlerFunc {
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
-		if secretFetcher == nil {
-			log.Error("critical: secretFetcher is nil")
-			render.Status(r, http.StatusInternalServerError)
-			render.JSON(w, r, resp.Error("internal server error"))
-			return
+		if h.opts.metrics != nil {
+			start := time.Now()
+			defer func() { h.opts.metrics.ObserveLatency("fetch", time.Since(start)) }()
 		}
 
-		alias := chi.URLParam(r, "alias")
-		if alias == "" {
-			log.Info("Alias parameter is missing")
-			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, resp.Error("Alias parameter is missing"))
-			return
-		}
+		h.traced(r.Context()).reveal(w, r, log, chi.URLParam(r, "alias"), chi.URLParam(r, "key"))
+	}
+}
 
-		key := chi.URLParam(r, "key")
-		if key == "" {
-			log.Info("Key parameter is missing")
-			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, resp.Error("Key parameter is missing"))
-			return
+// NewDownload serves GET /{alias}/{key}/download, which returns the secret
+// as a file instead of JSON.
+func NewDownload(log *slog.Logger, secretFetcher SecretFetcher, opts ...Option) http.HandlerFunc {
+	h := newHandler(secretFetcher, opts)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.fetch.NewDownload"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		h.traced(r.Context()).download(w, r, log, chi.URLParam(r, "alias"), chi.URLParam(r, "key"))
+	}
+}
+
+// NewStream serves GET /{alias}/{key}/stream, which sends the message as
+// Server-Sent Events so a client can show a large secret while it arrives.
+// Every "chunk" event carries the next part of the message as standard
+// base64, a final "done" event marks the end. A one-time secret is only
+// burned once every chunk was written, and "done" only follows a
+// successful burn, so a stream without it must be treated as failed.
+func NewStream(log *slog.Logger, secretFetcher SecretFetcher, opts ...Option) http.HandlerFunc {
+	h := newHandler(secretFetcher, opts)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.fetch.NewStream"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		h.traced(r.Context()).stream(w, r, log, chi.URLParam(r, "alias"), chi.URLParam(r, "key"))
+	}
+}
+
+// NewPost serves POST /fetch, which takes the alias and key from the body.
+func NewPost(log *slog.Logger, secretFetcher SecretFetcher, opts ...Option) http.HandlerFunc {
+	h := newHandler(secretFetcher, opts)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.fetch.NewPost"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		if h.opts.metrics != nil {
+			start := time.Now()
+			defer func() { h.opts.metrics.ObserveLatency("fetch", time.Since(start)) }()
 		}
 
-		cipherObject, err := secretFetcher.Fetch(alias)
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
 		if err != nil {
-			log.Error("Some error occured", slog.Any("error", err))
-			render.Status(r, http.StatusInternalServerError)
-			render.JSON(w, r, resp.Error(err.Error()))
+			log.Info("Failed to decode request", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusBadRequest, "Failed to read or decode request body.")
 			return
 		}
 
-		if cipherObject == nil {
-			log.Info("Secret not found in storage", slog.String("alias", alias))
-			render.Status(r, http.StatusNotFound)
-			render.JSON(w, r, resp.Error("Secret not found"))
-			return
+		alias := req.Alias
+		if h.opts.aliasVerifier != nil && alias != "" {
+			alias, err = h.opts.aliasVerifier.Verify(alias)
+			if err != nil {
+				log.Info("Rejected alias", slog.Any("error", err))
+				resp.RenderError(w, r, http.StatusBadRequest, "Invalid alias signature")
+				return
+			}
 		}
 
-		object, err := cipher.Decode(cipherObject, key)
-		if err != nil {
-			log.Error("Failed to decode secret", slog.Any("error", err))
-			render.Status(r, http.StatusInternalServerError)
-			render.JSON(w, r, resp.Error("Failed to decode secret"))
-			return
+		if h.opts.tenantChecker != nil {
+			if err := h.opts.tenantChecker.Check(alias, identity.User(r.Context())); err != nil {
+				log.Info("Rejected alias of another tenant")
+				resp.RenderError(w, r, http.StatusNotFound, "Secret not found")
+				return
+			}
 		}
 
-		var dest dto.Secret
+		key := req.Key
+		if len(req.Shares) > 0 {
+			if key != "" {
+				log.Info("Both key and key shares sent")
+				resp.RenderError(w, r, http.StatusBadRequest, "Send either a key or key shares")
+				return
+			}
 
-		err = json.Unmarshal(object, &dest)
-		if err != nil {
-			log.Error("Secret unmarshalling failed", slog.Any("error", err))
-			render.Status(r, http.StatusInternalServerError)
-			render.JSON(w, r, resp.Error("Secret unmarshalling failed"))
-			return
-		}
+			if err := cipher.Spend(r.Context()); err != nil {
+				log.Warn("Decode budget exhausted", slog.Any("error", err))
+				resp.RenderError(w, r, http.StatusBadRequest, "Too many decode attempts")
+				return
+			}
 
-		if dest.OneTime {
-			err = secretFetcher.Delete(alias)
+			key, err = combineShares(req.Shares)
 			if err != nil {
-				log.Error("Failed to delete secret", slog.Any("error", err))
-				render.Status(r, http.StatusInternalServerError)
-				render.JSON(w, r, resp.Error("Failed to delete secret"))
+				log.Info("Invalid key shares", slog.Any("error", err))
+				resp.RenderError(w, r, http.StatusBadRequest, "Invalid key shares")
 				return
 			}
 		}
 
+		if req.Password != "" {
+			r = r.WithContext(context.WithValue(r.Context(), passwordKey{}, req.Password))
+		}
+
+		h.traced(r.Context()).reveal(w, r, log, alias, key)
+	}
+}
+
+// combineShares rebuilds a hex key from hex encoded shares made at save.
+// Too few shares yield a wrong key, which fails to decrypt the secret.
+func combineShares(encoded []string) (string, error) {
+	shares := make([][]byte, len(encoded))
+	for i, share := range encoded {
+		b, err := hex.DecodeString(share)
+		if err != nil {
+			return "", err
+		}
+		shares[i] = b
+	}
+
+	key, err := shamir.Combine(shares)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// reveal loads, decrypts and returns the secret stored under alias, burning
+// it when it is one-time. It writes the response in every case.
+func (h *handler) reveal(w http.ResponseWriter, r *http.Request, log *slog.Logger, alias, key string) {
+	// Checked before reading storage so a bad request never burns a secret
+	encoding := r.URL.Query().Get("encoding")
+	if encoding != "" && encoding != "raw" && encoding != encodingBase64 {
+		log.Info("Unsupported encoding", slog.String("encoding", encoding))
+		resp.RenderError(w, r, http.StatusBadRequest, "Unsupported encoding")
+		return
+	}
+
+	o, ok := h.open(w, r, log, alias, key)
+	if !ok {
+		return
+	}
+
+	if encoding == encodingBase64 {
 		render.JSON(w, r, Response{
 			Response: resp.OK(),
-			Message:  dest.Message,
+			Message:  base64.StdEncoding.EncodeToString([]byte(o.secret.Message)),
+			Encoding: encodingBase64,
+			Consumed: o.consumed(),
 		})
+		return
+	}
+
+	render.JSON(w, r, Response{
+		Response: resp.OK(),
+		Message:  o.secret.Message,
+		Consumed: o.consumed(),
+	})
+}
+
+// download writes the secret stored under alias as a raw attachment. The
+// stored content type is only passed on when allowed, see
+// WithDownloadContentTypes, and nosniff keeps browsers from second-guessing
+// it, so a secret is never rendered as a page.
+func (h *handler) download(w http.ResponseWriter, r *http.Request, log *slog.Logger, alias, key string) {
+	o, ok := h.open(w, r, log, alias, key)
+	if !ok {
+		return
+	}
+	dest := o.secret
+
+	w.Header().Set("Content-Type", h.downloadContentType(dest.ContentType))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Disposition", "attachment")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte(dest.Message)); err != nil {
+		log.Warn("Failed to write download", slog.Any("error", err))
+	}
+}
+
+// stream writes the secret stored under alias as Server-Sent Events, see
+// NewStream. Errors before the first event are regular error responses.
+func (h *handler) stream(w http.ResponseWriter, r *http.Request, log *slog.Logger, alias, key string) {
+	// Checked before reading storage so a bad request never burns a secret
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		log.Info("Stream requested without event-stream accept", slog.String("accept", r.Header.Get("Accept")))
+		resp.RenderError(w, r, http.StatusNotAcceptable, "Streaming needs Accept: text/event-stream")
+		return
+	}
+
+	o, ok := h.load(w, r, log, alias, key)
+	if !ok {
+		return
+	}
+	alias = o.alias
+
+	chunkSize := h.opts.streamChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultStreamChunkSize
+	}
+
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	message := []byte(o.secret.Message)
+	for start := 0; start < len(message); start += chunkSize {
+		chunk := message[start:min(start+chunkSize, len(message))]
+		err := writeEvent(rc, w, "chunk", base64.StdEncoding.EncodeToString(chunk))
+		if err == nil {
+			err = r.Context().Err()
+		}
+		if err != nil {
+			// The client didn't get the whole secret, so it must stay
+			log.Info("Stream aborted, secret kept", slog.String("alias", alias), slog.Any("error", err))
+			return
+		}
+	}
+
+	if err := h.burn(alias, &o); err != nil {
+		log.Warn("Failed to consume streamed secret", slog.String("alias", alias), slog.Any("error", err))
+		msg := "Failed to delete secret"
+		if _, m, ok := resp.FromStorageError(err); ok {
+			msg = m
+		}
+		_ = writeEvent(rc, w, "error", i18n.Translate(i18n.FromRequest(r), msg))
+		return
+	}
+
+	h.afterRead(log, r, alias, o)
+
+	if err := writeEvent(rc, w, "done", ""); err != nil {
+		log.Info("Failed to finish stream", slog.String("alias", alias), slog.Any("error", err))
+	}
+}
+
+// writeEvent writes one Server-Sent Event and flushes it to the client. data
+// must not contain line breaks.
+func writeEvent(rc *http.ResponseController, w http.ResponseWriter, event, data string) error {
+	if _, err := io.WriteString(w, "event: "+event+"\ndata: "+data+"\n\n"); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
+
+// downloadContentType returns stored when its media type is allowed and
+// application/octet-stream otherwise.
+func (h *handler) downloadContentType(stored string) string {
+	mediaType, params, err := mime.ParseMediaType(stored)
+	if err != nil || !slices.Contains(h.opts.downloadContentTypes, mediaType) {
+		return defaultDownloadContentType
+	}
+	if formatted := mime.FormatMediaType(mediaType, params); formatted != "" {
+		return formatted
+	}
+	return defaultDownloadContentType
+}
+
+// opened is a decrypted secret that hasn't been handed out yet.
+type opened struct {
+	// alias is the alias as normalized by load
+	alias        string
+	secret       dto.Secret
+	cipherObject []byte
+	object       []byte
+	keyBytes     []byte
+	// graced is a one-time secret served again within its grace period
+	graced bool
+	// lastView is set by burn when this read used up the secret's MaxViews
+	lastView bool
+}
+
+// consumed reports whether reading o left nothing to read again.
+func (o opened) consumed() bool {
+	return o.secret.OneTime || o.lastView
+}
+
+// open loads and decrypts the secret stored under alias, burning it when it
+// is one-time and sending read notifications. On failure it writes the error
+// response and returns false.
+func (h *handler) open(w http.ResponseWriter, r *http.Request, log *slog.Logger, alias, key string) (opened, bool) {
+	o, ok := h.load(w, r, log, alias, key)
+	if !ok {
+		return opened{}, false
+	}
+	if !h.settle(w, r, log, &o) {
+		return opened{}, false
+	}
+	return o, true
+}
+
+// settle completes a reveal of the secret load returned, burning it when it
+// is one-time and sending read notifications. It must run before anything
+// of the response is written. On failure it writes the error response and
+// returns false.
+func (h *handler) settle(w http.ResponseWriter, r *http.Request, log *slog.Logger, o *opened) bool {
+	alias := o.alias
+
+	err := h.burn(alias, o)
+	if status, msg, ok := resp.FromStorageError(err); ok {
+		log.Info("Failed to consume secret", slog.String("alias", alias), slog.Any("error", err))
+		resp.RenderError(w, r, status, msg)
+		return false
+	}
+	if err != nil {
+		log.Error("Failed to delete secret", slog.Any("error", err))
+		resp.RenderError(w, r, http.StatusInternalServerError, "Failed to delete secret")
+		return false
+	}
+
+	h.keepForGrace(w, r, log, alias, *o)
+	h.afterRead(log, r, alias, *o)
+
+	return true
+}
+
+// load fetches and decrypts the secret stored under alias and checks it may
+// be revealed, without burning it yet. On failure it writes the error
+// response and returns false.
+func (h *handler) load(w http.ResponseWriter, r *http.Request, log *slog.Logger, alias, key string) (opened, bool) {
+	if h.secretFetcher == nil {
+		log.Error("critical: secretFetcher is nil")
+		resp.RenderError(w, r, http.StatusInternalServerError, "internal server error")
+		return opened{}, false
+	}
+
+	if alias == "" {
+		log.Info("Alias parameter is missing")
+		resp.RenderError(w, r, http.StatusBadRequest, "Alias parameter is missing")
+		return opened{}, false
+	}
+
+	if key == "" {
+		log.Info("Key parameter is missing")
+		resp.RenderError(w, r, http.StatusBadRequest, "Key parameter is missing")
+		return opened{}, false
+	}
+
+	// Reject over-long segments before they reach storage
+	if h.opts.maxAliasLength > 0 && len(alias) > h.opts.maxAliasLength {
+		log.Info("Alias parameter is too long", slog.Int("length", len(alias)))
+		resp.RenderError(w, r, http.StatusRequestURITooLong, "Alias parameter is too long")
+		return opened{}, false
+	}
+
+	if h.opts.maxKeyLength > 0 && len(key) > h.opts.maxKeyLength {
+		log.Info("Key parameter is too long", slog.Int("length", len(key)))
+		resp.RenderError(w, r, http.StatusRequestURITooLong, "Key parameter is too long")
+		return opened{}, false
+	}
+
+	if h.opts.uuidAliases {
+		// A tenant prefix is not part of the UUID
+		scope := tenant.Of(alias)
+		id, err := uuid.FromString(strings.TrimPrefix(alias, scope))
+		if err != nil {
+			log.Info("Alias is not a UUID")
+			resp.RenderError(w, r, http.StatusBadRequest, "Invalid alias format")
+			return opened{}, false
+		}
+		// Aliases are saved in canonical form, any other spelling would miss
+		alias = scope + id.String()
+	}
+
+	cipherObject, err := h.fetch(alias)
+	var graced bool
+	if errors.Is(err, storage.ErrNotFound) {
+		if object, ok := h.grace(log, r, alias); ok {
+			cipherObject, err, graced = object, nil, true
+		}
+	}
+	if errors.Is(err, storage.ErrNotFound) && h.expired(log, alias) {
+		log.Info("Secret has expired", slog.String("alias", alias))
+		resp.RenderError(w, r, http.StatusGone, "Secret has expired")
+		return opened{}, false
+	}
+	if (errors.Is(err, storage.ErrNotFound) || err == nil && cipherObject == nil) && h.opts.metrics != nil {
+		h.opts.metrics.FetchNotFound()
+	}
+	if status, msg, ok := resp.FromStorageError(err); ok {
+		log.Info("Failed to fetch secret", slog.String("alias", alias), slog.Any("error", err))
+		resp.RenderError(w, r, status, msg)
+		return opened{}, false
+	}
+	if err != nil {
+		log.Error("Some error occured", slog.Any("error", err))
+		resp.RenderError(w, r, http.StatusInternalServerError, "Failed to fetch secret")
+		return opened{}, false
+	}
+
+	if cipherObject == nil {
+		log.Info("Secret not found in storage", slog.String("alias", alias))
+		resp.RenderError(w, r, http.StatusNotFound, "Secret not found")
+		return opened{}, false
+	}
+
+	keyBytes, err := cipher.DecodeKey(key, h.opts.keyEncoding)
+	if err != nil {
+		log.Info("Invalid key format", slog.Any("error", err))
+		resp.RenderError(w, r, http.StatusBadRequest, "Invalid key format")
+		return opened{}, false
+	}
+
+	if err := cipher.Spend(r.Context()); err != nil {
+		log.Warn("Decode budget exhausted", slog.Any("error", err))
+		resp.RenderError(w, r, http.StatusBadRequest, "Too many decode attempts")
+		return opened{}, false
+	}
+
+	object, err := cipher.DecodeWithKey(cipherObject, keyBytes)
+	if err != nil {
+		if h.opts.metrics != nil {
+			h.opts.metrics.DecodeFailed()
+		}
+		log.Error("Failed to decode secret", slog.Any("error", err))
+		resp.RenderError(w, r, http.StatusInternalServerError, "Failed to decode secret")
+		return opened{}, false
+	}
+
+	// Audit trail of the crypto actually applied, never of the key
+	log.Debug("Secret decrypted",
+		slog.String("alias", alias),
+		slog.String("cipher", cipher.Name(len(keyBytes))),
+		slog.Int("key_bits", len(keyBytes)*8),
+	)
+
+	var dest dto.Secret
+
+	err = json.Unmarshal(object, &dest)
+	if err != nil {
+		// The secret decrypted fine, so the stored data is at fault, not the server
+		log.Warn("Secret unmarshalling failed", slog.Any("error", err))
+		resp.RenderError(w, r, http.StatusUnprocessableEntity, "Secret unmarshalling failed")
+		return opened{}, false
+	}
+
+	// Storage may not have evicted the key yet, the payload's expiry wins
+	if !dest.ExpiresAt.IsZero() && !time.Now().Before(dest.ExpiresAt) {
+		log.Info("Secret has expired", slog.String("alias", alias), slog.Time("expires_at", dest.ExpiresAt))
+		resp.RenderError(w, r, http.StatusGone, "Secret has expired")
+		return opened{}, false
+	}
+
+	// Outside its reveal window the secret is kept, a one-time secret must
+	// survive an early or late attempt untouched
+	now := time.Now()
+	if now.Before(dest.NotBefore) {
+		log.Info("Secret is not available yet", slog.String("alias", alias), slog.Time("not_before", dest.NotBefore))
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(dest.NotBefore.Sub(now).Seconds()))))
+		resp.RenderError(w, r, http.StatusTooEarly, "Secret is not available yet")
+		return opened{}, false
+	}
+	if !dest.NotAfter.IsZero() && !now.Before(dest.NotAfter) {
+		log.Info("Secret is no longer available", slog.String("alias", alias), slog.Time("not_after", dest.NotAfter))
+		resp.RenderError(w, r, http.StatusGone, "Secret is no longer available")
+		return opened{}, false
+	}
+
+	// Checked before the approval, a wrong password must not use it up
+	if len(dest.PasswordSalt) > 0 {
+		message, ok := h.unseal(w, r, log, alias, dest)
+		if !ok {
+			return opened{}, false
+		}
+		dest.Message = message
+	}
+
+	// The approval was used up by the read that burned the secret
+	if dest.RequireApproval && !graced {
+		approved, err := h.secretFetcher.ConsumeApproval(alias)
+		if status, msg, ok := resp.FromStorageError(err); ok {
+			log.Info("Failed to check approval", slog.String("alias", alias), slog.Any("error", err))
+			resp.RenderError(w, r, status, msg)
+			return opened{}, false
+		}
+		if err != nil {
+			log.Error("Failed to check approval", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to check approval")
+			return opened{}, false
+		}
+		if !approved {
+			log.Info("Secret awaits approval", slog.String("alias", alias))
+			resp.RenderError(w, r, http.StatusForbidden, "Secret requires approval")
+			return opened{}, false
+		}
+	}
+
+	return opened{alias: alias, secret: dest, cipherObject: cipherObject, object: object, keyBytes: keyBytes, graced: graced}, true
+}
+
+// unseal opens the message of a password-protected secret with the password
+// sent along. On failure it writes the error response and returns false.
+func (h *handler) unseal(w http.ResponseWriter, r *http.Request, log *slog.Logger, alias string, dest dto.Secret) (string, bool) {
+	pass := password(r)
+	if pass == "" {
+		log.Info("Password missing for protected secret", slog.String("alias", alias))
+		resp.RenderError(w, r, http.StatusUnauthorized, "Password required")
+		return "", false
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(dest.Message)
+	if err != nil {
+		log.Warn("Sealed message is not base64", slog.Any("error", err))
+		resp.RenderError(w, r, http.StatusUnprocessableEntity, "Secret unmarshalling failed")
+		return "", false
+	}
+
+	if h.opts.passwordLimiter != nil {
+		allowed, retryAfter, err := h.opts.passwordLimiter.Allow(passwordRateLimitPrefix+alias, h.opts.passwordAttempts, h.opts.passwordWindow)
+		if status, msg, ok := resp.FromStorageError(err); ok {
+			log.Error("Failed to check password attempt limit", slog.Any("error", err))
+			resp.RenderError(w, r, status, msg)
+			return "", false
+		}
+		if err != nil {
+			log.Error("Failed to check password attempt limit", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to fetch secret")
+			return "", false
+		}
+		if !allowed {
+			log.Warn("Password attempt limit reached", slog.String("alias", alias), slog.Int("attempts", h.opts.passwordAttempts))
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			resp.RenderError(w, r, http.StatusTooManyRequests, "Too many password attempts, try again later")
+			return "", false
+		}
+	}
+
+	// Deriving the password key is the most expensive step of a reveal
+	if err := cipher.Spend(r.Context()); err != nil {
+		log.Warn("Decode budget exhausted", slog.Any("error", err))
+		resp.RenderError(w, r, http.StatusBadRequest, "Too many decode attempts")
+		return "", false
+	}
+
+	message, err := cipher.OpenWithPassword(sealed, pass, dest.PasswordSalt)
+	if errors.Is(err, cipher.ErrPasswordBusy) {
+		log.Warn("Too many password derivations in progress", slog.String("alias", alias))
+		w.Header().Set("Retry-After", "1")
+		resp.RenderError(w, r, http.StatusServiceUnavailable, "Server is busy, try again later")
+		return "", false
+	}
+	if err != nil {
+		log.Info("Wrong password", slog.String("alias", alias))
+		resp.RenderError(w, r, http.StatusUnauthorized, "Wrong password")
+		return "", false
+	}
+
+	return string(message), true
+}
+
+// burn consumes a one-time secret, or counts a view of a secret with
+// MaxViews and deletes it on the last one. Only the caller that actually
+// consumes it may reveal it, concurrent readers of the same one-time secret
+// or of a used up view limit get ErrNotFound.
+func (h *handler) burn(alias string, o *opened) error {
+	if o.graced {
+		return nil
+	}
+	if o.secret.OneTime {
+		_, err := h.secretFetcher.Consume(alias)
+		return err
+	}
+	if o.secret.MaxViews <= 0 {
+		return nil
+	}
+
+	views, err := h.secretFetcher.CountView(alias)
+	if err != nil {
+		return err
+	}
+	switch {
+	case views > o.secret.MaxViews:
+		// A concurrent read took the last view, this one is too late
+		return storage.ErrNotFound
+	case views == o.secret.MaxViews:
+		o.lastView = true
+		return h.secretFetcher.Delete(alias)
+	}
+	return nil
+}
+
+// afterRead runs everything that follows a successful reveal: rotation, read
+// history, the read event and the read receipt.
+func (h *handler) afterRead(log *slog.Logger, r *http.Request, alias string, o opened) {
+	// Repeating a read is not a new read
+	if o.graced {
+		log.Info("Secret served again within grace period", slog.String("alias", alias))
+		return
+	}
+
+	if h.opts.metrics != nil {
+		h.opts.metrics.SecretRead(o.secret.OneTime)
+	}
+
+	if !o.consumed() && h.opts.rotateNonce {
+		h.rotate(log, alias, o.cipherObject, o.object, o.keyBytes)
+	}
+
+	// A consumed secret is gone after this read, there is no one to audit it
+	if !o.consumed() && h.opts.readHistoryLength > 0 {
+		h.recordRead(log, alias, r)
+	}
+
+	if h.opts.events != nil {
+		h.opts.events.Publish(webhook.NewEvent(webhook.EventSecretRead, alias))
+	}
+
+	if o.secret.NotifyEmail != "" && h.opts.notifier != nil {
+		h.opts.notifier.SecretRead(log, o.secret.NotifyEmail, netutil.ClientIP(r))
+	}
+
+	for _, hook := range h.opts.postFetchHooks {
+		hook.PostFetch(r.Context(), alias)
+	}
+}
+
+// keepForGrace keeps a just burned one-time secret for the grace period and
+// hands the client the cookie to read it again with. The secret has already
+// been burned, so failures only cost the grace period and are logged.
+func (h *handler) keepForGrace(w http.ResponseWriter, r *http.Request, log *slog.Logger, alias string, o opened) {
+	if h.opts.graceWindow <= 0 || !o.secret.OneTime || o.graced {
+		return
+	}
+
+	// One token serves every secret the client burns
+	token := rand.Text()
+	if cookie, err := r.Cookie(graceCookie); err == nil && cookie.Value != "" {
+		token = cookie.Value
+	}
+
+	err := h.secretFetcher.SetGrace(alias, h.graceHolder(r, token), o.cipherObject, h.opts.graceWindow)
+	if err != nil {
+		log.Warn("Failed to keep secret for grace period", slog.String("alias", alias), slog.Any("error", err))
+		return
+	}
+
+	cookie := &http.Cookie{
+		Name:     graceCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   max(1, int(h.opts.graceWindow/time.Second)),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	}
+	if h.opts.graceSession {
+		// The token alone stands for the client, it must never travel in clear
+		cookie.MaxAge = 0
+		cookie.Secure = true
+	}
+	http.SetCookie(w, cookie)
+}
+
+// grace returns the copy of a burned secret kept for this client, see
+// WithGracePeriod.
+func (h *handler) grace(log *slog.Logger, r *http.Request, alias string) ([]byte, bool) {
+	if h.opts.graceWindow <= 0 {
+		return nil, false
+	}
+	cookie, err := r.Cookie(graceCookie)
+	if err != nil || cookie.Value == "" {
+		return nil, false
+	}
+
+	cipherObject, err := h.secretFetcher.Grace(alias, h.graceHolder(r, cookie.Value))
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			log.Warn("Failed to check grace period", slog.String("alias", alias), slog.Any("error", err))
+		}
+		return nil, false
+	}
+	return cipherObject, true
+}
+
+// graceHolder identifies a client by its IP and grace token, or by the token
+// alone with WithSessionGrace, keyed so that neither can be recovered from
+// storage.
+func (h *handler) graceHolder(r *http.Request, token string) string {
+	if h.opts.graceSession {
+		return redact.HashIP(h.opts.graceKey, "session|"+token)
+	}
+	return redact.HashIP(h.opts.graceKey, netutil.ClientIP(r)+"|"+token)
+}
+
+// fetch reads the stored ciphertext, sharing one storage call between all
+// concurrent reads of alias. Only the read is shared: one-time secrets are
+// still burned by a separate Consume per request, so just one of the callers
+// wins. The returned slice is shared and must not be modified.
+func (h *handler) fetch(alias string) ([]byte, error) {
+	v, err, _ := h.reads.Do(alias, func() (interface{}, error) {
+		return h.secretFetcher.Fetch(alias)
+	})
+	cipherObject, _ := v.([]byte)
+	return cipherObject, err
+}
+
+// expired reports whether alias left a tombstone when it expired. Lookup
+// failures are treated as not expired, falling back to a plain 404.
+func (h *handler) expired(log *slog.Logger, alias string) bool {
+	if !h.opts.tombstones {
+		return false
+	}
+
+	tombstoned, err := h.secretFetcher.Tombstoned(alias)
+	if err != nil {
+		log.Warn("Failed to check tombstone", slog.String("alias", alias), slog.Any("error", err))
+		return false
+	}
+	return tombstoned
+}
+
+// recordRead adds this read to the history of alias. The secret has already
+// been read successfully, so failures are only logged.
+func (h *handler) recordRead(log *slog.Logger, alias string, r *http.Request) {
+	read := storage.Read{
+		At:     time.Now().UTC(),
+		IPHash: redact.HashIP(h.opts.readHistoryKey, netutil.ClientIP(r)),
+	}
+	if err := h.secretFetcher.RecordRead(alias, read, h.opts.readHistoryLength); err != nil {
+		log.Warn("Failed to record read", slog.String("alias", alias), slog.Any("error", err))
+	}
+}
+
+// rotate stores object re-encrypted under a new nonce with the same key, in
+// place of the ciphertext old it was read from. A secret updated since then
+// keeps the newer value. The secret has already been read successfully, so
+// failures are only logged.
+func (h *handler) rotate(log *slog.Logger, alias string, old, object, keyBytes []byte) {
+	cipherObject, err := cipher.EncodeWithKey(object, keyBytes)
+	if err != nil {
+		log.Error("Failed to re-encrypt secret", slog.Any("error", err))
+		return
+	}
+
+	err = h.secretFetcher.Replace(alias, old, cipherObject)
+	if errors.Is(err, storage.ErrStale) {
+		log.Info("Secret changed while read, skipping rotation", slog.String("alias", alias))
+		return
+	}
+	if err != nil {
+		log.Warn("Failed to store re-encrypted secret", slog.String("alias", alias), slog.Any("error", err))
 	}
 }
@@ -2,16 +2,27 @@ package fetch
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"image/png"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 	"yoopass-api/internal/dto"
 	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/notify"
+	"yoopass-api/internal/storage"
+	"yoopass-api/internal/storage/storagemock"
+	"yoopass-api/internal/testutil"
 	cipher "yoopass-api/internal/tools/cipher" // Assuming cipher package exists and works
+	"yoopass-api/internal/webhook"
 
 	"github.com/go-chi/chi"
 	"github.com/stretchr/testify/assert"
@@ -19,25 +30,6 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-// MockSecretFetcher is a mock type for the SecretFetcher interface
-type MockSecretFetcher struct {
-	mock.Mock
-}
-
-func (m *MockSecretFetcher) Fetch(key string) ([]byte, error) {
-	args := m.Called(key)
-	// Handle nil byte slice correctly
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).([]byte), args.Error(1)
-}
-
-func (m *MockSecretFetcher) Delete(key string) error {
-	args := m.Called(key)
-	return args.Error(0)
-}
-
 // Helper to create a chi context with URL parameters
 func chiCtx(alias, key string) context.Context {
 	rctx := chi.NewRouteContext()
@@ -46,14 +38,10 @@ func chiCtx(alias, key string) context.Context {
 	return context.WithValue(context.Background(), chi.RouteCtxKey, rctx)
 }
 
-// Helper to encode data for tests (replace with actual cipher logic if needed)
+// Helper to encode data for tests, see testutil.BuildCiphertext for variants
 func encodeForTest(t *testing.T, data dto.Secret, key string) []byte {
 	t.Helper()
-	jsonData, err := json.Marshal(data)
-	require.NoError(t, err)
-	encodedData, err := cipher.Encode(jsonData, key) // Use the actual Encode function
-	require.NoError(t, err)
-	return encodedData
+	return testutil.BuildCiphertext(t, data, key)
 }
 
 func TestFetchHandler(t *testing.T) {
@@ -65,147 +53,293 @@ func TestFetchHandler(t *testing.T) {
 		name           string
 		alias          string
 		key            string
-		setupMock      func(m *MockSecretFetcher, alias, key string)
+		options        []Option
+		setupMock      func(m *storagemock.Storage, alias, key string)
 		expectedStatus int
 		expectedBody   interface{} // Can be Response or resp.Response
-		checkMockCalls func(t *testing.T, m *MockSecretFetcher, alias string)
+		checkMockCalls func(t *testing.T, m *storagemock.Storage, alias string)
 	}{
 		{
 			name:  "Success Fetch Regular Secret",
 			alias: "f7ab603e-fbae-4182-8379-8763d9327d51",
 			key:   "46da5d3577209271242b42882a034c3d",
-			setupMock: func(m *MockSecretFetcher, alias, key string) {
+			setupMock: func(m *storagemock.Storage, alias, key string) {
 				secretData := dto.Secret{Message: "hello world", OneTime: false}
 				encodedData := encodeForTest(t, secretData, key)
 				m.On("Fetch", alias).Return(encodedData, nil).Once()
-				// Delete should NOT be called
+				// Consume should NOT be called
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody: Response{
 				Response: resp.OK(),
 				Message:  "hello world",
 			},
-			checkMockCalls: func(t *testing.T, m *MockSecretFetcher, alias string) {
+			checkMockCalls: func(t *testing.T, m *storagemock.Storage, alias string) {
 				m.AssertCalled(t, "Fetch", alias)
-				m.AssertNotCalled(t, "Delete", alias)
+				m.AssertNotCalled(t, "Consume", alias)
 			},
 		},
 		{
 			name:  "Success Fetch One-Time Secret",
 			alias: "f7ab603e-fbae-4182-8379-8763d9327d22",
 			key:   "46da5d3577209271242b42882a034c3d",
-			setupMock: func(m *MockSecretFetcher, alias, key string) {
+			setupMock: func(m *storagemock.Storage, alias, key string) {
 				secretData := dto.Secret{Message: "this will vanish", OneTime: true}
 				encodedData := encodeForTest(t, secretData, key)
 				m.On("Fetch", alias).Return(encodedData, nil).Once()
-				m.On("Delete", alias).Return(nil).Once() // Expect Delete to be called
+				m.On("Consume", alias).Return(encodedData, nil).Once() // Expect Consume to be called
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody: Response{
 				Response: resp.OK(),
 				Message:  "this will vanish",
+				Consumed: true,
 			},
-			checkMockCalls: func(t *testing.T, m *MockSecretFetcher, alias string) {
+			checkMockCalls: func(t *testing.T, m *storagemock.Storage, alias string) {
 				m.AssertCalled(t, "Fetch", alias)
-				m.AssertCalled(t, "Delete", alias)
+				m.AssertCalled(t, "Consume", alias)
 			},
 		},
 		{
-			name:  "Error Fetch One-Time Secret Delete Fails",
+			name:  "Error Fetch One-Time Secret Consume Fails",
 			alias: "f7ab603e-fbae-4182-8379-8763d9327d51",
 			key:   "46da5d3577209271242b42882a034c3d",
-			setupMock: func(m *MockSecretFetcher, alias, key string) {
+			setupMock: func(m *storagemock.Storage, alias, key string) {
 				secretData := dto.Secret{Message: "this should vanish but delete fails", OneTime: true}
 				encodedData := encodeForTest(t, secretData, key)
 				m.On("Fetch", alias).Return(encodedData, nil).Once()
-				m.On("Delete", alias).Return(errors.New("db error")).Once() // Simulate delete failure
+				m.On("Consume", alias).Return(nil, errors.New("db error")).Once() // Simulate consume failure
 			},
 			expectedStatus: http.StatusInternalServerError,
 			expectedBody:   resp.Error("Failed to delete secret"),
-			checkMockCalls: func(t *testing.T, m *MockSecretFetcher, alias string) {
+			checkMockCalls: func(t *testing.T, m *storagemock.Storage, alias string) {
 				m.AssertCalled(t, "Fetch", alias)
-				m.AssertCalled(t, "Delete", alias)
+				m.AssertCalled(t, "Consume", alias)
+			},
+		},
+		{
+			name:  "Error One-Time Secret Consumed Concurrently",
+			alias: "f7ab603e-fbae-4182-8379-8763d9327d23",
+			key:   "46da5d3577209271242b42882a034c3d",
+			setupMock: func(m *storagemock.Storage, alias, key string) {
+				secretData := dto.Secret{Message: "someone else got it", OneTime: true}
+				encodedData := encodeForTest(t, secretData, key)
+				m.On("Fetch", alias).Return(encodedData, nil).Once()
+				// Another reader consumed the secret between Fetch and Consume
+				m.On("Consume", alias).Return(nil, storage.ErrNotFound).Once()
 			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   resp.Error("Secret not found"),
 		},
 		{
 			name:  "Error Missing Alias",
 			alias: "", // Missing alias
 			key:   "46da5d3577209271242b42882a034c3d",
-			setupMock: func(m *MockSecretFetcher, alias, key string) {
-				// Fetch/Delete should not be called
+			setupMock: func(m *storagemock.Storage, alias, key string) {
+				// Fetch/Consume should not be called
 			},
 			expectedStatus: http.StatusBadRequest,
 			expectedBody:   resp.Error("Alias parameter is missing"),
-			checkMockCalls: func(t *testing.T, m *MockSecretFetcher, alias string) {
+			checkMockCalls: func(t *testing.T, m *storagemock.Storage, alias string) {
 				m.AssertNotCalled(t, "Fetch", mock.Anything)
-				m.AssertNotCalled(t, "Delete", mock.Anything)
+				m.AssertNotCalled(t, "Consume", mock.Anything)
 			},
 		},
 		{
 			name:  "Error Missing Key",
 			alias: "f7ab603e-fbae-4182-8379-8763d9327d51",
 			key:   "", // Missing key
-			setupMock: func(m *MockSecretFetcher, alias, key string) {
-				// Fetch/Delete should not be called
+			setupMock: func(m *storagemock.Storage, alias, key string) {
+				// Fetch/Consume should not be called
 			},
 			expectedStatus: http.StatusBadRequest,
 			expectedBody:   resp.Error("Key parameter is missing"),
-			checkMockCalls: func(t *testing.T, m *MockSecretFetcher, alias string) {
+			checkMockCalls: func(t *testing.T, m *storagemock.Storage, alias string) {
+				m.AssertNotCalled(t, "Fetch", mock.Anything)
+				m.AssertNotCalled(t, "Consume", mock.Anything)
+			},
+		},
+		{
+			name:    "Error Alias Too Long",
+			alias:   strings.Repeat("a", 65),
+			key:     "46da5d3577209271242b42882a034c3d",
+			options: []Option{WithMaxSegmentLength(64, 64)},
+			setupMock: func(m *storagemock.Storage, alias, key string) {
+				// Storage must not be queried for an over-long alias
+			},
+			expectedStatus: http.StatusRequestURITooLong,
+			expectedBody:   resp.Error("Alias parameter is too long"),
+			checkMockCalls: func(t *testing.T, m *storagemock.Storage, alias string) {
 				m.AssertNotCalled(t, "Fetch", mock.Anything)
-				m.AssertNotCalled(t, "Delete", mock.Anything)
 			},
 		},
+		{
+			name:    "Error Key Too Long",
+			alias:   "f7ab603e-fbae-4182-8379-8763d9327d51",
+			key:     strings.Repeat("0", 65),
+			options: []Option{WithMaxSegmentLength(64, 64)},
+			setupMock: func(m *storagemock.Storage, alias, key string) {
+				// Storage must not be queried for an over-long key
+			},
+			expectedStatus: http.StatusRequestURITooLong,
+			expectedBody:   resp.Error("Key parameter is too long"),
+			checkMockCalls: func(t *testing.T, m *storagemock.Storage, alias string) {
+				m.AssertNotCalled(t, "Fetch", mock.Anything)
+			},
+		},
+		{
+			name:    "Success Segments Within Limits",
+			alias:   "f7ab603e-fbae-4182-8379-8763d9327d51",
+			key:     "46da5d3577209271242b42882a034c3d",
+			options: []Option{WithMaxSegmentLength(36, 32)},
+			setupMock: func(m *storagemock.Storage, alias, key string) {
+				encodedData := encodeForTest(t, dto.Secret{Message: "fits"}, key)
+				m.On("Fetch", alias).Return(encodedData, nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: Response{
+				Response: resp.OK(),
+				Message:  "fits",
+			},
+		},
+		{
+			name:    "Success Base64url Key In Auto Mode",
+			alias:   "f7ab603e-fbae-4182-8379-8763d9327d51",
+			key:     "RtpdNXcgknEkK0KIKgNMPQ", // base64url of 46da5d3577209271242b42882a034c3d
+			options: []Option{WithKeyEncoding(cipher.KeyEncodingAuto)},
+			setupMock: func(m *storagemock.Storage, alias, key string) {
+				encodedData := encodeForTest(t, dto.Secret{Message: "any encoding"}, "46da5d3577209271242b42882a034c3d")
+				m.On("Fetch", alias).Return(encodedData, nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: Response{
+				Response: resp.OK(),
+				Message:  "any encoding",
+			},
+		},
+		{
+			name:    "Error Base64url Key In Strict Hex Mode",
+			alias:   "f7ab603e-fbae-4182-8379-8763d9327d51",
+			key:     "RtpdNXcgknEkK0KIKgNMPQ",
+			options: []Option{WithKeyEncoding(cipher.KeyEncodingHex)},
+			setupMock: func(m *storagemock.Storage, alias, key string) {
+				encodedData := encodeForTest(t, dto.Secret{Message: "hex only"}, "46da5d3577209271242b42882a034c3d")
+				m.On("Fetch", alias).Return(encodedData, nil).Once()
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   resp.Error("Invalid key format"),
+		},
 		{
 			name:  "Error Secret Not Found",
 			alias: "f7ab603e-fbae-4182-8379-8763d9327d52",
 			key:   "46da5d3577209271242b42882a034c3d",
-			setupMock: func(m *MockSecretFetcher, alias, key string) {
+			setupMock: func(m *storagemock.Storage, alias, key string) {
 				m.On("Fetch", alias).Return(nil, nil).Once() // Simulate not found
 			},
 			expectedStatus: http.StatusNotFound,
 			expectedBody:   resp.Error("Secret not found"),
-			checkMockCalls: func(t *testing.T, m *MockSecretFetcher, alias string) {
+			checkMockCalls: func(t *testing.T, m *storagemock.Storage, alias string) {
 				m.AssertCalled(t, "Fetch", alias)
-				m.AssertNotCalled(t, "Delete", alias)
+				m.AssertNotCalled(t, "Consume", alias)
+			},
+		},
+		{
+			name:  "Error Storage Not Found Sentinel",
+			alias: "f7ab603e-fbae-4182-8379-8763d9327d53",
+			key:   "46da5d3577209271242b42882a034c3d",
+			setupMock: func(m *storagemock.Storage, alias, key string) {
+				m.On("Fetch", alias).Return(nil, fmt.Errorf("storage.redis.Fetch: %w", storage.ErrNotFound)).Once()
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   resp.Error("Secret not found"),
+			checkMockCalls: func(t *testing.T, m *storagemock.Storage, alias string) {
+				m.AssertNotCalled(t, "Consume", alias)
+			},
+		},
+		{
+			name:  "Error Storage Unavailable",
+			alias: "f7ab603e-fbae-4182-8379-8763d9327d53",
+			key:   "46da5d3577209271242b42882a034c3d",
+			setupMock: func(m *storagemock.Storage, alias, key string) {
+				m.On("Fetch", alias).Return(nil, fmt.Errorf("storage.redis.Fetch: %w", storage.ErrUnavailable)).Once()
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   resp.Error("Storage is unavailable"),
+			checkMockCalls: func(t *testing.T, m *storagemock.Storage, alias string) {
+				m.AssertNotCalled(t, "Consume", alias)
+			},
+		},
+		{
+			name:  "Error Storage Timeout",
+			alias: "f7ab603e-fbae-4182-8379-8763d9327d53",
+			key:   "46da5d3577209271242b42882a034c3d",
+			setupMock: func(m *storagemock.Storage, alias, key string) {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+				m.On("Fetch", alias).Run(func(mock.Arguments) {
+					defer cancel()
+					// Block like a backend that never answers
+					<-ctx.Done()
+				}).Return(nil, fmt.Errorf("storage.redis.Fetch: %w", context.DeadlineExceeded)).Once()
+			},
+			expectedStatus: http.StatusGatewayTimeout,
+			expectedBody:   resp.Error("Storage timed out"),
+			checkMockCalls: func(t *testing.T, m *storagemock.Storage, alias string) {
+				m.AssertNotCalled(t, "Consume", alias)
 			},
 		},
 		{
 			name:  "Error Fetch Failed",
 			alias: "f7ab603e-fbae-4182-8379-8763d9327d52",
 			key:   "46da5d3577209271242b42882a034c3d",
-			setupMock: func(m *MockSecretFetcher, alias, key string) {
+			setupMock: func(m *storagemock.Storage, alias, key string) {
 				m.On("Fetch", alias).Return(nil, errors.New("internal storage error")).Once()
 			},
 			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   resp.Error("internal storage error"),
-			checkMockCalls: func(t *testing.T, m *MockSecretFetcher, alias string) {
+			// Storage error text never reaches the client
+			expectedBody: resp.Error("Failed to fetch secret"),
+			checkMockCalls: func(t *testing.T, m *storagemock.Storage, alias string) {
 				m.AssertCalled(t, "Fetch", alias)
-				m.AssertNotCalled(t, "Delete", alias)
+				m.AssertNotCalled(t, "Consume", alias)
 			},
 		},
 		{
 			name:  "Error Unmarshal Failed (Bad Data)",
 			alias: "f7ab603e-fbae-4182-8379-8763d9327d5x",
 			key:   "46da5d3577209271242b42882a034c3d",
-			setupMock: func(m *MockSecretFetcher, alias, key string) {
+			setupMock: func(m *storagemock.Storage, alias, key string) {
 				// Encode some invalid JSON data
 				invalidJsonData := []byte(`{"message": "hello", "onetime": true`) // Missing closing brace
 				encodedData, err := cipher.Encode(invalidJsonData, key)
 				require.NoError(t, err)
 				m.On("Fetch", alias).Return(encodedData, nil).Once()
 			},
-			expectedStatus: http.StatusInternalServerError,
+			expectedStatus: http.StatusUnprocessableEntity,
 			expectedBody:   resp.Error("Secret unmarshalling failed"),
-			checkMockCalls: func(t *testing.T, m *MockSecretFetcher, alias string) {
+			checkMockCalls: func(t *testing.T, m *storagemock.Storage, alias string) {
 				m.AssertCalled(t, "Fetch", alias)
-				m.AssertNotCalled(t, "Delete", alias)
+				m.AssertNotCalled(t, "Consume", alias)
+			},
+		},
+		{
+			name:  "Error Unmarshal Failed (Wrong Field Type)",
+			alias: "f7ab603e-fbae-4182-8379-8763d9327d51",
+			key:   "46da5d3577209271242b42882a034c3d",
+			setupMock: func(m *storagemock.Storage, alias, key string) {
+				// Well-formed JSON that doesn't match dto.Secret
+				encodedData := testutil.BuildCiphertext(t, dto.Secret{}, key, testutil.WithPayload([]byte(`{"message": 42}`)))
+				m.On("Fetch", alias).Return(encodedData, nil).Once()
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody:   resp.Error("Secret unmarshalling failed"),
+			checkMockCalls: func(t *testing.T, m *storagemock.Storage, alias string) {
+				m.AssertNotCalled(t, "Consume", alias)
 			},
 		},
 		{
 			name:  "Error Decode Failed (Wrong Key)",
 			alias: "f7ab603e-fbae-4182-8379-8763d9327d51",
 			key:   "46da5d3577209271242b42882a034c3e", // Use a different key than encoding
-			setupMock: func(m *MockSecretFetcher, alias, key string) {
+			setupMock: func(m *storagemock.Storage, alias, key string) {
 				secretData := dto.Secret{Message: "cant decode this", OneTime: false}
 				// Encode with the *correct* key for storage
 				encodedData := encodeForTest(t, secretData, "46da5d3577209271242b42882a034c3d")
@@ -215,21 +349,21 @@ func TestFetchHandler(t *testing.T) {
 			// The actual error message comes from the cipher package, check your implementation
 			// For this test, we check the handler's generic error message.
 			expectedBody: resp.Error("Failed to decode secret"), // Note: Handler logs "Failed to encode", should be "decode"
-			checkMockCalls: func(t *testing.T, m *MockSecretFetcher, alias string) {
+			checkMockCalls: func(t *testing.T, m *storagemock.Storage, alias string) {
 				m.AssertCalled(t, "Fetch", alias)
-				m.AssertNotCalled(t, "Delete", alias)
+				m.AssertNotCalled(t, "Consume", alias)
 			},
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			mockFetcher := new(MockSecretFetcher)
+			mockFetcher := new(storagemock.Storage)
 			if tc.setupMock != nil {
 				tc.setupMock(mockFetcher, tc.alias, tc.key)
 			}
 
-			handler := New(log, mockFetcher)
+			handler := New(log, mockFetcher, tc.options...)
 
 			req := httptest.NewRequest(http.MethodGet, "/fetch/{alias}/{key}", nil)
 			// Add chi context with URL parameters
@@ -265,3 +399,1075 @@ func TestChiCtxHelper(t *testing.T) {
 	assert.Equal(t, keyVal, chi.URLParamFromCtx(ctx, "key"))
 	assert.Empty(t, chi.URLParamFromCtx(ctx, "nonexistent"))
 }
+
+func TestFetchHandlerLocalizedErrors(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "fetch"))
+
+	testCases := []struct {
+		acceptLanguage string
+		expectedBody   resp.Response
+	}{
+		{acceptLanguage: "", expectedBody: resp.Error("Secret not found")},
+		{acceptLanguage: "ru-RU,ru;q=0.9", expectedBody: resp.Error("Секрет не найден")},
+		{acceptLanguage: "de", expectedBody: resp.Error("Secret not found")},
+	}
+
+	for _, tc := range testCases {
+		t.Run("Accept-Language "+tc.acceptLanguage, func(t *testing.T) {
+			alias := "f7ab603e-fbae-4182-8379-8763d9327d52"
+			mockFetcher := new(storagemock.Storage)
+			mockFetcher.On("Fetch", alias).Return(nil, storage.ErrNotFound).Once()
+
+			req := httptest.NewRequest(http.MethodGet, "/fetch/{alias}/{key}", nil)
+			req = req.WithContext(chiCtx(alias, "46da5d3577209271242b42882a034c3d"))
+			req.Header.Set("Accept-Language", tc.acceptLanguage)
+
+			rr := httptest.NewRecorder()
+			New(log, mockFetcher).ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusNotFound, rr.Code)
+			expectedJson, err := json.Marshal(tc.expectedBody)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(expectedJson), rr.Body.String())
+		})
+	}
+}
+
+func TestFetchPostHandler(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "fetch"))
+
+	const (
+		alias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+		key   = "46da5d3577209271242b42882a034c3d"
+	)
+
+	testCases := []struct {
+		name           string
+		body           string
+		setupMock      func(m *storagemock.Storage)
+		expectedStatus int
+		expectedBody   interface{}
+	}{
+		{
+			name: "Success Fetch Regular Secret",
+			body: `{"alias":"` + alias + `","key":"` + key + `"}`,
+			setupMock: func(m *storagemock.Storage) {
+				encodedData := encodeForTest(t, dto.Secret{Message: "posted secret"}, key)
+				m.On("Fetch", alias).Return(encodedData, nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   Response{Response: resp.OK(), Message: "posted secret"},
+		},
+		{
+			name: "Success Fetch One-Time Secret Burns It",
+			body: `{"alias":"` + alias + `","key":"` + key + `"}`,
+			setupMock: func(m *storagemock.Storage) {
+				encodedData := encodeForTest(t, dto.Secret{Message: "burn after reading", OneTime: true}, key)
+				m.On("Fetch", alias).Return(encodedData, nil).Once()
+				m.On("Consume", alias).Return(encodedData, nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   Response{Response: resp.OK(), Message: "burn after reading", Consumed: true},
+		},
+		{
+			name: "Error One-Time Secret Already Burned",
+			body: `{"alias":"` + alias + `","key":"` + key + `"}`,
+			setupMock: func(m *storagemock.Storage) {
+				m.On("Fetch", alias).Return(nil, storage.ErrNotFound).Once()
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   resp.Error("Secret not found"),
+		},
+		{
+			name:           "Error Missing Key",
+			body:           `{"alias":"` + alias + `"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   resp.Error("Key parameter is missing"),
+		},
+		{
+			name:           "Error Invalid JSON",
+			body:           `{"alias":`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   resp.Error("Failed to read or decode request body."),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockFetcher := new(storagemock.Storage)
+			if tc.setupMock != nil {
+				tc.setupMock(mockFetcher)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/fetch", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			NewPost(log, mockFetcher).ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			expectedJson, err := json.Marshal(tc.expectedBody)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(expectedJson), rr.Body.String())
+
+			mockFetcher.AssertExpectations(t)
+		})
+	}
+}
+
+func TestFetchHandlerRotatesNonce(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "fetch"))
+
+	const (
+		alias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+		key   = "46da5d3577209271242b42882a034c3d"
+	)
+
+	// Keep the stored ciphertext in a variable so Replace behaves like storage
+	stored := encodeForTest(t, dto.Secret{Message: "multi view"}, key)
+	var history [][]byte
+
+	mockFetcher := new(storagemock.Storage)
+	mockFetcher.On("Replace", alias, mock.AnythingOfType("[]uint8"), mock.AnythingOfType("[]uint8")).Run(func(args mock.Arguments) {
+		require.Equal(t, stored, args.Get(1).([]byte), "rotation must be conditional on what was read")
+		stored = args.Get(2).([]byte)
+	}).Return(nil)
+
+	handler := New(log, mockFetcher, WithNonceRotation())
+
+	for range 3 {
+		history = append(history, stored)
+		mockFetcher.On("Fetch", alias).Return(stored, nil).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/fetch/{alias}/{key}", nil)
+		req = req.WithContext(chiCtx(alias, key))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		var body Response
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+		assert.Equal(t, "multi view", body.Message)
+	}
+
+	// Every view stored a different ciphertext for the same plaintext
+	for i := 0; i < len(history); i++ {
+		for j := i + 1; j < len(history); j++ {
+			assert.NotEqual(t, history[i], history[j])
+		}
+	}
+	mockFetcher.AssertNumberOfCalls(t, "Replace", 3)
+}
+
+type mockSender struct {
+	mock.Mock
+	sent chan struct{}
+}
+
+func (m *mockSender) Send(ctx context.Context, to, subject, body string) error {
+	args := m.Called(to, subject, body)
+	m.sent <- struct{}{}
+	return args.Error(0)
+}
+
+func TestFetchHandlerReadReceipt(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "fetch"))
+
+	const (
+		alias    = "f7ab603e-fbae-4182-8379-8763d9327d51"
+		key      = "46da5d3577209271242b42882a034c3d"
+		wrongKey = "00000000000000000000000000000000"
+	)
+
+	secret := dto.Secret{Message: "top secret", NotifyEmail: "owner@example.com"}
+
+	testCases := []struct {
+		name           string
+		key            string
+		expectedStatus int
+		expectReceipt  bool
+	}{
+		{name: "Receipt Sent On Read", key: key, expectedStatus: http.StatusOK, expectReceipt: true},
+		{name: "No Receipt On Failed Decrypt", key: wrongKey, expectedStatus: http.StatusInternalServerError, expectReceipt: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockFetcher := new(storagemock.Storage)
+			mockFetcher.On("Fetch", alias).Return(encodeForTest(t, secret, key), nil).Once()
+
+			sender := &mockSender{sent: make(chan struct{}, 1)}
+			sender.On("Send", "owner@example.com", mock.AnythingOfType("string"), mock.MatchedBy(func(body string) bool {
+				return !strings.Contains(body, "top secret")
+			})).Return(nil)
+
+			handler := New(log, mockFetcher, WithReadNotifier(notify.New(sender, time.Second, false)))
+
+			req := httptest.NewRequest(http.MethodGet, "/fetch/{alias}/{key}", nil)
+			req = req.WithContext(chiCtx(alias, tc.key))
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.expectedStatus, rr.Code)
+
+			if tc.expectReceipt {
+				select {
+				case <-sender.sent:
+				case <-time.After(time.Second):
+					require.FailNow(t, "read receipt was not sent")
+				}
+				sender.AssertExpectations(t)
+			} else {
+				select {
+				case <-sender.sent:
+					assert.Fail(t, "read receipt sent for a failed read")
+				case <-time.After(50 * time.Millisecond):
+				}
+				sender.AssertNotCalled(t, "Send", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestFetchHandlerCoalescesConcurrentReads(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "fetch"))
+
+	const (
+		alias   = "f7ab603e-fbae-4182-8379-8763d9327d51"
+		key     = "46da5d3577209271242b42882a034c3d"
+		readers = 20
+	)
+
+	release := make(chan struct{})
+	mockFetcher := new(storagemock.Storage)
+	mockFetcher.On("Fetch", alias).Run(func(mock.Arguments) {
+		// Hold the first read open so the others pile up behind it
+		<-release
+	}).Return(encodeForTest(t, dto.Secret{Message: "popular"}, key), nil)
+
+	handler := New(log, mockFetcher)
+
+	var wg sync.WaitGroup
+	codes := make(chan int, readers)
+	for range readers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "/fetch/{alias}/{key}", nil)
+			req = req.WithContext(chiCtx(alias, key))
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			codes <- rr.Code
+		}()
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(codes)
+
+	for code := range codes {
+		assert.Equal(t, http.StatusOK, code)
+	}
+	mockFetcher.AssertNumberOfCalls(t, "Fetch", 1)
+}
+
+func TestFetchHandlerEncoding(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "fetch"))
+
+	const (
+		alias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+		key   = "46da5d3577209271242b42882a034c3d"
+	)
+
+	// NUL, bell and escape are legal in a JSON string but mangled by many clients
+	message := "line1\x00\x07\x1b[0mline2"
+
+	testCases := []struct {
+		name             string
+		query            string
+		expectFetch      bool
+		expectedStatus   int
+		expectedMessage  string
+		expectedEncoding string
+	}{
+		{name: "Raw By Default", query: "", expectFetch: true, expectedStatus: http.StatusOK, expectedMessage: message},
+		{name: "Explicit Raw", query: "?encoding=raw", expectFetch: true, expectedStatus: http.StatusOK, expectedMessage: message},
+		{
+			name:             "Base64",
+			query:            "?encoding=base64",
+			expectFetch:      true,
+			expectedStatus:   http.StatusOK,
+			expectedMessage:  base64.StdEncoding.EncodeToString([]byte(message)),
+			expectedEncoding: "base64",
+		},
+		{name: "Unsupported Encoding", query: "?encoding=rot13", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockFetcher := new(storagemock.Storage)
+			if tc.expectFetch {
+				mockFetcher.On("Fetch", alias).Return(encodeForTest(t, dto.Secret{Message: message}, key), nil).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/"+alias+"/"+key+tc.query, nil)
+			req = req.WithContext(chiCtx(alias, key))
+			rr := httptest.NewRecorder()
+			New(log, mockFetcher).ServeHTTP(rr, req)
+
+			require.Equal(t, tc.expectedStatus, rr.Code)
+			mockFetcher.AssertExpectations(t)
+			if tc.expectedStatus != http.StatusOK {
+				mockFetcher.AssertNotCalled(t, "Fetch", alias)
+				return
+			}
+
+			var body Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+			assert.Equal(t, tc.expectedMessage, body.Message)
+			assert.Equal(t, tc.expectedEncoding, body.Encoding)
+			if tc.expectedEncoding == "base64" {
+				assert.NotContains(t, rr.Body.String(), `\u0000`, "base64 output must be plain ASCII")
+				decoded, err := base64.StdEncoding.DecodeString(body.Message)
+				require.NoError(t, err)
+				assert.Equal(t, message, string(decoded))
+			}
+		})
+	}
+}
+
+func TestFetchHandlerTombstones(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "fetch"))
+
+	const (
+		alias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+		key   = "46da5d3577209271242b42882a034c3d"
+	)
+
+	testCases := []struct {
+		name           string
+		options        []Option
+		tombstoned     bool
+		tombstoneErr   error
+		expectedStatus int
+		expectedBody   resp.Response
+	}{
+		{
+			name:           "Expired Secret Is Gone",
+			options:        []Option{WithTombstones()},
+			tombstoned:     true,
+			expectedStatus: http.StatusGone,
+			expectedBody:   resp.Error("Secret has expired"),
+		},
+		{
+			name:           "Unknown Secret Is Not Found",
+			options:        []Option{WithTombstones()},
+			tombstoned:     false,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   resp.Error("Secret not found"),
+		},
+		{
+			name:           "Tombstone Lookup Failure Falls Back To Not Found",
+			options:        []Option{WithTombstones()},
+			tombstoneErr:   storage.ErrUnavailable,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   resp.Error("Secret not found"),
+		},
+		{
+			name:           "Tombstones Disabled",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   resp.Error("Secret not found"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockFetcher := new(storagemock.Storage)
+			mockFetcher.On("Fetch", alias).Return(nil, storage.ErrNotFound).Once()
+			if len(tc.options) > 0 {
+				mockFetcher.On("Tombstoned", alias).Return(tc.tombstoned, tc.tombstoneErr).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/"+alias+"/"+key, nil)
+			req = req.WithContext(chiCtx(alias, key))
+			rr := httptest.NewRecorder()
+			New(log, mockFetcher, tc.options...).ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			var body resp.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+			assert.Equal(t, tc.expectedBody, body)
+			mockFetcher.AssertExpectations(t)
+		})
+	}
+}
+
+type recordingPublisher struct {
+	events []webhook.Event
+}
+
+func (p *recordingPublisher) Publish(event webhook.Event) bool {
+	p.events = append(p.events, event)
+	return true
+}
+
+func TestFetchHandlerPublishesReadEvent(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "fetch"))
+
+	const (
+		alias    = "f7ab603e-fbae-4182-8379-8763d9327d51"
+		key      = "46da5d3577209271242b42882a034c3d"
+		wrongKey = "00000000000000000000000000000000"
+	)
+
+	events := &recordingPublisher{}
+	mockFetcher := new(storagemock.Storage)
+	mockFetcher.On("Fetch", alias).Return(encodeForTest(t, dto.Secret{Message: "hi"}, key), nil)
+	handler := New(log, mockFetcher, WithEventPublisher(events))
+
+	for _, k := range []string{wrongKey, key} {
+		req := httptest.NewRequest(http.MethodGet, "/"+alias+"/"+k, nil)
+		req = req.WithContext(chiCtx(alias, k))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Only the successful read is published
+	require.Len(t, events.events, 1)
+	assert.Equal(t, webhook.EventSecretRead, events.events[0].Type)
+	assert.Equal(t, alias, events.events[0].Alias)
+}
+
+func TestFetchHandlerRunsPostFetchHooks(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "fetch"))
+
+	const (
+		alias    = "f7ab603e-fbae-4182-8379-8763d9327d51"
+		key      = "46da5d3577209271242b42882a034c3d"
+		wrongKey = "00000000000000000000000000000000"
+	)
+
+	var calls []string
+	hook := func(name string) PostFetchHook {
+		return PostFetchHookFunc(func(ctx context.Context, alias string) {
+			require.NotNil(t, ctx)
+			calls = append(calls, name+":"+alias)
+		})
+	}
+
+	mockFetcher := new(storagemock.Storage)
+	mockFetcher.On("Fetch", alias).Return(encodeForTest(t, dto.Secret{Message: "hi"}, key), nil)
+	handler := New(log, mockFetcher, WithPostFetchHooks(hook("first"), hook("second")))
+
+	for _, k := range []string{wrongKey, key} {
+		req := httptest.NewRequest(http.MethodGet, "/"+alias+"/"+k, nil)
+		req = req.WithContext(chiCtx(alias, k))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Only the successful read runs the hooks
+	assert.Equal(t, []string{"first:" + alias, "second:" + alias}, calls)
+}
+
+func TestFetchHandlerHonoursPayloadExpiry(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "fetch"))
+
+	const (
+		alias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+		key   = "46da5d3577209271242b42882a034c3d"
+	)
+
+	testCases := []struct {
+		name           string
+		secret         dto.Secret
+		expectedStatus int
+	}{
+		{
+			name:           "Expired But Still Stored",
+			secret:         dto.Secret{Message: "stale", ExpiresAt: time.Now().Add(-time.Second)},
+			expectedStatus: http.StatusGone,
+		},
+		{
+			name:           "Expired One-Time Secret Is Not Consumed",
+			secret:         dto.Secret{Message: "stale", OneTime: true, ExpiresAt: time.Now().Add(-time.Second)},
+			expectedStatus: http.StatusGone,
+		},
+		{
+			name:           "Not Yet Expired",
+			secret:         dto.Secret{Message: "fresh", ExpiresAt: time.Now().Add(time.Hour)},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Never Expires",
+			secret:         dto.Secret{Message: "forever"},
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockFetcher := new(storagemock.Storage)
+			mockFetcher.On("Fetch", alias).Return(encodeForTest(t, tc.secret, key), nil).Once()
+
+			req := httptest.NewRequest(http.MethodGet, "/"+alias+"/"+key, nil)
+			req = req.WithContext(chiCtx(alias, key))
+			rr := httptest.NewRecorder()
+			New(log, mockFetcher).ServeHTTP(rr, req)
+
+			require.Equal(t, tc.expectedStatus, rr.Code)
+			if tc.expectedStatus == http.StatusGone {
+				assert.NotContains(t, rr.Body.String(), tc.secret.Message)
+				var body resp.Response
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, resp.Error("Secret has expired"), body)
+			}
+			mockFetcher.AssertNotCalled(t, "Consume", alias)
+		})
+	}
+}
+
+func TestFetchHandlerRevealWindow(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "fetch"))
+
+	const (
+		alias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+		key   = "46da5d3577209271242b42882a034c3d"
+	)
+
+	testCases := []struct {
+		name           string
+		secret         dto.Secret
+		expectedStatus int
+		expectedError  string
+	}{
+		{
+			name:           "Before Window",
+			secret:         dto.Secret{Message: "early", OneTime: true, NotBefore: time.Now().Add(time.Hour)},
+			expectedStatus: http.StatusTooEarly,
+			expectedError:  "Secret is not available yet",
+		},
+		{
+			name:           "Within Window",
+			secret:         dto.Secret{Message: "on time", OneTime: true, NotBefore: time.Now().Add(-time.Minute), NotAfter: time.Now().Add(time.Hour)},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "After Window",
+			secret:         dto.Secret{Message: "late", OneTime: true, NotAfter: time.Now().Add(-time.Second)},
+			expectedStatus: http.StatusGone,
+			expectedError:  "Secret is no longer available",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockFetcher := new(storagemock.Storage)
+			mockFetcher.On("Fetch", alias).Return(encodeForTest(t, tc.secret, key), nil).Once()
+			if tc.expectedStatus == http.StatusOK {
+				mockFetcher.On("Consume", alias).Return(nil, nil).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/"+alias+"/"+key, nil)
+			req = req.WithContext(chiCtx(alias, key))
+			rr := httptest.NewRecorder()
+			New(log, mockFetcher).ServeHTTP(rr, req)
+
+			require.Equal(t, tc.expectedStatus, rr.Code)
+			if tc.expectedError != "" {
+				var body resp.Response
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, resp.Error(tc.expectedError), body)
+				assert.NotContains(t, rr.Body.String(), tc.secret.Message)
+				// Outside the window the one-time secret must survive
+				mockFetcher.AssertNotCalled(t, "Consume", alias)
+			}
+			if tc.expectedStatus == http.StatusTooEarly {
+				assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+			}
+			mockFetcher.AssertExpectations(t)
+		})
+	}
+}
+
+func TestFetchDownloadHandler(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "fetch"))
+
+	const (
+		alias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+		key   = "46da5d3577209271242b42882a034c3d"
+	)
+
+	testCases := []struct {
+		name                string
+		storedContentType   string
+		options             []Option
+		expectedContentType string
+	}{
+		{name: "Default Octet Stream", expectedContentType: "application/octet-stream"},
+		{name: "Allowed Content Type", storedContentType: "application/pdf", options: []Option{WithDownloadContentTypes("application/pdf")}, expectedContentType: "application/pdf"},
+		{name: "Allowed Content Type With Params", storedContentType: "Text/Plain; charset=utf-8", options: []Option{WithDownloadContentTypes("text/plain")}, expectedContentType: "text/plain; charset=utf-8"},
+		{name: "Unsafe Content Type Falls Back", storedContentType: "text/html", options: []Option{WithDownloadContentTypes("application/pdf")}, expectedContentType: "application/octet-stream"},
+		{name: "Not Allowed Without Config", storedContentType: "application/pdf", expectedContentType: "application/octet-stream"},
+		{name: "Malformed Content Type Falls Back", storedContentType: "text/html;;;", options: []Option{WithDownloadContentTypes("text/html")}, expectedContentType: "application/octet-stream"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			secret := dto.Secret{Message: "<script>alert(1)</script>", ContentType: tc.storedContentType}
+			mockFetcher := new(storagemock.Storage)
+			mockFetcher.On("Fetch", alias).Return(encodeForTest(t, secret, key), nil).Once()
+
+			req := httptest.NewRequest(http.MethodGet, "/"+alias+"/"+key+"/download", nil)
+			req = req.WithContext(chiCtx(alias, key))
+			rr := httptest.NewRecorder()
+			NewDownload(log, mockFetcher, tc.options...).ServeHTTP(rr, req)
+
+			require.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, "nosniff", rr.Header().Get("X-Content-Type-Options"))
+			assert.Equal(t, tc.expectedContentType, rr.Header().Get("Content-Type"))
+			assert.Equal(t, "attachment", rr.Header().Get("Content-Disposition"))
+			assert.Equal(t, secret.Message, rr.Body.String())
+			mockFetcher.AssertExpectations(t)
+		})
+	}
+}
+
+// failingWriter accepts the response headers but fails every write after
+// the first allowed ones, like a client that went away mid-stream.
+type failingWriter struct {
+	*httptest.ResponseRecorder
+	writesLeft int
+}
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	if w.writesLeft == 0 {
+		return 0, errors.New("broken pipe")
+	}
+	w.writesLeft--
+	return w.ResponseRecorder.Write(b)
+}
+
+func (w *failingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func TestFetchStreamHandler(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "fetch"))
+
+	const (
+		alias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+		key   = "46da5d3577209271242b42882a034c3d"
+	)
+
+	secret := dto.Secret{Message: "abcdefghij", OneTime: true}
+
+	newRequest := func(accept string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/"+alias+"/"+key+"/stream", nil)
+		req.Header.Set("Accept", accept)
+		return req.WithContext(chiCtx(alias, key))
+	}
+
+	t.Run("Chunks Streamed Then Burned", func(t *testing.T) {
+		mockFetcher := new(storagemock.Storage)
+		mockFetcher.On("Fetch", alias).Return(encodeForTest(t, secret, key), nil).Once()
+		mockFetcher.On("Consume", alias).Return([]byte("x"), nil).Once()
+
+		rr := httptest.NewRecorder()
+		NewStream(log, mockFetcher, WithStreamChunkSize(4)).ServeHTTP(rr, newRequest("text/event-stream"))
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "text/event-stream", rr.Header().Get("Content-Type"))
+		assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+		expected := "event: chunk\ndata: YWJjZA==\n\n" + // abcd
+			"event: chunk\ndata: ZWZnaA==\n\n" + // efgh
+			"event: chunk\ndata: aWo=\n\n" + // ij
+			"event: done\ndata: \n\n"
+		assert.Equal(t, expected, rr.Body.String())
+		mockFetcher.AssertExpectations(t)
+	})
+
+	t.Run("Broken Stream Keeps Secret", func(t *testing.T) {
+		mockFetcher := new(storagemock.Storage)
+		mockFetcher.On("Fetch", alias).Return(encodeForTest(t, secret, key), nil).Once()
+
+		w := &failingWriter{ResponseRecorder: httptest.NewRecorder(), writesLeft: 1}
+		NewStream(log, mockFetcher, WithStreamChunkSize(4)).ServeHTTP(w, newRequest("text/event-stream"))
+
+		assert.Equal(t, "event: chunk\ndata: YWJjZA==\n\n", w.Body.String())
+		mockFetcher.AssertNotCalled(t, "Consume", alias)
+	})
+
+	t.Run("Lost Burn Race Sends No Done", func(t *testing.T) {
+		mockFetcher := new(storagemock.Storage)
+		mockFetcher.On("Fetch", alias).Return(encodeForTest(t, secret, key), nil).Once()
+		mockFetcher.On("Consume", alias).Return(nil, storage.ErrNotFound).Once()
+
+		rr := httptest.NewRecorder()
+		NewStream(log, mockFetcher, WithStreamChunkSize(4)).ServeHTTP(rr, newRequest("text/event-stream"))
+
+		assert.True(t, strings.HasSuffix(rr.Body.String(), "event: error\ndata: Secret not found\n\n"))
+		assert.NotContains(t, rr.Body.String(), "event: done")
+		mockFetcher.AssertExpectations(t)
+	})
+
+	t.Run("Requires Event Stream Accept", func(t *testing.T) {
+		mockFetcher := new(storagemock.Storage)
+
+		rr := httptest.NewRecorder()
+		NewStream(log, mockFetcher).ServeHTTP(rr, newRequest("application/json"))
+
+		assert.Equal(t, http.StatusNotAcceptable, rr.Code)
+		mockFetcher.AssertNotCalled(t, "Fetch", alias)
+	})
+}
+
+func TestFetchHandlerUUIDAliases(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "fetch"))
+
+	const (
+		alias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+		key   = "46da5d3577209271242b42882a034c3d"
+	)
+
+	testCases := []struct {
+		name           string
+		alias          string
+		opts           []Option
+		storedAs       string
+		expectedStatus int
+	}{
+		{name: "Valid UUID", alias: alias, opts: []Option{WithUUIDAliases()}, storedAs: alias, expectedStatus: http.StatusOK},
+		{name: "Upper Case UUID Is Normalized", alias: strings.ToUpper(alias), opts: []Option{WithUUIDAliases()}, storedAs: alias, expectedStatus: http.StatusOK},
+		{name: "Malformed Alias Rejected", alias: "not-a-uuid", opts: []Option{WithUUIDAliases()}, expectedStatus: http.StatusBadRequest},
+		{name: "Custom Alias Mode Allows Any Alias", alias: "my-custom-alias", storedAs: "my-custom-alias", expectedStatus: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockFetcher := new(storagemock.Storage)
+			if tc.storedAs != "" {
+				mockFetcher.On("Fetch", tc.storedAs).Return(encodeForTest(t, dto.Secret{Message: "s"}, key), nil).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/"+tc.alias+"/"+key, nil)
+			req = req.WithContext(chiCtx(tc.alias, key))
+			rr := httptest.NewRecorder()
+			New(log, mockFetcher, tc.opts...).ServeHTTP(rr, req)
+
+			require.Equal(t, tc.expectedStatus, rr.Code, rr.Body.String())
+			if tc.expectedStatus == http.StatusBadRequest {
+				var body resp.Response
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, resp.Error("Invalid alias format"), body)
+			}
+			mockFetcher.AssertExpectations(t)
+		})
+	}
+}
+
+func TestFetchHandlerConsumed(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "fetch"))
+
+	const (
+		alias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+		key   = "46da5d3577209271242b42882a034c3d"
+	)
+
+	testCases := []struct {
+		name             string
+		secret           dto.Secret
+		expectedConsumed bool
+	}{
+		{name: "One-Time Secret Is Consumed", secret: dto.Secret{Message: "once", OneTime: true}, expectedConsumed: true},
+		{name: "Persistent Secret Remains", secret: dto.Secret{Message: "kept"}, expectedConsumed: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			encodedData := encodeForTest(t, tc.secret, key)
+			mockFetcher := new(storagemock.Storage)
+			mockFetcher.On("Fetch", alias).Return(encodedData, nil).Once()
+			if tc.secret.OneTime {
+				mockFetcher.On("Consume", alias).Return(encodedData, nil).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/"+alias+"/"+key, nil)
+			req = req.WithContext(chiCtx(alias, key))
+			rr := httptest.NewRecorder()
+			New(log, mockFetcher).ServeHTTP(rr, req)
+
+			require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+			// The flag is always sent, false is an answer too
+			var body map[string]any
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+			assert.Equal(t, tc.expectedConsumed, body["consumed"])
+			mockFetcher.AssertExpectations(t)
+		})
+	}
+}
+
+func TestFetchHandlerPassword(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "fetch"))
+
+	const (
+		alias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+		key   = "46da5d3577209271242b42882a034c3d"
+	)
+
+	sealed, salt, err := cipher.SealWithPassword([]byte("behind a password"), "correct horse")
+	require.NoError(t, err)
+	encodedData := encodeForTest(t, dto.Secret{
+		Message:      base64.StdEncoding.EncodeToString(sealed),
+		PasswordSalt: salt,
+		OneTime:      true,
+	}, key)
+
+	testCases := []struct {
+		name           string
+		post           bool
+		password       string
+		expectedStatus int
+		expectedBody   any
+	}{
+		{
+			name:           "Password In Header",
+			password:       "correct horse",
+			expectedStatus: http.StatusOK,
+			expectedBody:   Response{Response: resp.OK(), Message: "behind a password", Consumed: true},
+		},
+		{
+			name:           "Password In Body",
+			post:           true,
+			password:       "correct horse",
+			expectedStatus: http.StatusOK,
+			expectedBody:   Response{Response: resp.OK(), Message: "behind a password", Consumed: true},
+		},
+		{
+			name:           "Missing Password Keeps Secret",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   resp.Error("Password required"),
+		},
+		{
+			name:           "Wrong Password Keeps Secret",
+			password:       "battery staple",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   resp.Error("Wrong password"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockFetcher := new(storagemock.Storage)
+			mockFetcher.On("Fetch", alias).Return(encodedData, nil).Once()
+			if tc.expectedStatus == http.StatusOK {
+				mockFetcher.On("Consume", alias).Return(encodedData, nil).Once()
+			}
+
+			rr := httptest.NewRecorder()
+			if tc.post {
+				body, err := json.Marshal(Request{Alias: alias, Key: key, Password: tc.password})
+				require.NoError(t, err)
+				NewPost(log, mockFetcher).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/fetch", strings.NewReader(string(body))))
+			} else {
+				req := httptest.NewRequest(http.MethodGet, "/"+alias+"/"+key, nil)
+				req = req.WithContext(chiCtx(alias, key))
+				if tc.password != "" {
+					req.Header.Set(PasswordHeader, tc.password)
+				}
+				New(log, mockFetcher).ServeHTTP(rr, req)
+			}
+
+			require.Equal(t, tc.expectedStatus, rr.Code, rr.Body.String())
+			expectedJson, err := json.Marshal(tc.expectedBody)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(expectedJson), rr.Body.String())
+			mockFetcher.AssertExpectations(t)
+			if tc.expectedStatus != http.StatusOK {
+				mockFetcher.AssertNotCalled(t, "Consume", alias)
+			}
+		})
+	}
+}
+
+func TestFetchHandlerPasswordAttemptLimit(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "fetch"))
+
+	const (
+		alias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+		key   = "46da5d3577209271242b42882a034c3d"
+	)
+
+	sealed, salt, err := cipher.SealWithPassword([]byte("behind a password"), "correct horse")
+	require.NoError(t, err)
+	encodedData := encodeForTest(t, dto.Secret{
+		Message:      base64.StdEncoding.EncodeToString(sealed),
+		PasswordSalt: salt,
+	}, key)
+
+	testCases := []struct {
+		name               string
+		allowed            bool
+		retryAfter         time.Duration
+		limitErr           error
+		expectedStatus     int
+		expectedBody       any
+		expectedRetryAfter string
+	}{
+		{
+			name:           "Attempt Within Limit",
+			allowed:        true,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   resp.Error("Wrong password"),
+		},
+		{
+			name:               "Attempt Over Limit",
+			retryAfter:         1500 * time.Millisecond,
+			expectedStatus:     http.StatusTooManyRequests,
+			expectedBody:       resp.Error("Too many password attempts, try again later"),
+			expectedRetryAfter: "2",
+		},
+		{
+			name:           "Storage Unavailable",
+			limitErr:       storage.ErrUnavailable,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   resp.Error("Storage is unavailable"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockFetcher := new(storagemock.Storage)
+			mockFetcher.On("Fetch", alias).Return(encodedData, nil).Once()
+			mockFetcher.On("Allow", "password:"+alias, 3, time.Hour).Return(tc.allowed, tc.retryAfter, tc.limitErr).Once()
+
+			req := httptest.NewRequest(http.MethodGet, "/"+alias+"/"+key, nil)
+			req = req.WithContext(chiCtx(alias, key))
+			req.Header.Set(PasswordHeader, "battery staple")
+			rr := httptest.NewRecorder()
+			New(log, mockFetcher, WithPasswordAttemptLimit(mockFetcher, 3, time.Hour)).ServeHTTP(rr, req)
+
+			require.Equal(t, tc.expectedStatus, rr.Code, rr.Body.String())
+			expectedJson, err := json.Marshal(tc.expectedBody)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(expectedJson), rr.Body.String())
+			assert.Equal(t, tc.expectedRetryAfter, rr.Header().Get("Retry-After"))
+			mockFetcher.AssertExpectations(t)
+		})
+	}
+}
+
+func TestFetchHandlerMaxViews(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "fetch"))
+
+	const (
+		alias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+		key   = "46da5d3577209271242b42882a034c3d"
+	)
+
+	encodedData := encodeForTest(t, dto.Secret{Message: "three times", MaxViews: 3}, key)
+
+	steps := []struct {
+		name             string
+		views            int
+		expectedStatus   int
+		expectedConsumed bool
+		expectDelete     bool
+	}{
+		{name: "First View Keeps Secret", views: 1, expectedStatus: http.StatusOK},
+		{name: "Second View Keeps Secret", views: 2, expectedStatus: http.StatusOK},
+		{name: "Third View Deletes Secret", views: 3, expectedStatus: http.StatusOK, expectedConsumed: true, expectDelete: true},
+		{name: "Concurrent Read Past Limit Not Found", views: 4, expectedStatus: http.StatusNotFound},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			mockFetcher := new(storagemock.Storage)
+			mockFetcher.On("Fetch", alias).Return(encodedData, nil).Once()
+			mockFetcher.On("CountView", alias).Return(step.views, nil).Once()
+			if step.expectDelete {
+				mockFetcher.On("Delete", alias).Return(nil).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/"+alias+"/"+key, nil)
+			req = req.WithContext(chiCtx(alias, key))
+			rr := httptest.NewRecorder()
+			New(log, mockFetcher).ServeHTTP(rr, req)
+
+			require.Equal(t, step.expectedStatus, rr.Code, rr.Body.String())
+			if step.expectedStatus == http.StatusOK {
+				var body map[string]any
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, "three times", body["message"])
+				assert.Equal(t, step.expectedConsumed, body["consumed"])
+			}
+			mockFetcher.AssertExpectations(t)
+			if !step.expectDelete {
+				mockFetcher.AssertNotCalled(t, "Delete", alias)
+			}
+		})
+	}
+}
+
+func TestFetchContentQRHandler(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "fetch"))
+
+	const (
+		alias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+		key   = "46da5d3577209271242b42882a034c3d"
+	)
+
+	testCases := []struct {
+		name           string
+		secret         dto.Secret
+		expectedStatus int
+		expectBurn     bool
+	}{
+		{
+			name:           "Small One-Time Secret Rendered And Burned",
+			secret:         dto.Secret{Message: "otpauth://totp/demo?secret=JBSWY3DPEHPK3PXP", OneTime: true},
+			expectedStatus: http.StatusOK,
+			expectBurn:     true,
+		},
+		{
+			name:           "Small Multi-View Secret Rendered",
+			secret:         dto.Secret{Message: "JBSWY3DPEHPK3PXP"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Over-Large Secret Rejected And Kept",
+			secret:         dto.Secret{Message: strings.Repeat("x", 65), OneTime: true},
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockFetcher := new(storagemock.Storage)
+			mockFetcher.On("Fetch", alias).Return(encodeForTest(t, tc.secret, key), nil).Once()
+			if tc.expectBurn {
+				mockFetcher.On("Consume", alias).Return([]byte("x"), nil).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/"+alias+"/"+key+"/content-qr", nil)
+			req = req.WithContext(chiCtx(alias, key))
+			rr := httptest.NewRecorder()
+			NewContentQR(log, mockFetcher, WithContentQRMaxBytes(64)).ServeHTTP(rr, req)
+
+			require.Equal(t, tc.expectedStatus, rr.Code, rr.Body.String())
+			if tc.expectedStatus == http.StatusOK {
+				assert.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+				assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+				_, err := png.Decode(rr.Body)
+				require.NoError(t, err)
+			} else {
+				var body resp.Response
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, resp.Error("Secret is too large for a QR code"), body)
+			}
+			if !tc.expectBurn {
+				mockFetcher.AssertNotCalled(t, "Consume", alias)
+			}
+			mockFetcher.AssertExpectations(t)
+		})
+	}
+}
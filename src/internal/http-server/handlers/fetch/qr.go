@@ -0,0 +1,84 @@
+package fetch
+
+import (
+	"log/slog"
+	"net/http"
+	resp "yoopass-api/internal/http-server/handlers/response"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/skip2/go-qrcode"
+)
+
+// defaultContentQRMaxBytes is the largest message rendered as a QR code
+// unless WithContentQRMaxBytes says otherwise. Denser codes are hard to
+// scan off a screen.
+const defaultContentQRMaxBytes = 512
+
+// contentQRSize is the width and height of content QR codes in pixels.
+const contentQRSize = 256
+
+// WithContentQRMaxBytes sets the largest message NewContentQR renders.
+func WithContentQRMaxBytes(max int) Option {
+	return func(o *options) {
+		o.contentQRMaxBytes = max
+	}
+}
+
+// NewContentQR serves GET /{alias}/{key}/content-qr, which returns the
+// message itself as a QR code PNG, for secrets such as TOTP seeds that are
+// meant to be scanned. Only small messages qualify, see
+// WithContentQRMaxBytes. A one-time secret is only burned once its code
+// was rendered.
+func NewContentQR(log *slog.Logger, secretFetcher SecretFetcher, opts ...Option) http.HandlerFunc {
+	h := newHandler(secretFetcher, opts)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.fetch.NewContentQR"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		h.traced(r.Context()).contentQR(w, r, log, chi.URLParam(r, "alias"), chi.URLParam(r, "key"))
+	}
+}
+
+// contentQR writes the secret stored under alias as a QR code PNG.
+func (h *handler) contentQR(w http.ResponseWriter, r *http.Request, log *slog.Logger, alias, key string) {
+	o, ok := h.load(w, r, log, alias, key)
+	if !ok {
+		return
+	}
+
+	maxBytes := h.opts.contentQRMaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultContentQRMaxBytes
+	}
+	if len(o.secret.Message) > maxBytes {
+		log.Info("Secret is too large for a QR code", slog.Int("size", len(o.secret.Message)))
+		resp.RenderError(w, r, http.StatusRequestEntityTooLarge, "Secret is too large for a QR code")
+		return
+	}
+
+	png, err := qrcode.Encode(o.secret.Message, qrcode.Medium, contentQRSize)
+	if err != nil {
+		log.Error("Failed to render QR code", slog.Any("error", err))
+		resp.RenderError(w, r, http.StatusInternalServerError, "Failed to render QR code")
+		return
+	}
+
+	// Burn only now, a secret whose code failed to render must stay
+	if !h.settle(w, r, log, &o) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(png); err != nil {
+		log.Warn("Failed to write QR code", slog.Any("error", err))
+	}
+}
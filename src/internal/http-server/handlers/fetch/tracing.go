@@ -0,0 +1,107 @@
+package fetch
+
+import (
+	"context"
+	"time"
+	"yoopass-api/internal/storage"
+	"yoopass-api/internal/telemetry"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithTracer runs every storage call of a reveal in a span of its own,
+// below the span of the request.
+func WithTracer(tracer trace.Tracer) Option {
+	return func(o *options) {
+		o.tracer = tracer
+	}
+}
+
+// traced returns h with its storage calls traced as children of ctx, or h
+// itself when no tracer is set.
+func (h *handler) traced(ctx context.Context) *handler {
+	if h.opts.tracer == nil || h.secretFetcher == nil {
+		return h
+	}
+	c := *h
+	c.secretFetcher = tracedFetcher{ctx: ctx, tracer: h.opts.tracer, next: h.secretFetcher}
+	return &c
+}
+
+type tracedFetcher struct {
+	ctx    context.Context
+	tracer trace.Tracer
+	next   SecretFetcher
+}
+
+func (f tracedFetcher) Fetch(key string) (value []byte, err error) {
+	err = telemetry.Run(f.ctx, f.tracer, "Fetch", func() error {
+		value, err = f.next.Fetch(key)
+		return err
+	})
+	return value, err
+}
+
+func (f tracedFetcher) Consume(key string) (value []byte, err error) {
+	err = telemetry.Run(f.ctx, f.tracer, "Consume", func() error {
+		value, err = f.next.Consume(key)
+		return err
+	})
+	return value, err
+}
+
+func (f tracedFetcher) Replace(key string, old, value []byte) error {
+	return telemetry.Run(f.ctx, f.tracer, "Replace", func() error {
+		return f.next.Replace(key, old, value)
+	})
+}
+
+func (f tracedFetcher) Tombstoned(key string) (tombstoned bool, err error) {
+	err = telemetry.Run(f.ctx, f.tracer, "Tombstoned", func() error {
+		tombstoned, err = f.next.Tombstoned(key)
+		return err
+	})
+	return tombstoned, err
+}
+
+func (f tracedFetcher) ConsumeApproval(key string) (approved bool, err error) {
+	err = telemetry.Run(f.ctx, f.tracer, "ConsumeApproval", func() error {
+		approved, err = f.next.ConsumeApproval(key)
+		return err
+	})
+	return approved, err
+}
+
+func (f tracedFetcher) RecordRead(key string, read storage.Read, max int) error {
+	return telemetry.Run(f.ctx, f.tracer, "RecordRead", func() error {
+		return f.next.RecordRead(key, read, max)
+	})
+}
+
+func (f tracedFetcher) SetGrace(key, holder string, value []byte, ttl time.Duration) error {
+	return telemetry.Run(f.ctx, f.tracer, "SetGrace", func() error {
+		return f.next.SetGrace(key, holder, value, ttl)
+	})
+}
+
+func (f tracedFetcher) Grace(key, holder string) (value []byte, err error) {
+	err = telemetry.Run(f.ctx, f.tracer, "Grace", func() error {
+		value, err = f.next.Grace(key, holder)
+		return err
+	})
+	return value, err
+}
+
+func (f tracedFetcher) CountView(key string) (views int, err error) {
+	err = telemetry.Run(f.ctx, f.tracer, "CountView", func() error {
+		views, err = f.next.CountView(key)
+		return err
+	})
+	return views, err
+}
+
+func (f tracedFetcher) Delete(key string) error {
+	return telemetry.Run(f.ctx, f.tracer, "Delete", func() error {
+		return f.next.Delete(key)
+	})
+}
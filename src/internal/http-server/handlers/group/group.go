@@ -0,0 +1,70 @@
+package group
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"yoopass-api/internal/http-server/handlers/response"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/storage"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+)
+
+type Response struct {
+	response.Response
+	GroupID string `json:"group_id"`
+	Deleted int    `json:"deleted"`
+}
+
+type GroupDeleter interface {
+	// this matches call in storage
+	DeleteGroup(group string) (int, error)
+}
+
+// NewDelete serves DELETE /group/{id}, burning every secret saved with that
+// group id in one transaction. The route must sit behind authentication.
+func NewDelete(log *slog.Logger, groupDeleter GroupDeleter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.group.NewDelete"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			log.Info("Group id is missing")
+			resp.RenderError(w, r, http.StatusBadRequest, "Group id is missing")
+			return
+		}
+
+		deleted, err := groupDeleter.DeleteGroup(id)
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Info("Group not found", slog.String("group_id", id))
+			resp.RenderError(w, r, http.StatusNotFound, "Group not found")
+			return
+		}
+		if status, msg, ok := resp.FromStorageError(err); ok {
+			log.Error("Failed to delete group", slog.String("group_id", id), slog.Any("error", err))
+			resp.RenderError(w, r, status, msg)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to delete group", slog.String("group_id", id), slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to delete group")
+			return
+		}
+
+		log.Info("Group deleted", slog.String("group_id", id), slog.Int("deleted", deleted))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			GroupID:  id,
+			Deleted:  deleted,
+		})
+	}
+}
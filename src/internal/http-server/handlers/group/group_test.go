@@ -0,0 +1,96 @@
+package group
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/storage"
+	"yoopass-api/internal/storage/storagemock"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteGroupHandler(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "group"))
+
+	testCases := []struct {
+		name           string
+		id             string
+		setupMock      func(m *storagemock.Storage)
+		expectedStatus int
+		expectedBody   interface{}
+	}{
+		{
+			name: "Success Delete Group",
+			id:   "deploy-2024",
+			setupMock: func(m *storagemock.Storage) {
+				m.On("DeleteGroup", "deploy-2024").Return(3, nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   Response{Response: resp.OK(), GroupID: "deploy-2024", Deleted: 3},
+		},
+		{
+			name: "Error Group Not Found",
+			id:   "missing",
+			setupMock: func(m *storagemock.Storage) {
+				m.On("DeleteGroup", "missing").Return(0, storage.ErrNotFound).Once()
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   resp.Error("Group not found"),
+		},
+		{
+			name: "Error Storage Unavailable",
+			id:   "deploy-2024",
+			setupMock: func(m *storagemock.Storage) {
+				m.On("DeleteGroup", "deploy-2024").Return(0, storage.ErrUnavailable).Once()
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   resp.Error("Storage is unavailable"),
+		},
+		{
+			name: "Error Generic Storage Failure",
+			id:   "deploy-2024",
+			setupMock: func(m *storagemock.Storage) {
+				m.On("DeleteGroup", "deploy-2024").Return(0, errors.New("boom")).Once()
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   resp.Error("Failed to delete group"),
+		},
+		{
+			name:           "Error Missing Id",
+			id:             "",
+			setupMock:      func(m *storagemock.Storage) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   resp.Error("Group id is missing"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStorage := new(storagemock.Storage)
+			tc.setupMock(mockStorage)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tc.id)
+			req := httptest.NewRequest(http.MethodDelete, "/group/"+tc.id, nil)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			rr := httptest.NewRecorder()
+
+			NewDelete(log, mockStorage).ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			expectedJson, err := json.Marshal(tc.expectedBody)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(expectedJson), rr.Body.String())
+			mockStorage.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package health
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	resp "yoopass-api/internal/http-server/handlers/response"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+)
+
+type Pinger interface {
+	// this matches call in storage
+	Ping(ctx context.Context) error
+}
+
+// New serves GET /healthz. The instance is healthy while storage answers a
+// ping, otherwise it reports 503 so the orchestrator can restart it.
+func New(log *slog.Logger, pinger Pinger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.health.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		w.Header().Set("Cache-Control", "no-store")
+
+		if err := pinger.Ping(r.Context()); err != nil {
+			log.Error("Storage ping failed", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusServiceUnavailable, "redis unreachable")
+			return
+		}
+
+		render.JSON(w, r, resp.OK())
+	}
+}
@@ -0,0 +1,62 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"yoopass-api/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePinger struct {
+	err error
+}
+
+func (f fakePinger) Ping(ctx context.Context) error {
+	return f.err
+}
+
+func TestHealthHandler(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "health"))
+
+	testCases := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Storage Answers",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"OK"}`,
+		},
+		{
+			name:           "Storage Unavailable",
+			err:            storage.ErrUnavailable,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   `{"status":"ERROR","type":"error","error":"redis unreachable"}`,
+		},
+		{
+			name:           "Storage Fails",
+			err:            errors.New("NOAUTH Authentication required"),
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   `{"status":"ERROR","type":"error","error":"redis unreachable"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			New(log, fakePinger{err: tc.err}).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String())
+			assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+		})
+	}
+}
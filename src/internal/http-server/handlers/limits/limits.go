@@ -0,0 +1,52 @@
+package limits
+
+import (
+	"log/slog"
+	"net/http"
+	"yoopass-api/internal/http-server/handlers/response"
+	resp "yoopass-api/internal/http-server/handlers/response"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+)
+
+// maxAge is how long clients and proxies may cache the limits. They only
+// change with a config reload, which needs a restart anyway.
+const maxAge = "public, max-age=300"
+
+// Limits is the save policy a client has to respect. Zero maxima mean the
+// server enforces no limit.
+type Limits struct {
+	MaxSecretBytes    int64    `json:"max_secret_bytes"`
+	MaxTTLHours       int      `json:"max_ttl_hours"`
+	MinTTLSeconds     int      `json:"min_ttl_seconds"`
+	OneTimeAllowed    bool     `json:"one_time_allowed"`
+	PassphraseAllowed bool     `json:"passphrase_allowed"`
+	AllowedCiphers    []string `json:"allowed_ciphers"`
+}
+
+type Response struct {
+	response.Response
+	Limits
+}
+
+// New serves GET /limits so frontends can build their forms without
+// hard-coding server policy.
+func New(log *slog.Logger, limits Limits) http.HandlerFunc {
+	body := Response{
+		Response: resp.OK(),
+		Limits:   limits,
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.limits.New"
+
+		log.Debug("Serving limits",
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		w.Header().Set("Cache-Control", maxAge)
+		render.JSON(w, r, body)
+	}
+}
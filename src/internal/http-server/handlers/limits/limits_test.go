@@ -0,0 +1,38 @@
+package limits
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitsHandler(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	handler := New(log, Limits{
+		MaxSecretBytes:    4096,
+		MaxTTLHours:       720,
+		OneTimeAllowed:    true,
+		PassphraseAllowed: false,
+		AllowedCiphers:    []string{"AES-128-GCM"},
+	})
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/limits", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "public, max-age=300", rr.Header().Get("Cache-Control"))
+	assert.JSONEq(t, `{
+		"status": "OK",
+		"max_secret_bytes": 4096,
+		"max_ttl_hours": 720,
+		"min_ttl_seconds": 0,
+		"one_time_allowed": true,
+		"passphrase_allowed": false,
+		"allowed_ciphers": ["AES-128-GCM"]
+	}`, rr.Body.String())
+}
@@ -0,0 +1,106 @@
+package meta
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"yoopass-api/internal/http-server/handlers/response"
+	resp "yoopass-api/internal/http-server/handlers/response"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+)
+
+// ttlBucket is the granularity of the TTL used for the ETag, so that polling
+// clients only see a change when the expiry moves by a full bucket.
+const ttlBucket = time.Minute
+
+type Response struct {
+	response.Response
+	Alias     string `json:"alias"`
+	Expires   bool   `json:"expires"`
+	ExpiresIn int64  `json:"expires_in,omitempty"`
+}
+
+type MetaReader interface {
+	// this matches call in storage
+	TTL(key string) (time.Duration, error)
+}
+
+// New serves GET /{alias}/meta. It never reads or burns the secret itself, so
+// it is safe to poll and supports conditional requests via ETag.
+func New(log *slog.Logger, metaReader MetaReader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.meta.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.Info("Alias parameter is missing")
+			resp.RenderError(w, r, http.StatusBadRequest, "Alias parameter is missing")
+			return
+		}
+
+		ttl, err := metaReader.TTL(alias)
+		if status, msg, ok := resp.FromStorageError(err); ok {
+			log.Info("Failed to read secret metadata", slog.String("alias", alias), slog.Any("error", err))
+			resp.RenderError(w, r, status, msg)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to read secret metadata", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to read secret metadata")
+			return
+		}
+
+		bucket := ttl.Truncate(ttlBucket)
+		etag := computeETag(bucket)
+
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "no-cache")
+
+		if matchesETag(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Response:  resp.OK(),
+			Alias:     alias,
+			Expires:   ttl > 0,
+			ExpiresIn: int64(bucket / time.Second),
+		})
+	}
+}
+
+// computeETag derives a weak ETag from the metadata that clients poll for.
+func computeETag(ttl time.Duration) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("ttl=%d", ttl/ttlBucket)))
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// matchesETag reports whether an If-None-Match header value matches etag,
+// using the weak comparison RFC 9110 prescribes for If-None-Match.
+func matchesETag(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,96 @@
+package meta
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/storage"
+	"yoopass-api/internal/storage/storagemock"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const alias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+
+// Helper to build a metadata request for alias with an optional If-None-Match
+func newMetaRequest(ifNoneMatch string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("alias", alias)
+
+	req := httptest.NewRequest(http.MethodGet, "/"+alias+"/meta", nil)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	return req
+}
+
+func TestMetaHandlerConditionalRequests(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "meta"))
+
+	mockStorage := new(storagemock.Storage)
+	handler := New(log, mockStorage)
+
+	// First poll: full body and an ETag
+	mockStorage.On("TTL", alias).Return(90*time.Minute+20*time.Second, nil).Once()
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newMetaRequest(""))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	etag := rr.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	expectedJson, err := json.Marshal(Response{
+		Response:  resp.OK(),
+		Alias:     alias,
+		Expires:   true,
+		ExpiresIn: int64((90 * time.Minute).Seconds()),
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expectedJson), rr.Body.String())
+
+	// Second poll within the same TTL bucket: 304 without a body
+	mockStorage.On("TTL", alias).Return(90*time.Minute+5*time.Second, nil).Once()
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, newMetaRequest(etag))
+
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Empty(t, rr.Body.String())
+	assert.Equal(t, etag, rr.Header().Get("ETag"))
+
+	// Once the TTL moves to the next bucket the metadata is sent again
+	mockStorage.On("TTL", alias).Return(89*time.Minute, nil).Once()
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, newMetaRequest(etag))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotEqual(t, etag, rr.Header().Get("ETag"))
+
+	mockStorage.AssertExpectations(t)
+	mockStorage.AssertNotCalled(t, "Fetch", alias)
+	mockStorage.AssertNotCalled(t, "Consume", alias)
+}
+
+func TestMetaHandlerNotFound(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "meta"))
+
+	mockStorage := new(storagemock.Storage)
+	mockStorage.On("TTL", alias).Return(time.Duration(0), storage.ErrNotFound).Once()
+
+	rr := httptest.NewRecorder()
+	New(log, mockStorage).ServeHTTP(rr, newMetaRequest(""))
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Empty(t, rr.Header().Get("ETag"))
+	expectedJson, err := json.Marshal(resp.Error("Secret not found"))
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expectedJson), rr.Body.String())
+}
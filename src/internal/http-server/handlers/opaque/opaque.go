@@ -0,0 +1,88 @@
+package opaque
+
+import (
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"yoopass-api/internal/http-server/handlers/response"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/storage"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+)
+
+type Response struct {
+	response.Response
+	Alias string `json:"alias"`
+	// Ciphertext is the base64 blob exactly as the client saved it
+	Ciphertext string `json:"ciphertext"`
+}
+
+type CiphertextGetter interface {
+	// this matches call in storage
+	Metadata(key string) (storage.Metadata, error)
+	Fetch(key string) ([]byte, error)
+	Consume(key string) ([]byte, error)
+}
+
+// New serves GET /{alias}, which hands a client encrypted secret back to be
+// decrypted by the client. Secrets encrypted by the server are reported as
+// not found, their ciphertext is only ever opened with the key.
+func New(log *slog.Logger, ciphertextGetter CiphertextGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.opaque.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.Info("Alias parameter is missing")
+			resp.RenderError(w, r, http.StatusBadRequest, "Alias parameter is missing")
+			return
+		}
+
+		md, err := ciphertextGetter.Metadata(alias)
+		if err == nil && !md.ClientEncrypted {
+			err = storage.ErrNotFound
+		}
+
+		var cipherObject []byte
+		if err == nil {
+			if md.OneTime {
+				cipherObject, err = ciphertextGetter.Consume(alias)
+			} else {
+				cipherObject, err = ciphertextGetter.Fetch(alias)
+			}
+		}
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Info("Secret not found", slog.String("alias", alias))
+			resp.RenderError(w, r, http.StatusNotFound, "Secret not found")
+			return
+		}
+		if status, msg, ok := resp.FromStorageError(err); ok {
+			log.Error("Failed to fetch secret", slog.String("alias", alias), slog.Any("error", err))
+			resp.RenderError(w, r, status, msg)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to fetch secret", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to fetch secret")
+			return
+		}
+
+		log.Info("Client encrypted secret served", slog.String("alias", alias), slog.Bool("one_time", md.OneTime))
+
+		w.Header().Set("Cache-Control", "no-store")
+		render.JSON(w, r, Response{
+			Response:   resp.OK(),
+			Alias:      alias,
+			Ciphertext: base64.StdEncoding.EncodeToString(cipherObject),
+		})
+	}
+}
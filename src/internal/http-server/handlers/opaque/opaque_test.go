@@ -0,0 +1,96 @@
+package opaque
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/storage"
+	"yoopass-api/internal/storage/storagemock"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpaqueHandler(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "opaque"))
+
+	const alias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+
+	testCases := []struct {
+		name           string
+		setupMock      func(m *storagemock.Storage)
+		expectedStatus int
+		expectedBody   interface{}
+	}{
+		{
+			name: "Success Multi-View",
+			setupMock: func(m *storagemock.Storage) {
+				m.On("Metadata", alias).Return(storage.Metadata{ClientEncrypted: true}, nil).Once()
+				m.On("Fetch", alias).Return([]byte("sealed"), nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   Response{Response: resp.OK(), Alias: alias, Ciphertext: "c2VhbGVk"},
+		},
+		{
+			name: "Success One-Time Consumed",
+			setupMock: func(m *storagemock.Storage) {
+				m.On("Metadata", alias).Return(storage.Metadata{ClientEncrypted: true, OneTime: true}, nil).Once()
+				m.On("Consume", alias).Return([]byte("sealed"), nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   Response{Response: resp.OK(), Alias: alias, Ciphertext: "c2VhbGVk"},
+		},
+		{
+			name: "Error Server Encrypted Secret",
+			setupMock: func(m *storagemock.Storage) {
+				m.On("Metadata", alias).Return(storage.Metadata{AbuseTag: "spam"}, nil).Once()
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   resp.Error("Secret not found"),
+		},
+		{
+			name: "Error No Metadata",
+			setupMock: func(m *storagemock.Storage) {
+				m.On("Metadata", alias).Return(storage.Metadata{}, storage.ErrNotFound).Once()
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   resp.Error("Secret not found"),
+		},
+		{
+			name: "Error Storage Unavailable",
+			setupMock: func(m *storagemock.Storage) {
+				m.On("Metadata", alias).Return(storage.Metadata{ClientEncrypted: true}, nil).Once()
+				m.On("Fetch", alias).Return(nil, storage.ErrUnavailable).Once()
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   resp.Error("Storage is unavailable"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStorage := new(storagemock.Storage)
+			tc.setupMock(mockStorage)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("alias", alias)
+			req := httptest.NewRequest(http.MethodGet, "/"+alias, nil)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			rr := httptest.NewRecorder()
+
+			New(log, mockStorage).ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			expectedJson, err := json.Marshal(tc.expectedBody)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(expectedJson), rr.Body.String())
+			mockStorage.AssertExpectations(t)
+		})
+	}
+}
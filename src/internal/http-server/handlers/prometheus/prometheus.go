@@ -0,0 +1,34 @@
+package prometheus
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+type Exporter interface {
+	WritePrometheus(w io.Writer) error
+}
+
+// New serves GET /metrics in the Prometheus text exposition format. The
+// counters are per instance, so every instance has to be scraped.
+func New(log *slog.Logger, exporter Exporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.prometheus.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+
+		if err := exporter.WritePrometheus(w); err != nil {
+			// Headers are out already, the scrape just fails
+			log.Warn("Failed to write metrics", slog.Any("error", err))
+		}
+	}
+}
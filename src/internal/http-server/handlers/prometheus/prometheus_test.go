@@ -0,0 +1,29 @@
+package prometheus
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+	"yoopass-api/internal/metrics"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheusHandler(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "prometheus"))
+
+	counters := metrics.New()
+	counters.SecretSaved()
+	counters.ObserveLatency("save", 30*time.Millisecond)
+
+	rr := httptest.NewRecorder()
+	New(log, counters).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Body.String(), "\nyoopass_secrets_created_total 1\n")
+	assert.Contains(t, rr.Body.String(), `yoopass_handler_duration_seconds_count{handler="save"} 1`)
+}
@@ -0,0 +1,77 @@
+package reads
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+	"yoopass-api/internal/http-server/handlers/response"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/storage"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+)
+
+type Response struct {
+	response.Response
+	Alias string         `json:"alias"`
+	Reads []storage.Read `json:"reads"`
+}
+
+type ReadLister interface {
+	// this matches call in storage
+	TTL(key string) (time.Duration, error)
+	Reads(key string) ([]storage.Read, error)
+}
+
+// New serves GET /{alias}/reads, the read history of a multi-view secret,
+// newest first. Readers are only identified by a keyed hash of their IP.
+// The route must sit behind authentication.
+func New(log *slog.Logger, readLister ReadLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.reads.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.Info("Alias parameter is missing")
+			resp.RenderError(w, r, http.StatusBadRequest, "Alias parameter is missing")
+			return
+		}
+
+		// An unknown alias and a secret nobody read yet must not look alike
+		_, err := readLister.TTL(alias)
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Info("Secret not found", slog.String("alias", alias))
+			resp.RenderError(w, r, http.StatusNotFound, "Secret not found")
+			return
+		}
+
+		var reads []storage.Read
+		if err == nil {
+			reads, err = readLister.Reads(alias)
+		}
+		if status, msg, ok := resp.FromStorageError(err); ok {
+			log.Error("Failed to read history", slog.String("alias", alias), slog.Any("error", err))
+			resp.RenderError(w, r, status, msg)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to read history", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to read history")
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Alias:    alias,
+			Reads:    reads,
+		})
+	}
+}
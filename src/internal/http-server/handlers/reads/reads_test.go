@@ -0,0 +1,85 @@
+package reads
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/storage"
+	"yoopass-api/internal/storage/storagemock"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadsHandler(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "reads"))
+
+	const alias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+	history := []storage.Read{
+		{At: time.Date(2025, 3, 1, 12, 5, 0, 0, time.UTC), IPHash: "9f86d081884c7d659a2feaa0"},
+		{At: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC), IPHash: "60303ae22b998861bce3b28f"},
+	}
+
+	testCases := []struct {
+		name           string
+		setupMock      func(m *storagemock.Storage)
+		expectedStatus int
+		expectedBody   interface{}
+	}{
+		{
+			name: "Success History",
+			setupMock: func(m *storagemock.Storage) {
+				m.On("TTL", alias).Return(time.Hour, nil).Once()
+				m.On("Reads", alias).Return(history, nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   Response{Response: resp.OK(), Alias: alias, Reads: history},
+		},
+		{
+			name: "Error Secret Not Found",
+			setupMock: func(m *storagemock.Storage) {
+				m.On("TTL", alias).Return(time.Duration(0), storage.ErrNotFound).Once()
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   resp.Error("Secret not found"),
+		},
+		{
+			name: "Error Storage Failure",
+			setupMock: func(m *storagemock.Storage) {
+				m.On("TTL", alias).Return(time.Hour, nil).Once()
+				m.On("Reads", alias).Return(nil, errors.New("boom")).Once()
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   resp.Error("Failed to read history"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStorage := new(storagemock.Storage)
+			tc.setupMock(mockStorage)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("alias", alias)
+			req := httptest.NewRequest(http.MethodGet, "/"+alias+"/reads", nil)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			rr := httptest.NewRecorder()
+
+			New(log, mockStorage).ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			expectedJson, err := json.Marshal(tc.expectedBody)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(expectedJson), rr.Body.String())
+			mockStorage.AssertExpectations(t)
+		})
+	}
+}
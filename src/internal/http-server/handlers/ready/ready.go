@@ -0,0 +1,58 @@
+package ready
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"yoopass-api/internal/health"
+	"yoopass-api/internal/http-server/handlers/response"
+	resp "yoopass-api/internal/http-server/handlers/response"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+)
+
+type Response struct {
+	response.Response
+	Workers []health.Status `json:"workers"`
+}
+
+type WorkerReporter interface {
+	Status() []health.Status
+}
+
+// New serves GET /readyz. The instance is ready while every background
+// worker has sent a heartbeat within its expected interval, so a stuck
+// worker takes the instance out of rotation with 503.
+func New(log *slog.Logger, workers WorkerReporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.ready.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		statuses := workers.Status()
+
+		var stale []string
+		for _, status := range statuses {
+			if !status.Healthy {
+				stale = append(stale, status.Name)
+			}
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+
+		if len(stale) > 0 {
+			log.Warn("Background workers are stale", slog.Any("workers", stale))
+			resp.RenderErrorf(w, r, http.StatusServiceUnavailable, "Background workers are stale: %s", strings.Join(stale, ", "))
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Workers:  statuses,
+		})
+	}
+}
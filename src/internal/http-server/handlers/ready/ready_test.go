@@ -0,0 +1,76 @@
+package ready
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+	"yoopass-api/internal/health"
+	resp "yoopass-api/internal/http-server/handlers/response"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeWorkers []health.Status
+
+func (f fakeWorkers) Status() []health.Status {
+	return f
+}
+
+func TestReadyHandler(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "ready"))
+	beat := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name           string
+		workers        fakeWorkers
+		expectedStatus int
+		expectedError  string
+	}{
+		{
+			name:           "No Workers",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "All Workers Healthy",
+			workers: fakeWorkers{
+				{Name: "expiry", LastBeat: beat, Healthy: true},
+				{Name: "webhooks", LastBeat: beat, Healthy: true},
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "Stale Worker",
+			workers: fakeWorkers{
+				{Name: "expiry", LastBeat: beat, Healthy: false},
+				{Name: "webhooks", LastBeat: beat, Healthy: true},
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedError:  "Background workers are stale: expiry",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			New(log, tc.workers).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			require.Equal(t, tc.expectedStatus, rr.Code)
+			if tc.expectedError != "" {
+				expectedJson, err := json.Marshal(resp.Error(tc.expectedError))
+				require.NoError(t, err)
+				assert.JSONEq(t, string(expectedJson), rr.Body.String())
+				return
+			}
+
+			var body Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+			assert.Equal(t, resp.StatusOK, body.Status)
+			assert.Len(t, body.Workers, len(tc.workers))
+		})
+	}
+}
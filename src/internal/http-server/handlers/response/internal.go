@@ -0,0 +1,58 @@
+package response
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+)
+
+// CodeInternal is the code of the minimal body written for internal errors.
+const CodeInternal = "internal_error"
+
+// InternalError is the whole body of a 500 behind WithMinimalInternalErrors.
+// RequestID lets operators find the details in the logs.
+type InternalError struct {
+	Status    string `json:"status"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id"`
+}
+
+type minimalCtxKey struct{}
+
+// WithMinimalInternalErrors returns a middleware that makes every 500 written
+// through this package, recovered panics included, a fixed body holding
+// nothing but the request id. Whatever message the caller passed is dropped,
+// so no error text can reach the client by accident.
+func WithMinimalInternalErrors() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), minimalCtxKey{}, true)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// minimalInternalErrors reports whether WithMinimalInternalErrors applies.
+func minimalInternalErrors(r *http.Request) bool {
+	minimal, _ := r.Context().Value(minimalCtxKey{}).(bool)
+	return minimal
+}
+
+// renderInternalError writes the minimal 500.
+func renderInternalError(w http.ResponseWriter, r *http.Request) {
+	if formatFromRequest(r) == FormatProblem {
+		renderProblem(w, r, http.StatusInternalServerError, "", nil)
+		return
+	}
+
+	render.Status(r, http.StatusInternalServerError)
+	render.JSON(w, r, InternalError{
+		Status:    StatusError,
+		Code:      CodeInternal,
+		RequestID: middleware.GetReqID(r.Context()),
+	})
+}
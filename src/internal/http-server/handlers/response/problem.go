@@ -0,0 +1,88 @@
+package response
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+// Format selects how error responses are written.
+type Format string
+
+const (
+	// FormatSimple is the default {status, type, error} envelope.
+	FormatSimple Format = "simple"
+	// FormatProblem writes RFC 7807 application/problem+json documents.
+	FormatProblem Format = "problem"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// ParseFormat parses the error_format config value. An empty value means
+// FormatSimple.
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case "", FormatSimple:
+		return FormatSimple, nil
+	case FormatProblem:
+		return FormatProblem, nil
+	default:
+		return "", fmt.Errorf("unknown error format %q", s)
+	}
+}
+
+// Problem is an RFC 7807 problem details object. Validation failures add the
+// per-field errors as an extension member.
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Errors   []ValidationError `json:"errors,omitempty"`
+}
+
+type formatCtxKey struct{}
+
+// WithFormat returns a middleware that makes every error rendered by this
+// package for the request use format.
+func WithFormat(format Format) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), formatCtxKey{}, format)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func formatFromRequest(r *http.Request) Format {
+	if format, ok := r.Context().Value(formatCtxKey{}).(Format); ok {
+		return format
+	}
+	return FormatSimple
+}
+
+func renderProblem(w http.ResponseWriter, r *http.Request, status int, detail string, errs []ValidationError) {
+	problem := Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Errors: errs,
+	}
+
+	// The request path may carry a decryption key, so the occurrence is
+	// identified by its request id instead
+	if reqID := middleware.GetReqID(r.Context()); reqID != "" {
+		problem.Instance = "urn:request-id:" + reqID
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(problem)
+}
@@ -1,15 +1,25 @@
 package response
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"strings"
+	"yoopass-api/internal/i18n"
+	"yoopass-api/internal/storage"
 
+	"github.com/go-chi/render"
 	"github.com/go-playground/validator"
 )
 
+// Response is the envelope shared by every response. Failed responses carry
+// StatusError and a Type telling plain errors apart from validation errors.
 type Response struct {
-	Status string `json:"status"`
-	Error  string `json:"error,omitempty"`
+	Status string            `json:"status"`
+	Type   string            `json:"type,omitempty"`
+	Error  string            `json:"error,omitempty"`
+	Errors []ValidationError `json:"errors,omitempty"`
 }
 
 const (
@@ -17,6 +27,11 @@ const (
 	StatusError = "ERROR"
 )
 
+const (
+	TypeError      = "error"
+	TypeValidation = "validation"
+)
+
 func OK() Response {
 	return Response{Status: StatusOK}
 }
@@ -24,6 +39,7 @@ func OK() Response {
 func Error(msg string) Response {
 	return Response{
 		Status: StatusError,
+		Type:   TypeError,
 		Error:  msg,
 	}
 }
@@ -49,14 +65,81 @@ func ValidateError(errs validator.ValidationErrors) Response {
 
 	return Response{
 		Status: StatusError,
+		Type:   TypeValidation,
 		Error:  strings.Join(errMsgs, ", "),
 	}
 }
 
-func ValidationErrorResponse(errors []ValidationError) map[string]interface{} {
-	return map[string]interface{}{
-		"status": "error",
-		"type":   "validation",
-		"errors": errors,
+func ValidationErrorResponse(errors []ValidationError) Response {
+	return Response{
+		Status: StatusError,
+		Type:   TypeValidation,
+		Errors: errors,
+	}
+}
+
+// FromStorageError maps a storage sentinel error to an HTTP status and a
+// client-facing message. ok is false when err is not a known storage error,
+// leaving the caller to decide how to report it.
+func FromStorageError(err error) (status int, msg string, ok bool) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return http.StatusNotFound, "Secret not found", true
+	case errors.Is(err, storage.ErrConflict):
+		return http.StatusConflict, "Secret already exists", true
+	case errors.Is(err, storage.ErrStale):
+		return http.StatusConflict, "Secret was modified concurrently", true
+	case errors.Is(err, storage.ErrCapacity):
+		return http.StatusInsufficientStorage, "Storage capacity exceeded", true
+	case errors.Is(err, storage.ErrUnavailable):
+		return http.StatusServiceUnavailable, "Storage is unavailable", true
+	// A caller's own deadline counts as a storage timeout as well
+	case errors.Is(err, storage.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, "Storage timed out", true
+	default:
+		return 0, "", false
+	}
+}
+
+// RenderError writes an error response with the given status code. msg is
+// translated to the language the client asked for via Accept-Language.
+func RenderError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	renderError(w, r, status, i18n.Translate(i18n.FromRequest(r), msg))
+}
+
+// RenderErrorf is like RenderError but formats the translated message with args.
+func RenderErrorf(w http.ResponseWriter, r *http.Request, status int, format string, args ...any) {
+	renderError(w, r, status, fmt.Sprintf(i18n.Translate(i18n.FromRequest(r), format), args...))
+}
+
+// RenderValidationError writes a 400 listing the fields that failed
+// validation, or a generic 400 behind WithTerseValidation.
+func RenderValidationError(w http.ResponseWriter, r *http.Request, errs []ValidationError) {
+	if log := terseLogger(r); log != nil {
+		renderTerseValidationError(log, w, r, errs)
+		return
+	}
+
+	if formatFromRequest(r) == FormatProblem {
+		renderProblem(w, r, http.StatusBadRequest, "", errs)
+		return
+	}
+
+	render.Status(r, http.StatusBadRequest)
+	render.JSON(w, r, ValidationErrorResponse(errs))
+}
+
+func renderError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	if status == http.StatusInternalServerError && minimalInternalErrors(r) {
+		renderInternalError(w, r)
+		return
 	}
+
+	if formatFromRequest(r) == FormatProblem {
+		renderProblem(w, r, status, msg, nil)
+		return
+	}
+
+	render.Status(r, status)
+	render.JSON(w, r, Error(msg))
 }
@@ -0,0 +1,257 @@
+package response
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorEnvelopesShareStatus(t *testing.T) {
+	plain := Error("Secret not found")
+	validation := ValidationErrorResponse([]ValidationError{
+		{Field: "message", Error: "This field is required"},
+	})
+
+	assert.Equal(t, StatusError, plain.Status)
+	assert.Equal(t, plain.Status, validation.Status)
+	assert.Equal(t, TypeError, plain.Type)
+	assert.Equal(t, TypeValidation, validation.Type)
+}
+
+func TestErrorEnvelopesJSON(t *testing.T) {
+	plain, err := json.Marshal(Error("Secret not found"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"status":"ERROR","type":"error","error":"Secret not found"}`, string(plain))
+
+	validation, err := json.Marshal(ValidationErrorResponse([]ValidationError{
+		{Field: "message", Error: "This field is required"},
+	}))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"status": "ERROR",
+		"type": "validation",
+		"errors": [{"field": "message", "error": "This field is required"}]
+	}`, string(validation))
+
+	ok, err := json.Marshal(OK())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"status":"OK"}`, string(ok))
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, s := range []string{"", "simple"} {
+		format, err := ParseFormat(s)
+		require.NoError(t, err)
+		assert.Equal(t, FormatSimple, format)
+	}
+
+	format, err := ParseFormat("problem")
+	require.NoError(t, err)
+	assert.Equal(t, FormatProblem, format)
+
+	_, err = ParseFormat("xml")
+	assert.Error(t, err)
+}
+
+func TestRenderErrorFormats(t *testing.T) {
+	testCases := []struct {
+		name                string
+		format              Format
+		render              func(w http.ResponseWriter, r *http.Request)
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:   "Simple Error By Default",
+			format: "",
+			render: func(w http.ResponseWriter, r *http.Request) {
+				RenderError(w, r, http.StatusNotFound, "Secret not found")
+			},
+			expectedStatus:      http.StatusNotFound,
+			expectedContentType: "application/json",
+			expectedBody:        `{"status":"ERROR","type":"error","error":"Secret not found"}`,
+		},
+		{
+			name:   "Problem Error",
+			format: FormatProblem,
+			render: func(w http.ResponseWriter, r *http.Request) {
+				RenderError(w, r, http.StatusNotFound, "Secret not found")
+			},
+			expectedStatus:      http.StatusNotFound,
+			expectedContentType: ProblemContentType,
+			expectedBody: `{
+				"type": "about:blank",
+				"title": "Not Found",
+				"status": 404,
+				"detail": "Secret not found",
+				"instance": "urn:request-id:test-request"
+			}`,
+		},
+		{
+			name:   "Problem Validation Error",
+			format: FormatProblem,
+			render: func(w http.ResponseWriter, r *http.Request) {
+				RenderValidationError(w, r, []ValidationError{{Field: "message", Error: "This field is required"}})
+			},
+			expectedStatus:      http.StatusBadRequest,
+			expectedContentType: ProblemContentType,
+			expectedBody: `{
+				"type": "about:blank",
+				"title": "Bad Request",
+				"status": 400,
+				"instance": "urn:request-id:test-request",
+				"errors": [{"field": "message", "error": "This field is required"}]
+			}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var handler http.Handler = http.HandlerFunc(tc.render)
+			if tc.format != "" {
+				handler = WithFormat(tc.format)(handler)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req = req.WithContext(context.WithValue(req.Context(), middleware.RequestIDKey, "test-request"))
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			assert.Contains(t, rr.Header().Get("Content-Type"), tc.expectedContentType)
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestRenderValidationErrorTerse(t *testing.T) {
+	render := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		RenderValidationError(w, r, []ValidationError{{Field: "expiration", Error: "Value must be less than or equal to 720"}})
+	})
+
+	testCases := []struct {
+		name         string
+		terse        bool
+		format       Format
+		expectedBody string
+	}{
+		{
+			name:         "Verbose",
+			expectedBody: `{"status":"ERROR","type":"validation","errors":[{"field":"expiration","error":"Value must be less than or equal to 720"}]}`,
+		},
+		{
+			name:         "Terse",
+			terse:        true,
+			expectedBody: `{"status":"ERROR","type":"error","error":"Invalid request"}`,
+		},
+		{
+			name:   "Terse Problem",
+			terse:  true,
+			format: FormatProblem,
+			expectedBody: `{
+				"type": "about:blank",
+				"title": "Bad Request",
+				"status": 400,
+				"detail": "Invalid request",
+				"instance": "urn:request-id:test-request"
+			}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			var handler http.Handler = render
+			if tc.terse {
+				handler = WithTerseValidation(slog.New(slog.NewJSONHandler(&logs, nil)))(handler)
+			}
+			if tc.format != "" {
+				handler = WithFormat(tc.format)(handler)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/add", nil)
+			req = req.WithContext(context.WithValue(req.Context(), middleware.RequestIDKey, "test-request"))
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String())
+			if tc.terse {
+				// The details still reach the operator
+				assert.Contains(t, logs.String(), `"field":"expiration"`)
+				assert.Contains(t, logs.String(), `"request_id":"test-request"`)
+			}
+		})
+	}
+}
+
+func TestRenderErrorMinimalInternal(t *testing.T) {
+	testCases := []struct {
+		name         string
+		status       int
+		minimal      bool
+		format       Format
+		expectedBody string
+	}{
+		{
+			name:         "Detailed",
+			status:       http.StatusInternalServerError,
+			expectedBody: `{"status":"ERROR","type":"error","error":"Failed to decode secret"}`,
+		},
+		{
+			name:         "Minimal",
+			status:       http.StatusInternalServerError,
+			minimal:      true,
+			expectedBody: `{"status":"ERROR","code":"internal_error","request_id":"test-request"}`,
+		},
+		{
+			name:    "Minimal Problem",
+			status:  http.StatusInternalServerError,
+			minimal: true,
+			format:  FormatProblem,
+			expectedBody: `{
+				"type": "about:blank",
+				"title": "Internal Server Error",
+				"status": 500,
+				"instance": "urn:request-id:test-request"
+			}`,
+		},
+		{
+			name:         "Other Statuses Untouched",
+			status:       http.StatusServiceUnavailable,
+			minimal:      true,
+			expectedBody: `{"status":"ERROR","type":"error","error":"Failed to decode secret"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				RenderError(w, r, tc.status, "Failed to decode secret")
+			})
+			if tc.minimal {
+				handler = WithMinimalInternalErrors()(handler)
+			}
+			if tc.format != "" {
+				handler = WithFormat(tc.format)(handler)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/alias/key", nil)
+			req = req.WithContext(context.WithValue(req.Context(), middleware.RequestIDKey, "test-request"))
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String())
+		})
+	}
+}
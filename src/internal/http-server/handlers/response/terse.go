@@ -0,0 +1,43 @@
+package response
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+type terseCtxKey struct{}
+
+// WithTerseValidation returns a middleware that makes RenderValidationError
+// answer a single generic "Invalid request" instead of listing the fields
+// that failed, so the API gives less away to someone probing it. The field
+// errors are logged to log instead.
+func WithTerseValidation(log *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), terseCtxKey{}, log)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// terseLogger returns the logger set by WithTerseValidation, or nil when
+// validation errors are verbose.
+func terseLogger(r *http.Request) *slog.Logger {
+	log, _ := r.Context().Value(terseCtxKey{}).(*slog.Logger)
+	return log
+}
+
+// renderTerseValidationError logs errs and writes the generic message.
+func renderTerseValidationError(log *slog.Logger, w http.ResponseWriter, r *http.Request, errs []ValidationError) {
+	log.Info("Validation failed",
+		slog.String("op", "response.RenderValidationError"),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.Any("errors", errs),
+	)
+	RenderError(w, r, http.StatusBadRequest, "Invalid request")
+}
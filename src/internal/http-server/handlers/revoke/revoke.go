@@ -0,0 +1,92 @@
+package revoke
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+	"yoopass-api/internal/http-server/handlers/response"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/storage"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+)
+
+// Request selects the secrets to revoke. Exactly one criterion must be set,
+// both are plaintext data kept at save time since messages are encrypted.
+type Request struct {
+	GroupID       string    `json:"group_id,omitempty"`
+	CreatedBefore time.Time `json:"created_before,omitzero"`
+}
+
+type Response struct {
+	response.Response
+	Deleted int `json:"deleted"`
+}
+
+type Revoker interface {
+	// this matches call in storage
+	DeleteGroup(group string) (int, error)
+	DeleteCreatedBefore(before time.Time) (int, error)
+}
+
+// New serves POST /admin/revoke, deleting every secret that matches the
+// request in bulk. The route must sit behind authentication.
+func New(log *slog.Logger, revoker Revoker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.revoke.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if err != nil {
+			log.Info("Failed to decode request", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusBadRequest, "Failed to read or decode request body.")
+			return
+		}
+
+		if (req.GroupID == "") == req.CreatedBefore.IsZero() {
+			log.Info("Invalid revoke criteria")
+			resp.RenderError(w, r, http.StatusBadRequest, "Exactly one of group_id or created_before is required")
+			return
+		}
+
+		var deleted int
+		if req.GroupID != "" {
+			deleted, err = revoker.DeleteGroup(req.GroupID)
+			// Nothing left to revoke is not a failure here
+			if errors.Is(err, storage.ErrNotFound) {
+				err = nil
+			}
+		} else {
+			deleted, err = revoker.DeleteCreatedBefore(req.CreatedBefore)
+		}
+		if status, msg, ok := resp.FromStorageError(err); ok {
+			log.Error("Failed to revoke secrets", slog.Any("error", err))
+			resp.RenderError(w, r, status, msg)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to revoke secrets", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to revoke secrets")
+			return
+		}
+
+		log.Warn("Secrets revoked",
+			slog.String("group_id", req.GroupID),
+			slog.Time("created_before", req.CreatedBefore),
+			slog.Int("deleted", deleted),
+		)
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Deleted:  deleted,
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package revoke
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/storage"
+	"yoopass-api/internal/storage/storagemock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevokeHandler(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "revoke"))
+
+	cutoff := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name           string
+		body           string
+		setupMock      func(m *storagemock.Storage)
+		expectedStatus int
+		expectedBody   interface{}
+	}{
+		{
+			name: "Success By Group",
+			body: `{"group_id":"deploy-2024"}`,
+			setupMock: func(m *storagemock.Storage) {
+				m.On("DeleteGroup", "deploy-2024").Return(3, nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   Response{Response: resp.OK(), Deleted: 3},
+		},
+		{
+			name: "Success By Unknown Group",
+			body: `{"group_id":"gone"}`,
+			setupMock: func(m *storagemock.Storage) {
+				m.On("DeleteGroup", "gone").Return(0, storage.ErrNotFound).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   Response{Response: resp.OK(), Deleted: 0},
+		},
+		{
+			name: "Success By Creation Time",
+			body: `{"created_before":"2025-03-01T12:00:00Z"}`,
+			setupMock: func(m *storagemock.Storage) {
+				m.On("DeleteCreatedBefore", cutoff).Return(5, nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   Response{Response: resp.OK(), Deleted: 5},
+		},
+		{
+			name:           "Error No Criteria",
+			body:           `{}`,
+			setupMock:      func(m *storagemock.Storage) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   resp.Error("Exactly one of group_id or created_before is required"),
+		},
+		{
+			name:           "Error Both Criteria",
+			body:           `{"group_id":"deploy-2024","created_before":"2025-03-01T12:00:00Z"}`,
+			setupMock:      func(m *storagemock.Storage) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   resp.Error("Exactly one of group_id or created_before is required"),
+		},
+		{
+			name:           "Error Malformed Timestamp",
+			body:           `{"created_before":"yesterday"}`,
+			setupMock:      func(m *storagemock.Storage) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   resp.Error("Failed to read or decode request body."),
+		},
+		{
+			name: "Error Storage Unavailable",
+			body: `{"created_before":"2025-03-01T12:00:00Z"}`,
+			setupMock: func(m *storagemock.Storage) {
+				m.On("DeleteCreatedBefore", cutoff).Return(0, storage.ErrUnavailable).Once()
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   resp.Error("Storage is unavailable"),
+		},
+		{
+			name: "Error Generic Storage Failure",
+			body: `{"group_id":"deploy-2024"}`,
+			setupMock: func(m *storagemock.Storage) {
+				m.On("DeleteGroup", "deploy-2024").Return(0, errors.New("boom")).Once()
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   resp.Error("Failed to revoke secrets"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStorage := new(storagemock.Storage)
+			tc.setupMock(mockStorage)
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/revoke", strings.NewReader(tc.body))
+			rr := httptest.NewRecorder()
+			New(log, mockStorage).ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			expectedJson, err := json.Marshal(tc.expectedBody)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(expectedJson), rr.Body.String())
+			mockStorage.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package save
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+	"yoopass-api/internal/http-server/middleware/identity"
+	"yoopass-api/internal/tools/netutil"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+// dedupe remembers the responses to recent saves so a form submitted twice
+// gets the first secret back instead of a second one. Responses hold the
+// key, so they are only ever kept in memory and only for the window.
+type dedupe struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]*dedupeEntry
+}
+
+type dedupeEntry struct {
+	// done is closed once the first submission has been answered
+	done    chan struct{}
+	body    []byte
+	ok      bool
+	expires time.Time
+}
+
+func newDedupe(window time.Duration) *dedupe {
+	return &dedupe{
+		window:  window,
+		now:     time.Now,
+		entries: make(map[[sha256.Size]byte]*dedupeEntry),
+	}
+}
+
+// wrap answers a repeat of a successful save from the same client within
+// the window with the first response. A repeat arriving while the first is
+// still running waits for it. Failed saves are not remembered, so their
+// repeats are handled afresh.
+func (d *dedupe) wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			// Let the handler report the broken body
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next(w, r)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		h := sha256.New()
+		h.Write([]byte(netutil.ClientIP(r)))
+		h.Write([]byte{0})
+		// Tenants behind one address must not get each other's alias
+		h.Write([]byte(identity.User(r.Context())))
+		h.Write([]byte{0})
+		h.Write(body)
+		var sum [sha256.Size]byte
+		h.Sum(sum[:0])
+
+		entry, first := d.claim(sum)
+		if !first {
+			<-entry.done
+			if entry.ok {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("X-Duplicate-Submission", "true")
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write(entry.body)
+				return
+			}
+			next(w, r)
+			return
+		}
+
+		var buf bytes.Buffer
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(&buf)
+		next(ww, r)
+
+		d.finish(sum, entry, ww.Status() == http.StatusOK, buf.Bytes())
+	}
+}
+
+// claim returns the live entry for sum, or registers a new one and reports
+// that the caller is the first submission.
+func (d *dedupe) claim(sum [sha256.Size]byte) (*dedupeEntry, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	for k, e := range d.entries {
+		if !e.expires.IsZero() && now.After(e.expires) {
+			delete(d.entries, k)
+		}
+	}
+
+	if entry, ok := d.entries[sum]; ok {
+		return entry, false
+	}
+
+	entry := &dedupeEntry{done: make(chan struct{})}
+	d.entries[sum] = entry
+	return entry, true
+}
+
+func (d *dedupe) finish(sum [sha256.Size]byte, entry *dedupeEntry, ok bool, body []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entry.ok = ok
+	if ok {
+		entry.body = bytes.Clone(body)
+		entry.expires = d.now().Add(d.window)
+	} else {
+		delete(d.entries, sum)
+	}
+	close(entry.done)
+}
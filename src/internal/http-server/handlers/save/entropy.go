@@ -0,0 +1,42 @@
+package save
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// minEntropyLength is the shortest message judged by looksEncrypted. Shorter
+// samples can't show their alphabet, so their entropy says little.
+const minEntropyLength = 32
+
+// looksEncrypted reports whether message reads like ciphertext or another
+// random blob rather than text: a single long token whose Shannon entropy is
+// at least threshold bits per byte. Base64 of random data comes close to 6,
+// natural language and hex stay well below.
+func looksEncrypted(message string, threshold float64) bool {
+	message = strings.TrimSpace(message)
+	if len(message) < minEntropyLength || strings.ContainsFunc(message, unicode.IsSpace) {
+		return false
+	}
+	return entropy(message) >= threshold
+}
+
+// entropy returns the Shannon entropy of s in bits per byte.
+func entropy(s string) float64 {
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+
+	var bits float64
+	n := float64(len(s))
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / n
+		bits -= p * math.Log2(p)
+	}
+	return bits
+}
@@ -1,53 +1,450 @@
 package save
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 	"yoopass-api/internal/dto"
 	"yoopass-api/internal/http-server/handlers/response"
 	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/http-server/middleware/identity"
+	"yoopass-api/internal/i18n"
+	"yoopass-api/internal/storage"
 	cipher "yoopass-api/internal/tools/cipher"
+	"yoopass-api/internal/tools/shamir"
+	"yoopass-api/internal/tools/shareurl"
 
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/render"
 	"github.com/go-playground/validator/v10"
 	"github.com/gofrs/uuid"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Request struct {
-	Message    string `json:"message" validate:"required"`
-	Expiration int    `json:"expiration"`
+	Message    string `json:"message" validate:"required_without=Ciphertext"`
+	Expiration int    `json:"expiration" validate:"gte=0,maxttl"`
 	OneTime    bool   `json:"one_time"`
+	// NotifyEmail optionally receives a receipt when the secret is read
+	NotifyEmail string `json:"notify_email,omitempty" validate:"omitempty,email"`
+	// GroupID ties the secret to others that are revoked together
+	GroupID string `json:"group_id,omitempty" validate:"omitempty,groupid"`
+	// AbuseTag is an optional plaintext category for operators, stored
+	// outside the encrypted payload, see WithAbuseTags
+	AbuseTag string `json:"abuse_tag,omitempty"`
+	// ContentType is used when the secret is downloaded as a file, it is
+	// kept inside the encrypted payload
+	ContentType string `json:"content_type,omitempty"`
+	// Split hands out key shares instead of the key, see SplitRequest
+	Split *SplitRequest `json:"split,omitempty"`
+	// RequireApproval makes every reveal wait for an operator approval
+	RequireApproval bool `json:"require_approval,omitempty"`
+	// Password is asked for on every reveal on top of the key, it is never
+	// stored
+	Password string `json:"password,omitempty" validate:"omitempty,max=256"`
+	// MaxViews deletes the secret once it was read that many times, 0 keeps
+	// it until it expires
+	MaxViews int `json:"max_views,omitempty" validate:"gte=0"`
+	// Ciphertext is a base64 blob the client encrypted with a key the
+	// server never sees. It is stored as is and served back by
+	// GET /{alias}, in place of Message.
+	Ciphertext string `json:"ciphertext,omitempty" validate:"omitempty,base64"`
+	// Title is only put into the fragment of the returned URL, it is never
+	// stored, see WithURLTitles
+	Title string `json:"title,omitempty" validate:"omitempty,max=100"`
+	// NotBefore and NotAfter bound when the secret may be revealed. They
+	// are kept inside the encrypted payload, see WithRevealWindows
+	NotBefore time.Time `json:"not_before,omitzero"`
+	NotAfter  time.Time `json:"not_after,omitzero"`
+	// CreatedAt keeps the creation time of a migrated secret. It is only
+	// honoured behind WithCreatedAtOverride, otherwise the server's time is
+	// stored.
+	CreatedAt time.Time `json:"created_at,omitzero"`
+	// NoExpiry asks for a secret that never expires, see WithExplicitNoExpiry
+	NoExpiry bool `json:"no_expiry,omitempty"`
+}
+
+// SplitRequest asks for the key to be split into N shares of which any K
+// open the secret, so no single recipient can read it alone.
+type SplitRequest struct {
+	N int `json:"n" validate:"gte=2,lte=255"`
+	K int `json:"k" validate:"gte=2,ltefield=N"`
 }
 
 type Response struct {
 	response.Response
 	Alias string `json:"alias,omitempty"`
 	Key   string `json:"key,omitempty"`
+	URL   string `json:"url,omitempty"`
+	// Shares replace Key and URL for split secrets
+	Shares      []string `json:"shares,omitempty"`
+	HumanExpiry string   `json:"human_expiry,omitempty"`
+	// KeyBits and Cipher describe the protection of the secret, for auditing
+	KeyBits int    `json:"key_bits,omitempty"`
+	Cipher  string `json:"cipher,omitempty"`
+	// HighEntropy warns that the message looks already encrypted, see
+	// WithHighEntropyCheck
+	HighEntropy bool `json:"high_entropy,omitempty"`
+	// ShortCode names the secret to support staff, see WithShortCodes
+	ShortCode string `json:"short_code,omitempty"`
+}
+
+// saveRateLimit names the global rate limit of saves in storage.
+const saveRateLimit = "saves"
+
+// Option configures optional behaviour of the save handler.
+type Option func(*options)
+
+type options struct {
+	forceHTTPS         bool
+	publicBaseURL      *url.URL
+	requestHostURLs    bool
+	maxAbuseTagLength  int
+	maxExpirationHours int
+	trimMessage        bool
+	approvals          bool
+	clientKeysOnly     bool
+	urlTitles          bool
+	dedupeWindow       time.Duration
+	aliasSigner        AliasSigner
+	rateLimiter        RateLimiter
+	maxSavesPerMinute  int
+	entropyThreshold   float64
+	rejectHighEntropy  bool
+	revealWindows      bool
+	preSaveHooks       []PreSaveHook
+	contentSignals     bool
+	signalPatterns     map[string]*regexp.Regexp
+	createdAtOverride  bool
+	createdAtSkew      time.Duration
+	metrics            MetricsRecorder
+	tracer             trace.Tracer
+	maxMetadataBytes   int
+	explicitNoExpiry   bool
+	defaultExpiration  int
+	maxSecretBytes     int
+	secretBytesByUser  map[string]int
+	tenantPrefixer     TenantPrefixer
+	shortCoder         ShortCoder
+	keySize            int
+}
+
+// WithKeySize generates keys of size bytes, 16, 24 or 32 for AES-128,
+// AES-192 or AES-256. Without it keys have cipher.KeySize bytes.
+func WithKeySize(size int) Option {
+	return func(o *options) {
+		o.keySize = size
+	}
+}
+
+// WithForceHTTPS makes share URLs built from the request, see
+// WithRequestHostURLs, always use https, whatever scheme the request came in
+// with.
+func WithForceHTTPS() Option {
+	return func(o *options) {
+		o.forceHTTPS = true
+	}
+}
+
+// WithPublicBaseURL builds share URLs under base, see shareurl.ParseBase.
+// Without it, or WithRequestHostURLs, responses carry no URL. It takes
+// precedence over WithRequestHostURLs.
+func WithPublicBaseURL(base *url.URL) Option {
+	return func(o *options) {
+		o.publicBaseURL = base
+	}
+}
+
+// WithRequestHostURLs builds share URLs from the scheme and Host header of
+// the request when no public base URL is set. The client controls Host, so
+// this is only safe with an allowed hosts list in front.
+func WithRequestHostURLs() Option {
+	return func(o *options) {
+		o.requestHostURLs = true
+	}
+}
+
+// WithAbuseTags accepts abuse tags of up to maxLength characters. Without it
+// requests carrying an abuse tag are rejected.
+func WithAbuseTags(maxLength int) Option {
+	return func(o *options) {
+		o.maxAbuseTagLength = maxLength
+	}
+}
+
+// DefaultMaxExpirationHours caps the expiration a client may ask for unless
+// WithMaxExpiration says otherwise, 30 days.
+const DefaultMaxExpirationHours = 720
+
+// WithMaxExpiration caps the expiration a client may ask for instead of
+// DefaultMaxExpirationHours. A zero value means no cap.
+func WithMaxExpiration(hours int) Option {
+	return func(o *options) {
+		o.maxExpirationHours = hours
+	}
+}
+
+// WithTrimMessage strips leading and trailing whitespace from messages before
+// they are validated, so a blank message is rejected as missing instead of
+// being saved as an empty secret.
+func WithTrimMessage() Option {
+	return func(o *options) {
+		o.trimMessage = true
+	}
+}
+
+// WithApprovals accepts secrets that require approval before every reveal.
+// Only enable it when operators can approve, without it such requests are
+// rejected.
+func WithApprovals() Option {
+	return func(o *options) {
+		o.approvals = true
+	}
+}
+
+// WithClientKeysOnly refuses to generate keys, so every secret has to arrive
+// as a client encrypted ciphertext and the server never holds a key.
+func WithClientKeysOnly() Option {
+	return func(o *options) {
+		o.clientKeysOnly = true
+	}
+}
+
+// WithURLTitles accepts a title that is added to the returned URL as a
+// fragment, so the recipient sees what the link is for. Without it requests
+// carrying a title are rejected.
+func WithURLTitles() Option {
+	return func(o *options) {
+		o.urlTitles = true
+	}
+}
+
+// WithDedupe answers an identical save from the same client IP within window
+// with the first response instead of creating another secret, which catches
+// double-submitted forms. The first response is kept in memory only, so
+// instances behind a load balancer dedupe independently.
+func WithDedupe(window time.Duration) Option {
+	return func(o *options) {
+		o.dedupeWindow = window
+	}
+}
+
+// PreSaveHook runs custom logic, such as extra validation or DLP scanning, on
+// every validated save before anything is stored. It may adjust req. An
+// error aborts the save with 400 and its text, translated when known, as the
+// message, so it must not echo secret content.
+type PreSaveHook interface {
+	PreSave(ctx context.Context, req *Request) error
+}
+
+// PreSaveHookFunc adapts a function to a PreSaveHook.
+type PreSaveHookFunc func(ctx context.Context, req *Request) error
+
+func (f PreSaveHookFunc) PreSave(ctx context.Context, req *Request) error {
+	return f(ctx, req)
+}
+
+// WithPreSaveHooks runs hooks in order on every save, see PreSaveHook.
+func WithPreSaveHooks(hooks ...PreSaveHook) Option {
+	return func(o *options) {
+		o.preSaveHooks = append(o.preSaveHooks, hooks...)
+	}
+}
+
+// WithRevealWindows accepts not_before and not_after, which limit when the
+// secret may be revealed independently of its TTL. Without it requests
+// carrying either are rejected.
+func WithRevealWindows() Option {
+	return func(o *options) {
+		o.revealWindows = true
+	}
+}
+
+// WithHighEntropyCheck flags messages that look like they are already
+// encrypted, a long random blob with an entropy of at least threshold bits
+// per byte, with high_entropy in the response. Such a blob was most likely
+// meant to be decrypted before sharing. With reject the save is refused
+// instead.
+func WithHighEntropyCheck(threshold float64, reject bool) Option {
+	return func(o *options) {
+		o.entropyThreshold = threshold
+		o.rejectHighEntropy = reject
+	}
+}
+
+// WithContentSignals logs features of every server encrypted message for
+// DLP and SIEM ingestion: its length bucket, entropy and which of patterns
+// it matches, by name. The content itself, and what matched, is never
+// logged. Client encrypted secrets have no content to look at.
+func WithContentSignals(patterns map[string]*regexp.Regexp) Option {
+	return func(o *options) {
+		o.contentSignals = true
+		o.signalPatterns = patterns
+	}
+}
+
+// WithCreatedAtOverride stores a client provided created_at instead of the
+// current time, for migrations from other systems. It is meant for an
+// authenticated import route only. Times more than skew in the future are
+// rejected.
+func WithCreatedAtOverride(skew time.Duration) Option {
+	return func(o *options) {
+		o.createdAtOverride = true
+		o.createdAtSkew = skew
+	}
+}
+
+// WithMaxMetadataBytes caps everything encrypted along with the message,
+// serialized as it is stored, at max bytes. It keeps small messages from
+// carrying large payloads in other fields. A zero value means no cap.
+func WithMaxMetadataBytes(max int) Option {
+	return func(o *options) {
+		o.maxMetadataBytes = max
+	}
+}
+
+// WithExplicitNoExpiry only keeps a secret forever when no_expiry is set,
+// so a client that merely omits the expiration doesn't create one by
+// accident. Such saves get defaultHours instead, or are rejected when
+// defaultHours is zero.
+func WithExplicitNoExpiry(defaultHours int) Option {
+	return func(o *options) {
+		o.explicitNoExpiry = true
+		o.defaultExpiration = defaultHours
+	}
+}
+
+// WithMaxSecretBytes caps the size of a secret, its message or client
+// encrypted ciphertext, at max bytes. Users authenticated by the identity
+// middleware get the cap in byUser instead when they have one, so trusted
+// clients can store larger secrets than the public. A non-positive cap means
+// no cap beyond the request body limit.
+func WithMaxSecretBytes(max int, byUser map[string]int) Option {
+	return func(o *options) {
+		o.maxSecretBytes = max
+		o.secretBytesByUser = byUser
+	}
+}
+
+// MetricsRecorder counts and times saves, see metrics.Metrics.
+type MetricsRecorder interface {
+	SecretSaved()
+	ObserveLatency(handler string, elapsed time.Duration)
+}
+
+// WithMetrics counts every saved secret with recorder.
+func WithMetrics(recorder MetricsRecorder) Option {
+	return func(o *options) {
+		o.metrics = recorder
+	}
+}
+
+// AliasSigner signs aliases before they are handed out, see aliassig.
+type AliasSigner interface {
+	Sign(alias string) string
+}
+
+// WithAliasSigner hands out every new alias, and the URL built from it, in
+// its signed form. Storage keeps using the bare alias.
+func WithAliasSigner(signer AliasSigner) Option {
+	return func(o *options) {
+		o.aliasSigner = signer
+	}
+}
+
+// TenantPrefixer derives the alias prefix of an authenticated user, see
+// tenant.
+type TenantPrefixer interface {
+	Prefix(user string) string
+}
+
+// WithTenantPrefix stores the secrets of users authenticated by the identity
+// middleware under their tenant prefix, so aliases never collide across
+// tenants. The prefix is part of the alias handed out.
+func WithTenantPrefix(prefixer TenantPrefixer) Option {
+	return func(o *options) {
+		o.tenantPrefixer = prefixer
+	}
+}
+
+// ShortCoder derives the support reference code of an alias, see shortcode.
+type ShortCoder interface {
+	Code(alias string) string
+}
+
+// WithShortCodes hands out a short code with every new alias, which users
+// can read out to support staff. It names the secret without the key, so
+// it opens nothing.
+func WithShortCodes(coder ShortCoder) Option {
+	return func(o *options) {
+		o.shortCoder = coder
+	}
+}
+
+// RateLimiter counts events against a limit shared by all instances, see
+// storage.Storage.
+type RateLimiter interface {
+	Allow(name string, limit int, window time.Duration) (bool, time.Duration, error)
+}
+
+// WithGlobalRateLimit caps how many secrets the whole service creates per
+// minute, across every client and instance, to keep write storms off the
+// storage. Saves past the budget get 429. Unlike the per-IP limits it can't
+// be dodged by spreading a burst over many addresses.
+func WithGlobalRateLimit(limiter RateLimiter, perMinute int) Option {
+	return func(o *options) {
+		o.rateLimiter = limiter
+		o.maxSavesPerMinute = perMinute
+	}
 }
 
 type SecretSaver interface {
 	// this matches call in storage
 	Set(key string, value []byte, ttl time.Duration) error
+	AddToGroup(group, key string, ttl time.Duration) error
+	SetMetadata(key string, md storage.Metadata, ttl time.Duration) error
 }
 
-var validate = validator.New()
+// abuseTagPattern limits abuse tags to category-like words, which keeps free
+// text, and with it any secret content, out of plaintext storage.
+var abuseTagPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
 
-func ValidationErrorResponse(errors []resp.ValidationError) map[string]interface{} {
-	return map[string]interface{}{
-		"status": "error",
-		"type":   "validation",
-		"errors": errors,
-	}
+// groupIDPattern keeps group ids safe to embed in storage keys and URLs.
+var groupIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// newValidator builds a validator for one handler. Struct tags are static, so
+// limits taken from config are checked by validations that read o when a
+// request is validated.
+func newValidator(o *options) *validator.Validate {
+	v := validator.New()
+	_ = v.RegisterValidation("groupid", func(fl validator.FieldLevel) bool {
+		return groupIDPattern.MatchString(fl.Field().String())
+	})
+	_ = v.RegisterValidation("maxttl", func(fl validator.FieldLevel) bool {
+		return o.maxExpirationHours <= 0 || fl.Field().Int() <= int64(o.maxExpirationHours)
+	})
+	return v
 }
 
-func New(log *slog.Logger, secretSaver SecretSaver) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+func New(log *slog.Logger, secretSaver SecretSaver, opts ...Option) http.HandlerFunc {
+	o := options{keySize: cipher.KeySize, maxExpirationHours: DefaultMaxExpirationHours}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	validate := newValidator(&o)
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.url.save.New"
 
 		log := log.With(
@@ -55,12 +452,17 @@ func New(log *slog.Logger, secretSaver SecretSaver) http.HandlerFunc {
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
+		if o.metrics != nil {
+			start := time.Now()
+			defer func() { o.metrics.ObserveLatency("save", time.Since(start)) }()
+		}
+
 		if secretSaver == nil {
 			log.Error("critical: secretSaver is nil")
-			render.Status(r, http.StatusInternalServerError)
-			render.JSON(w, r, resp.Error("internal server error"))
+			resp.RenderError(w, r, http.StatusInternalServerError, "internal server error")
 			return
 		}
+		saver := traced(r.Context(), o.tracer, secretSaver)
 
 		var req Request
 
@@ -72,34 +474,35 @@ func New(log *slog.Logger, secretSaver SecretSaver) http.HandlerFunc {
 			var unmarshalTypeError *json.UnmarshalTypeError
 			var syntaxError *json.SyntaxError
 
-			clientErrorMessage := "Invalid request format."
-
 			switch {
 			// Check for JSON syntax errors (e.g., malformed JSON)
 			case errors.As(err, &syntaxError):
 				// Provide a message about syntax without revealing too much
-				clientErrorMessage = fmt.Sprintf("Invalid JSON syntax near character %d.", syntaxError.Offset)
-				// Or a simpler generic one: clientErrorMessage = "Invalid JSON syntax."
+				resp.RenderErrorf(w, r, http.StatusBadRequest, "Invalid JSON syntax near character %d.", syntaxError.Offset)
 
 			// Check for JSON type mismatch errors
 			case errors.As(err, &unmarshalTypeError):
 				// Construct a user-friendly message about the type mismatch
 				// Example: "Cannot unmarshal JSON string into field 'expiration' (expected type 'int')"
-				clientErrorMessage = fmt.Sprintf("Invalid type for field '%s'. Expected type '%s' but received JSON %s.",
+				resp.RenderErrorf(w, r, http.StatusBadRequest, "Invalid type for field '%s'. Expected type '%s' but received JSON %s.",
 					unmarshalTypeError.Field,         // e.g., "expiration"
 					unmarshalTypeError.Type.String(), // e.g., "int"
 					unmarshalTypeError.Value)         // e.g., "string", "number"
 
 			// Handle other potential errors (like empty body, I/O errors)
 			default:
-				clientErrorMessage = "Failed to read or decode request body."
+				resp.RenderError(w, r, http.StatusBadRequest, "Failed to read or decode request body.")
 			}
-
-			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, resp.Error(clientErrorMessage))
 			return
 		}
 
+		if o.trimMessage {
+			req.Message = strings.TrimSpace(req.Message)
+		}
+		if !o.createdAtOverride {
+			req.CreatedAt = time.Time{}
+		}
+
 		//move this to separate module with ValidationErrors
 		err = validate.Struct(req)
 		if err != nil {
@@ -108,80 +511,540 @@ func New(log *slog.Logger, secretSaver SecretSaver) http.HandlerFunc {
 				log.Error("Invalid request body", slog.Any("error", err))
 
 				// Format validation errors for the client
+				lang := i18n.FromRequest(r)
 				var errorMsgs []resp.ValidationError
 				for _, fe := range validationErrs {
 					errorMsgs = append(errorMsgs, resp.ValidationError{
-						Field: strings.ToLower(fe.Field()), // Use lowercase field name
-						Error: formatValidationError(fe),   // Helper to make messages user-friendly
+						Field: strings.ToLower(fe.Field()),        // Use lowercase field name
+						Error: formatValidationError(lang, fe, o), // Helper to make messages user-friendly
 					})
 				}
 
-				render.Status(r, http.StatusBadRequest)                    // Use 400 for validation errors
-				render.JSON(w, r, resp.ValidationErrorResponse(errorMsgs)) // Use a specific validation error response
+				resp.RenderValidationError(w, r, errorMsgs)
 				return
 			}
 
 			// Handle non-validation errors from validate.Struct (less common)
 			log.Error("Error during validation", slog.Any("error", err))
-			render.Status(r, http.StatusInternalServerError)
-			render.JSON(w, r, resp.Error("Error during validation"))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Error during validation")
+			return
+		}
+
+		if limit, size := o.secretLimit(identity.User(r.Context())), secretSize(req); limit > 0 && size > limit {
+			log.Info("Secret is too large", slog.Int("size", size), slog.Int("limit", limit))
+			resp.RenderError(w, r, http.StatusRequestEntityTooLarge, "Secret is too large")
+			return
+		}
+
+		if o.explicitNoExpiry {
+			switch {
+			case req.NoExpiry && req.Expiration != 0:
+				log.Info("Expiration sent along with no_expiry")
+				resp.RenderValidationError(w, r, []resp.ValidationError{{
+					Field: "no_expiry",
+					Error: i18n.Translate(i18n.FromRequest(r), "Can't be combined with an expiration"),
+				}})
+				return
+			case !req.NoExpiry && req.Expiration == 0 && o.defaultExpiration <= 0:
+				log.Info("Expiration omitted without no_expiry")
+				resp.RenderValidationError(w, r, []resp.ValidationError{{
+					Field: "expiration",
+					Error: i18n.Translate(i18n.FromRequest(r), "Set no_expiry for a secret that never expires"),
+				}})
+				return
+			case !req.NoExpiry && req.Expiration == 0:
+				req.Expiration = o.defaultExpiration
+			}
+		}
+
+		if req.AbuseTag != "" {
+			if msg := checkAbuseTag(i18n.FromRequest(r), req.AbuseTag, o.maxAbuseTagLength); msg != "" {
+				log.Info("Invalid abuse tag")
+				resp.RenderValidationError(w, r, []resp.ValidationError{{Field: "abuse_tag", Error: msg}})
+				return
+			}
+		}
+
+		if req.RequireApproval && !o.approvals {
+			log.Info("Approvals are not enabled")
+			resp.RenderValidationError(w, r, []resp.ValidationError{{
+				Field: "require_approval",
+				Error: i18n.Translate(i18n.FromRequest(r), "Approvals are not enabled"),
+			}})
+			return
+		}
+
+		if req.MaxViews > 0 && req.OneTime {
+			log.Info("View limit sent along with one_time")
+			resp.RenderValidationError(w, r, []resp.ValidationError{{
+				Field: "max_views",
+				Error: i18n.Translate(i18n.FromRequest(r), "Can't be combined with one_time"),
+			}})
+			return
+		}
+
+		if !req.NotBefore.IsZero() || !req.NotAfter.IsZero() {
+			if field, msg := checkRevealWindow(i18n.FromRequest(r), req, o.revealWindows, time.Now()); msg != "" {
+				log.Info("Invalid reveal window", slog.String("field", field))
+				resp.RenderValidationError(w, r, []resp.ValidationError{{Field: field, Error: msg}})
+				return
+			}
+		}
+
+		if req.CreatedAt.After(time.Now().Add(o.createdAtSkew)) {
+			log.Info("Creation time is in the future")
+			resp.RenderValidationError(w, r, []resp.ValidationError{{
+				Field: "created_at",
+				Error: i18n.Translate(i18n.FromRequest(r), "Must not be in the future"),
+			}})
+			return
+		}
+
+		if req.Title != "" && !o.urlTitles {
+			log.Info("URL titles are not enabled")
+			resp.RenderValidationError(w, r, []resp.ValidationError{{
+				Field: "title",
+				Error: i18n.Translate(i18n.FromRequest(r), "URL titles are not enabled"),
+			}})
+			return
+		}
+
+		if req.Ciphertext != "" {
+			if field := serverSideField(req); field != "" {
+				log.Info("Field needs server-side encryption", slog.String("field", field))
+				resp.RenderValidationError(w, r, []resp.ValidationError{{
+					Field: field,
+					Error: i18n.Translate(i18n.FromRequest(r), "Not available for client encrypted secrets"),
+				}})
+				return
+			}
+		} else if o.clientKeysOnly {
+			log.Info("Server-side encryption is disabled")
+			resp.RenderError(w, r, http.StatusForbidden, "Server-side encryption is disabled, send a ciphertext")
 			return
 		}
 
+		for _, hook := range o.preSaveHooks {
+			if err := hook.PreSave(r.Context(), &req); err != nil {
+				log.Info("Save rejected by hook", slog.Any("error", err))
+				resp.RenderError(w, r, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+
+		highEntropy := o.entropyThreshold > 0 && req.Ciphertext == "" && looksEncrypted(req.Message, o.entropyThreshold)
+		if highEntropy && o.rejectHighEntropy {
+			log.Info("Message looks already encrypted")
+			resp.RenderValidationError(w, r, []resp.ValidationError{{
+				Field: "message",
+				Error: i18n.Translate(i18n.FromRequest(r), "Message looks already encrypted"),
+			}})
+			return
+		}
+
+		if o.rateLimiter != nil {
+			allowed, retryAfter, err := o.rateLimiter.Allow(saveRateLimit, o.maxSavesPerMinute, time.Minute)
+			if status, msg, ok := resp.FromStorageError(err); ok {
+				log.Error("Failed to check save rate limit", slog.Any("error", err))
+				resp.RenderError(w, r, status, msg)
+				return
+			}
+			if err != nil {
+				log.Error("Failed to check save rate limit", slog.Any("error", err))
+				resp.RenderError(w, r, http.StatusInternalServerError, "Failed to save secret")
+				return
+			}
+			if !allowed {
+				log.Warn("Global save rate limit reached", slog.Int("per_minute", o.maxSavesPerMinute))
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				resp.RenderError(w, r, http.StatusTooManyRequests, "Too many secrets are being created, try again later")
+				return
+			}
+		}
+
 		message := req.Message
 		uuid, _ := uuid.NewV4()
 		alias := uuid.String()
+		if o.tenantPrefixer != nil {
+			alias = o.tenantPrefixer.Prefix(identity.User(r.Context())) + alias
+		}
+
+		ttl := time.Duration(req.Expiration) * time.Hour
+
+		var key string
+		var cipherObject []byte
+		if req.Ciphertext != "" {
+			// Validated as base64 above
+			cipherObject, _ = base64.StdEncoding.DecodeString(req.Ciphertext)
+		} else {
+			key, err = cipher.GenerateRandomHexKeyWithSize(o.keySize)
+			if err != nil {
+				log.Error("Failed to generate key", slog.Any("error", err))
+				resp.RenderError(w, r, http.StatusInternalServerError, "Failed to save secret")
+				return
+			}
+
+			secret := dto.Secret{
+				Message:         message,
+				OneTime:         req.OneTime,
+				NotifyEmail:     req.NotifyEmail,
+				ContentType:     req.ContentType,
+				RequireApproval: req.RequireApproval,
+				MaxViews:        req.MaxViews,
+				NotBefore:       req.NotBefore.UTC(),
+				NotAfter:        req.NotAfter.UTC(),
+				CreatedAt:       time.Now().UTC(),
+			}
+			if req.Password != "" {
+				sealed, salt, err := cipher.SealWithPassword([]byte(message), req.Password)
+				if errors.Is(err, cipher.ErrPasswordBusy) {
+					log.Warn("Too many password derivations in progress")
+					w.Header().Set("Retry-After", "1")
+					resp.RenderError(w, r, http.StatusServiceUnavailable, "Server is busy, try again later")
+					return
+				}
+				if err != nil {
+					log.Error("Failed to seal secret with password", slog.Any("error", err))
+					resp.RenderError(w, r, http.StatusInternalServerError, "Failed to save secret")
+					return
+				}
+				secret.Message = base64.StdEncoding.EncodeToString(sealed)
+				secret.PasswordSalt = salt
+			}
+			if !req.CreatedAt.IsZero() {
+				secret.CreatedAt = req.CreatedAt.UTC()
+			}
+			if ttl > 0 {
+				secret.ExpiresAt = time.Now().Add(ttl).UTC()
+			}
 
-		key, err := cipher.GenerateRandomHexKey()
+			if o.maxMetadataBytes > 0 {
+				if size := metadataSize(secret); size > o.maxMetadataBytes {
+					log.Info("Secret metadata is too large", slog.Int("size", size))
+					resp.RenderError(w, r, http.StatusBadRequest, "Secret metadata is too large")
+					return
+				}
+			}
 
-		secret := dto.Secret{
-			Message: message,
-			OneTime: req.OneTime,
+			object, err := json.Marshal(secret)
+			if err != nil {
+				log.Error("Failed to marshal secret", slog.Any("error", err))
+				resp.RenderError(w, r, http.StatusInternalServerError, "Failed to marshal secret")
+				return
+			}
+
+			cipherObject, err = cipher.Encode(object, key)
+			if err != nil {
+				log.Error("Failed to encode secret", slog.Any("error", err))
+				resp.RenderError(w, r, http.StatusInternalServerError, "Failed to encode secret")
+				return
+			}
+
+			// Audit trail of the crypto actually applied, never of the key
+			log.Debug("Secret encrypted",
+				slog.String("alias", alias),
+				slog.String("cipher", cipher.Name(o.keySize)),
+				slog.Int("key_bits", o.keySize*8),
+			)
+		}
+
+		// Split before storing anything, a saved secret whose shares were
+		// never handed out could not be opened by anyone
+		var shares []string
+		if req.Split != nil {
+			shares, err = splitKey(key, req.Split.N, req.Split.K)
+			if err != nil {
+				log.Error("Failed to split key", slog.Any("error", err))
+				resp.RenderError(w, r, http.StatusInternalServerError, "Failed to split key")
+				return
+			}
+		}
+
+		// Metadata and group go first: leftovers of a failed save expire on
+		// their own, while a saved secret missing them can't be moderated
+		// The server can't look inside client encrypted secrets, so how to
+		// serve them is kept in the metadata
+		md := storage.Metadata{AbuseTag: req.AbuseTag}
+		if req.Ciphertext != "" {
+			md.ClientEncrypted = true
+			md.OneTime = req.OneTime
+		}
+		if md != (storage.Metadata{}) {
+			err = saver.SetMetadata(alias, md, ttl)
+			if status, msg, ok := resp.FromStorageError(err); ok {
+				log.Error("Failed to store secret metadata", slog.Any("error", err))
+				resp.RenderError(w, r, status, msg)
+				return
+			}
+			if err != nil {
+				log.Error("Failed to store secret metadata", slog.Any("error", err))
+				resp.RenderError(w, r, http.StatusInternalServerError, "Failed to store secret metadata")
+				return
+			}
 		}
 
-		object, err := json.Marshal(secret)
+		// Join the group first, a member entry whose secret failed to save is
+		// harmless while a saved secret missing from its group is not
+		if req.GroupID != "" {
+			err = saver.AddToGroup(req.GroupID, alias, ttl)
+			if status, msg, ok := resp.FromStorageError(err); ok {
+				log.Error("Failed to add secret to group", slog.Any("error", err))
+				resp.RenderError(w, r, status, msg)
+				return
+			}
+			if err != nil {
+				log.Error("Failed to add secret to group", slog.Any("error", err))
+				resp.RenderError(w, r, http.StatusInternalServerError, "Failed to add secret to group")
+				return
+			}
+		}
+
+		err = saver.Set(alias, cipherObject, ttl)
+		if errors.Is(err, storage.ErrConflict) {
+			// Only a freshly generated alias lands here, a retry gets another
+			log.Error("Alias collided with an existing secret", slog.String("alias", alias))
+			resp.RenderError(w, r, http.StatusConflict, "Secret ID collision, try again")
+			return
+		}
+		if status, msg, ok := resp.FromStorageError(err); ok {
+			log.Error("Failed to store secret", slog.Any("error", err))
+			resp.RenderError(w, r, status, msg)
+			return
+		}
 		if err != nil {
-			log.Error("Failed to marshal secret", slog.Any("error", err))
-			render.Status(r, http.StatusInternalServerError)
-			render.JSON(w, r, resp.Error("Failed to marshal secret"))
+			log.Error("Failed to store secret", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to store secret")
 			return
 		}
 
-		cipherObject, err := cipher.Encode(object, key)
-		if err != nil {
-			log.Error("Failed to encode secret", slog.Any("error", err))
-			render.Status(r, http.StatusInternalServerError)
-			render.JSON(w, r, resp.Error("Failed to encode secret"))
+		log.Info("Secret saved", slog.String("alias", alias))
+
+		if o.metrics != nil {
+			o.metrics.SecretSaved()
+		}
+
+		if o.contentSignals && req.Ciphertext == "" {
+			log.Info("Secret content signals", slog.String("alias", alias), contentSignals(message, o.signalPatterns))
+		}
+
+		// Derived from the stored alias, which support staff look up
+		var shortCode string
+		if o.shortCoder != nil {
+			shortCode = o.shortCoder.Code(alias)
+		}
+
+		if o.aliasSigner != nil {
+			alias = o.aliasSigner.Sign(alias)
+		}
+
+		if req.Ciphertext != "" {
+			render.JSON(w, r, Response{
+				Response:    resp.OK(),
+				Alias:       alias,
+				HumanExpiry: humanExpiry(ttl),
+				ShortCode:   shortCode,
+			})
 			return
 		}
 
-		err = secretSaver.Set(alias, cipherObject, time.Duration(req.Expiration)*time.Hour)
-		if err != nil {
-			log.Error("Url already exists")
-			render.Status(r, http.StatusInternalServerError)
-			render.JSON(w, r, resp.Error("Url already exists"))
+		if shares != nil {
+			render.JSON(w, r, Response{
+				Response:    resp.OK(),
+				Alias:       alias,
+				Shares:      shares,
+				HumanExpiry: humanExpiry(ttl),
+				KeyBits:     o.keySize * 8,
+				Cipher:      cipher.Name(o.keySize),
+				HighEntropy: highEntropy,
+				ShortCode:   shortCode,
+			})
 			return
 		}
 
 		render.JSON(w, r, Response{
-			Response: resp.OK(),
-			Alias:    alias,
-			Key:      key,
+			Response:    resp.OK(),
+			Alias:       alias,
+			Key:         key,
+			URL:         shareurl.WithTitle(o.link(r, alias, key), req.Title),
+			HumanExpiry: humanExpiry(ttl),
+			KeyBits:     o.keySize * 8,
+			Cipher:      cipher.Name(o.keySize),
+			HighEntropy: highEntropy,
+			ShortCode:   shortCode,
 		})
 	}
+
+	if o.dedupeWindow > 0 {
+		return newDedupe(o.dedupeWindow).wrap(handler)
+	}
+	return handler
 }
 
-// Helper function to create user-friendly validation messages
-func formatValidationError(fe validator.FieldError) string {
+// serverSideField names the first field of req that only works when the
+// server can decrypt the secret, or returns an empty string.
+func serverSideField(req Request) string {
+	switch {
+	case req.Message != "":
+		return "message"
+	case req.Split != nil:
+		return "split"
+	case req.NotifyEmail != "":
+		return "notify_email"
+	case req.ContentType != "":
+		return "content_type"
+	case req.RequireApproval:
+		return "require_approval"
+	case req.MaxViews > 0:
+		return "max_views"
+	case req.Password != "":
+		return "password"
+	case !req.NotBefore.IsZero():
+		return "not_before"
+	case !req.NotAfter.IsZero():
+		return "not_after"
+	case !req.CreatedAt.IsZero():
+		return "created_at"
+	}
+	return ""
+}
+
+// splitKey splits the hex key into n hex encoded shares, any k of which
+// rebuild it.
+func splitKey(key string, n, k int) ([]string, error) {
+	keyBytes, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, err
+	}
+
+	parts, err := shamir.Split(keyBytes, n, k)
+	if err != nil {
+		return nil, err
+	}
+
+	shares := make([]string, len(parts))
+	for i, part := range parts {
+		shares[i] = hex.EncodeToString(part)
+	}
+	return shares, nil
+}
+
+// Helper function to create user-friendly validation messages in lang
+func formatValidationError(lang string, fe validator.FieldError, o options) string {
 	switch fe.Tag() {
-	case "required":
-		return "This field is required"
+	case "required", "required_without":
+		return i18n.Translate(lang, "This field is required")
 	case "gte":
-		return "Value must be greater than or equal to " + fe.Param()
+		return fmt.Sprintf(i18n.Translate(lang, "Value must be greater than or equal to %s"), fe.Param())
 	case "lte":
-		return "Value must be less than or equal to " + fe.Param()
+		return fmt.Sprintf(i18n.Translate(lang, "Value must be less than or equal to %s"), fe.Param())
+	case "max":
+		return fmt.Sprintf(i18n.Translate(lang, "Must be at most %s characters"), fe.Param())
+	case "maxttl":
+		return fmt.Sprintf(i18n.Translate(lang, "Value must be less than or equal to %s"), strconv.Itoa(o.maxExpirationHours))
+	case "ltefield":
+		return fmt.Sprintf(i18n.Translate(lang, "Value must be less than or equal to %s"), strings.ToLower(fe.Param()))
+	case "email":
+		return i18n.Translate(lang, "Invalid email address")
+	case "groupid":
+		return i18n.Translate(lang, "Must be 1 to 64 letters, digits, '-' or '_'")
 	// Add more cases for other validation tags you might use
 	default:
-		return "Invalid value" // Generic fallback
+		return i18n.Translate(lang, "Invalid value") // Generic fallback
+	}
+}
+
+// checkAbuseTag returns a translated validation message for tag, or an empty
+// string when it is acceptable.
+func checkAbuseTag(lang, tag string, maxLength int) string {
+	if maxLength <= 0 {
+		return i18n.Translate(lang, "Abuse tags are not enabled")
+	}
+	if len(tag) > maxLength || !abuseTagPattern.MatchString(tag) {
+		return fmt.Sprintf(i18n.Translate(lang, "Must be at most %d letters, digits, '-' or '_'"), maxLength)
+	}
+	return ""
+}
+
+// checkRevealWindow returns the offending field and a translated validation
+// message for the reveal window of req, or an empty message when the window
+// can be honoured.
+func checkRevealWindow(lang string, req Request, enabled bool, now time.Time) (string, string) {
+	field := "not_before"
+	if req.NotBefore.IsZero() {
+		field = "not_after"
+	}
+
+	switch {
+	case !enabled:
+		return field, i18n.Translate(lang, "Reveal windows are not enabled")
+	case !req.NotAfter.IsZero() && !req.NotAfter.After(now):
+		return "not_after", i18n.Translate(lang, "Must be in the future")
+	case !req.NotAfter.IsZero() && !req.NotAfter.After(req.NotBefore):
+		return "not_after", i18n.Translate(lang, "Must be after not_before")
+	case req.Expiration > 0 && !req.NotBefore.Before(now.Add(time.Duration(req.Expiration)*time.Hour)):
+		return "not_before", i18n.Translate(lang, "Must be before the secret expires")
+	}
+	return "", ""
+}
+
+// humanExpiry describes a TTL in words for clients that don't want to do date
+// math. Whole days are used from two days up, whole hours below that.
+func humanExpiry(ttl time.Duration) string {
+	const day = 24 * time.Hour
+
+	switch {
+	case ttl <= 0:
+		return "Never expires"
+	case ttl < time.Minute:
+		return "Expires in less than a minute"
+	case ttl >= 2*day && ttl%day == 0:
+		return "Expires in " + plural(int(ttl/day), "day")
+	case ttl%time.Hour == 0:
+		return "Expires in " + plural(int(ttl/time.Hour), "hour")
+	default:
+		return "Expires in " + plural(int(ttl/time.Minute), "minute")
+	}
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// metadataSize returns how many bytes secret takes serialized without its
+// message.
+func metadataSize(secret dto.Secret) int {
+	secret.Message = ""
+	object, _ := json.Marshal(secret)
+	return len(object)
+}
+
+// secretLimit returns the largest secret user may save, see
+// WithMaxSecretBytes. Anonymous requests have an empty user.
+func (o *options) secretLimit(user string) int {
+	if limit, ok := o.secretBytesByUser[user]; ok && user != "" {
+		return limit
+	}
+	return o.maxSecretBytes
+}
+
+// secretSize returns how many bytes the secret in req takes before
+// encryption, or as ciphertext for client encrypted ones.
+func secretSize(req Request) int {
+	return len(req.Message) + base64.StdEncoding.DecodedLen(len(req.Ciphertext))
+}
+
+// link returns the retrieval URL of a new secret, empty when there is no
+// trusted base to build it on.
+func (o *options) link(r *http.Request, alias, key string) string {
+	switch {
+	case o.publicBaseURL != nil:
+		return shareurl.BuildFrom(o.publicBaseURL, alias, key)
+	case o.requestHostURLs:
+		return shareurl.Build(r, o.forceHTTPS, alias, key)
+	default:
+		return ""
 	}
 }
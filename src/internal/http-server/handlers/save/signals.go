@@ -0,0 +1,51 @@
+package save
+
+import (
+	"log/slog"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+// DefaultSignalPatterns are the well-known credential formats reported by
+// WithContentSignals.
+var DefaultSignalPatterns = map[string]*regexp.Regexp{
+	"private_key":    regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`),
+	"aws_access_key": regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+	"github_token":   regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36}\b`),
+	"jwt":            regexp.MustCompile(`\beyJ[\w-]+\.eyJ[\w-]+\.[\w-]+`),
+}
+
+// lengthBuckets are the upper bounds lengths are reported under, coarse
+// enough that a length can't single out a secret.
+var lengthBuckets = []int{16, 64, 256, 1024, 4096, 16384}
+
+// contentSignals describes message for DLP by features that can't be turned
+// back into it: a length bucket, the entropy rounded to a tenth of a bit per
+// byte and the names of the patterns it matches.
+func contentSignals(message string, patterns map[string]*regexp.Regexp) slog.Attr {
+	matched := []string{}
+	for name, pattern := range patterns {
+		if pattern.MatchString(message) {
+			matched = append(matched, name)
+		}
+	}
+	slices.Sort(matched)
+
+	return slog.Group("signals",
+		slog.String("length", lengthBucket(len(message))),
+		slog.Float64("entropy", math.Round(entropy(message)*10)/10),
+		slog.Any("patterns", matched),
+	)
+}
+
+// lengthBucket returns the bucket of a message of n bytes, like "<256".
+func lengthBucket(n int) string {
+	for _, bound := range lengthBuckets {
+		if n < bound {
+			return "<" + strconv.Itoa(bound)
+		}
+	}
+	return ">=" + strconv.Itoa(lengthBuckets[len(lengthBuckets)-1])
+}
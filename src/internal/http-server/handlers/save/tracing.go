@@ -0,0 +1,51 @@
+package save
+
+import (
+	"context"
+	"time"
+	"yoopass-api/internal/storage"
+	"yoopass-api/internal/telemetry"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithTracer runs every storage call of a save in a span of its own, below
+// the span of the request.
+func WithTracer(tracer trace.Tracer) Option {
+	return func(o *options) {
+		o.tracer = tracer
+	}
+}
+
+// traced returns secretSaver with its calls traced as children of ctx, or
+// secretSaver itself when no tracer is set.
+func traced(ctx context.Context, tracer trace.Tracer, secretSaver SecretSaver) SecretSaver {
+	if tracer == nil {
+		return secretSaver
+	}
+	return tracedSaver{ctx: ctx, tracer: tracer, next: secretSaver}
+}
+
+type tracedSaver struct {
+	ctx    context.Context
+	tracer trace.Tracer
+	next   SecretSaver
+}
+
+func (s tracedSaver) Set(key string, value []byte, ttl time.Duration) error {
+	return telemetry.Run(s.ctx, s.tracer, "Set", func() error {
+		return s.next.Set(key, value, ttl)
+	})
+}
+
+func (s tracedSaver) AddToGroup(group, key string, ttl time.Duration) error {
+	return telemetry.Run(s.ctx, s.tracer, "AddToGroup", func() error {
+		return s.next.AddToGroup(group, key, ttl)
+	})
+}
+
+func (s tracedSaver) SetMetadata(key string, md storage.Metadata, ttl time.Duration) error {
+	return telemetry.Run(s.ctx, s.tracer, "SetMetadata", func() error {
+		return s.next.SetMetadata(key, md, ttl)
+	})
+}
@@ -0,0 +1,254 @@
+package share
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+	"yoopass-api/internal/dto"
+	"yoopass-api/internal/http-server/handlers/response"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	cipher "yoopass-api/internal/tools/cipher"
+	"yoopass-api/internal/tools/shareurl"
+	"yoopass-api/internal/tools/tenant"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+	"github.com/gofrs/uuid"
+)
+
+type Response struct {
+	response.Response
+	Alias string `json:"alias,omitempty"`
+	Key   string `json:"key,omitempty"`
+	URL   string `json:"url,omitempty"`
+}
+
+// Option configures optional behaviour of the share handler.
+type Option func(*options)
+
+type options struct {
+	keyEncoding   cipher.KeyEncoding
+	forceHTTPS    bool
+	publicBaseURL *url.URL
+	requestHost   bool
+	aliasSigner   AliasSigner
+	keySize       int
+}
+
+// WithKeySize generates the keys of copies with size bytes, see
+// save.WithKeySize.
+func WithKeySize(size int) Option {
+	return func(o *options) {
+		o.keySize = size
+	}
+}
+
+// WithKeyEncoding sets which encodings are accepted for the key of the
+// shared secret, see fetch.WithKeyEncoding.
+func WithKeyEncoding(encoding cipher.KeyEncoding) Option {
+	return func(o *options) {
+		o.keyEncoding = encoding
+	}
+}
+
+// WithForceHTTPS makes share URLs built from the request always use https.
+func WithForceHTTPS() Option {
+	return func(o *options) {
+		o.forceHTTPS = true
+	}
+}
+
+// WithPublicBaseURL builds share URLs under base, see
+// save.WithPublicBaseURL.
+func WithPublicBaseURL(base *url.URL) Option {
+	return func(o *options) {
+		o.publicBaseURL = base
+	}
+}
+
+// WithRequestHostURLs builds share URLs from the request without a public
+// base URL, see save.WithRequestHostURLs.
+func WithRequestHostURLs() Option {
+	return func(o *options) {
+		o.requestHost = true
+	}
+}
+
+// AliasSigner signs aliases before they are handed out, see aliassig.
+type AliasSigner interface {
+	Sign(alias string) string
+}
+
+// WithAliasSigner hands out the new alias in its signed form, see
+// save.WithAliasSigner.
+func WithAliasSigner(signer AliasSigner) Option {
+	return func(o *options) {
+		o.aliasSigner = signer
+	}
+}
+
+type SecretSharer interface {
+	// this matches call in storage
+	Fetch(key string) ([]byte, error)
+	TTL(key string) (time.Duration, error)
+	Set(key string, value []byte, ttl time.Duration) error
+}
+
+// New serves POST /{alias}/{key}/share. It copies a multi-view secret to a
+// new alias under a fresh key, so every recipient gets a link of their own
+// that can be revoked by deleting its alias without touching the others. The
+// copy expires together with the original.
+func New(log *slog.Logger, secretSharer SecretSharer, opts ...Option) http.HandlerFunc {
+	o := options{keySize: cipher.KeySize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.share.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.Info("Alias parameter is missing")
+			resp.RenderError(w, r, http.StatusBadRequest, "Alias parameter is missing")
+			return
+		}
+
+		key := chi.URLParam(r, "key")
+		if key == "" {
+			log.Info("Key parameter is missing")
+			resp.RenderError(w, r, http.StatusBadRequest, "Key parameter is missing")
+			return
+		}
+
+		keyBytes, err := cipher.DecodeKey(key, o.keyEncoding)
+		if err != nil {
+			log.Info("Invalid key format", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusBadRequest, "Invalid key format")
+			return
+		}
+
+		cipherObject, err := secretSharer.Fetch(alias)
+		if status, msg, ok := resp.FromStorageError(err); ok {
+			log.Info("Failed to fetch secret", slog.String("alias", alias), slog.Any("error", err))
+			resp.RenderError(w, r, status, msg)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to fetch secret", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to fetch secret")
+			return
+		}
+		if cipherObject == nil {
+			log.Info("Secret not found in storage", slog.String("alias", alias))
+			resp.RenderError(w, r, http.StatusNotFound, "Secret not found")
+			return
+		}
+
+		if err := cipher.Spend(r.Context()); err != nil {
+			log.Warn("Decode budget exhausted", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusBadRequest, "Too many decode attempts")
+			return
+		}
+
+		object, err := cipher.DecodeWithKey(cipherObject, keyBytes)
+		if err != nil {
+			log.Error("Failed to decode secret", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to decode secret")
+			return
+		}
+
+		var secret dto.Secret
+
+		err = json.Unmarshal(object, &secret)
+		if err != nil {
+			log.Warn("Secret unmarshalling failed", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusUnprocessableEntity, "Secret unmarshalling failed")
+			return
+		}
+
+		if !secret.ExpiresAt.IsZero() && !time.Now().Before(secret.ExpiresAt) {
+			log.Info("Secret has expired", slog.String("alias", alias))
+			resp.RenderError(w, r, http.StatusGone, "Secret has expired")
+			return
+		}
+
+		// Copying a one-time secret would let it be read more than once
+		if secret.OneTime {
+			log.Info("One-time secret can't be shared", slog.String("alias", alias))
+			resp.RenderError(w, r, http.StatusConflict, "One-time secrets can't be shared")
+			return
+		}
+
+		ttl, err := secretSharer.TTL(alias)
+		if status, msg, ok := resp.FromStorageError(err); ok {
+			log.Info("Failed to read secret TTL", slog.String("alias", alias), slog.Any("error", err))
+			resp.RenderError(w, r, status, msg)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to read secret TTL", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to share secret")
+			return
+		}
+
+		id, _ := uuid.NewV4()
+		// The copy stays with the tenant of the secret it copies
+		shareAlias := tenant.Of(alias) + id.String()
+
+		shareKey, err := cipher.GenerateRandomHexKeyWithSize(o.keySize)
+		if err != nil {
+			log.Error("Failed to generate key", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to share secret")
+			return
+		}
+
+		shareObject, err := cipher.Encode(object, shareKey)
+		if err != nil {
+			log.Error("Failed to encode secret", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to encode secret")
+			return
+		}
+
+		err = secretSharer.Set(shareAlias, shareObject, ttl)
+		if status, msg, ok := resp.FromStorageError(err); ok {
+			log.Error("Failed to store shared secret", slog.Any("error", err))
+			resp.RenderError(w, r, status, msg)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to store shared secret", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to share secret")
+			return
+		}
+
+		log.Info("Secret shared", slog.String("alias", alias))
+
+		if o.aliasSigner != nil {
+			shareAlias = o.aliasSigner.Sign(shareAlias)
+		}
+
+		var link string
+		switch {
+		case o.publicBaseURL != nil:
+			link = shareurl.BuildFrom(o.publicBaseURL, shareAlias, shareKey)
+		case o.requestHost:
+			link = shareurl.Build(r, o.forceHTTPS, shareAlias, shareKey)
+		}
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Alias:    shareAlias,
+			Key:      shareKey,
+			URL:      link,
+		})
+	}
+}
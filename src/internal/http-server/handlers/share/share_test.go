@@ -0,0 +1,180 @@
+package share
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+	"yoopass-api/internal/dto"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/storage"
+	"yoopass-api/internal/storage/storagemock"
+	"yoopass-api/internal/testutil"
+	"yoopass-api/internal/tools/cipher"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testAlias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+	testKey   = "46da5d3577209271242b42882a034c3d"
+)
+
+func chiCtx(alias, key string) context.Context {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("alias", alias)
+	rctx.URLParams.Add("key", key)
+	return context.WithValue(context.Background(), chi.RouteCtxKey, rctx)
+}
+
+func serveShare(t *testing.T, m *storagemock.Storage, alias, key string, opts ...Option) *httptest.ResponseRecorder {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "share"))
+	req := httptest.NewRequest(http.MethodPost, "/"+alias+"/"+key+"/share", nil).WithContext(chiCtx(alias, key))
+	rr := httptest.NewRecorder()
+	New(log, m, opts...).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestShareHandlerErrors(t *testing.T) {
+	testCases := []struct {
+		name           string
+		key            string
+		setupMock      func(m *storagemock.Storage)
+		expectedStatus int
+		expectedError  string
+	}{
+		{
+			name:           "Error Invalid Key",
+			key:            "not-a-key",
+			setupMock:      func(m *storagemock.Storage) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Invalid key format",
+		},
+		{
+			name: "Error Secret Not Found",
+			key:  testKey,
+			setupMock: func(m *storagemock.Storage) {
+				m.On("Fetch", testAlias).Return(nil, storage.ErrNotFound).Once()
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedError:  "Secret not found",
+		},
+		{
+			name: "Error One-Time Secret",
+			key:  testKey,
+			setupMock: func(m *storagemock.Storage) {
+				m.On("Fetch", testAlias).Return(testutil.BuildCiphertext(t, dto.Secret{Message: "s", OneTime: true}, testKey), nil).Once()
+			},
+			expectedStatus: http.StatusConflict,
+			expectedError:  "One-time secrets can't be shared",
+		},
+		{
+			name: "Error Expired Secret",
+			key:  testKey,
+			setupMock: func(m *storagemock.Storage) {
+				secret := dto.Secret{Message: "s", ExpiresAt: time.Now().Add(-time.Minute)}
+				m.On("Fetch", testAlias).Return(testutil.BuildCiphertext(t, secret, testKey), nil).Once()
+			},
+			expectedStatus: http.StatusGone,
+			expectedError:  "Secret has expired",
+		},
+		{
+			name: "Error Storing Share",
+			key:  testKey,
+			setupMock: func(m *storagemock.Storage) {
+				m.On("Fetch", testAlias).Return(testutil.BuildCiphertext(t, dto.Secret{Message: "s"}, testKey), nil).Once()
+				m.On("TTL", testAlias).Return(time.Hour, nil).Once()
+				m.On("Set", mock.Anything, mock.Anything, time.Hour).Return(errors.New("boom")).Once()
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedError:  "Failed to share secret",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(storagemock.Storage)
+			tc.setupMock(m)
+
+			rr := serveShare(t, m, testAlias, tc.key)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			var body resp.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+			assert.Equal(t, resp.Error(tc.expectedError), body)
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestShareHandlerCreatesIndependentShares(t *testing.T) {
+	original := testutil.BuildCiphertext(t, dto.Secret{Message: "shared secret"}, testKey)
+
+	m := new(storagemock.Storage)
+	m.On("Fetch", testAlias).Return(original, nil).Twice()
+	m.On("TTL", testAlias).Return(90*time.Minute, nil).Twice()
+
+	stored := map[string][]byte{}
+	m.On("Set", mock.Anything, mock.Anything, 90*time.Minute).Run(func(args mock.Arguments) {
+		stored[args.String(0)] = args.Get(1).([]byte)
+	}).Return(nil).Twice()
+
+	var shares []Response
+	for range 2 {
+		rr := serveShare(t, m, testAlias, testKey)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var body Response
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+		shares = append(shares, body)
+	}
+	m.AssertExpectations(t)
+
+	assert.NotEqual(t, shares[0].Alias, shares[1].Alias)
+	assert.NotEqual(t, shares[0].Key, shares[1].Key)
+	assert.NotEqual(t, testKey, shares[0].Key)
+	// Without a public base URL there is nothing trusted to build a link on
+	assert.Empty(t, shares[0].URL)
+
+	for i, share := range shares {
+		object, err := cipher.Decode(stored[share.Alias], share.Key)
+		require.NoError(t, err)
+		var secret dto.Secret
+		require.NoError(t, json.Unmarshal(object, &secret))
+		assert.Equal(t, "shared secret", secret.Message)
+
+		// A share's key opens neither the original nor the other share
+		other := shares[1-i]
+		_, err = cipher.Decode(stored[other.Alias], share.Key)
+		assert.Error(t, err)
+		_, err = cipher.Decode(original, share.Key)
+		assert.Error(t, err)
+	}
+}
+
+func TestShareHandlerRequestHostURLs(t *testing.T) {
+	original := testutil.BuildCiphertext(t, dto.Secret{Message: "shared secret"}, testKey)
+
+	m := new(storagemock.Storage)
+	m.On("Fetch", testAlias).Return(original, nil).Once()
+	m.On("TTL", testAlias).Return(time.Hour, nil).Once()
+	m.On("Set", mock.Anything, mock.Anything, time.Hour).Return(nil).Once()
+
+	rr := serveShare(t, m, testAlias, testKey, WithRequestHostURLs(), WithForceHTTPS())
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var body Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.Equal(t, "https://example.com/"+body.Alias+"/"+body.Key, body.URL)
+	m.AssertExpectations(t)
+}
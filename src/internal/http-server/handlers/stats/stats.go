@@ -0,0 +1,69 @@
+package stats
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+	"yoopass-api/internal/http-server/handlers/response"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/metrics"
+	"yoopass-api/internal/storage"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+)
+
+type Response struct {
+	response.Response
+	TotalSecrets  int64 `json:"total_secrets"`
+	ActiveSecrets int64 `json:"active_secrets"`
+	metrics.Snapshot
+	UptimeSeconds int64 `json:"uptime_seconds"`
+}
+
+type StatsCounter interface {
+	// this matches call in storage
+	Stats(now time.Time) (storage.Stats, error)
+}
+
+type Snapshotter interface {
+	Snapshot() metrics.Snapshot
+}
+
+// New serves GET /admin/stats, a JSON snapshot of the stored secrets and of
+// what this instance did since it started, for a quick look without a
+// metrics stack. The counters are per instance. The route must sit behind
+// authentication.
+func New(log *slog.Logger, statsCounter StatsCounter, counters Snapshotter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.stats.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		stored, err := statsCounter.Stats(time.Now())
+		if status, msg, ok := resp.FromStorageError(err); ok {
+			log.Error("Failed to count secrets", slog.Any("error", err))
+			resp.RenderError(w, r, status, msg)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to count secrets", slog.Any("error", err))
+			resp.RenderError(w, r, http.StatusInternalServerError, "Failed to count secrets")
+			return
+		}
+
+		snapshot := counters.Snapshot()
+
+		w.Header().Set("Cache-Control", "no-store")
+		render.JSON(w, r, Response{
+			Response:      resp.OK(),
+			TotalSecrets:  stored.Total,
+			ActiveSecrets: stored.Active,
+			Snapshot:      snapshot,
+			UptimeSeconds: int64(snapshot.Uptime / time.Second),
+		})
+	}
+}
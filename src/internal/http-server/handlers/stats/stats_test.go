@@ -0,0 +1,69 @@
+package stats
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/metrics"
+	"yoopass-api/internal/storage"
+	"yoopass-api/internal/storage/storagemock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsHandler(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With(slog.String("test", "stats"))
+
+	counters := metrics.New()
+	counters.SecretSaved()
+	counters.SecretRead(true)
+
+	testCases := []struct {
+		name           string
+		stats          storage.Stats
+		err            error
+		expectedStatus int
+		expectedError  string
+	}{
+		{name: "Success", stats: storage.Stats{Total: 5, Active: 4}, expectedStatus: http.StatusOK},
+		{name: "Storage Unavailable", err: storage.ErrUnavailable, expectedStatus: http.StatusServiceUnavailable, expectedError: "Storage is unavailable"},
+		{name: "Unexpected Error", err: errors.New("boom"), expectedStatus: http.StatusInternalServerError, expectedError: "Failed to count secrets"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStorage := new(storagemock.Storage)
+			mockStorage.On("Stats", mock.Anything).Return(tc.stats, tc.err).Once()
+
+			rr := httptest.NewRecorder()
+			New(log, mockStorage, counters).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/stats", nil))
+
+			require.Equal(t, tc.expectedStatus, rr.Code)
+			if tc.expectedError != "" {
+				expectedJson, err := json.Marshal(resp.Error(tc.expectedError))
+				require.NoError(t, err)
+				assert.JSONEq(t, string(expectedJson), rr.Body.String())
+				return
+			}
+
+			var body map[string]any
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+			assert.Equal(t, "OK", body["status"])
+			assert.EqualValues(t, 5, body["total_secrets"])
+			assert.EqualValues(t, 4, body["active_secrets"])
+			assert.EqualValues(t, 1, body["saves"])
+			assert.EqualValues(t, 1, body["fetches"])
+			assert.EqualValues(t, 1, body["burns"])
+			assert.EqualValues(t, 0, body["decode_failures"])
+			assert.Contains(t, body, "uptime_seconds")
+			mockStorage.AssertExpectations(t)
+		})
+	}
+}
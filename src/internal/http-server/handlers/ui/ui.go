@@ -0,0 +1,22 @@
+package ui
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+//go:embed static/index.html
+var index []byte
+
+// New serves the built-in page for creating and reading secrets by hand. It
+// only talks to the public JSON API, so it needs no dependencies.
+func New() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		// Links carry the key in the fragment, never leak it via Referer
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = w.Write(index)
+	}
+}
@@ -0,0 +1,55 @@
+package aliascheck
+
+import (
+	"log/slog"
+	"net/http"
+	resp "yoopass-api/internal/http-server/handlers/response"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+)
+
+// Verifier checks a signed alias and returns the bare one.
+type Verifier interface {
+	Verify(signed string) (string, error)
+}
+
+// New returns a middleware that checks the signature of the {alias} route
+// parameter and hands the bare alias on to the handler, answering 400 for
+// a bad one before any storage lookup. It has to be mounted inline, with
+// With or Group, so the route is already matched. A nil verifier disables
+// the check.
+func New(log *slog.Logger, verifier Verifier) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if verifier == nil {
+			return next
+		}
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			rctx := chi.RouteContext(r.Context())
+			if rctx != nil {
+				for i, name := range rctx.URLParams.Keys {
+					if name != "alias" {
+						continue
+					}
+
+					alias, err := verifier.Verify(rctx.URLParams.Values[i])
+					if err != nil {
+						log.Info("Rejected alias",
+							slog.String("op", "middleware.aliascheck"),
+							slog.String("request_id", middleware.GetReqID(r.Context())),
+							slog.Any("error", err),
+						)
+						resp.RenderError(w, r, http.StatusBadRequest, "Invalid alias signature")
+						return
+					}
+					rctx.URLParams.Values[i] = alias
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,94 @@
+package bodylimit
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/http-server/middleware/identity"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+// Option configures optional behaviour of the body limit.
+type Option func(*options)
+
+type options struct {
+	authenticatedMaxBytes int64
+}
+
+// WithAuthenticatedLimit applies maxBytes instead to requests from users
+// the identity middleware authenticated, which must run first. A
+// non-positive maxBytes keeps the default limit for them too.
+func WithAuthenticatedLimit(maxBytes int64) Option {
+	return func(o *options) {
+		o.authenticatedMaxBytes = maxBytes
+	}
+}
+
+// New returns a middleware that rejects request bodies over maxBytes with a
+// 413 before any handler sees them. The body is read up front, so handlers
+// never buffer more than maxBytes however they decode it. A non-positive
+// maxBytes disables the limit.
+func New(log *slog.Logger, maxBytes int64, opts ...Option) func(next http.Handler) http.Handler {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return func(next http.Handler) http.Handler {
+		if maxBytes <= 0 {
+			return next
+		}
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			maxBytes := maxBytes
+			if o.authenticatedMaxBytes > 0 && identity.User(r.Context()) != "" {
+				maxBytes = o.authenticatedMaxBytes
+			}
+
+			if r.ContentLength > maxBytes {
+				reject(log, w, r)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					reject(log, w, r)
+					return
+				}
+
+				log.Info("Failed to read request body",
+					slog.String("op", "middleware.bodylimit"),
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+					slog.Any("error", err),
+				)
+				resp.RenderError(w, r, http.StatusBadRequest, "Failed to read or decode request body.")
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func reject(log *slog.Logger, w http.ResponseWriter, r *http.Request) {
+	log.Warn("Rejected oversized request body",
+		slog.String("op", "middleware.bodylimit"),
+		slog.Int64("content_length", r.ContentLength),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+	)
+	resp.RenderError(w, r, http.StatusRequestEntityTooLarge, "Request body is too large")
+}
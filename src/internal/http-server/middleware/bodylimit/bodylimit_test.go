@@ -0,0 +1,93 @@
+package bodylimit
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/http-server/middleware/identity"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyLimit(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	testCases := []struct {
+		name           string
+		maxBytes       int64
+		body           string
+		hideLength     bool
+		expectedStatus int
+	}{
+		{name: "Body Under Limit", maxBytes: 16, body: `{"a":"b"}`, expectedStatus: http.StatusOK},
+		{name: "Body At Limit", maxBytes: 9, body: `{"a":"b"}`, expectedStatus: http.StatusOK},
+		{name: "Body Over Limit", maxBytes: 8, body: `{"a":"b"}`, expectedStatus: http.StatusRequestEntityTooLarge},
+		{name: "Chunked Body Over Limit", maxBytes: 8, body: `{"a":"b"}`, hideLength: true, expectedStatus: http.StatusRequestEntityTooLarge},
+		{name: "Limit Disabled", maxBytes: 0, body: strings.Repeat("x", 1<<16), expectedStatus: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				assert.Equal(t, tc.body, string(body), "handler must still see the full body")
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(tc.body))
+			if tc.hideLength {
+				req.ContentLength = -1
+			}
+			rr := httptest.NewRecorder()
+			New(log, tc.maxBytes)(next).ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			if tc.expectedStatus == http.StatusRequestEntityTooLarge {
+				var body resp.Response
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, resp.Error("Request body is too large"), body)
+			}
+		})
+	}
+}
+
+func TestBodyLimitAuthenticated(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	testCases := []struct {
+		name           string
+		user           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Anonymous Under Default Limit", body: strings.Repeat("x", 8), expectedStatus: http.StatusOK},
+		{name: "Anonymous Over Default Limit", body: strings.Repeat("x", 9), expectedStatus: http.StatusRequestEntityTooLarge},
+		{name: "Authenticated Over Default Limit", user: "admin", body: strings.Repeat("x", 9), expectedStatus: http.StatusOK},
+		{name: "Authenticated At Own Limit", user: "admin", body: strings.Repeat("x", 32), expectedStatus: http.StatusOK},
+		{name: "Authenticated Over Own Limit", user: "admin", body: strings.Repeat("x", 33), expectedStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(tc.body))
+			if tc.user != "" {
+				req = req.WithContext(identity.NewContext(req.Context(), tc.user))
+			}
+			rr := httptest.NewRecorder()
+			New(log, 8, WithAuthenticatedLimit(32))(next).ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+		})
+	}
+}
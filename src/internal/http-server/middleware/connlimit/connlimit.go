@@ -0,0 +1,74 @@
+package connlimit
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/tools/netutil"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+// limiter counts in-flight requests per client IP. An IP's entry is removed
+// as soon as its last request finishes, so the map only ever holds clients
+// that currently have requests open.
+type limiter struct {
+	mu       sync.Mutex
+	inFlight map[string]int
+	max      int
+}
+
+func (l *limiter) acquire(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight[ip] >= l.max {
+		return false
+	}
+	l.inFlight[ip]++
+	return true
+}
+
+func (l *limiter) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight[ip]--
+	if l.inFlight[ip] <= 0 {
+		delete(l.inFlight, ip)
+	}
+}
+
+// New returns a middleware that lets each client IP have at most maxPerIP
+// requests in flight and answers any further one with 429. It keeps a single
+// source from tying up the server with slow requests. A non-positive
+// maxPerIP disables the limit.
+func New(log *slog.Logger, maxPerIP int) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if maxPerIP <= 0 {
+			return next
+		}
+
+		l := &limiter{inFlight: make(map[string]int), max: maxPerIP}
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ip := netutil.ClientIP(r)
+			if !l.acquire(ip) {
+				log.Warn("Too many concurrent requests",
+					slog.String("op", "middleware.connlimit"),
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+					slog.Int("max_per_ip", maxPerIP),
+				)
+				w.Header().Set("Retry-After", "1")
+				resp.RenderError(w, r, http.StatusTooManyRequests, "Too many concurrent requests")
+				return
+			}
+			defer l.release(ip)
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
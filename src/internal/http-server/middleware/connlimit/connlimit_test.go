@@ -0,0 +1,65 @@
+package connlimit
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnLimit(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			entered <- struct{}{}
+			<-unblock
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := New(log, 2)(next)
+
+	serve := func(path, remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// Two slow requests from one IP, on different ports, fill its slots
+	var wg sync.WaitGroup
+	for _, addr := range []string{"203.0.113.7:40000", "203.0.113.7:40001"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, http.StatusOK, serve("/slow", addr))
+		}()
+		<-entered
+	}
+
+	assert.Equal(t, http.StatusTooManyRequests, serve("/fast", "203.0.113.7:40002"), "third request from the same IP")
+	assert.Equal(t, http.StatusOK, serve("/fast", "198.51.100.1:40000"), "other IPs are not affected")
+
+	close(unblock)
+	wg.Wait()
+
+	assert.Equal(t, http.StatusOK, serve("/fast", "203.0.113.7:40003"), "slots are freed when requests finish")
+}
+
+func TestLimiterCleansUp(t *testing.T) {
+	l := &limiter{inFlight: make(map[string]int), max: 1}
+
+	assert.True(t, l.acquire("203.0.113.7"))
+	assert.False(t, l.acquire("203.0.113.7"))
+	l.release("203.0.113.7")
+
+	assert.Empty(t, l.inFlight, "idle IPs must not stay in the map")
+	assert.True(t, l.acquire("203.0.113.7"))
+}
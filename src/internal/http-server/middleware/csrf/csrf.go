@@ -0,0 +1,70 @@
+package csrf
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	resp "yoopass-api/internal/http-server/handlers/response"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+// CookieName is the cookie carrying the token, which the UI sends back in
+// HeaderName. JavaScript has to read it, so it can't be HttpOnly.
+const CookieName = "yoopass_csrf"
+
+// HeaderName is the header requests from the UI echo the token in.
+const HeaderName = "X-CSRF-Token"
+
+// Issue returns a middleware that hands out a CSRF token cookie with the
+// page it wraps, keeping a token the browser already has.
+func Issue(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie(CookieName); err != nil || cookie.Value == "" {
+			http.SetCookie(w, &http.Cookie{
+				Name:     CookieName,
+				Value:    rand.Text(),
+				Path:     "/",
+				Secure:   r.TLS != nil,
+				SameSite: http.SameSiteStrictMode,
+			})
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// New returns a middleware that rejects browser requests with 403 unless the
+// token in HeaderName matches the cookie, as a double-submit check. Browsers
+// always send Origin or Sec-Fetch-Site with a POST, while API clients don't,
+// so those are passed on with or without credentials. Browsers replay cached
+// basic credentials to any form, so credentials alone never exempt a
+// browser request.
+func New(log *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Origin") == "" && r.Header.Get("Sec-Fetch-Site") == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cookie, err := r.Cookie(CookieName)
+			token := r.Header.Get(HeaderName)
+			if err != nil || cookie.Value == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(token)) != 1 {
+				log.Warn("Rejected request without a valid CSRF token",
+					slog.String("op", "middleware.csrf"),
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+				)
+				resp.RenderError(w, r, http.StatusForbidden, "Invalid CSRF token")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,93 @@
+package csrf
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	resp "yoopass-api/internal/http-server/handlers/response"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssue(t *testing.T) {
+	page := Issue(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rr := httptest.NewRecorder()
+	page.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookies := rr.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, CookieName, cookies[0].Name)
+	assert.NotEmpty(t, cookies[0].Value)
+	assert.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+
+	// A browser that has a token keeps it
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookies[0])
+	rr = httptest.NewRecorder()
+	page.ServeHTTP(rr, req)
+	assert.Empty(t, rr.Result().Cookies())
+}
+
+func TestCSRF(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	testCases := []struct {
+		name           string
+		origin         string
+		fetchSite      string
+		cookie         string
+		header         string
+		basicAuth      bool
+		expectedStatus int
+	}{
+		{name: "Valid Token", origin: "https://yoopass.example", cookie: "token", header: "token", expectedStatus: http.StatusOK},
+		{name: "Missing Token", origin: "https://yoopass.example", cookie: "token", expectedStatus: http.StatusForbidden},
+		{name: "Missing Cookie", origin: "https://yoopass.example", header: "token", expectedStatus: http.StatusForbidden},
+		{name: "Mismatched Token", origin: "https://yoopass.example", cookie: "token", header: "forged", expectedStatus: http.StatusForbidden},
+		{name: "Cross Site Form", fetchSite: "cross-site", expectedStatus: http.StatusForbidden},
+		{name: "Browser With Cached Credentials", origin: "https://evil.example", basicAuth: true, expectedStatus: http.StatusForbidden},
+		{name: "API Client", expectedStatus: http.StatusOK},
+		{name: "Authenticated API Client", basicAuth: true, expectedStatus: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"message":"m"}`))
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			if tc.fetchSite != "" {
+				req.Header.Set("Sec-Fetch-Site", tc.fetchSite)
+			}
+			if tc.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: CookieName, Value: tc.cookie})
+			}
+			if tc.header != "" {
+				req.Header.Set(HeaderName, tc.header)
+			}
+			if tc.basicAuth {
+				req.SetBasicAuth("admin", "s3cret")
+			}
+			rr := httptest.NewRecorder()
+			New(log)(next).ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			if tc.expectedStatus == http.StatusForbidden {
+				var body resp.Response
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, resp.Error("Invalid CSRF token"), body)
+			}
+		})
+	}
+}
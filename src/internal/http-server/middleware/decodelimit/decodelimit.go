@@ -0,0 +1,23 @@
+package decodelimit
+
+import (
+	"net/http"
+	cipher "yoopass-api/internal/tools/cipher"
+)
+
+// New returns a middleware that gives every request a budget of max decrypt
+// and key derivation operations, see cipher.WithBudget. Handlers reject the
+// request with 400 once it is spent. A non-positive max disables the limit.
+func New(max int) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if max <= 0 {
+			return next
+		}
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(cipher.WithBudget(r.Context(), max)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
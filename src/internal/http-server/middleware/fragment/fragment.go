@@ -0,0 +1,29 @@
+package fragment
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Strip returns a middleware that drops a URL fragment a client sent along
+// with the request target. Browsers never send fragments, but a copy-pasted
+// link handed to a script may, and the fragment can carry a title the server
+// must not see, see shareurl.WithTitle. Without this the fragment would end
+// up in the last path segment.
+func Strip(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if i := strings.IndexByte(r.URL.Path, '#'); i >= 0 {
+			r.URL.Path = r.URL.Path[:i]
+			r.URL.Fragment = ""
+			if j := strings.IndexByte(r.URL.RawPath, '#'); j >= 0 {
+				r.URL.RawPath = r.URL.RawPath[:j]
+			}
+			if j := strings.IndexByte(r.RequestURI, '#'); j >= 0 {
+				r.RequestURI = r.RequestURI[:j]
+			}
+		}
+		next.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
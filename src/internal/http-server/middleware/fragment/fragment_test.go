@@ -0,0 +1,36 @@
+package fragment
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStrip(t *testing.T) {
+	testCases := []struct {
+		name         string
+		target       string
+		expectedPath string
+	}{
+		{name: "No Fragment", target: "/alias/key", expectedPath: "/alias/key"},
+		{name: "Title Fragment", target: "/alias/key#title=Deploy%20keys", expectedPath: "/alias/key"},
+		{name: "Empty Fragment", target: "/alias/key#", expectedPath: "/alias/key"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var seen *http.Request
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = r
+			})
+
+			Strip(next).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tc.target, nil))
+
+			assert.Equal(t, tc.expectedPath, seen.URL.Path)
+			assert.Equal(t, tc.expectedPath, seen.URL.EscapedPath())
+			assert.NotContains(t, seen.RequestURI, "#")
+		})
+	}
+}
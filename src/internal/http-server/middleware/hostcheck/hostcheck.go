@@ -0,0 +1,65 @@
+package hostcheck
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	resp "yoopass-api/internal/http-server/handlers/response"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+// New returns a middleware that rejects requests whose Host header is missing
+// or not in the allowed list. Entries may be a bare hostname, which matches any
+// port, or a host:port pair, which must match exactly. An empty list disables
+// the check, which is convenient for local development. Paths in exempt, such
+// as probes sent straight to the pod IP, are let through whatever their host.
+func New(log *slog.Logger, allowed []string, exempt ...string) func(next http.Handler) http.Handler {
+	allowedSet := make(map[string]struct{}, len(allowed))
+	for _, host := range allowed {
+		allowedSet[strings.ToLower(strings.TrimSpace(host))] = struct{}{}
+	}
+
+	return func(next http.Handler) http.Handler {
+		if len(allowedSet) == 0 {
+			return next
+		}
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if !isAllowed(allowedSet, r.Host) && !slices.Contains(exempt, r.URL.Path) {
+				log.Warn("Rejected request with unexpected host",
+					slog.String("op", "middleware.hostcheck"),
+					slog.String("host", r.Host),
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+				)
+				resp.RenderError(w, r, http.StatusBadRequest, "Invalid host header")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func isAllowed(allowed map[string]struct{}, host string) bool {
+	host = strings.ToLower(host)
+	if host == "" {
+		return false
+	}
+
+	if _, ok := allowed[host]; ok {
+		return true
+	}
+
+	hostname, _, err := net.SplitHostPort(host)
+	if err != nil {
+		return false
+	}
+
+	_, ok := allowed[hostname]
+	return ok
+}
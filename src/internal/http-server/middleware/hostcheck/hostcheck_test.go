@@ -0,0 +1,103 @@
+package hostcheck
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	resp "yoopass-api/internal/http-server/handlers/response"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostCheck(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	testCases := []struct {
+		name           string
+		allowed        []string
+		host           string
+		path           string
+		expectedStatus int
+	}{
+		{
+			name:           "Allowed Host",
+			allowed:        []string{"secrets.example.com"},
+			host:           "secrets.example.com",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Allowed Host With Port",
+			allowed:        []string{"secrets.example.com"},
+			host:           "Secrets.Example.com:8082",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Allowed Exact Host And Port",
+			allowed:        []string{"localhost:8082"},
+			host:           "localhost:8082",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Disallowed Port For Exact Entry",
+			allowed:        []string{"localhost:8082"},
+			host:           "localhost:9999",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Disallowed Host",
+			allowed:        []string{"secrets.example.com"},
+			host:           "evil.example.com",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Missing Host",
+			allowed:        []string{"secrets.example.com"},
+			host:           "",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Exempt Path",
+			allowed:        []string{"secrets.example.com"},
+			host:           "10.0.0.7:8082",
+			path:           "/healthz",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Empty List Skips Check",
+			allowed:        nil,
+			host:           "anything.example.com",
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			handler := New(log, tc.allowed, "/healthz")(next)
+
+			path := tc.path
+			if path == "" {
+				path = "/"
+			}
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Host = tc.host
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+
+			if tc.expectedStatus == http.StatusBadRequest {
+				expectedJson, err := json.Marshal(resp.Error("Invalid host header"))
+				require.NoError(t, err)
+				assert.JSONEq(t, string(expectedJson), rr.Body.String())
+			}
+		})
+	}
+}
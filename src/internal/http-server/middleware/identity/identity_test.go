@@ -0,0 +1,47 @@
+package identity
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdentity(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	credentials := map[string]string{"admin": "s3cret"}
+
+	testCases := []struct {
+		name         string
+		user         string
+		password     string
+		expectedUser string
+	}{
+		{name: "Anonymous"},
+		{name: "Valid Credentials", user: "admin", password: "s3cret", expectedUser: "admin"},
+		{name: "Wrong Password", user: "admin", password: "guess"},
+		{name: "Unknown User", user: "mallory", password: "s3cret"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var seenUser string
+			handler := New(log, credentials)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seenUser = User(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/add", nil)
+			if tc.user != "" {
+				req.SetBasicAuth(tc.user, tc.password)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, tc.expectedUser, seenUser)
+		})
+	}
+}
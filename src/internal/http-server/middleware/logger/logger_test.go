@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoggerDoesNotLogSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	router := chi.NewRouter()
+	router.Use(New(log))
+	router.Get("/{alias}/{key}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	router.Post("/fetch", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	const key = "46da5d3577209271242b42882a034c3d"
+
+	testCases := []struct {
+		name string
+		req  *http.Request
+	}{
+		{
+			name: "Key In Path",
+			req:  httptest.NewRequest(http.MethodGet, "/f7ab603e-fbae-4182-8379-8763d9327d51/"+key, nil),
+		},
+		{
+			name: "Key In Body",
+			req: httptest.NewRequest(http.MethodPost, "/fetch",
+				strings.NewReader(`{"alias":"f7ab603e-fbae-4182-8379-8763d9327d51","key":"`+key+`"}`)),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buf.Reset()
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, tc.req)
+			require.Equal(t, http.StatusOK, rr.Code)
+
+			logged := buf.String()
+			assert.Contains(t, logged, "request completed")
+			assert.NotContains(t, logged, key, "the key must never be logged")
+			assert.NotContains(t, logged, "f7ab603e", "the raw path must not be logged")
+		})
+	}
+}
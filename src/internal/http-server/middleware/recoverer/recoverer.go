@@ -0,0 +1,45 @@
+package recoverer
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	resp "yoopass-api/internal/http-server/handlers/response"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+// New returns a middleware that recovers from panics in later handlers. The
+// panic and its stack trace are logged with the request id, while the client
+// only gets a generic JSON 500 so no internals leak.
+func New(log *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				rvr := recover()
+				if rvr == nil {
+					return
+				}
+
+				// http.ErrAbortHandler is the documented way to abort a response
+				if err, ok := rvr.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(rvr)
+				}
+
+				log.Error("panic recovered",
+					slog.String("op", "middleware.recoverer"),
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+					slog.Any("panic", rvr),
+					slog.String("stack", string(debug.Stack())),
+				)
+
+				resp.RenderError(w, r, http.StatusInternalServerError, "internal server error")
+			}()
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
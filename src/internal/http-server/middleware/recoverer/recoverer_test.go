@@ -0,0 +1,80 @@
+package recoverer
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	resp "yoopass-api/internal/http-server/handlers/response"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecovererReturnsJSON500(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
+	router.Use(New(log))
+	router.Get("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("database password is hunter2")
+	})
+	router.Get("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	expectedJson, err := json.Marshal(resp.Error("internal server error"))
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expectedJson), rr.Body.String())
+	assert.NotContains(t, rr.Body.String(), "hunter2", "panic details must not reach the client")
+
+	// The panic and stack trace are logged instead
+	logged := buf.String()
+	assert.Contains(t, logged, "panic recovered")
+	assert.Contains(t, logged, "hunter2")
+	assert.Contains(t, logged, "goroutine")
+	assert.Contains(t, logged, "request_id")
+
+	// The server keeps serving after a recovered panic
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/ok", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestRecovererMinimalInternalErrors(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
+	router.Use(resp.WithMinimalInternalErrors())
+	router.Use(New(log))
+	router.Get("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("database password is hunter2")
+	})
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+
+	// Only status, code and the id to look the details up by
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	require.Len(t, body, 3)
+	assert.Equal(t, resp.StatusError, body["status"])
+	assert.Equal(t, resp.CodeInternal, body["code"])
+	require.NotEmpty(t, body["request_id"])
+	assert.Contains(t, buf.String(), `"request_id":"`+body["request_id"]+`"`)
+	assert.Contains(t, buf.String(), "hunter2")
+}
@@ -0,0 +1,38 @@
+package requestid
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	resp "yoopass-api/internal/http-server/handlers/response"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+// New returns a middleware that, when required is set, rejects requests
+// without an X-Request-Id header with 400, for deployments where a gateway
+// must stamp every request so it can be traced end to end. Paths in exempt,
+// such as probes that bypass the gateway, are let through. Without required
+// it does nothing and ids are generated as usual.
+func New(log *slog.Logger, required bool, exempt ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !required {
+			return next
+		}
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(middleware.RequestIDHeader) == "" && !slices.Contains(exempt, r.URL.Path) {
+				log.Warn("Rejected request without request id",
+					slog.String("op", "middleware.requestid"),
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+				)
+				resp.RenderError(w, r, http.StatusBadRequest, "Request id header is missing")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
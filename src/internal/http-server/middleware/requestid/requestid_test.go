@@ -0,0 +1,64 @@
+package requestid
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	resp "yoopass-api/internal/http-server/handlers/response"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireRequestID(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	testCases := []struct {
+		name           string
+		required       bool
+		path           string
+		requestID      string
+		expectedStatus int
+		expectedID     string
+	}{
+		{name: "Off Without Header", path: "/add", expectedStatus: http.StatusOK},
+		{name: "Off With Header", path: "/add", requestID: "gw-1", expectedStatus: http.StatusOK, expectedID: "gw-1"},
+		{name: "On With Header", required: true, path: "/add", requestID: "gw-1", expectedStatus: http.StatusOK, expectedID: "gw-1"},
+		{name: "On Without Header", required: true, path: "/add", expectedStatus: http.StatusBadRequest},
+		{name: "On Exempt Path", required: true, path: "/readyz", expectedStatus: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var seenID string
+			handler := middleware.RequestID(New(log, tc.required, "/readyz")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seenID = middleware.GetReqID(r.Context())
+				w.WriteHeader(http.StatusOK)
+			})))
+
+			req := httptest.NewRequest(http.MethodPost, tc.path, nil)
+			if tc.requestID != "" {
+				req.Header.Set("X-Request-Id", tc.requestID)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.expectedStatus, rr.Code)
+			if tc.expectedStatus != http.StatusOK {
+				expectedJson, err := json.Marshal(resp.Error("Request id header is missing"))
+				require.NoError(t, err)
+				assert.JSONEq(t, string(expectedJson), rr.Body.String())
+				return
+			}
+			if tc.expectedID != "" {
+				assert.Equal(t, tc.expectedID, seenID)
+			} else {
+				assert.NotEmpty(t, seenID, "an id is generated as usual")
+			}
+		})
+	}
+}
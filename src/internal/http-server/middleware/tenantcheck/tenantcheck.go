@@ -0,0 +1,45 @@
+package tenantcheck
+
+import (
+	"log/slog"
+	"net/http"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/http-server/middleware/identity"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+)
+
+// Checker decides whether user may look up alias, see tenant.
+type Checker interface {
+	Check(alias, user string) error
+}
+
+// New returns a middleware that keeps requests away from the {alias} route
+// parameter of another tenant, answering 404 as if the secret didn't exist.
+// The identity middleware must run first. It has to be mounted inline, with
+// With or Group, so the route is already matched. A nil checker disables
+// the check.
+func New(log *slog.Logger, checker Checker) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if checker == nil {
+			return next
+		}
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			alias := chi.URLParam(r, "alias")
+			if err := checker.Check(alias, identity.User(r.Context())); err != nil {
+				log.Info("Rejected alias of another tenant",
+					slog.String("op", "middleware.tenantcheck"),
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+				)
+				resp.RenderError(w, r, http.StatusNotFound, "Secret not found")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
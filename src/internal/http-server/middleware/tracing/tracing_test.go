@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	testCases := []struct {
+		name           string
+		path           string
+		status         int
+		expectedName   string
+		expectedStatus codes.Code
+	}{
+		{name: "Success", path: "/f7ab603e/46da5d35", status: http.StatusOK, expectedName: "GET /{alias}/{key}", expectedStatus: codes.Unset},
+		{name: "Client Error", path: "/f7ab603e/46da5d35", status: http.StatusNotFound, expectedName: "GET /{alias}/{key}", expectedStatus: codes.Unset},
+		{name: "Server Error", path: "/f7ab603e/46da5d35", status: http.StatusInternalServerError, expectedName: "GET /{alias}/{key}", expectedStatus: codes.Error},
+		{name: "Unrouted", path: "/a/b/c", status: http.StatusNotFound, expectedName: "GET", expectedStatus: codes.Unset},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exporter := tracetest.NewInMemoryExporter()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+			router := chi.NewRouter()
+			router.Use(New(provider.Tracer("test"), propagation.TraceContext{}))
+			router.Get("/{alias}/{key}", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+			})
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			spans := exporter.GetSpans()
+			require.Len(t, spans, 1)
+			assert.Equal(t, tc.expectedName, spans[0].Name)
+			assert.Equal(t, tc.expectedStatus, spans[0].Status.Code)
+			assert.Contains(t, spans[0].Attributes, attribute.Int("http.response.status_code", tc.status))
+			for _, attr := range spans[0].Attributes {
+				assert.NotContains(t, attr.Value.Emit(), "46da5d35", "the path must not be recorded")
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package i18n
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLanguage is used when the client doesn't ask for a supported language.
+// Messages in the code base are written in it, so it needs no catalog.
+const DefaultLanguage = "en"
+
+// catalogs maps a language to translations keyed by the English message.
+var catalogs = map[string]map[string]string{
+	"ru": ru,
+}
+
+// Translate returns msg in lang. Messages without a translation, and unknown
+// languages, fall back to the English original.
+func Translate(lang, msg string) string {
+	if translated, ok := catalogs[lang][msg]; ok {
+		return translated
+	}
+	return msg
+}
+
+// FromRequest picks the best supported language from the Accept-Language
+// header, honouring quality values. It returns DefaultLanguage when nothing
+// acceptable is supported.
+func FromRequest(r *http.Request) string {
+	header := r.Header.Get("Accept-Language")
+	if header == "" {
+		return DefaultLanguage
+	}
+
+	type candidate struct {
+		lang string
+		q    float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+
+		// Only the primary subtag matters: "ru-RU" is served the "ru" catalog
+		lang, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		candidates = append(candidates, candidate{lang: lang, q: q})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+
+	for _, c := range candidates {
+		if c.lang == DefaultLanguage {
+			return DefaultLanguage
+		}
+		if _, ok := catalogs[c.lang]; ok {
+			return c.lang
+		}
+	}
+
+	return DefaultLanguage
+}
@@ -0,0 +1,44 @@
+package i18n
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromRequest(t *testing.T) {
+	testCases := []struct {
+		name           string
+		acceptLanguage string
+		expected       string
+	}{
+		{name: "No Header", acceptLanguage: "", expected: "en"},
+		{name: "English", acceptLanguage: "en-US,en;q=0.9", expected: "en"},
+		{name: "Russian", acceptLanguage: "ru", expected: "ru"},
+		{name: "Russian Region", acceptLanguage: "ru-RU", expected: "ru"},
+		{name: "Quality Prefers Russian", acceptLanguage: "en;q=0.5, ru;q=0.8", expected: "ru"},
+		{name: "Quality Prefers English", acceptLanguage: "ru;q=0.3, en", expected: "en"},
+		{name: "Unsupported Falls Through", acceptLanguage: "de, ru;q=0.5", expected: "ru"},
+		{name: "Only Unsupported", acceptLanguage: "de, fr", expected: "en"},
+		{name: "Zero Quality Ignored", acceptLanguage: "ru;q=0", expected: "en"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tc.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tc.acceptLanguage)
+			}
+			assert.Equal(t, tc.expected, FromRequest(req))
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	assert.Equal(t, "Secret not found", Translate("en", "Secret not found"))
+	assert.Equal(t, "Секрет не найден", Translate("ru", "Secret not found"))
+	// Unknown messages and languages fall back to the original text
+	assert.Equal(t, "no such message", Translate("ru", "no such message"))
+	assert.Equal(t, "Secret not found", Translate("xx", "Secret not found"))
+}
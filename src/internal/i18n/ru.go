@@ -0,0 +1,136 @@
+package i18n
+
+var ru = map[string]string{
+	// Generic
+	"internal server error":             "внутренняя ошибка сервера",
+	"Invalid host header":               "Недопустимый заголовок Host",
+	"Request id header is missing":      "Отсутствует заголовок с идентификатором запроса",
+	"Invalid CSRF token":                "Недействительный CSRF-токен",
+	"Not found":                         "Не найдено",
+	"Too many concurrent requests":      "Слишком много одновременных запросов",
+	"Request body is too large":         "Тело запроса слишком большое",
+	"Secret is too large":               "Секрет слишком большой",
+	"Secret is too large for a QR code": "Секрет слишком большой для QR-кода",
+	"Failed to render QR code":          "Не удалось построить QR-код",
+	"Secret metadata is too large":      "Метаданные секрета слишком большие",
+
+	// Fetch
+	"Alias parameter is missing":                  "Не указан параметр alias",
+	"Streaming needs Accept: text/event-stream":   "Для потоковой передачи нужен заголовок Accept: text/event-stream",
+	"Key parameter is missing":                    "Не указан параметр key",
+	"Alias parameter is too long":                 "Параметр alias слишком длинный",
+	"Invalid alias format":                        "Неверный формат псевдонима",
+	"Invalid alias signature":                     "Неверная подпись alias",
+	"Too many decode attempts":                    "Слишком много попыток расшифровки",
+	"Password required":                           "Требуется пароль",
+	"Wrong password":                              "Неверный пароль",
+	"Too many password attempts, try again later": "Слишком много попыток ввода пароля, попробуйте позже",
+	"Server is busy, try again later":             "Сервер перегружен, попробуйте позже",
+	"Key parameter is too long":                   "Параметр key слишком длинный",
+	"Secret not found":                            "Секрет не найден",
+	"Secret has expired":                          "Срок действия секрета истёк",
+	"Secret is not available yet":                 "Секрет пока недоступен",
+	"Secret is no longer available":               "Секрет больше недоступен",
+	"Failed to decode secret":                     "Не удалось расшифровать секрет",
+	"Invalid key format":                          "Некорректный формат ключа",
+	"Secret unmarshalling failed":                 "Не удалось разобрать секрет",
+	"Failed to delete secret":                     "Не удалось удалить секрет",
+	"Unsupported encoding":                        "Неподдерживаемая кодировка",
+	"Send either a key or key shares":             "Передайте либо ключ, либо части ключа",
+	"Secret requires approval":                    "Для получения секрета нужно одобрение",
+	"Failed to check approval":                    "Не удалось проверить одобрение",
+	"Invalid key shares":                          "Некорректные части ключа",
+
+	// Groups
+	"Group id is missing":    "Не указан идентификатор группы",
+	"Group not found":        "Группа не найдена",
+	"Failed to delete group": "Не удалось удалить группу",
+
+	// Revoke
+	"Exactly one of group_id or created_before is required": "Нужно указать ровно одно из полей group_id или created_before",
+	"Failed to revoke secrets":                              "Не удалось отозвать секреты",
+
+	// Approve
+	"Failed to approve secret": "Не удалось одобрить секрет",
+
+	// Reads
+	"Failed to read history":                            "Не удалось получить историю просмотров",
+	"Failed to count secrets":                           "Не удалось подсчитать секреты",
+	"Failed to list dead letters":                       "Не удалось получить недоставленные события",
+	"Failed to inspect secret":                          "Не удалось проверить секрет",
+	"Short code matches several secrets, use the alias": "Короткий код подходит к нескольким секретам, укажите алиас",
+
+	// Metadata
+	"Failed to read secret metadata": "Не удалось прочитать метаданные секрета",
+
+	// Extend
+	"Invalid If-Match header":     "Некорректный заголовок If-Match",
+	"If-Match header is required": "Требуется заголовок If-Match",
+	"Failed to update secret":     "Не удалось обновить секрет",
+
+	// Health
+	"Background workers are stale: %s": "Фоновые задачи зависли: %s",
+	"redis unreachable":                "redis недоступен",
+
+	// Keys
+	"Server-side decryption is disabled": "Расшифровка на сервере отключена",
+
+	// Cleanup
+	"Failed to clean up storage":       "Не удалось очистить хранилище",
+	"Failed to export secrets":         "Не удалось выгрузить секреты",
+	"Failed to import secrets":         "Не удалось загрузить секреты",
+	"Invalid backup record on line %d": "Некорректная запись резервной копии в строке %d",
+
+	// Share
+	"Failed to fetch secret":           "Не удалось получить секрет",
+	"One-time secrets can't be shared": "Одноразовым секретом нельзя поделиться",
+	"Failed to share secret":           "Не удалось поделиться секретом",
+
+	// Save
+	"Invalid JSON syntax near character %d.":                                "Некорректный JSON около символа %d.",
+	"Invalid type for field '%s'. Expected type '%s' but received JSON %s.": "Некорректный тип поля '%s'. Ожидался тип '%s', получен JSON %s.",
+	"Failed to read or decode request body.":                                "Не удалось прочитать или разобрать тело запроса.",
+	"Error during validation":                                               "Ошибка при проверке запроса",
+	"Failed to marshal secret":                                              "Не удалось сериализовать секрет",
+	"Failed to encode secret":                                               "Не удалось зашифровать секрет",
+	"Failed to add secret to group":                                         "Не удалось добавить секрет в группу",
+	"Failed to store secret metadata":                                       "Не удалось сохранить метаданные секрета",
+	"Failed to store secret":                                                "Не удалось сохранить секрет",
+	"Secret ID collision, try again":                                        "Совпадение идентификатора секрета, попробуйте ещё раз",
+	"Failed to split key":                                                   "Не удалось разделить ключ",
+	"Server-side encryption is disabled, send a ciphertext":                 "Шифрование на сервере отключено, отправьте шифротекст",
+	"Failed to save secret":                                                 "Не удалось сохранить секрет",
+	"Too many secrets are being created, try again later":                   "Создаётся слишком много секретов, попробуйте позже",
+	"Message looks already encrypted":                                       "Сообщение похоже на уже зашифрованные данные",
+
+	// Validation
+	"Invalid request":                                "Некорректный запрос",
+	"This field is required":                         "Это поле обязательно",
+	"Value must be greater than or equal to %s":      "Значение должно быть больше или равно %s",
+	"Value must be less than or equal to %s":         "Значение должно быть меньше или равно %s",
+	"Invalid value":                                  "Недопустимое значение",
+	"Invalid email address":                          "Некорректный адрес электронной почты",
+	"Must be 1 to 64 letters, digits, '-' or '_'":    "Допустимы от 1 до 64 букв, цифр, '-' или '_'",
+	"Must be at most %d letters, digits, '-' or '_'": "Допустимо не более %d букв, цифр, '-' или '_'",
+	"Approvals are not enabled":                      "Одобрения отключены",
+	"URL titles are not enabled":                     "Заголовки в ссылках отключены",
+	"Must be at most %s characters":                  "Допустимо не более %s символов",
+	"Reveal windows are not enabled":                 "Окна раскрытия отключены",
+	"Must be in the future":                          "Должно быть в будущем",
+	"Must not be in the future":                      "Не должно быть в будущем",
+	"Can't be combined with an expiration":           "Нельзя сочетать со сроком хранения",
+	"Can't be combined with one_time":                "Нельзя сочетать с one_time",
+	"Set no_expiry for a secret that never expires":  "Укажите no_expiry для бессрочного секрета",
+	"Must be after not_before":                       "Должно быть позже not_before",
+	"Must be before the secret expires":              "Должно быть раньше истечения срока секрета",
+	"Exceeds the maximum lifetime of the secret":     "Превышает максимальный срок жизни секрета",
+	"Abuse tags are not enabled":                     "Метки модерации отключены",
+	"Not available for client encrypted secrets":     "Недоступно для секретов, зашифрованных клиентом",
+
+	// Storage
+	"Secret already exists":            "Секрет уже существует",
+	"Secret was modified concurrently": "Секрет был изменён параллельно",
+	"Storage capacity exceeded":        "Хранилище переполнено",
+	"Storage is unavailable":           "Хранилище недоступно",
+	"Storage timed out":                "Хранилище не ответило вовремя",
+}
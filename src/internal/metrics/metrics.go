@@ -0,0 +1,100 @@
+// Package metrics counts what the service did since it started. The same
+// counters back every way they are exported, so all views agree.
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics holds the counters. The zero value is not usable, see New. A nil
+// *Metrics counts nothing, so callers needn't check whether metrics are on.
+type Metrics struct {
+	started        time.Time
+	saves          atomic.Int64
+	fetches        atomic.Int64
+	burns          atomic.Int64
+	decodeFailures atomic.Int64
+	notFound       atomic.Int64
+
+	mu        sync.Mutex
+	latencies map[string]*histogram
+}
+
+// Snapshot is a point in time copy of the counters.
+type Snapshot struct {
+	Saves          int64         `json:"saves"`
+	Fetches        int64         `json:"fetches"`
+	Burns          int64         `json:"burns"`
+	DecodeFailures int64         `json:"decode_failures"`
+	FetchNotFound  int64         `json:"fetch_not_found"`
+	Uptime         time.Duration `json:"-"`
+}
+
+func New() *Metrics {
+	return &Metrics{started: time.Now(), latencies: make(map[string]*histogram)}
+}
+
+// SecretSaved counts a stored secret.
+func (m *Metrics) SecretSaved() {
+	if m == nil {
+		return
+	}
+	m.saves.Add(1)
+}
+
+// SecretRead counts a revealed secret, burned when it was one-time.
+func (m *Metrics) SecretRead(burned bool) {
+	if m == nil {
+		return
+	}
+	m.fetches.Add(1)
+	if burned {
+		m.burns.Add(1)
+	}
+}
+
+// DecodeFailed counts a secret that failed to decrypt, most often because
+// of a wrong key.
+func (m *Metrics) DecodeFailed() {
+	if m == nil {
+		return
+	}
+	m.decodeFailures.Add(1)
+}
+
+// FetchNotFound counts a reveal of a secret that wasn't stored.
+func (m *Metrics) FetchNotFound() {
+	if m == nil {
+		return
+	}
+	m.notFound.Add(1)
+}
+
+// ObserveLatency records how long handler took to answer a request.
+func (m *Metrics) ObserveLatency(handler string, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	h, ok := m.latencies[handler]
+	if !ok {
+		h = new(histogram)
+		m.latencies[handler] = h
+	}
+	h.observe(elapsed)
+	m.mu.Unlock()
+}
+
+// Snapshot returns the current counters.
+func (m *Metrics) Snapshot() Snapshot {
+	return Snapshot{
+		Saves:          m.saves.Load(),
+		Fetches:        m.fetches.Load(),
+		Burns:          m.burns.Load(),
+		DecodeFailures: m.decodeFailures.Load(),
+		FetchNotFound:  m.notFound.Load(),
+		Uptime:         time.Since(m.started),
+	}
+}
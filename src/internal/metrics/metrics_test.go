@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	m := New()
+
+	m.SecretSaved()
+	m.SecretSaved()
+	m.SecretRead(false)
+	m.SecretRead(true)
+	m.DecodeFailed()
+	m.FetchNotFound()
+
+	snapshot := m.Snapshot()
+	assert.Equal(t, int64(2), snapshot.Saves)
+	assert.Equal(t, int64(2), snapshot.Fetches)
+	assert.Equal(t, int64(1), snapshot.Burns)
+	assert.Equal(t, int64(1), snapshot.DecodeFailures)
+	assert.Equal(t, int64(1), snapshot.FetchNotFound)
+	assert.Positive(t, snapshot.Uptime)
+
+	// Disabled metrics count nothing and don't panic
+	var disabled *Metrics
+	disabled.SecretSaved()
+	disabled.SecretRead(true)
+	disabled.DecodeFailed()
+	disabled.FetchNotFound()
+	disabled.ObserveLatency("save", time.Second)
+}
+
+func TestWritePrometheus(t *testing.T) {
+	m := New()
+	m.SecretSaved()
+	m.SecretRead(true)
+	m.FetchNotFound()
+	m.ObserveLatency("fetch", 20*time.Millisecond)
+	m.ObserveLatency("fetch", 3*time.Second)
+	m.ObserveLatency("save", time.Minute)
+
+	var out strings.Builder
+	require.NoError(t, m.WritePrometheus(&out))
+
+	for _, line := range []string{
+		"# TYPE yoopass_secrets_created_total counter",
+		"yoopass_secrets_created_total 1",
+		"yoopass_secrets_fetched_total 1",
+		"yoopass_secrets_burned_total 1",
+		"yoopass_fetch_not_found_total 1",
+		"yoopass_decode_failures_total 0",
+		"# TYPE yoopass_handler_duration_seconds histogram",
+		`yoopass_handler_duration_seconds_bucket{handler="fetch",le="0.01"} 0`,
+		`yoopass_handler_duration_seconds_bucket{handler="fetch",le="0.025"} 1`,
+		`yoopass_handler_duration_seconds_bucket{handler="fetch",le="5"} 2`,
+		`yoopass_handler_duration_seconds_bucket{handler="fetch",le="+Inf"} 2`,
+		`yoopass_handler_duration_seconds_sum{handler="fetch"} 3.02`,
+		`yoopass_handler_duration_seconds_count{handler="fetch"} 2`,
+		`yoopass_handler_duration_seconds_bucket{handler="save",le="10"} 0`,
+		`yoopass_handler_duration_seconds_bucket{handler="save",le="+Inf"} 1`,
+	} {
+		assert.Contains(t, strings.Split(out.String(), "\n"), line)
+	}
+}
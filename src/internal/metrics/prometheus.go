@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// latencyBuckets are the upper bounds in seconds of the handler latency
+// histogram, the Prometheus client defaults.
+var latencyBuckets = [...]float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// histogram counts observations per bucket of latencyBuckets, the last count
+// being those above every bound.
+type histogram struct {
+	counts [len(latencyBuckets) + 1]int64
+	sum    time.Duration
+	total  int64
+}
+
+func (h *histogram) observe(elapsed time.Duration) {
+	i, _ := slices.BinarySearch(latencyBuckets[:], elapsed.Seconds())
+	h.counts[i]++
+	h.sum += elapsed
+	h.total++
+}
+
+// WritePrometheus writes the counters in the Prometheus text exposition
+// format, for scraping from GET /metrics.
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	snapshot := m.Snapshot()
+	bw := bufio.NewWriter(w)
+
+	counter := func(name, help string, value int64) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, value)
+	}
+	counter("yoopass_secrets_created_total", "Secrets stored.", snapshot.Saves)
+	counter("yoopass_secrets_fetched_total", "Secrets revealed.", snapshot.Fetches)
+	counter("yoopass_secrets_burned_total", "One-time secrets burned by their reveal.", snapshot.Burns)
+	counter("yoopass_fetch_not_found_total", "Reveals of secrets that weren't stored.", snapshot.FetchNotFound)
+	counter("yoopass_decode_failures_total", "Secrets that failed to decrypt.", snapshot.DecodeFailures)
+
+	fmt.Fprintf(bw, "# HELP yoopass_uptime_seconds Time since the instance started.\n# TYPE yoopass_uptime_seconds gauge\nyoopass_uptime_seconds %s\n",
+		strconv.FormatFloat(snapshot.Uptime.Seconds(), 'f', -1, 64))
+
+	const latency = "yoopass_handler_duration_seconds"
+	fmt.Fprintf(bw, "# HELP %s Time taken to answer a request, by handler.\n# TYPE %s histogram\n", latency, latency)
+	m.mu.Lock()
+	for _, handler := range slices.Sorted(maps.Keys(m.latencies)) {
+		h := m.latencies[handler]
+		var cumulative int64
+		for i, bound := range latencyBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(bw, "%s_bucket{handler=%q,le=\"%s\"} %d\n", latency, handler, strconv.FormatFloat(bound, 'f', -1, 64), cumulative)
+		}
+		fmt.Fprintf(bw, "%s_bucket{handler=%q,le=\"+Inf\"} %d\n", latency, handler, h.total)
+		fmt.Fprintf(bw, "%s_sum{handler=%q} %s\n", latency, handler, strconv.FormatFloat(h.sum.Seconds(), 'f', -1, 64))
+		fmt.Fprintf(bw, "%s_count{handler=%q} %d\n", latency, handler, h.total)
+	}
+	m.mu.Unlock()
+
+	return bw.Flush()
+}
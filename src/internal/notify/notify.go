@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Sender delivers a plain-text email.
+type Sender interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// Notifier sends read receipts to secret creators. Receipts never contain
+// the secret itself, only the fact that it was read.
+type Notifier struct {
+	sender          Sender
+	timeout         time.Duration
+	includeClientIP bool
+	now             func() time.Time
+}
+
+func New(sender Sender, timeout time.Duration, includeClientIP bool) *Notifier {
+	return &Notifier{
+		sender:          sender,
+		timeout:         timeout,
+		includeClientIP: includeClientIP,
+		now:             time.Now,
+	}
+}
+
+// SecretRead sends a read receipt to the given address in the background, so
+// a slow mail server never delays the response. Failures are only logged.
+func (n *Notifier) SecretRead(log *slog.Logger, to, clientIP string) {
+	subject, body := n.readReceipt(clientIP)
+
+	go func() {
+		ctx := context.Background()
+		if n.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, n.timeout)
+			defer cancel()
+		}
+
+		if err := n.sender.Send(ctx, to, subject, body); err != nil {
+			log.Warn("Failed to send read receipt", slog.Any("error", err))
+		}
+	}()
+}
+
+func (n *Notifier) readReceipt(clientIP string) (subject, body string) {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "A secret you shared was accessed at %s.\n", n.now().UTC().Format(time.RFC1123))
+	if n.includeClientIP && clientIP != "" {
+		fmt.Fprintf(&b, "It was read from %s.\n", clientIP)
+	}
+
+	return "Your secret was read", b.String()
+}
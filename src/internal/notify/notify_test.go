@@ -0,0 +1,69 @@
+package notify
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sentMail struct {
+	to, subject, body string
+}
+
+type fakeSender struct {
+	sent chan sentMail
+}
+
+func (f *fakeSender) Send(_ context.Context, to, subject, body string) error {
+	f.sent <- sentMail{to: to, subject: subject, body: body}
+	return nil
+}
+
+func TestSecretReadSendsReceipt(t *testing.T) {
+	readAt := time.Date(2025, 3, 14, 15, 9, 26, 0, time.UTC)
+
+	testCases := []struct {
+		name            string
+		includeClientIP bool
+		expectIP        bool
+	}{
+		{name: "Without Client IP", includeClientIP: false, expectIP: false},
+		{name: "With Client IP", includeClientIP: true, expectIP: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sender := &fakeSender{sent: make(chan sentMail, 1)}
+			n := New(sender, time.Second, tc.includeClientIP)
+			n.now = func() time.Time { return readAt }
+
+			n.SecretRead(slog.New(slog.NewTextHandler(io.Discard, nil)), "owner@example.com", "203.0.113.7")
+
+			select {
+			case mail := <-sender.sent:
+				assert.Equal(t, "owner@example.com", mail.to)
+				assert.Equal(t, "Your secret was read", mail.subject)
+				assert.Contains(t, mail.body, readAt.Format(time.RFC1123))
+				if tc.expectIP {
+					assert.Contains(t, mail.body, "203.0.113.7")
+				} else {
+					assert.NotContains(t, mail.body, "203.0.113.7")
+				}
+			case <-time.After(time.Second):
+				require.FailNow(t, "read receipt was not sent")
+			}
+		})
+	}
+}
+
+func TestSMTPSenderRejectsHeaderInjection(t *testing.T) {
+	s := NewSMTPSender("localhost", 25, "", "", "noreply@example.com")
+
+	err := s.Send(context.Background(), "a@example.com\r\nBcc: b@example.com", "subject", "body")
+	assert.ErrorIs(t, err, ErrInvalidAddress)
+}
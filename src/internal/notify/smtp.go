@@ -0,0 +1,108 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidAddress = errors.New("invalid email address")
+
+// SMTPSender sends emails through an SMTP server, upgrading to TLS when the
+// server offers STARTTLS.
+type SMTPSender struct {
+	host string
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func NewSMTPSender(host string, port int, username, password, from string) *SMTPSender {
+	s := &SMTPSender{
+		host: host,
+		addr: net.JoinHostPort(host, strconv.Itoa(port)),
+		from: from,
+	}
+	if username != "" {
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return s
+}
+
+func (s *SMTPSender) Send(ctx context.Context, to, subject, body string) error {
+	const op = "notify.smtp.Send"
+
+	// Addresses end up in headers, so line breaks would allow header injection
+	if strings.ContainsAny(to, "\r\n") {
+		return fmt.Errorf("%s: %w", op, ErrInvalidAddress)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if s.auth != nil {
+		if err := client.Auth(s.auth); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := client.Mail(s.from); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := client.Rcpt(to); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	wc, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err := wc.Write(s.message(to, subject, body)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := wc.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return client.Quit()
+}
+
+func (s *SMTPSender) message(to, subject, body string) []byte {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "From: %s\r\n", s.from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	return []byte(b.String())
+}
@@ -0,0 +1,220 @@
+package keyring
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+	"yoopass-api/internal/storage"
+	cipher "yoopass-api/internal/tools/cipher"
+)
+
+// envelopePrefix starts every wrapped value, followed by the key id, a dot
+// and the value sealed under that key.
+var envelopePrefix = []byte("kr1.")
+
+// keyIDPattern keeps ids free of the dot that ends them in the envelope.
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+var (
+	// ErrUnknownKeyID is returned for a value wrapped under a key id that is
+	// no longer in the keyring.
+	ErrUnknownKeyID = errors.New("unknown key id")
+	// ErrMalformed is returned for a value whose envelope can't be read.
+	ErrMalformed = errors.New("malformed keyring envelope")
+)
+
+// Keyring holds the server-side wrapping keys by id. New values are wrapped
+// under the active key, existing ones are opened with the key their envelope
+// names, so keys can be added and rotated without rewriting stored secrets.
+type Keyring struct {
+	active string
+	keys   map[string][]byte
+}
+
+// New returns a Keyring wrapping under active. Every key has to be a valid
+// AES key.
+func New(keys map[string][]byte, active string) (*Keyring, error) {
+	for id, key := range keys {
+		if !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("invalid key id %q", id)
+		}
+		if !slices.Contains(cipher.KeySizes, len(key)) {
+			return nil, fmt.Errorf("key %q: %w", id, cipher.ErrInvalidKey)
+		}
+	}
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("active key %q: %w", active, ErrUnknownKeyID)
+	}
+
+	return &Keyring{active: active, keys: keys}, nil
+}
+
+// Wrap seals value under the active key.
+func (k *Keyring) Wrap(value []byte) ([]byte, error) {
+	sealed, err := cipher.EncodeWithKey(value, k.keys[k.active])
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped := make([]byte, 0, len(envelopePrefix)+len(k.active)+1+len(sealed))
+	wrapped = append(wrapped, envelopePrefix...)
+	wrapped = append(wrapped, k.active...)
+	wrapped = append(wrapped, '.')
+	return append(wrapped, sealed...), nil
+}
+
+// Unwrap opens a value sealed by Wrap. Values stored before the keyring was
+// enabled carry no envelope and are returned as they are.
+func (k *Keyring) Unwrap(value []byte) ([]byte, error) {
+	rest, ok := bytes.CutPrefix(value, envelopePrefix)
+	if !ok {
+		return value, nil
+	}
+
+	id, sealed, ok := bytes.Cut(rest, []byte("."))
+	if !ok {
+		return nil, ErrMalformed
+	}
+	key, ok := k.keys[string(id)]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKeyID, id)
+	}
+
+	return cipher.DecodeWithKey(sealed, key)
+}
+
+// Store wraps every secret written to the underlying storage with a
+// Keyring and unwraps it on the way out. Everything else passes through.
+type Store struct {
+	storage.Storage
+	ring *Keyring
+}
+
+// Wrap returns store behind ring.
+func Wrap(store storage.Storage, ring *Keyring) *Store {
+	return &Store{Storage: store, ring: ring}
+}
+
+func (s *Store) Set(key string, value []byte, ttl time.Duration) error {
+	const op = "storage.keyring.Set"
+
+	wrapped, err := s.ring.Wrap(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s.Storage.Set(key, wrapped, ttl)
+}
+
+func (s *Store) Fetch(key string) ([]byte, error) {
+	return s.unwrap("storage.keyring.Fetch")(s.Storage.Fetch(key))
+}
+
+func (s *Store) Consume(key string) ([]byte, error) {
+	return s.unwrap("storage.keyring.Consume")(s.Storage.Consume(key))
+}
+
+// Replace compares old against the unwrapped current value. Wrapped values
+// differ on every write, so the swap itself is made against the stored
+// envelope, which keeps it atomic.
+func (s *Store) Replace(key string, old, value []byte) error {
+	const op = "storage.keyring.Replace"
+
+	current, err := s.Storage.Fetch(key)
+	if err != nil {
+		return err
+	}
+	plain, err := s.ring.Unwrap(current)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !bytes.Equal(plain, old) {
+		return fmt.Errorf("%s: %w", op, storage.ErrStale)
+	}
+
+	wrapped, err := s.ring.Wrap(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s.Storage.Replace(key, current, wrapped)
+}
+
+func (s *Store) Update(key string, value []byte, ttl time.Duration, version int64) (int64, error) {
+	const op = "storage.keyring.Update"
+
+	wrapped, err := s.ring.Wrap(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s.Storage.Update(key, wrapped, ttl, version)
+}
+
+func (s *Store) SetOrGet(key string, value []byte, ttl time.Duration) (bool, []byte, error) {
+	const op = "storage.keyring.SetOrGet"
+
+	wrapped, err := s.ring.Wrap(value)
+	if err != nil {
+		return false, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	stored, existing, err := s.Storage.SetOrGet(key, wrapped, ttl)
+	if err != nil || stored {
+		return stored, nil, err
+	}
+
+	existing, err = s.ring.Unwrap(existing)
+	if err != nil {
+		return false, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return false, existing, nil
+}
+
+func (s *Store) SetGrace(key, holder string, value []byte, ttl time.Duration) error {
+	const op = "storage.keyring.SetGrace"
+
+	wrapped, err := s.ring.Wrap(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s.Storage.SetGrace(key, holder, wrapped, ttl)
+}
+
+func (s *Store) Grace(key, holder string) ([]byte, error) {
+	return s.unwrap("storage.keyring.Grace")(s.Storage.Grace(key, holder))
+}
+
+// Export hands out unwrapped records, so a backup restores into an instance
+// with a different keyring. They stay encrypted with their per-secret keys.
+func (s *Store) Export(fn func(storage.Record) error) error {
+	const op = "storage.keyring.Export"
+
+	return s.Storage.Export(func(record storage.Record) error {
+		ciphertext, err := s.ring.Unwrap(record.Ciphertext)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", op, record.Alias, err)
+		}
+		record.Ciphertext = ciphertext
+		return fn(record)
+	})
+}
+
+// unwrap returns a func unwrapping the results of a read named op.
+func (s *Store) unwrap(op string) func([]byte, error) ([]byte, error) {
+	return func(value []byte, err error) ([]byte, error) {
+		if err != nil {
+			return nil, err
+		}
+
+		value, err = s.ring.Unwrap(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return value, nil
+	}
+}
@@ -0,0 +1,113 @@
+package keyring
+
+import (
+	"bytes"
+	"testing"
+	"time"
+	"yoopass-api/internal/storage"
+	"yoopass-api/internal/storage/redis"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	key1 = bytes.Repeat([]byte{1}, 16)
+	key2 = bytes.Repeat([]byte{2}, 32)
+)
+
+func newRing(t *testing.T, keys map[string][]byte, active string) *Keyring {
+	t.Helper()
+	ring, err := New(keys, active)
+	require.NoError(t, err)
+	return ring
+}
+
+func TestNew(t *testing.T) {
+	testCases := []struct {
+		name    string
+		keys    map[string][]byte
+		active  string
+		wantErr bool
+	}{
+		{name: "Valid", keys: map[string][]byte{"k1": key1, "k2": key2}, active: "k2"},
+		{name: "Unknown Active Key", keys: map[string][]byte{"k1": key1}, active: "k2", wantErr: true},
+		{name: "Invalid Key Size", keys: map[string][]byte{"k1": key1[:10]}, active: "k1", wantErr: true},
+		{name: "Invalid Key Id", keys: map[string][]byte{"k.1": key1}, active: "k.1", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := New(tc.keys, tc.active)
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestStoreRotation(t *testing.T) {
+	server := miniredis.RunT(t)
+	backend, err := redis.New(server.Addr())
+	require.NoError(t, err)
+
+	old := Wrap(backend, newRing(t, map[string][]byte{"k1": key1}, "k1"))
+	require.NoError(t, old.Set("first", []byte("cipher-1"), time.Hour))
+
+	raw, err := backend.Fetch("first")
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(raw, []byte("kr1.k1.")), "the envelope records the key id")
+	assert.NotContains(t, string(raw), "cipher-1")
+
+	// A second key becomes active, the first still opens what it wrapped
+	rotated := Wrap(backend, newRing(t, map[string][]byte{"k1": key1, "k2": key2}, "k2"))
+	require.NoError(t, rotated.Set("second", []byte("cipher-2"), time.Hour))
+
+	object, err := rotated.Fetch("first")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("cipher-1"), object)
+	object, err = rotated.Consume("second")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("cipher-2"), object)
+
+	// Once a key is dropped its values can't be opened any more
+	_, err = Wrap(backend, newRing(t, map[string][]byte{"k2": key2}, "k2")).Fetch("first")
+	assert.ErrorIs(t, err, ErrUnknownKeyID)
+
+	// Values stored before the keyring was enabled pass through
+	require.NoError(t, backend.Set("legacy", []byte("cipher-0"), time.Hour))
+	object, err = rotated.Fetch("legacy")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("cipher-0"), object)
+}
+
+func TestStoreConditionalWrites(t *testing.T) {
+	backend, err := redis.New(miniredis.RunT(t).Addr())
+	require.NoError(t, err)
+	store := Wrap(backend, newRing(t, map[string][]byte{"k1": key1}, "k1"))
+
+	stored, _, err := store.SetOrGet("alias", []byte("v1"), time.Hour)
+	require.NoError(t, err)
+	require.True(t, stored)
+	stored, existing, err := store.SetOrGet("alias", []byte("other"), time.Hour)
+	require.NoError(t, err)
+	assert.False(t, stored)
+	assert.Equal(t, []byte("v1"), existing)
+
+	assert.ErrorIs(t, store.Replace("alias", []byte("stale"), []byte("v2")), storage.ErrStale)
+	require.NoError(t, store.Replace("alias", []byte("v1"), []byte("v2")))
+	object, err := store.Fetch("alias")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v2"), object)
+
+	var records []storage.Record
+	require.NoError(t, store.Export(func(record storage.Record) error {
+		records = append(records, record)
+		return nil
+	}))
+	require.Len(t, records, 1)
+	assert.Equal(t, []byte("v2"), records[0].Ciphertext, "backups don't depend on the keyring")
+}
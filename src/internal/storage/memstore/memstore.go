@@ -0,0 +1,763 @@
+// Package memstore keeps secrets in process memory. It implements the same
+// semantics as the Redis backend, which makes it suited to tests and local
+// development, but everything is lost on restart and nothing is shared
+// between instances.
+package memstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"yoopass-api/internal/storage"
+)
+
+// DefaultSweepInterval is how often expired entries are removed unless
+// WithSweepInterval says otherwise.
+const DefaultSweepInterval = time.Second
+
+// item is a value that disappears once expiresAt has passed, a zero
+// expiresAt keeps it forever.
+type item[T any] struct {
+	value     T
+	expiresAt time.Time
+	// writtenAt is when the item was last written, PurgeOrphans spares
+	// recent ones
+	writtenAt time.Time
+}
+
+func (i item[T]) expired(now time.Time) bool {
+	return !i.expiresAt.IsZero() && !now.Before(i.expiresAt)
+}
+
+// expiresAt turns a ttl into the time it runs out, zero when ttl is not
+// positive.
+func expiresAt(now time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
+
+type Store struct {
+	mu sync.Mutex
+
+	secrets    map[string]item[[]byte]
+	meta       map[string]item[storage.Metadata]
+	reads      map[string]item[[]storage.Read]
+	versions   map[string]item[int64]
+	views      map[string]item[int]
+	approvals  map[string]item[struct{}]
+	tombstones map[string]item[struct{}]
+	grace      map[string]item[[]byte]
+	groups     map[string]item[map[string]struct{}]
+	rateLimits map[string]item[[]time.Time]
+
+	deadLetters []storage.DeadLetter
+
+	// created and expiries index keys by creation and expiry time. Like
+	// their Redis counterparts they outlive expired secrets until
+	// ClaimExpired or PurgeOrphans drops them
+	created  map[string]time.Time
+	expiries map[string]time.Time
+
+	now       func() time.Time
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+var _ storage.Storage = (*Store)(nil)
+
+// Option configures optional behaviour of the store.
+type Option func(*options)
+
+type options struct {
+	sweepInterval time.Duration
+	// now is swapped out by tests to control expiry
+	now func() time.Time
+}
+
+// WithSweepInterval sets how often the background sweeper removes expired
+// entries. Expired entries are never returned in between, the sweeper only
+// frees their memory.
+func WithSweepInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.sweepInterval = d
+	}
+}
+
+// New returns an empty store and starts its sweeper, which runs until Close.
+func New(opts ...Option) *Store {
+	o := options{
+		sweepInterval: DefaultSweepInterval,
+		now:           time.Now,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	s := &Store{
+		secrets:    make(map[string]item[[]byte]),
+		meta:       make(map[string]item[storage.Metadata]),
+		reads:      make(map[string]item[[]storage.Read]),
+		versions:   make(map[string]item[int64]),
+		views:      make(map[string]item[int]),
+		approvals:  make(map[string]item[struct{}]),
+		tombstones: make(map[string]item[struct{}]),
+		grace:      make(map[string]item[[]byte]),
+		groups:     make(map[string]item[map[string]struct{}]),
+		rateLimits: make(map[string]item[[]time.Time]),
+		created:    make(map[string]time.Time),
+		expiries:   make(map[string]time.Time),
+		now:        o.now,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	go s.sweep(o.sweepInterval)
+
+	return s
+}
+
+// sweep removes expired entries every interval until the store is closed.
+func (s *Store) sweep(interval time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			s.removeExpired(s.now())
+			s.mu.Unlock()
+		}
+	}
+}
+
+// removeExpired drops every expired entry. The indexes are left alone, an
+// expired secret stays in them until it is claimed.
+func (s *Store) removeExpired(now time.Time) {
+	dropExpired(s.secrets, now)
+	dropExpired(s.meta, now)
+	dropExpired(s.reads, now)
+	dropExpired(s.versions, now)
+	dropExpired(s.views, now)
+	dropExpired(s.approvals, now)
+	dropExpired(s.tombstones, now)
+	dropExpired(s.grace, now)
+	dropExpired(s.groups, now)
+	dropExpired(s.rateLimits, now)
+}
+
+func dropExpired[T any](m map[string]item[T], now time.Time) {
+	maps.DeleteFunc(m, func(_ string, i item[T]) bool {
+		return i.expired(now)
+	})
+}
+
+// lookup returns the entry of key unless it is missing or expired, the
+// sweeper may not have caught up with it yet.
+func lookup[T any](m map[string]item[T], key string, now time.Time) (item[T], bool) {
+	i, ok := m[key]
+	if !ok || i.expired(now) {
+		return i, false
+	}
+	return i, true
+}
+
+// Close stops the sweeper. The store keeps its contents but Ping reports it
+// as unavailable.
+func (s *Store) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+
+	return nil
+}
+
+func (s *Store) Ping(ctx context.Context) error {
+	const op = "storage.memstore.Ping"
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s: %w: %v", op, storage.ErrTimeout, err)
+	}
+
+	select {
+	case <-s.stop:
+		return fmt.Errorf("%s: %w: store is closed", op, storage.ErrUnavailable)
+	default:
+	}
+
+	return nil
+}
+
+func (s *Store) Set(key string, value []byte, ttl time.Duration) error {
+	const op = "storage.memstore.Set"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if _, ok := lookup(s.secrets, key, now); ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrConflict)
+	}
+	s.store(key, value, ttl, now)
+
+	return nil
+}
+
+// store writes a new key and indexes it like Set.
+func (s *Store) store(key string, value []byte, ttl time.Duration, now time.Time) {
+	s.secrets[key] = item[[]byte]{value: bytes.Clone(value), expiresAt: expiresAt(now, ttl)}
+	s.created[key] = now
+	if ttl > 0 {
+		s.expiries[key] = now.Add(ttl)
+	} else {
+		delete(s.expiries, key)
+	}
+}
+
+func (s *Store) Fetch(key string) ([]byte, error) {
+	const op = "storage.memstore.Fetch"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secret, ok := lookup(s.secrets, key, s.now())
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return bytes.Clone(secret.value), nil
+}
+
+func (s *Store) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remove(key)
+
+	return nil
+}
+
+// remove deletes key together with its index entries and everything kept
+// next to it, reporting whether the key still existed.
+func (s *Store) remove(key string) bool {
+	_, existed := lookup(s.secrets, key, s.now())
+
+	delete(s.secrets, key)
+	delete(s.expiries, key)
+	delete(s.created, key)
+	delete(s.meta, key)
+	delete(s.reads, key)
+	delete(s.versions, key)
+	delete(s.views, key)
+
+	return existed
+}
+
+func (s *Store) Consume(key string) ([]byte, error) {
+	const op = "storage.memstore.Consume"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secret, ok := lookup(s.secrets, key, s.now())
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+	s.remove(key)
+
+	return secret.value, nil
+}
+
+func (s *Store) Replace(key string, old, value []byte) error {
+	const op = "storage.memstore.Replace"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secret, ok := lookup(s.secrets, key, s.now())
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+	if !bytes.Equal(secret.value, old) {
+		return fmt.Errorf("%s: %w", op, storage.ErrStale)
+	}
+
+	secret.value = bytes.Clone(value)
+	s.secrets[key] = secret
+
+	return nil
+}
+
+func (s *Store) Update(key string, value []byte, ttl time.Duration, version int64) (int64, error) {
+	const op = "storage.memstore.Update"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if _, ok := lookup(s.secrets, key, now); !ok {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	current, _ := lookup(s.versions, key, now)
+	if version >= 0 && current.value != version {
+		return current.value, fmt.Errorf("%s: %w", op, storage.ErrStale)
+	}
+
+	// The version lives and dies with its key
+	exp := expiresAt(now, ttl)
+	s.secrets[key] = item[[]byte]{value: bytes.Clone(value), expiresAt: exp}
+	s.versions[key] = item[int64]{value: current.value + 1, expiresAt: exp, writtenAt: now}
+	if ttl > 0 {
+		s.expiries[key] = exp
+	} else {
+		delete(s.expiries, key)
+	}
+
+	return current.value + 1, nil
+}
+
+func (s *Store) SetOrGet(key string, value []byte, ttl time.Duration) (bool, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if existing, ok := lookup(s.secrets, key, now); ok {
+		return false, bytes.Clone(existing.value), nil
+	}
+	s.store(key, value, ttl, now)
+
+	return true, nil, nil
+}
+
+func (s *Store) TTL(key string) (time.Duration, error) {
+	const op = "storage.memstore.TTL"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	secret, ok := lookup(s.secrets, key, now)
+	if !ok {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+	if secret.expiresAt.IsZero() {
+		return 0, nil
+	}
+
+	return secret.expiresAt.Sub(now), nil
+}
+
+func (s *Store) AddToGroup(group, key string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	g, ok := lookup(s.groups, group, now)
+	if !ok {
+		// An expired group starts over without a TTL
+		g = item[map[string]struct{}]{value: make(map[string]struct{})}
+	}
+	g.value[key] = struct{}{}
+
+	// A fresh group takes the TTL of its first member, after that it only
+	// ever grows so the group never expires before its longest lived member
+	switch {
+	case ttl <= 0:
+		g.expiresAt = time.Time{}
+	case g.expiresAt.IsZero() && len(g.value) == 1:
+		g.expiresAt = now.Add(ttl)
+	case !g.expiresAt.IsZero() && g.expiresAt.Before(now.Add(ttl)):
+		g.expiresAt = now.Add(ttl)
+	}
+	g.writtenAt = now
+	s.groups[group] = g
+
+	return nil
+}
+
+func (s *Store) DeleteGroup(group string) (int, error) {
+	const op = "storage.memstore.DeleteGroup"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, ok := lookup(s.groups, group, s.now())
+	if !ok || len(g.value) == 0 {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	var deleted int
+	for member := range g.value {
+		if s.remove(member) {
+			deleted++
+		}
+	}
+	delete(s.groups, group)
+
+	return deleted, nil
+}
+
+func (s *Store) DeleteCreatedBefore(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int
+	for key, created := range s.created {
+		if created.Before(before) && s.remove(key) {
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func (s *Store) PurgeOrphans() (storage.PurgeStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	exists := func(key string) bool {
+		_, ok := lookup(s.secrets, key, now)
+		return ok
+	}
+
+	// A save writes these before its secret
+	recent := now.Add(-storage.PurgeGrace)
+
+	var stats storage.PurgeStats
+	stats.AuxKeys += purgeAux(s.meta, exists, recent)
+	stats.AuxKeys += purgeAux(s.reads, exists, recent)
+	stats.AuxKeys += purgeAux(s.versions, exists, recent)
+	stats.AuxKeys += purgeAux(s.views, exists, recent)
+	stats.AuxKeys += purgeAux(s.approvals, exists, recent)
+
+	for key, expiry := range s.expiries {
+		// Due entries are left to ClaimExpired, their secrets still get
+		// tombstones and webhooks
+		if expiry.After(now) && !exists(key) {
+			delete(s.expiries, key)
+			stats.IndexEntries++
+		}
+	}
+	for key := range s.created {
+		if !exists(key) {
+			delete(s.created, key)
+			stats.IndexEntries++
+		}
+	}
+
+	for name, g := range s.groups {
+		if g.writtenAt.After(recent) {
+			continue
+		}
+		for member := range g.value {
+			if !exists(member) {
+				delete(g.value, member)
+				stats.GroupMembers++
+			}
+		}
+		if len(g.value) == 0 {
+			delete(s.groups, name)
+			stats.Groups++
+		}
+	}
+
+	return stats, nil
+}
+
+// purgeAux deletes the entries of m written before recent whose secret no
+// longer exists, returning how many it deleted.
+func purgeAux[T any](m map[string]item[T], exists func(string) bool, recent time.Time) int {
+	var removed int
+	for key, i := range m {
+		if !i.writtenAt.After(recent) && !exists(key) {
+			delete(m, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (s *Store) ClaimExpired(now time.Time, limit int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []string
+	for key, expiry := range s.expiries {
+		if !expiry.After(now) {
+			due = append(due, key)
+		}
+	}
+
+	// Oldest first, the same order the Redis index hands them out in
+	slices.SortFunc(due, func(a, b string) int {
+		if c := s.expiries[a].Compare(s.expiries[b]); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	for _, key := range due {
+		delete(s.expiries, key)
+		delete(s.created, key)
+	}
+
+	return due, nil
+}
+
+func (s *Store) SetTombstone(key string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tombstones[key] = item[struct{}]{expiresAt: expiresAt(s.now(), ttl)}
+
+	return nil
+}
+
+func (s *Store) Tombstoned(key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := lookup(s.tombstones, key, s.now())
+
+	return ok, nil
+}
+
+func (s *Store) SetGrace(key, holder string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.grace[key+":"+holder] = item[[]byte]{value: bytes.Clone(value), expiresAt: expiresAt(s.now(), ttl)}
+
+	return nil
+}
+
+func (s *Store) Grace(key, holder string) ([]byte, error) {
+	const op = "storage.memstore.Grace"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	grace, ok := lookup(s.grace, key+":"+holder, s.now())
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return bytes.Clone(grace.value), nil
+}
+
+func (s *Store) Approve(key string, window time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.approvals[key] = item[struct{}]{expiresAt: expiresAt(now, window), writtenAt: now}
+
+	return nil
+}
+
+func (s *Store) ConsumeApproval(key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := lookup(s.approvals, key, s.now())
+	delete(s.approvals, key)
+
+	return ok, nil
+}
+
+// secretExpiry returns when the secret at key expires, zero when it doesn't
+// exist or never expires.
+func (s *Store) secretExpiry(key string, now time.Time) time.Time {
+	secret, _ := lookup(s.secrets, key, now)
+	return secret.expiresAt
+}
+
+func (s *Store) RecordRead(key string, read storage.Read, max int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	history, _ := lookup(s.reads, key, now)
+	history.value = append([]storage.Read{read}, history.value...)
+	if len(history.value) > max {
+		history.value = history.value[:max]
+	}
+	// The history expires together with its secret
+	if exp := s.secretExpiry(key, now); !exp.IsZero() {
+		history.expiresAt = exp
+	}
+	history.writtenAt = now
+	s.reads[key] = history
+
+	return nil
+}
+
+func (s *Store) Reads(key string) ([]storage.Read, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history, _ := lookup(s.reads, key, s.now())
+
+	return append([]storage.Read{}, history.value...), nil
+}
+
+func (s *Store) CountView(key string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	views, _ := lookup(s.views, key, now)
+	views.value++
+	// The counter expires together with its secret
+	if exp := s.secretExpiry(key, now); !exp.IsZero() {
+		views.expiresAt = exp
+	}
+	views.writtenAt = now
+	s.views[key] = views
+
+	return views.value, nil
+}
+
+func (s *Store) SetMetadata(key string, md storage.Metadata, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.meta[key] = item[storage.Metadata]{value: md, expiresAt: expiresAt(now, ttl), writtenAt: now}
+
+	return nil
+}
+
+func (s *Store) Metadata(key string) (storage.Metadata, error) {
+	const op = "storage.memstore.Metadata"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	md, ok := lookup(s.meta, key, s.now())
+	if !ok {
+		return storage.Metadata{}, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return md.value, nil
+}
+
+func (s *Store) Allow(name string, limit int, window time.Duration) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	events, _ := lookup(s.rateLimits, name, now)
+
+	// Events that left the window no longer count
+	events.value = slices.DeleteFunc(events.value, func(at time.Time) bool {
+		return !at.After(now.Add(-window))
+	})
+	if len(events.value) >= limit {
+		s.rateLimits[name] = events
+		if len(events.value) == 0 {
+			return false, window, nil
+		}
+		return false, events.value[0].Add(window).Sub(now), nil
+	}
+
+	events.value = append(events.value, now)
+	events.expiresAt = now.Add(window)
+	s.rateLimits[name] = events
+
+	return true, 0, nil
+}
+
+func (s *Store) Stats(now time.Time) (storage.Stats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	total := int64(len(s.created))
+	var expired int64
+	for _, expiry := range s.expiries {
+		if !expiry.After(now) {
+			expired++
+		}
+	}
+
+	return storage.Stats{
+		Total:  total,
+		Active: max(0, total-expired),
+	}, nil
+}
+
+func (s *Store) AddDeadLetter(letter storage.DeadLetter, max int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deadLetters = append([]storage.DeadLetter{letter}, s.deadLetters...)
+	if len(s.deadLetters) > max {
+		s.deadLetters = s.deadLetters[:max]
+	}
+
+	return nil
+}
+
+func (s *Store) DeadLetters() ([]storage.DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]storage.DeadLetter{}, s.deadLetters...), nil
+}
+
+func (s *Store) Export(fn func(storage.Record) error) error {
+	// fn may call back into the store, so the records are collected first
+	// and handed out without holding the lock
+	s.mu.Lock()
+	now := s.now()
+	keys := slices.SortedFunc(maps.Keys(s.created), func(a, b string) int {
+		if c := s.created[a].Compare(s.created[b]); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+
+	records := make([]storage.Record, 0, len(keys))
+	for _, key := range keys {
+		secret, ok := lookup(s.secrets, key, now)
+		if !ok {
+			continue
+		}
+
+		record := storage.Record{Alias: key, Ciphertext: bytes.Clone(secret.value)}
+		if !secret.expiresAt.IsZero() {
+			record.ExpiresAt = secret.expiresAt.UTC()
+		}
+		if md, ok := lookup(s.meta, key, now); ok {
+			record.Metadata = &md.value
+		}
+		records = append(records, record)
+	}
+	s.mu.Unlock()
+
+	for _, record := range records {
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
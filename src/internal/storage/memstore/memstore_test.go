@@ -0,0 +1,295 @@
+package memstore
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"yoopass-api/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clock is a settable time source shared with the sweeper goroutine
+type clock struct {
+	now atomic.Int64
+}
+
+func newClock() *clock {
+	c := &clock{}
+	c.now.Store(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+	return c
+}
+
+func (c *clock) Now() time.Time          { return time.Unix(0, c.now.Load()) }
+func (c *clock) Advance(d time.Duration) { c.now.Add(int64(d)) }
+func withClock(c *clock) Option          { return func(o *options) { o.now = c.Now } }
+
+func newStore(t *testing.T, opts ...Option) *Store {
+	s := New(opts...)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestTTLExpiry(t *testing.T) {
+	c := newClock()
+	s := newStore(t, withClock(c), WithSweepInterval(time.Millisecond))
+
+	require.NoError(t, s.Set("short", []byte("cipher"), time.Minute))
+	require.NoError(t, s.Set("forever", []byte("cipher"), 0))
+	require.NoError(t, s.SetMetadata("short", storage.Metadata{AbuseTag: "tag"}, time.Minute))
+
+	ttl, err := s.TTL("short")
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, ttl)
+
+	ttl, err = s.TTL("forever")
+	require.NoError(t, err)
+	assert.Zero(t, ttl)
+
+	c.Advance(time.Minute)
+
+	// Expired keys are gone right away, the sweeper only frees them
+	_, err = s.Fetch("short")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = s.TTL("short")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = s.Metadata("short")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	assert.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		_, secret := s.secrets["short"]
+		_, meta := s.meta["short"]
+		return !secret && !meta
+	}, time.Second, time.Millisecond)
+
+	object, err := s.Fetch("forever")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("cipher"), object)
+
+	// The expiry index outlives the key until it is claimed
+	keys, err := s.ClaimExpired(c.Now(), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"short"}, keys)
+
+	keys, err = s.ClaimExpired(c.Now(), 10)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestOverwrite(t *testing.T) {
+	s := newStore(t)
+
+	require.NoError(t, s.Set("alias", []byte("first"), time.Hour))
+
+	// Set never overwrites
+	err := s.Set("alias", []byte("second"), time.Hour)
+	assert.ErrorIs(t, err, storage.ErrConflict)
+
+	object, err := s.Fetch("alias")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("first"), object)
+
+	// Update does, once its version is current
+	_, err = s.Update("alias", []byte("second"), time.Hour, 1)
+	assert.ErrorIs(t, err, storage.ErrStale)
+
+	version, err := s.Update("alias", []byte("second"), 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), version)
+
+	object, err = s.Fetch("alias")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("second"), object)
+
+	ttl, err := s.TTL("alias")
+	require.NoError(t, err)
+	assert.Zero(t, ttl)
+
+	assert.ErrorIs(t, s.Replace("alias", []byte("stale"), []byte("third")), storage.ErrStale)
+	require.NoError(t, s.Replace("alias", []byte("second"), []byte("third")))
+
+	_, err = s.Update("missing", []byte("value"), time.Hour, -1)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestDelete(t *testing.T) {
+	s := newStore(t)
+
+	require.NoError(t, s.Set("alias", []byte("cipher"), time.Hour))
+	require.NoError(t, s.SetMetadata("alias", storage.Metadata{OneTime: true}, time.Hour))
+	require.NoError(t, s.RecordRead("alias", storage.Read{IPHash: "hash"}, 5))
+	_, err := s.CountView("alias")
+	require.NoError(t, err)
+
+	require.NoError(t, s.Delete("alias"))
+	// Deleting a missing key is not an error
+	require.NoError(t, s.Delete("alias"))
+
+	_, err = s.Fetch("alias")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = s.Metadata("alias")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	reads, err := s.Reads("alias")
+	require.NoError(t, err)
+	assert.Empty(t, reads)
+
+	views, err := s.CountView("alias")
+	require.NoError(t, err)
+	assert.Equal(t, 1, views)
+
+	// A deleted key never shows up as expired
+	keys, err := s.ClaimExpired(time.Now().Add(2*time.Hour), 10)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	// The alias is free again
+	require.NoError(t, s.Set("alias", []byte("new"), time.Hour))
+}
+
+func TestConsumeConcurrentSingleWinner(t *testing.T) {
+	s := newStore(t)
+	require.NoError(t, s.Set("alias", []byte("cipher"), time.Hour))
+
+	var wg sync.WaitGroup
+	var winners atomic.Int32
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Consume("alias"); err == nil {
+				winners.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), winners.Load())
+}
+
+func TestDeleteGroup(t *testing.T) {
+	s := newStore(t)
+
+	require.NoError(t, s.Set("a", []byte("cipher"), time.Hour))
+	require.NoError(t, s.Set("b", []byte("cipher"), time.Hour))
+	require.NoError(t, s.AddToGroup("group", "a", time.Hour))
+	require.NoError(t, s.AddToGroup("group", "b", time.Hour))
+	require.NoError(t, s.AddToGroup("group", "gone", time.Hour))
+
+	deleted, err := s.DeleteGroup("group")
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+
+	_, err = s.Fetch("a")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	_, err = s.DeleteGroup("group")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestPurgeOrphans(t *testing.T) {
+	c := newClock()
+	s := newStore(t, withClock(c))
+
+	require.NoError(t, s.Set("live", []byte("cipher"), time.Hour))
+	require.NoError(t, s.SetMetadata("live", storage.Metadata{AbuseTag: "spam"}, time.Hour))
+	require.NoError(t, s.AddToGroup("deploy", "live", time.Hour))
+
+	require.NoError(t, s.Set("gone", []byte("cipher"), time.Hour))
+	require.NoError(t, s.SetMetadata("gone", storage.Metadata{AbuseTag: "spam"}, time.Hour))
+	require.NoError(t, s.Approve("gone", time.Hour))
+	require.NoError(t, s.AddToGroup("deploy", "gone", time.Hour))
+	require.NoError(t, s.AddToGroup("stale", "gone", time.Hour))
+	s.mu.Lock()
+	delete(s.secrets, "gone")
+	s.mu.Unlock()
+	// Only entries left alone for a while are purged
+	c.Advance(storage.PurgeGrace)
+
+	stats, err := s.PurgeOrphans()
+	require.NoError(t, err)
+	assert.Equal(t, storage.PurgeStats{AuxKeys: 2, IndexEntries: 2, GroupMembers: 2, Groups: 1}, stats)
+
+	_, err = s.Metadata("live")
+	require.NoError(t, err)
+
+	stats, err = s.PurgeOrphans()
+	require.NoError(t, err)
+	assert.Zero(t, stats, "a second run finds nothing left")
+}
+
+func TestPurgeOrphansKeepsDueExpiries(t *testing.T) {
+	c := newClock()
+	s := newStore(t, withClock(c))
+
+	require.NoError(t, s.Set("expired", []byte("cipher"), time.Minute))
+	c.Advance(storage.PurgeGrace)
+
+	stats, err := s.PurgeOrphans()
+	require.NoError(t, err)
+	assert.Equal(t, storage.PurgeStats{IndexEntries: 1}, stats, "only the creation entry goes")
+
+	// The expiry still gets its tombstone and webhook
+	keys, err := s.ClaimExpired(c.Now(), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"expired"}, keys)
+}
+
+func TestPurgeOrphansSparesPendingSave(t *testing.T) {
+	c := newClock()
+	s := newStore(t, withClock(c))
+
+	// Save writes these before the secret itself
+	md := storage.Metadata{OneTime: true, ClientEncrypted: true}
+	require.NoError(t, s.SetMetadata("pending", md, time.Hour))
+	require.NoError(t, s.AddToGroup("deploy", "pending", time.Hour))
+
+	stats, err := s.PurgeOrphans()
+	require.NoError(t, err)
+	assert.Zero(t, stats)
+
+	require.NoError(t, s.Set("pending", []byte("cipher"), time.Hour))
+	got, err := s.Metadata("pending")
+	require.NoError(t, err)
+	assert.Equal(t, md, got)
+
+	deleted, err := s.DeleteGroup("deploy")
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+}
+
+func TestAllow(t *testing.T) {
+	c := newClock()
+	s := newStore(t, withClock(c))
+
+	for range 2 {
+		allowed, _, err := s.Allow("save", 2, time.Minute)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+	}
+
+	c.Advance(10 * time.Second)
+	allowed, retryAfter, err := s.Allow("save", 2, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Equal(t, 50*time.Second, retryAfter)
+
+	c.Advance(50 * time.Second)
+	allowed, _, err = s.Allow("save", 2, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+}
+
+func TestPingAndClose(t *testing.T) {
+	s := New()
+
+	require.NoError(t, s.Ping(context.Background()))
+	require.NoError(t, s.Close())
+	require.NoError(t, s.Close())
+	assert.ErrorIs(t, s.Ping(context.Background()), storage.ErrUnavailable)
+}
@@ -0,0 +1,1099 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"yoopass-api/internal/storage"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// consumeLockTTL bounds how long a crashed consumer can hold a key's lock.
+const consumeLockTTL = 5 * time.Second
+
+// groupKeyPrefix namespaces group member sets away from secret keys.
+const groupKeyPrefix = "group:"
+
+// expiriesKey is the sorted set indexing keys by expiry time in unix
+// milliseconds.
+const expiriesKey = "expiries"
+
+// metaKeyPrefix namespaces the plaintext metadata of secrets.
+const metaKeyPrefix = "meta:"
+
+// approvalKeyPrefix namespaces the reveal windows opened by approvals.
+const approvalKeyPrefix = "approval:"
+
+// versionKeyPrefix namespaces the update counters of secrets.
+const versionKeyPrefix = "version:"
+
+// readsKeyPrefix namespaces the capped read histories of secrets.
+const readsKeyPrefix = "reads:"
+
+// viewsKeyPrefix namespaces the view counters of secrets.
+const viewsKeyPrefix = "views:"
+
+// tombstoneKeyPrefix namespaces the markers left behind by expired keys.
+const tombstoneKeyPrefix = "tombstone:"
+
+// graceKeyPrefix namespaces the copies of burned secrets kept for a grace
+// period, followed by the alias and the holder.
+const graceKeyPrefix = "grace:"
+
+// rateLimitKeyPrefix namespaces the sorted sets behind rate limits.
+const rateLimitKeyPrefix = "ratelimit:"
+
+// deadLettersKey is the capped list of undeliverable webhook events.
+const deadLettersKey = "webhook:dead-letters"
+
+// deleteGroupRetries bounds how often DeleteGroup retries when members are
+// added to the group while it is being deleted.
+const deleteGroupRetries = 3
+
+// createdKey is the sorted set indexing keys by creation time in unix
+// milliseconds.
+const createdKey = "created"
+
+// revokeBatchSize bounds how many keys DeleteCreatedBefore removes per round
+// trip.
+const revokeBatchSize = 500
+
+// setScript stores a new key and indexes it, refusing to overwrite a key
+// that already exists so a colliding alias never clobbers another secret.
+var setScript = redis.NewScript(`
+local ttl = tonumber(ARGV[2])
+local stored
+if ttl > 0 then
+	stored = redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ttl)
+else
+	stored = redis.call('SET', KEYS[1], ARGV[1], 'NX')
+end
+if not stored then
+	return 0
+end
+redis.call('ZADD', KEYS[2], ARGV[3], KEYS[1])
+if ttl > 0 then
+	redis.call('ZADD', KEYS[3], ARGV[4], KEYS[1])
+else
+	redis.call('ZREM', KEYS[3], KEYS[1])
+end
+return 1
+`)
+
+// claimExpiredScript pops due entries off the expiry index in one step, so
+// concurrent processors never see the same key twice. Claimed keys are gone,
+// so they are dropped from the creation index as well.
+var claimExpiredScript = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+if #due > 0 then
+	redis.call('ZREM', KEYS[1], unpack(due))
+	redis.call('ZREM', KEYS[2], unpack(due))
+end
+return due
+`)
+
+// addToGroupScript adds a member and stretches the group's TTL so it never
+// expires before its longest lived member. A fresh set has no TTL yet, which
+// is told apart from a permanent group by it having a single member.
+var addToGroupScript = redis.NewScript(`
+local added = redis.call('SADD', KEYS[1], ARGV[1])
+local ttl = tonumber(ARGV[2])
+if ttl <= 0 then
+	redis.call('PERSIST', KEYS[1])
+	return added
+end
+local current = redis.call('PTTL', KEYS[1])
+if (current == -1 and redis.call('SCARD', KEYS[1]) == 1) or (current >= 0 and current < ttl) then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return added
+`)
+
+// recordReadScript pushes a read and trims the history in one step, giving
+// the history the remaining TTL of its secret so both expire together.
+var recordReadScript = redis.NewScript(`
+redis.call('LPUSH', KEYS[1], ARGV[1])
+redis.call('LTRIM', KEYS[1], 0, tonumber(ARGV[2]) - 1)
+local ttl = redis.call('PTTL', KEYS[2])
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return 1
+`)
+
+// countViewScript counts a view, giving the counter the remaining TTL of its
+// secret so both expire together.
+var countViewScript = redis.NewScript(`
+local views = redis.call('INCR', KEYS[1])
+local ttl = redis.call('PTTL', KEYS[2])
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return views
+`)
+
+// replaceScript swaps the value of a key only while it still holds the value
+// the caller read, so a stale writer never clobbers a newer one.
+var replaceScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if not current then
+	return -1
+end
+if current ~= ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[2], 'KEEPTTL')
+return 1
+`)
+
+// updateScript overwrites a key and bumps its version in one step, refusing
+// when the caller's version is out of date. The version counter lives and
+// dies with its key.
+var updateScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return {-1, 0}
+end
+local version = tonumber(redis.call('GET', KEYS[2]) or '0')
+local expected = tonumber(ARGV[3])
+if expected >= 0 and version ~= expected then
+	return {0, version}
+end
+version = version + 1
+local ttl = tonumber(ARGV[2])
+if ttl > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ttl)
+	redis.call('SET', KEYS[2], version, 'PX', ttl)
+	redis.call('ZADD', KEYS[3], ARGV[4], KEYS[1])
+else
+	redis.call('SET', KEYS[1], ARGV[1])
+	redis.call('SET', KEYS[2], version)
+	redis.call('ZREM', KEYS[3], KEYS[1])
+end
+return {1, version}
+`)
+
+// setOrGetScript sets a key only when it is absent and indexes it like Set,
+// otherwise it returns the value already there, so no writer can slip in
+// between the check and the write.
+var setOrGetScript = redis.NewScript(`
+local existing = redis.call('GET', KEYS[1])
+if existing then
+	return {0, existing}
+end
+local ttl = tonumber(ARGV[2])
+if ttl > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ttl)
+	redis.call('ZADD', KEYS[3], ARGV[4], KEYS[1])
+else
+	redis.call('SET', KEYS[1], ARGV[1])
+	redis.call('ZREM', KEYS[3], KEYS[1])
+end
+redis.call('ZADD', KEYS[2], ARGV[3], KEYS[1])
+return {1}
+`)
+
+// allowScript keeps a sliding window log of events scored by their time in
+// unix milliseconds. Events that left the window are dropped before counting,
+// a refused event isn't logged and gets the time until a slot frees up.
+var allowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[3]) then
+	local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+	return {0, tonumber(oldest[2]) + window - now}
+end
+redis.call('ZADD', KEYS[1], now, ARGV[4])
+redis.call('PEXPIRE', KEYS[1], window)
+return {1, 0}
+`)
+
+// ErrInvalidAddress is returned by New for an address that isn't host:port.
+var ErrInvalidAddress = errors.New("invalid redis address")
+
+type Store struct {
+	client *redis.Client
+	// replica serves the reads that may lag behind, it is client itself
+	// unless WithReplica is used
+	replica *redis.Client
+	ctx     context.Context
+}
+
+var _ storage.Storage = (*Store)(nil)
+
+// Option configures optional behaviour of the store.
+type Option func(*options)
+
+type options struct {
+	replicaAddr string
+}
+
+// WithReplica sends the reads that tolerate lag, Fetch, Metadata, TTL,
+// Reads and Tombstoned, to the read replica at addr. Writes, and reads that
+// decide a write such as Consume, always go to the primary, so a one-time
+// secret is burned exactly once however far the replica lags. A key the
+// replica doesn't have yet is looked up on the primary, so a secret can be
+// read right after it was saved.
+func WithReplica(addr string) Option {
+	return func(o *options) {
+		o.replicaAddr = addr
+	}
+}
+
+func New(addr string, opts ...Option) (*Store, error) {
+	const op = "storage.redis.New"
+
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// An empty or malformed address would otherwise surface as a confusing
+	// dial error, or silently fall back to the client's default address
+	if err := validateAddr(addr); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{
+		Addr: addr,
+	})
+
+	// Check connection
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("%s: Redis connection failed: %w", op, translateError(err))
+	}
+
+	replica := client
+	if o.replicaAddr != "" {
+		if err := validateAddr(o.replicaAddr); err != nil {
+			return nil, fmt.Errorf("%s: replica: %w", op, err)
+		}
+
+		replica = redis.NewClient(&redis.Options{
+			Addr: o.replicaAddr,
+		})
+		if err := replica.Ping(ctx).Err(); err != nil {
+			return nil, fmt.Errorf("%s: Redis replica connection failed: %w", op, translateError(err))
+		}
+	}
+
+	return &Store{
+		client:  client,
+		replica: replica,
+		ctx:     ctx,
+	}, nil
+}
+
+// validateAddr checks that addr is a non-empty host:port with a valid port.
+func validateAddr(addr string) error {
+	if addr == "" {
+		return fmt.Errorf("%w: storage_path is empty", ErrInvalidAddress)
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("%w %q: %v", ErrInvalidAddress, addr, err)
+	}
+	if host == "" {
+		return fmt.Errorf("%w %q: missing host", ErrInvalidAddress, addr)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("%w %q: invalid port", ErrInvalidAddress, addr)
+	}
+	return nil
+}
+
+func (s *Store) Set(key string, value []byte, ttl time.Duration) error {
+	const op = "storage.redis.Set"
+
+	now := time.Now()
+	keys := []string{key, createdKey, expiriesKey}
+	stored, err := setScript.Run(s.ctx, s.client, keys, value, ttl.Milliseconds(), now.UnixMilli(), now.Add(ttl).UnixMilli()).Int()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, translateError(err))
+	}
+	if stored == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrConflict)
+	}
+
+	return nil
+}
+
+func (s *Store) Fetch(key string) ([]byte, error) {
+	const op = "storage.redis.Fetch"
+
+	object, err := s.replica.Get(s.ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) && s.replica != s.client {
+		object, err = s.client.Get(s.ctx, key).Bytes()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	return object, nil
+}
+
+func (s *Store) Delete(key string) error {
+	const op = "storage.redis.Delete"
+
+	_, err := s.client.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(s.ctx, append(auxKeys(key), key)...)
+		pipe.ZRem(s.ctx, expiriesKey, key)
+		pipe.ZRem(s.ctx, createdKey, key)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	return nil
+}
+
+func (s *Store) Consume(key string) ([]byte, error) {
+	const op = "storage.redis.Consume"
+
+	object, err := s.client.GetDel(s.ctx, key).Bytes()
+	if redis.HasErrorPrefix(err, "ERR unknown command") {
+		// GETDEL needs Redis 6.2+, older servers fall back to a lock
+		object, err = s.consumeWithLock(key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	// The secret is already gone, a stale index entry only means a spurious
+	// tombstone later, so this is best effort
+	s.client.ZRem(s.ctx, expiriesKey, key)
+	s.client.ZRem(s.ctx, createdKey, key)
+	s.client.Del(s.ctx, auxKeys(key)...)
+
+	return object, nil
+}
+
+func (s *Store) Replace(key string, old, value []byte) error {
+	const op = "storage.redis.Replace"
+
+	replaced, err := replaceScript.Run(s.ctx, s.client, []string{key}, old, value).Int()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	switch replaced {
+	case -1:
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	case 0:
+		return fmt.Errorf("%s: %w", op, storage.ErrStale)
+	}
+
+	return nil
+}
+
+func (s *Store) Update(key string, value []byte, ttl time.Duration, version int64) (int64, error) {
+	const op = "storage.redis.Update"
+
+	keys := []string{key, versionKeyPrefix + key, expiriesKey}
+	expiresAt := time.Now().Add(ttl).UnixMilli()
+	result, err := updateScript.Run(s.ctx, s.client, keys, value, ttl.Milliseconds(), version, expiresAt).Int64Slice()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	switch result[0] {
+	case -1:
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	case 0:
+		return result[1], fmt.Errorf("%s: %w", op, storage.ErrStale)
+	}
+
+	return result[1], nil
+}
+
+func (s *Store) SetOrGet(key string, value []byte, ttl time.Duration) (bool, []byte, error) {
+	const op = "storage.redis.SetOrGet"
+
+	now := time.Now()
+	keys := []string{key, createdKey, expiriesKey}
+	result, err := setOrGetScript.Run(s.ctx, s.client, keys, value, ttl.Milliseconds(), now.UnixMilli(), now.Add(ttl).UnixMilli()).Slice()
+	if err != nil {
+		return false, nil, fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	if stored, _ := result[0].(int64); stored == 1 {
+		return true, nil, nil
+	}
+	existing, ok := result[1].(string)
+	if !ok {
+		return false, nil, fmt.Errorf("%s: unexpected reply %v", op, result)
+	}
+
+	return false, []byte(existing), nil
+}
+
+func (s *Store) TTL(key string) (time.Duration, error) {
+	const op = "storage.redis.TTL"
+
+	ttl, err := s.replica.TTL(s.ctx, key).Result()
+	if err == nil && ttl == -2 && s.replica != s.client {
+		ttl, err = s.client.TTL(s.ctx, key).Result()
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	// go-redis reports -2 for a missing key and -1 for a key without expiry
+	switch ttl {
+	case -2:
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	case -1:
+		return 0, nil
+	}
+
+	return ttl, nil
+}
+
+func (s *Store) AddToGroup(group, key string, ttl time.Duration) error {
+	const op = "storage.redis.AddToGroup"
+
+	err := addToGroupScript.Run(s.ctx, s.client, []string{groupKeyPrefix + group}, key, ttl.Milliseconds()).Err()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteGroup(group string) (int, error) {
+	const op = "storage.redis.DeleteGroup"
+
+	groupKey := groupKeyPrefix + group
+	var deleted int
+
+	// WATCH aborts the transaction if a member is added in between, so no
+	// member outlives the group
+	txf := func(tx *redis.Tx) error {
+		members, err := tx.SMembers(s.ctx, groupKey).Result()
+		if err != nil {
+			return err
+		}
+		if len(members) == 0 {
+			return redis.Nil
+		}
+
+		var del *redis.IntCmd
+		_, err = tx.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+			del = pipe.Del(s.ctx, members...)
+			pipe.Del(s.ctx, groupKey)
+			pipe.ZRem(s.ctx, expiriesKey, toAny(members)...)
+			pipe.ZRem(s.ctx, createdKey, toAny(members)...)
+			for _, member := range members {
+				pipe.Del(s.ctx, auxKeys(member)...)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		deleted = int(del.Val())
+		return nil
+	}
+
+	var err error
+	for range deleteGroupRetries {
+		err = s.client.Watch(s.ctx, txf, groupKey)
+		if !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	return deleted, nil
+}
+
+func (s *Store) DeleteCreatedBefore(before time.Time) (int, error) {
+	const op = "storage.redis.DeleteCreatedBefore"
+
+	var deleted int
+	for {
+		keys, err := s.client.ZRangeByScore(s.ctx, createdKey, &redis.ZRangeBy{
+			Min:   "-inf",
+			Max:   "(" + strconv.FormatInt(before.UnixMilli(), 10),
+			Count: revokeBatchSize,
+		}).Result()
+		if err != nil {
+			return deleted, fmt.Errorf("%s: %w", op, translateError(err))
+		}
+		if len(keys) == 0 {
+			return deleted, nil
+		}
+
+		var del *redis.IntCmd
+		_, err = s.client.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+			del = pipe.Del(s.ctx, keys...)
+			pipe.ZRem(s.ctx, expiriesKey, toAny(keys)...)
+			pipe.ZRem(s.ctx, createdKey, toAny(keys)...)
+			for _, key := range keys {
+				pipe.Del(s.ctx, auxKeys(key)...)
+			}
+			return nil
+		})
+		if err != nil {
+			return deleted, fmt.Errorf("%s: %w", op, translateError(err))
+		}
+
+		deleted += int(del.Val())
+	}
+}
+
+func (s *Store) ClaimExpired(now time.Time, limit int) ([]string, error) {
+	const op = "storage.redis.ClaimExpired"
+
+	keys, err := claimExpiredScript.Run(s.ctx, s.client, []string{expiriesKey, createdKey}, now.UnixMilli(), limit).StringSlice()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	return keys, nil
+}
+
+func (s *Store) SetTombstone(key string, ttl time.Duration) error {
+	const op = "storage.redis.SetTombstone"
+
+	if err := s.client.Set(s.ctx, tombstoneKeyPrefix+key, 1, ttl).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	return nil
+}
+
+func (s *Store) Tombstoned(key string) (bool, error) {
+	const op = "storage.redis.Tombstoned"
+
+	n, err := s.replica.Exists(s.ctx, tombstoneKeyPrefix+key).Result()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	return n > 0, nil
+}
+
+func (s *Store) AddDeadLetter(letter storage.DeadLetter, max int) error {
+	const op = "storage.redis.AddDeadLetter"
+
+	value, err := json.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = s.client.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(s.ctx, deadLettersKey, value)
+		pipe.LTrim(s.ctx, deadLettersKey, 0, int64(max)-1)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	return nil
+}
+
+func (s *Store) DeadLetters() ([]storage.DeadLetter, error) {
+	const op = "storage.redis.DeadLetters"
+
+	values, err := s.client.LRange(s.ctx, deadLettersKey, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	letters := make([]storage.DeadLetter, 0, len(values))
+	for _, value := range values {
+		var letter storage.DeadLetter
+		if err := json.Unmarshal([]byte(value), &letter); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		letters = append(letters, letter)
+	}
+
+	return letters, nil
+}
+
+// Close closes the connections to Redis and its replica.
+func (s *Store) Close() error {
+	const op = "storage.redis.Close"
+
+	err := s.client.Close()
+	if s.replica != s.client {
+		err = errors.Join(err, s.replica.Close())
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Store) Ping(ctx context.Context) error {
+	const op = "storage.redis.Ping"
+
+	if err := s.client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	return nil
+}
+
+func (s *Store) Stats(now time.Time) (storage.Stats, error) {
+	const op = "storage.redis.Stats"
+
+	var total, expired *redis.IntCmd
+	_, err := s.client.Pipelined(s.ctx, func(pipe redis.Pipeliner) error {
+		total = pipe.ZCard(s.ctx, createdKey)
+		expired = pipe.ZCount(s.ctx, expiriesKey, "-inf", strconv.FormatInt(now.UnixMilli(), 10))
+		return nil
+	})
+	if err != nil {
+		return storage.Stats{}, fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	return storage.Stats{
+		Total:  total.Val(),
+		Active: max(0, total.Val()-expired.Val()),
+	}, nil
+}
+
+func (s *Store) SetGrace(key, holder string, value []byte, ttl time.Duration) error {
+	const op = "storage.redis.SetGrace"
+
+	if err := s.client.Set(s.ctx, graceKeyPrefix+key+":"+holder, value, ttl).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	return nil
+}
+
+func (s *Store) Grace(key, holder string) ([]byte, error) {
+	const op = "storage.redis.Grace"
+
+	value, err := s.client.Get(s.ctx, graceKeyPrefix+key+":"+holder).Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	return value, nil
+}
+
+func (s *Store) Approve(key string, window time.Duration) error {
+	const op = "storage.redis.Approve"
+
+	if err := s.client.Set(s.ctx, approvalKeyPrefix+key, 1, window).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	return nil
+}
+
+func (s *Store) ConsumeApproval(key string) (bool, error) {
+	const op = "storage.redis.ConsumeApproval"
+
+	// DEL reports whether the key existed, so only one reader wins
+	n, err := s.client.Del(s.ctx, approvalKeyPrefix+key).Result()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	return n > 0, nil
+}
+
+func (s *Store) RecordRead(key string, read storage.Read, max int) error {
+	const op = "storage.redis.RecordRead"
+
+	value, err := json.Marshal(read)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = recordReadScript.Run(s.ctx, s.client, []string{readsKeyPrefix + key, key}, value, max).Err()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	return nil
+}
+
+func (s *Store) CountView(key string) (int, error) {
+	const op = "storage.redis.CountView"
+
+	views, err := countViewScript.Run(s.ctx, s.client, []string{viewsKeyPrefix + key, key}).Int()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	return views, nil
+}
+
+func (s *Store) Reads(key string) ([]storage.Read, error) {
+	const op = "storage.redis.Reads"
+
+	values, err := s.replica.LRange(s.ctx, readsKeyPrefix+key, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	reads := make([]storage.Read, 0, len(values))
+	for _, value := range values {
+		var read storage.Read
+		if err := json.Unmarshal([]byte(value), &read); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		reads = append(reads, read)
+	}
+
+	return reads, nil
+}
+
+func (s *Store) SetMetadata(key string, md storage.Metadata, ttl time.Duration) error {
+	const op = "storage.redis.SetMetadata"
+
+	value, err := json.Marshal(md)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.client.Set(s.ctx, metaKeyPrefix+key, value, ttl).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	return nil
+}
+
+func (s *Store) Metadata(key string) (storage.Metadata, error) {
+	const op = "storage.redis.Metadata"
+
+	var md storage.Metadata
+
+	value, err := s.replica.Get(s.ctx, metaKeyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) && s.replica != s.client {
+		value, err = s.client.Get(s.ctx, metaKeyPrefix+key).Bytes()
+	}
+	if err != nil {
+		return md, fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	if err := json.Unmarshal(value, &md); err != nil {
+		return md, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return md, nil
+}
+
+func (s *Store) Allow(name string, limit int, window time.Duration) (bool, time.Duration, error) {
+	const op = "storage.redis.Allow"
+
+	// Members only need to be unique, events of the same millisecond from
+	// several instances must all count
+	now := time.Now().UnixMilli()
+	member := strconv.FormatInt(now, 10) + "-" + rand.Text()
+
+	result, err := allowScript.Run(s.ctx, s.client, []string{rateLimitKeyPrefix + name}, now, window.Milliseconds(), limit, member).Int64Slice()
+	if err != nil {
+		return false, 0, fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	return result[0] == 1, time.Duration(result[1]) * time.Millisecond, nil
+}
+
+// exportBatchSize bounds how many secrets Export reads per round trip.
+const exportBatchSize = 500
+
+func (s *Store) Export(fn func(storage.Record) error) error {
+	const op = "storage.redis.Export"
+
+	// Every secret is indexed by creation time, which makes the index the
+	// cheapest way to find them all
+	var keys []string
+	iter := s.client.ZScan(s.ctx, createdKey, 0, "", exportBatchSize).Iterator()
+	for i := 0; iter.Next(s.ctx); i++ {
+		// ZSCAN alternates members and scores
+		if i%2 == 0 {
+			keys = append(keys, iter.Val())
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	for batch := range slices.Chunk(keys, exportBatchSize) {
+		values := make([]*redis.StringCmd, len(batch))
+		ttls := make([]*redis.DurationCmd, len(batch))
+		metas := make([]*redis.StringCmd, len(batch))
+		_, err := s.client.Pipelined(s.ctx, func(pipe redis.Pipeliner) error {
+			for i, key := range batch {
+				values[i] = pipe.Get(s.ctx, key)
+				ttls[i] = pipe.PTTL(s.ctx, key)
+				metas[i] = pipe.Get(s.ctx, metaKeyPrefix+key)
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return fmt.Errorf("%s: %w", op, translateError(err))
+		}
+
+		now := time.Now()
+		for i, key := range batch {
+			value, err := values[i].Bytes()
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, translateError(err))
+			}
+
+			record := storage.Record{Alias: key, Ciphertext: value}
+			if ttl := ttls[i].Val(); ttl > 0 {
+				record.ExpiresAt = now.Add(ttl).UTC()
+			}
+			if raw, err := metas[i].Bytes(); err == nil {
+				var md storage.Metadata
+				if err := json.Unmarshal(raw, &md); err != nil {
+					return fmt.Errorf("%s: %w", op, err)
+				}
+				record.Metadata = &md
+			}
+
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// purgeScanCount is the COUNT hint of the scans PurgeOrphans runs.
+const purgeScanCount = 500
+
+// purgeAuxScript deletes each of KEYS whose secret, the ARGV entry after
+// ARGV[1] at the same position, no longer exists, returning how many it
+// deleted. Keys written or read within ARGV[1] seconds are kept, as are all
+// keys when the eviction policy doesn't track idle times.
+var purgeAuxScript = redis.NewScript(`
+local grace = tonumber(ARGV[1])
+local removed = 0
+for i, key in ipairs(KEYS) do
+	local idle = redis.pcall('OBJECT', 'IDLETIME', key)
+	if type(idle) == 'number' and idle >= grace and redis.call('EXISTS', ARGV[i + 1]) == 0 then
+		removed = removed + redis.call('DEL', key)
+	end
+end
+return removed
+`)
+
+// purgeMembersScript removes the members in ARGV[2:] that no longer exist
+// from the zset KEYS[1], keeping those scored at or below ARGV[1]. It
+// returns how many it removed.
+var purgeMembersScript = redis.NewScript(`
+local keep = tonumber(ARGV[1])
+local removed = 0
+for i = 2, #ARGV do
+	if redis.call('EXISTS', ARGV[i]) == 0 and tonumber(redis.call('ZSCORE', KEYS[1], ARGV[i]) or keep) > keep then
+		removed = removed + redis.call('ZREM', KEYS[1], ARGV[i])
+	end
+end
+return removed
+`)
+
+// purgeGroupScript removes the members of the group KEYS[1] that no longer
+// exist, unless the group was written or read within ARGV[1] seconds. Reading
+// the members resets that, so the check and the removal share a script. It
+// returns {removed members, emptied groups}.
+var purgeGroupScript = redis.NewScript(`
+local idle = redis.pcall('OBJECT', 'IDLETIME', KEYS[1])
+if type(idle) ~= 'number' or idle < tonumber(ARGV[1]) then
+	return {0, 0}
+end
+local removed = 0
+for _, member in ipairs(redis.call('SMEMBERS', KEYS[1])) do
+	if redis.call('EXISTS', member) == 0 then
+		removed = removed + redis.call('SREM', KEYS[1], member)
+	end
+end
+-- Redis drops a set with its last member
+if removed > 0 and redis.call('EXISTS', KEYS[1]) == 0 then
+	return {removed, 1}
+end
+return {removed, 0}
+`)
+
+func (s *Store) PurgeOrphans() (storage.PurgeStats, error) {
+	const op = "storage.redis.PurgeOrphans"
+
+	var stats storage.PurgeStats
+
+	for _, prefix := range []string{metaKeyPrefix, readsKeyPrefix, versionKeyPrefix, viewsKeyPrefix, approvalKeyPrefix} {
+		iter := s.client.Scan(s.ctx, 0, prefix+"*", purgeScanCount).Iterator()
+		var keys, secrets []string
+		flush := func() error {
+			if len(keys) == 0 {
+				return nil
+			}
+			args := append([]any{int(storage.PurgeGrace.Seconds())}, toAny(secrets)...)
+			n, err := purgeAuxScript.Run(s.ctx, s.client, keys, args...).Int()
+			stats.AuxKeys += n
+			keys, secrets = keys[:0], secrets[:0]
+			return err
+		}
+		for iter.Next(s.ctx) {
+			keys = append(keys, iter.Val())
+			secrets = append(secrets, strings.TrimPrefix(iter.Val(), prefix))
+			if len(keys) == purgeScanCount {
+				if err := flush(); err != nil {
+					return stats, fmt.Errorf("%s: %w", op, translateError(err))
+				}
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return stats, fmt.Errorf("%s: %w", op, translateError(err))
+		}
+		if err := flush(); err != nil {
+			return stats, fmt.Errorf("%s: %w", op, translateError(err))
+		}
+	}
+
+	for _, index := range []string{expiriesKey, createdKey} {
+		var members []string
+		iter := s.client.ZScan(s.ctx, index, 0, "", purgeScanCount).Iterator()
+		for i := 0; iter.Next(s.ctx); i++ {
+			// ZSCAN alternates members and scores
+			if i%2 == 0 {
+				members = append(members, iter.Val())
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return stats, fmt.Errorf("%s: %w", op, translateError(err))
+		}
+		// Entries due soon are left to ClaimExpired, their secrets still get
+		// tombstones and webhooks. The grace covers clock skew between
+		// instances and Redis expiring the secret.
+		var keep int64
+		if index == expiriesKey {
+			keep = time.Now().Add(storage.PurgeGrace).UnixMilli()
+		}
+		for batch := range slices.Chunk(members, purgeScanCount) {
+			args := append([]any{keep}, toAny(batch)...)
+			removed, err := purgeMembersScript.Run(s.ctx, s.client, []string{index}, args...).Int()
+			if err != nil {
+				return stats, fmt.Errorf("%s: %w", op, translateError(err))
+			}
+			stats.IndexEntries += removed
+		}
+	}
+
+	iter := s.client.Scan(s.ctx, 0, groupKeyPrefix+"*", purgeScanCount).Iterator()
+	for iter.Next(s.ctx) {
+		result, err := purgeGroupScript.Run(s.ctx, s.client, []string{iter.Val()}, int(storage.PurgeGrace.Seconds())).Int64Slice()
+		if err != nil {
+			return stats, fmt.Errorf("%s: %w", op, translateError(err))
+		}
+		stats.GroupMembers += int(result[0])
+		stats.Groups += int(result[1])
+	}
+	if err := iter.Err(); err != nil {
+		return stats, fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	return stats, nil
+}
+
+// auxKeys lists the keys kept next to the secret at key, all of which go
+// when the secret goes.
+func auxKeys(key string) []string {
+	return []string{metaKeyPrefix + key, readsKeyPrefix + key, versionKeyPrefix + key, viewsKeyPrefix + key}
+}
+
+func toAny(keys []string) []interface{} {
+	out := make([]interface{}, len(keys))
+	for i, key := range keys {
+		out[i] = key
+	}
+	return out
+}
+
+// consumeWithLock emulates GETDEL by guarding the read-then-delete with a
+// short-lived lock key, so only the node holding the lock can consume.
+func (s *Store) consumeWithLock(key string) ([]byte, error) {
+	lockKey := key + ":lock"
+
+	acquired, err := s.client.SetNX(s.ctx, lockKey, 1, consumeLockTTL).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		// Another node is consuming this key right now
+		return nil, redis.Nil
+	}
+	defer s.client.Del(s.ctx, lockKey)
+
+	object, err := s.client.Get(s.ctx, key).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	deleted, err := s.client.Del(s.ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if deleted == 0 {
+		return nil, redis.Nil
+	}
+
+	return object, nil
+}
+
+// translateError maps go-redis errors onto the storage sentinel errors.
+// Errors that don't match a known category are returned unchanged.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, redis.Nil) {
+		return storage.ErrNotFound
+	}
+
+	if redis.HasErrorPrefix(err, "OOM") {
+		return fmt.Errorf("%w: %v", storage.ErrCapacity, err)
+	}
+
+	if redis.HasErrorPrefix(err, "LOADING") ||
+		redis.HasErrorPrefix(err, "MASTERDOWN") ||
+		redis.HasErrorPrefix(err, "READONLY") {
+		return fmt.Errorf("%w: %v", storage.ErrUnavailable, err)
+	}
+
+	// Timeouts are net.Errors too, so they have to be told apart first
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("%w: %v", storage.ErrTimeout, err)
+	}
+
+	if errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, redis.ErrClosed) {
+		return fmt.Errorf("%w: %v", storage.ErrUnavailable, err)
+	}
+
+	return err
+}
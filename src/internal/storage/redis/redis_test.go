@@ -0,0 +1,785 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"yoopass-api/internal/storage"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// redisError mimics the error type go-redis returns for server replies
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+func (e redisError) RedisError()   {}
+
+func TestTranslateError(t *testing.T) {
+	otherErr := errors.New("something unexpected")
+
+	testCases := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{
+			name:     "Nil Stays Nil",
+			err:      nil,
+			expected: nil,
+		},
+		{
+			name:     "Missing Key",
+			err:      redis.Nil,
+			expected: storage.ErrNotFound,
+		},
+		{
+			name:     "Out Of Memory",
+			err:      redisError("OOM command not allowed when used memory > 'maxmemory'."),
+			expected: storage.ErrCapacity,
+		},
+		{
+			name:     "Loading Dataset",
+			err:      redisError("LOADING Redis is loading the dataset in memory"),
+			expected: storage.ErrUnavailable,
+		},
+		{
+			name:     "Read Only Replica",
+			err:      redisError("READONLY You can't write against a read only replica."),
+			expected: storage.ErrUnavailable,
+		},
+		{
+			name:     "Connection Refused",
+			err:      &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+			expected: storage.ErrUnavailable,
+		},
+		{
+			name:     "Read Timeout",
+			err:      &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded},
+			expected: storage.ErrTimeout,
+		},
+		{
+			name:     "Context Deadline",
+			err:      fmt.Errorf("dial: %w", context.DeadlineExceeded),
+			expected: storage.ErrTimeout,
+		},
+		{
+			name:     "Connection Dropped",
+			err:      fmt.Errorf("read: %w", io.EOF),
+			expected: storage.ErrUnavailable,
+		},
+		{
+			name:     "Client Closed",
+			err:      redis.ErrClosed,
+			expected: storage.ErrUnavailable,
+		},
+		{
+			name:     "Unknown Error Passes Through",
+			err:      otherErr,
+			expected: otherErr,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := translateError(tc.err)
+			if tc.expected == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tc.expected)
+		})
+	}
+}
+
+// newTestStore starts an in-process Redis server and connects a Store to it
+func newTestStore(t *testing.T) (*Store, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	store, err := New(server.Addr())
+	require.NoError(t, err)
+	return store, server
+}
+
+func TestPingAndClose(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	require.NoError(t, store.Ping(context.Background()))
+	require.NoError(t, store.Close())
+	assert.Error(t, store.Ping(context.Background()), "a closed store no longer answers")
+}
+
+func TestSetRefusesExistingKey(t *testing.T) {
+	store, server := newTestStore(t)
+
+	require.NoError(t, store.Set("alias", []byte("first"), time.Hour))
+
+	err := store.Set("alias", []byte("second"), 2*time.Hour)
+	assert.ErrorIs(t, err, storage.ErrConflict)
+
+	object, err := store.Fetch("alias")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("first"), object, "a colliding set must not clobber")
+	assert.Equal(t, time.Hour, server.TTL("alias"))
+}
+
+func TestConsume(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	require.NoError(t, store.Set("alias", []byte("cipher"), time.Hour))
+
+	object, err := store.Consume("alias")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("cipher"), object)
+
+	// The key is gone after the first consume
+	_, err = store.Consume("alias")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = store.Fetch("alias")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestConsumeConcurrentSingleWinner(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	consumers := map[string]func(key string) ([]byte, error){
+		"GETDEL": store.Consume,
+		"Lock":   store.consumeWithLock,
+	}
+
+	for name, consume := range consumers {
+		t.Run(name, func(t *testing.T) {
+			const workers = 50
+			key := "alias-" + name
+			require.NoError(t, store.Set(key, []byte("cipher"), time.Hour))
+
+			var (
+				wg        sync.WaitGroup
+				successes atomic.Int32
+			)
+			for range workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if object, err := consume(key); err == nil {
+						assert.Equal(t, []byte("cipher"), object)
+						successes.Add(1)
+					}
+				}()
+			}
+			wg.Wait()
+
+			assert.Equal(t, int32(1), successes.Load(), "exactly one consumer must win")
+		})
+	}
+}
+
+func TestReplaceKeepsTTL(t *testing.T) {
+	store, server := newTestStore(t)
+
+	require.NoError(t, store.Set("alias", []byte("old"), time.Hour))
+	server.FastForward(10 * time.Minute)
+
+	require.NoError(t, store.Replace("alias", []byte("old"), []byte("new")))
+
+	object, err := store.Fetch("alias")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("new"), object)
+	assert.Equal(t, 50*time.Minute, server.TTL("alias"))
+
+	// Replacing a missing key must not create it
+	err = store.Replace("missing", []byte("old"), []byte("new"))
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.False(t, server.Exists("missing"))
+}
+
+func TestTTL(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	require.NoError(t, store.Set("expiring", []byte("v"), time.Hour))
+	require.NoError(t, store.Set("forever", []byte("v"), 0))
+
+	ttl, err := store.TTL("expiring")
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, ttl)
+
+	ttl, err = store.TTL("forever")
+	require.NoError(t, err)
+	assert.Zero(t, ttl)
+
+	_, err = store.TTL("missing")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestAddToGroupStretchesTTL(t *testing.T) {
+	store, server := newTestStore(t)
+
+	require.NoError(t, store.AddToGroup("team", "a", time.Hour))
+	assert.Equal(t, time.Hour, server.TTL("group:team"))
+
+	// A shorter lived member must not shorten the group
+	require.NoError(t, store.AddToGroup("team", "b", time.Minute))
+	assert.Equal(t, time.Hour, server.TTL("group:team"))
+
+	require.NoError(t, store.AddToGroup("team", "c", 2*time.Hour))
+	assert.Equal(t, 2*time.Hour, server.TTL("group:team"))
+
+	// A member that never expires keeps the group forever
+	require.NoError(t, store.AddToGroup("team", "d", 0))
+	assert.Zero(t, server.TTL("group:team"))
+
+	members, err := server.Members("group:team")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a", "b", "c", "d"}, members)
+}
+
+func TestDeleteGroup(t *testing.T) {
+	store, server := newTestStore(t)
+
+	for _, alias := range []string{"a", "b", "c"} {
+		require.NoError(t, store.Set(alias, []byte("cipher"), time.Hour))
+		require.NoError(t, store.AddToGroup("team", alias, time.Hour))
+	}
+	require.NoError(t, store.Set("outsider", []byte("cipher"), time.Hour))
+
+	// A member that was already read and burned
+	_, err := store.Consume("c")
+	require.NoError(t, err)
+
+	deleted, err := store.DeleteGroup("team")
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+
+	for _, key := range []string{"a", "b", "c", "group:team"} {
+		assert.False(t, server.Exists(key), key)
+	}
+	assert.True(t, server.Exists("outsider"))
+
+	_, err = store.DeleteGroup("team")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestMetadata(t *testing.T) {
+	store, server := newTestStore(t)
+
+	require.NoError(t, store.Set("alias", []byte("cipher"), time.Hour))
+	require.NoError(t, store.SetMetadata("alias", storage.Metadata{AbuseTag: "phishing"}, time.Hour))
+
+	md, err := store.Metadata("alias")
+	require.NoError(t, err)
+	assert.Equal(t, "phishing", md.AbuseTag)
+
+	// Stored as plaintext JSON next to the secret, with the same TTL
+	raw, err := server.Get("meta:alias")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"abuse_tag":"phishing"}`, raw)
+	assert.Equal(t, time.Hour, server.TTL("meta:alias"))
+
+	_, err = store.Consume("alias")
+	require.NoError(t, err)
+	_, err = store.Metadata("alias")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestNewValidatesAddress(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	testCases := []struct {
+		name    string
+		addr    string
+		wantErr bool
+	}{
+		{name: "Empty", addr: "", wantErr: true},
+		{name: "Missing Port", addr: "localhost", wantErr: true},
+		{name: "Missing Host", addr: ":6379", wantErr: true},
+		{name: "Non Numeric Port", addr: "localhost:redis", wantErr: true},
+		{name: "Port Out Of Range", addr: "localhost:70000", wantErr: true},
+		{name: "URL Instead Of Address", addr: "redis://localhost:6379", wantErr: true},
+		{name: "Valid", addr: server.Addr()},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store, err := New(tc.addr)
+			if tc.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidAddress)
+				assert.Nil(t, store)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, store)
+		})
+	}
+}
+
+func TestDeleteCreatedBefore(t *testing.T) {
+	store, server := newTestStore(t)
+
+	for _, key := range []string{"old-a", "old-b", "new"} {
+		require.NoError(t, store.Set(key, []byte("cipher"), time.Hour))
+	}
+	require.NoError(t, store.SetMetadata("old-a", storage.Metadata{AbuseTag: "spam"}, time.Hour))
+
+	// Backdate two secrets, as if they were saved two hours ago
+	twoHoursAgo := float64(time.Now().Add(-2 * time.Hour).UnixMilli())
+	_, err := server.ZAdd("created", twoHoursAgo, "old-a")
+	require.NoError(t, err)
+	_, err = server.ZAdd("created", twoHoursAgo, "old-b")
+	require.NoError(t, err)
+
+	deleted, err := store.DeleteCreatedBefore(time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+
+	for _, key := range []string{"old-a", "old-b", "meta:old-a"} {
+		assert.False(t, server.Exists(key), key)
+	}
+	assert.True(t, server.Exists("new"))
+
+	members, err := server.ZMembers("created")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"new"}, members)
+	members, err = server.ZMembers("expiries")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"new"}, members)
+
+	deleted, err = store.DeleteCreatedBefore(time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+}
+
+func TestApproval(t *testing.T) {
+	store, server := newTestStore(t)
+
+	approved, err := store.ConsumeApproval("alias")
+	require.NoError(t, err)
+	assert.False(t, approved, "no approval yet")
+
+	require.NoError(t, store.Approve("alias", 10*time.Minute))
+	assert.Equal(t, 10*time.Minute, server.TTL("approval:alias"))
+
+	approved, err = store.ConsumeApproval("alias")
+	require.NoError(t, err)
+	assert.True(t, approved)
+
+	approved, err = store.ConsumeApproval("alias")
+	require.NoError(t, err)
+	assert.False(t, approved, "an approval admits a single reveal")
+
+	require.NoError(t, store.Approve("alias", time.Minute))
+	server.FastForward(2 * time.Minute)
+	approved, err = store.ConsumeApproval("alias")
+	require.NoError(t, err)
+	assert.False(t, approved, "the window has closed")
+}
+
+func TestReadHistory(t *testing.T) {
+	store, server := newTestStore(t)
+
+	require.NoError(t, store.Set("alias", []byte("cipher"), time.Hour))
+
+	reads, err := store.Reads("alias")
+	require.NoError(t, err)
+	assert.Empty(t, reads)
+
+	start := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		read := storage.Read{At: start.Add(time.Duration(i) * time.Minute), IPHash: fmt.Sprintf("hash-%d", i)}
+		require.NoError(t, store.RecordRead("alias", read, 3))
+	}
+
+	reads, err = store.Reads("alias")
+	require.NoError(t, err)
+	require.Len(t, reads, 3, "history is capped")
+	assert.Equal(t, "hash-4", reads[0].IPHash, "newest first")
+	assert.Equal(t, "hash-2", reads[2].IPHash)
+	assert.True(t, start.Add(4*time.Minute).Equal(reads[0].At))
+	assert.Equal(t, time.Hour, server.TTL("reads:alias"), "expires with the secret")
+
+	require.NoError(t, store.Delete("alias"))
+	assert.False(t, server.Exists("reads:alias"))
+}
+
+func TestCountView(t *testing.T) {
+	store, server := newTestStore(t)
+
+	require.NoError(t, store.Set("alias", []byte("cipher"), time.Hour))
+
+	for want := 1; want <= 3; want++ {
+		views, err := store.CountView("alias")
+		require.NoError(t, err)
+		assert.Equal(t, want, views)
+	}
+	assert.Equal(t, time.Hour, server.TTL("views:alias"), "expires with the secret")
+
+	require.NoError(t, store.Delete("alias"))
+	assert.False(t, server.Exists("views:alias"))
+}
+
+func TestReplaceRefusesStaleValue(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	require.NoError(t, store.Set("alias", []byte("newer"), time.Hour))
+
+	err := store.Replace("alias", []byte("older"), []byte("rotated"))
+	assert.ErrorIs(t, err, storage.ErrStale)
+
+	object, err := store.Fetch("alias")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("newer"), object, "a stale replace must not clobber")
+}
+
+func TestUpdateVersions(t *testing.T) {
+	store, server := newTestStore(t)
+
+	_, err := store.Update("missing", []byte("v"), time.Hour, 0)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.False(t, server.Exists("missing"))
+
+	require.NoError(t, store.Set("alias", []byte("v0"), time.Hour))
+
+	version, err := store.Update("alias", []byte("v1"), 2*time.Hour, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), version)
+	assert.Equal(t, 2*time.Hour, server.TTL("alias"))
+	assert.Equal(t, 2*time.Hour, server.TTL("version:alias"))
+
+	// A second writer still holding version 0 loses and learns the current one
+	version, err = store.Update("alias", []byte("stale"), 3*time.Hour, 0)
+	assert.ErrorIs(t, err, storage.ErrStale)
+	assert.Equal(t, int64(1), version)
+	object, err := store.Fetch("alias")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v1"), object)
+
+	version, err = store.Update("alias", []byte("v2"), 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), version, "a negative version skips the check")
+	assert.Zero(t, server.TTL("alias"))
+
+	require.NoError(t, store.Delete("alias"))
+	assert.False(t, server.Exists("version:alias"))
+}
+
+func TestSetOrGet(t *testing.T) {
+	store, server := newTestStore(t)
+
+	stored, existing, err := store.SetOrGet("alias", []byte("first"), time.Hour)
+	require.NoError(t, err)
+	assert.True(t, stored)
+	assert.Nil(t, existing)
+	assert.Equal(t, time.Hour, server.TTL("alias"))
+
+	// The second writer stores nothing and gets the first value back
+	stored, existing, err = store.SetOrGet("alias", []byte("second"), 2*time.Hour)
+	require.NoError(t, err)
+	assert.False(t, stored)
+	assert.Equal(t, []byte("first"), existing)
+	assert.Equal(t, time.Hour, server.TTL("alias"))
+
+	// Stored keys are indexed like Set does, so revocation finds them
+	removed, err := store.DeleteCreatedBefore(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+}
+
+func TestSetOrGetConcurrentSingleWinner(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	const workers = 50
+	var (
+		wg      sync.WaitGroup
+		winners atomic.Int32
+		values  sync.Map
+	)
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value := []byte(fmt.Sprint(i))
+			stored, existing, err := store.SetOrGet("alias", value, time.Hour)
+			if !assert.NoError(t, err) {
+				return
+			}
+			if stored {
+				winners.Add(1)
+				existing = value
+			}
+			values.Store(string(existing), true)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), winners.Load(), "exactly one writer must store its value")
+
+	// Every loser saw the winner's value and nothing else
+	object, err := store.Fetch("alias")
+	require.NoError(t, err)
+	values.Range(func(key, _ any) bool {
+		assert.Equal(t, string(object), key)
+		return true
+	})
+}
+
+func TestStats(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	require.NoError(t, store.Set("short", []byte("v"), time.Minute))
+	require.NoError(t, store.Set("long", []byte("v"), time.Hour))
+	require.NoError(t, store.Set("forever", []byte("v"), 0))
+
+	stats, err := store.Stats(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, storage.Stats{Total: 3, Active: 3}, stats)
+
+	// Ran out but not claimed yet
+	stats, err = store.Stats(time.Now().Add(2 * time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, storage.Stats{Total: 3, Active: 2}, stats)
+}
+
+func TestGrace(t *testing.T) {
+	store, server := newTestStore(t)
+
+	require.NoError(t, store.SetGrace("alias", "holder", []byte("cipher"), time.Minute))
+
+	object, err := store.Grace("alias", "holder")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("cipher"), object)
+
+	_, err = store.Grace("alias", "someone-else")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	server.FastForward(time.Minute)
+	_, err = store.Grace("alias", "holder")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+// commandLog is a go-redis hook recording the commands a client sends.
+type commandLog struct {
+	mu       sync.Mutex
+	commands []string
+}
+
+func (l *commandLog) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (l *commandLog) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		l.record(cmd)
+		return next(ctx, cmd)
+	}
+}
+
+func (l *commandLog) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			l.record(cmd)
+		}
+		return next(ctx, cmds)
+	}
+}
+
+func (l *commandLog) record(cmd redis.Cmder) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.commands = append(l.commands, cmd.Name())
+}
+
+func (l *commandLog) reset() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	commands := l.commands
+	l.commands = nil
+	return commands
+}
+
+func TestReplicaReads(t *testing.T) {
+	primary, replica := miniredis.RunT(t), miniredis.RunT(t)
+	store, err := New(primary.Addr(), WithReplica(replica.Addr()))
+	require.NoError(t, err)
+	routed := new(commandLog)
+	store.replica.AddHook(routed)
+
+	// The replica lags behind: it still holds a value the primary replaced
+	require.NoError(t, store.Set("alias", []byte("primary"), time.Hour))
+	require.NoError(t, replica.Set("alias", "replica"))
+	routed.reset()
+
+	object, err := store.Fetch("alias")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("replica"), object, "plain reads go to the replica")
+	assert.Equal(t, []string{"get"}, routed.reset())
+
+	// A key the replica hasn't seen yet is found on the primary
+	require.NoError(t, store.Set("fresh", []byte("new"), time.Hour))
+	object, err = store.Fetch("fresh")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("new"), object)
+	routed.reset()
+
+	// Nothing that writes or decides a write may reach the replica
+	object, err = store.Consume("alias")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("primary"), object)
+	require.NoError(t, store.Set("versioned", []byte("v0"), time.Hour))
+	_, err = store.Update("versioned", []byte("v1"), time.Hour, 0)
+	require.NoError(t, err)
+	require.NoError(t, store.Replace("versioned", []byte("v1"), []byte("v2")))
+	_, _, err = store.SetOrGet("versioned", []byte("v3"), time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, store.Approve("versioned", time.Minute))
+	_, err = store.ConsumeApproval("versioned")
+	require.NoError(t, err)
+	_, _, err = store.Allow("saves", 10, time.Minute)
+	require.NoError(t, err)
+	_, err = store.ClaimExpired(time.Now(), 10)
+	require.NoError(t, err)
+
+	assert.Empty(t, routed.reset())
+	assert.True(t, replica.Exists("alias"), "the replica was never written to")
+}
+
+func TestPurgeOrphans(t *testing.T) {
+	store, server := newTestStore(t)
+
+	// A live secret with everything kept next to it
+	require.NoError(t, store.Set("live", []byte("cipher"), time.Hour))
+	require.NoError(t, store.SetMetadata("live", storage.Metadata{AbuseTag: "spam"}, time.Hour))
+	require.NoError(t, store.AddToGroup("deploy", "live", time.Hour))
+
+	// A secret that vanished without its auxiliary keys being cleaned up,
+	// e.g. a save that failed halfway
+	require.NoError(t, store.Set("gone", []byte("cipher"), time.Hour))
+	require.NoError(t, store.SetMetadata("gone", storage.Metadata{AbuseTag: "spam"}, time.Hour))
+	require.NoError(t, store.Approve("gone", time.Hour))
+	require.NoError(t, store.RecordRead("gone", storage.Read{At: time.Now(), IPHash: "ab"}, 5))
+	require.NoError(t, store.AddToGroup("deploy", "gone", time.Hour))
+	require.NoError(t, store.AddToGroup("stale", "gone", time.Hour))
+	server.Del("gone")
+	// Only keys left alone for a while are purged
+	server.SetTime(time.Now().Add(storage.PurgeGrace))
+
+	stats, err := store.PurgeOrphans()
+	require.NoError(t, err)
+	assert.Equal(t, storage.PurgeStats{AuxKeys: 3, IndexEntries: 2, GroupMembers: 2, Groups: 1}, stats)
+
+	for _, key := range []string{"meta:gone", "approval:gone", "reads:gone", "group:stale"} {
+		assert.False(t, server.Exists(key), key)
+	}
+	assert.True(t, server.Exists("meta:live"))
+	members, err := server.Members("group:deploy")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"live"}, members)
+	for _, index := range []string{"created", "expiries"} {
+		members, err := server.ZMembers(index)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"live"}, members, index)
+	}
+
+	stats, err = store.PurgeOrphans()
+	require.NoError(t, err)
+	assert.Zero(t, stats, "a second run finds nothing left")
+}
+
+func TestPurgeOrphansKeepsDueExpiries(t *testing.T) {
+	store, server := newTestStore(t)
+
+	require.NoError(t, store.Set("expired", []byte("cipher"), time.Minute))
+	server.SetTime(time.Now().Add(storage.PurgeGrace))
+	server.FastForward(time.Minute)
+	require.False(t, server.Exists("expired"))
+
+	stats, err := store.PurgeOrphans()
+	require.NoError(t, err)
+	assert.Equal(t, storage.PurgeStats{IndexEntries: 1}, stats, "only the creation entry goes")
+
+	// The expiry still gets its tombstone and webhook
+	keys, err := store.ClaimExpired(time.Now().Add(time.Minute), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"expired"}, keys)
+}
+
+func TestPurgeOrphansSparesPendingSave(t *testing.T) {
+	store, server := newTestStore(t)
+
+	// Save writes these before the secret itself
+	md := storage.Metadata{OneTime: true, ClientEncrypted: true}
+	require.NoError(t, store.SetMetadata("pending", md, time.Hour))
+	require.NoError(t, store.AddToGroup("deploy", "pending", time.Hour))
+
+	stats, err := store.PurgeOrphans()
+	require.NoError(t, err)
+	assert.Zero(t, stats)
+
+	require.NoError(t, store.Set("pending", []byte("cipher"), time.Hour))
+	got, err := store.Metadata("pending")
+	require.NoError(t, err)
+	assert.Equal(t, md, got)
+	members, err := server.Members("group:deploy")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pending"}, members)
+}
+
+func TestAllow(t *testing.T) {
+	store, server := newTestStore(t)
+
+	for range 3 {
+		allowed, _, err := store.Allow("saves", 3, time.Minute)
+		require.NoError(t, err)
+		require.True(t, allowed)
+	}
+
+	allowed, retryAfter, err := store.Allow("saves", 3, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, allowed, "the budget is spent")
+	assert.Greater(t, retryAfter, time.Duration(0))
+	assert.LessOrEqual(t, retryAfter, time.Minute)
+	assert.Equal(t, time.Minute, server.TTL("ratelimit:saves"))
+
+	allowed, _, err = store.Allow("other", 3, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, allowed, "limits are kept apart")
+
+	// Events slide out of a short window
+	for range 2 {
+		allowed, _, err = store.Allow("burst", 2, 50*time.Millisecond)
+		require.NoError(t, err)
+		require.True(t, allowed)
+	}
+	allowed, _, err = store.Allow("burst", 2, 50*time.Millisecond)
+	require.NoError(t, err)
+	require.False(t, allowed)
+	time.Sleep(60 * time.Millisecond)
+	allowed, _, err = store.Allow("burst", 2, 50*time.Millisecond)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+}
+
+func TestDeadLetters(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	letters, err := store.DeadLetters()
+	require.NoError(t, err)
+	assert.Empty(t, letters)
+
+	for i := range 4 {
+		letter := storage.DeadLetter{Type: "secret.read", Alias: fmt.Sprintf("alias-%d", i), Error: "unexpected status 500"}
+		require.NoError(t, store.AddDeadLetter(letter, 3))
+	}
+
+	letters, err = store.DeadLetters()
+	require.NoError(t, err)
+	require.Len(t, letters, 3, "the log is capped")
+	assert.Equal(t, "alias-3", letters[0].Alias, "newest first")
+	assert.Equal(t, "alias-1", letters[2].Alias)
+}
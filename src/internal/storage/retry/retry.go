@@ -0,0 +1,74 @@
+package retry
+
+import (
+	"errors"
+	"time"
+	"yoopass-api/internal/storage"
+)
+
+// Store retries Set, Fetch and Delete when they fail with a transient error,
+// see Transient. Those are safe to run twice. Everything else passes through
+// untouched, Consume above all, so a retry never burns a secret twice.
+type Store struct {
+	storage.Storage
+	attempts int
+	backoff  time.Duration
+}
+
+// Wrap returns store making up to attempts tries of each retried call. The
+// wait between tries starts at backoff and doubles every time.
+func Wrap(store storage.Storage, attempts int, backoff time.Duration) *Store {
+	return &Store{Storage: store, attempts: attempts, backoff: backoff}
+}
+
+// Transient reports whether err may go away on its own, like a connection
+// reset or a Redis still loading its dataset.
+func Transient(err error) bool {
+	return errors.Is(err, storage.ErrUnavailable) || errors.Is(err, storage.ErrTimeout)
+}
+
+// Set retries like the others, but a try that timed out may still have
+// stored the key. A conflict on a later try is then no collision, and the
+// caller gets the earlier failure instead.
+func (s *Store) Set(key string, value []byte, ttl time.Duration) error {
+	var failed error
+	err := s.do(func() error {
+		err := s.Storage.Set(key, value, ttl)
+		if Transient(err) {
+			failed = err
+		}
+		return err
+	})
+	if errors.Is(err, storage.ErrConflict) && failed != nil {
+		return failed
+	}
+	return err
+}
+
+func (s *Store) Fetch(key string) ([]byte, error) {
+	var object []byte
+	err := s.do(func() error {
+		var err error
+		object, err = s.Storage.Fetch(key)
+		return err
+	})
+	return object, err
+}
+
+func (s *Store) Delete(key string) error {
+	return s.do(func() error {
+		return s.Storage.Delete(key)
+	})
+}
+
+func (s *Store) do(fn func() error) error {
+	wait := s.backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !Transient(err) || attempt >= s.attempts {
+			return err
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
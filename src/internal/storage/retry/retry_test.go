@@ -0,0 +1,92 @@
+package retry
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"yoopass-api/internal/storage"
+	"yoopass-api/internal/storage/storagemock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetch(t *testing.T) {
+	const alias = "alias"
+
+	testCases := []struct {
+		name          string
+		errs          []error
+		expectedCalls int
+		expectedErr   error
+	}{
+		{name: "Success", errs: []error{nil}, expectedCalls: 1},
+		{name: "Unavailable Then Success", errs: []error{storage.ErrUnavailable, nil}, expectedCalls: 2},
+		{name: "Timeout Then Success", errs: []error{storage.ErrTimeout, storage.ErrTimeout, nil}, expectedCalls: 3},
+		{name: "Not Found Fails Immediately", errs: []error{storage.ErrNotFound}, expectedCalls: 1, expectedErr: storage.ErrNotFound},
+		{name: "Unknown Error Fails Immediately", errs: []error{errors.New("WRONGTYPE")}, expectedCalls: 1},
+		{name: "Attempts Exhausted", errs: []error{storage.ErrUnavailable, storage.ErrUnavailable, storage.ErrUnavailable}, expectedCalls: 3, expectedErr: storage.ErrUnavailable},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStorage := new(storagemock.Storage)
+			for _, err := range tc.errs {
+				var object []byte
+				if err == nil {
+					object = []byte("ciphertext")
+				}
+				mockStorage.On("Fetch", alias).Return(object, err).Once()
+			}
+
+			object, err := Wrap(mockStorage, 3, time.Millisecond).Fetch(alias)
+
+			mockStorage.AssertNumberOfCalls(t, "Fetch", tc.expectedCalls)
+			if tc.errs[len(tc.errs)-1] != nil {
+				require.Error(t, err)
+				if tc.expectedErr != nil {
+					assert.ErrorIs(t, err, tc.expectedErr)
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []byte("ciphertext"), object)
+		})
+	}
+}
+
+func TestSetAndDelete(t *testing.T) {
+	mockStorage := new(storagemock.Storage)
+	mockStorage.On("Set", "alias", []byte("v"), time.Hour).Return(storage.ErrUnavailable).Once()
+	mockStorage.On("Set", "alias", []byte("v"), time.Hour).Return(nil).Once()
+	mockStorage.On("Delete", "alias").Return(storage.ErrTimeout).Once()
+	mockStorage.On("Delete", "alias").Return(nil).Once()
+
+	store := Wrap(mockStorage, 2, 0)
+	require.NoError(t, store.Set("alias", []byte("v"), time.Hour))
+	require.NoError(t, store.Delete("alias"))
+	mockStorage.AssertExpectations(t)
+}
+
+func TestSetConflictAfterTimeout(t *testing.T) {
+	mockStorage := new(storagemock.Storage)
+	mockStorage.On("Set", "alias", []byte("v"), time.Hour).Return(storage.ErrTimeout).Once()
+	mockStorage.On("Set", "alias", []byte("v"), time.Hour).Return(storage.ErrConflict).Once()
+
+	err := Wrap(mockStorage, 3, 0).Set("alias", []byte("v"), time.Hour)
+
+	// The timed out try may have stored the key itself, that is no collision
+	assert.ErrorIs(t, err, storage.ErrTimeout)
+	assert.NotErrorIs(t, err, storage.ErrConflict)
+	mockStorage.AssertNumberOfCalls(t, "Set", 2)
+}
+
+func TestConsumeIsNotRetried(t *testing.T) {
+	mockStorage := new(storagemock.Storage)
+	mockStorage.On("Consume", "alias").Return(nil, storage.ErrTimeout).Once()
+
+	_, err := Wrap(mockStorage, 3, 0).Consume("alias")
+
+	assert.ErrorIs(t, err, storage.ErrTimeout)
+	mockStorage.AssertNumberOfCalls(t, "Consume", 1)
+}
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Backend-agnostic errors. Every storage backend translates its native errors
+// into one of these so handlers never depend on a concrete driver.
+var (
+	ErrNotFound    = errors.New("secret not found")
+	ErrConflict    = errors.New("secret already exists")
+	ErrCapacity    = errors.New("storage capacity exceeded")
+	ErrUnavailable = errors.New("storage unavailable")
+	// ErrTimeout means the backend didn't answer in time. It is kept apart
+	// from ErrUnavailable so slowness can be told from outages.
+	ErrTimeout = errors.New("storage timed out")
+	// ErrStale means a conditional write lost to a concurrent change.
+	ErrStale = errors.New("secret was modified concurrently")
+)
+
+// Metadata is plaintext information kept next to a secret for operators. It
+// must never hold anything derived from the secret content.
+type Metadata struct {
+	AbuseTag string `json:"abuse_tag,omitempty"`
+	// ClientEncrypted marks a secret the client encrypted itself, which the
+	// server stores and serves without ever decrypting it
+	ClientEncrypted bool `json:"client_encrypted,omitempty"`
+	// OneTime burns a client encrypted secret on its first read, the flag
+	// of other secrets lives in their encrypted payload
+	OneTime bool `json:"one_time,omitempty"`
+}
+
+// Read is one entry of a secret's read history. The reader's IP is only kept
+// as a keyed hash, enough to tell readers apart but not to recover the IP.
+type Read struct {
+	At     time.Time `json:"at"`
+	IPHash string    `json:"ip_hash"`
+}
+
+// DeadLetter records a webhook event that couldn't be delivered. Events
+// only carry an alias and metadata, never secret content.
+type DeadLetter struct {
+	Type       string    `json:"type"`
+	Alias      string    `json:"alias"`
+	OccurredAt time.Time `json:"occurred_at"`
+	FailedAt   time.Time `json:"failed_at"`
+	Error      string    `json:"error"`
+}
+
+// PurgeStats counts what PurgeOrphans removed.
+type PurgeStats struct {
+	// AuxKeys are metadata, read histories, versions and approvals
+	AuxKeys      int `json:"aux_keys"`
+	IndexEntries int `json:"index_entries"`
+	GroupMembers int `json:"group_members"`
+	Groups       int `json:"groups"`
+}
+
+// PurgeGrace is how long PurgeOrphans spares auxiliary keys and groups after
+// they were last written. Saves write those before the secret itself, a
+// purge running in between must not take them.
+const PurgeGrace = 5 * time.Minute
+
+// Stats counts the stored secrets.
+type Stats struct {
+	Total int64 `json:"total"`
+	// Active leaves out secrets whose TTL ran out but that weren't removed
+	// yet
+	Active int64 `json:"active"`
+}
+
+// Record is a secret as exported for backups. Ciphertext is exactly what was
+// stored, so a backup never holds anything the server could read without
+// the key.
+type Record struct {
+	Alias      string    `json:"alias"`
+	Ciphertext []byte    `json:"ciphertext"`
+	ExpiresAt  time.Time `json:"expires_at,omitzero"`
+	Metadata   *Metadata `json:"metadata,omitempty"`
+}
+
+// Storage is the full set of operations a secret backend provides.
+//
+// Backends keep an expiry index next to the secrets: Set records when a key
+// with a TTL expires, and Delete, Consume and DeleteGroup drop the entry, so
+// ClaimExpired only ever reports keys that ran out of time. A creation index
+// is kept the same way for DeleteCreatedBefore.
+type Storage interface {
+	// Set stores a new key. It never overwrites, a key that already exists
+	// gets ErrConflict.
+	Set(key string, value []byte, ttl time.Duration) error
+	Fetch(key string) ([]byte, error)
+	Delete(key string) error
+	// Consume atomically reads and removes key. When several callers race for
+	// the same key only one of them gets the value, the rest get ErrNotFound.
+	Consume(key string) ([]byte, error)
+	// Replace overwrites the value of an existing key, keeping its TTL, as
+	// long as it still holds old. It returns ErrNotFound when the key no
+	// longer exists and ErrStale when its value changed in the meantime.
+	Replace(key string, old, value []byte) error
+	// Update overwrites the value and TTL of an existing key if its version
+	// is still version, a negative version skips the check. Every key starts
+	// at version 0 and each update bumps it. Update returns the new version,
+	// or the current one together with ErrStale.
+	Update(key string, value []byte, ttl time.Duration, version int64) (int64, error)
+	// SetOrGet sets key like Set unless it already exists, in which case it
+	// returns the existing value and stores nothing. When several callers
+	// race for the same key exactly one of them stores its value.
+	SetOrGet(key string, value []byte, ttl time.Duration) (stored bool, existing []byte, err error)
+	// TTL returns the remaining time to live of key, or zero when the key
+	// never expires. It returns ErrNotFound when the key doesn't exist.
+	TTL(key string) (time.Duration, error)
+	// AddToGroup records key as a member of group. The group lives at least
+	// as long as ttl, a zero ttl keeps it forever.
+	AddToGroup(group, key string, ttl time.Duration) error
+	// DeleteGroup atomically deletes every member of group and the group
+	// itself, returning how many members still existed. It returns
+	// ErrNotFound when the group doesn't exist.
+	DeleteGroup(group string) (int, error)
+	// DeleteCreatedBefore deletes every secret saved before before, returning
+	// how many still existed.
+	DeleteCreatedBefore(before time.Time) (int, error)
+	// PurgeOrphans removes everything kept next to secrets that no longer
+	// exist: auxiliary keys, expiry and creation index entries and group
+	// members, along with groups left empty. Anything written within
+	// PurgeGrace is kept, saves write it before the secret itself, and so
+	// are expiry entries already due, ClaimExpired still hands those out.
+	PurgeOrphans() (PurgeStats, error)
+	// ClaimExpired removes and returns up to limit keys whose expiry is at
+	// or before now. Each expired key is handed out exactly once, however
+	// many callers claim concurrently.
+	ClaimExpired(now time.Time, limit int) ([]string, error)
+	// SetTombstone remembers for ttl that key existed and expired.
+	SetTombstone(key string, ttl time.Duration) error
+	// Tombstoned reports whether key has a tombstone.
+	Tombstoned(key string) (bool, error)
+	// SetGrace keeps value, what a burned key held, for ttl and only for
+	// holder, see Grace.
+	SetGrace(key, holder string, value []byte, ttl time.Duration) error
+	// Grace returns the value SetGrace kept for key and holder, or
+	// ErrNotFound for any other holder or once ttl is over.
+	Grace(key, holder string) ([]byte, error)
+	// Approve opens a window of length window in which key may be revealed
+	// once, see ConsumeApproval.
+	Approve(key string, window time.Duration) error
+	// ConsumeApproval atomically uses up the open approval of key. It reports
+	// false when there is none, so each approval admits a single reveal.
+	ConsumeApproval(key string) (bool, error)
+	// RecordRead prepends read to the read history of key, keeping at most
+	// max entries. The history expires together with key.
+	RecordRead(key string, read Read, max int) error
+	// Reads returns the read history of key, newest first. A key that was
+	// never read has an empty history.
+	Reads(key string) ([]Read, error)
+	// CountView counts a view of key and returns how many it has had,
+	// including this one. Concurrent views each get their own count. The
+	// counter expires with key and is removed together with it.
+	CountView(key string) (int, error)
+	// SetMetadata stores md for key with the same ttl as the secret. It is
+	// removed together with the secret.
+	SetMetadata(key string, md Metadata, ttl time.Duration) error
+	// Metadata returns the metadata of key, or ErrNotFound when it has none.
+	Metadata(key string) (Metadata, error)
+	// Allow counts an event against the sliding window rate limit name,
+	// shared by every instance using the backend. Once limit events happened
+	// within window it refuses and reports how long until the oldest of them
+	// leaves the window. Refused events are not counted.
+	Allow(name string, limit int, window time.Duration) (bool, time.Duration, error)
+	// Stats counts the secrets stored at now.
+	Stats(now time.Time) (Stats, error)
+	// AddDeadLetter prepends letter to the dead-letter log, keeping at most
+	// max entries.
+	AddDeadLetter(letter DeadLetter, max int) error
+	// DeadLetters returns the dead-letter log, newest first.
+	DeadLetters() ([]DeadLetter, error)
+	// Export calls fn for every stored secret, stopping at the first error
+	// fn returns. Secrets deleted while the export runs may be skipped.
+	Export(fn func(Record) error) error
+	// Ping reports whether the backend answers, for health checks.
+	Ping(ctx context.Context) error
+}
@@ -0,0 +1,181 @@
+// Package storagemock provides a configurable testify mock of storage.Storage
+// shared by handler tests.
+package storagemock
+
+import (
+	"context"
+	"time"
+	"yoopass-api/internal/storage"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// Storage is a mock of storage.Storage. Set expectations with On as with any
+// testify mock; byte slice returns may be given as nil.
+type Storage struct {
+	mock.Mock
+}
+
+var _ storage.Storage = (*Storage)(nil)
+
+func (m *Storage) Set(key string, value []byte, ttl time.Duration) error {
+	args := m.Called(key, value, ttl)
+	return args.Error(0)
+}
+
+func (m *Storage) Fetch(key string) ([]byte, error) {
+	args := m.Called(key)
+	return bytes(args, 0), args.Error(1)
+}
+
+func (m *Storage) Delete(key string) error {
+	args := m.Called(key)
+	return args.Error(0)
+}
+
+func (m *Storage) Consume(key string) ([]byte, error) {
+	args := m.Called(key)
+	return bytes(args, 0), args.Error(1)
+}
+
+func (m *Storage) Replace(key string, old, value []byte) error {
+	args := m.Called(key, old, value)
+	return args.Error(0)
+}
+
+func (m *Storage) Update(key string, value []byte, ttl time.Duration, version int64) (int64, error) {
+	args := m.Called(key, value, ttl, version)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *Storage) SetOrGet(key string, value []byte, ttl time.Duration) (bool, []byte, error) {
+	args := m.Called(key, value, ttl)
+	return args.Bool(0), bytes(args, 1), args.Error(2)
+}
+
+func (m *Storage) TTL(key string) (time.Duration, error) {
+	args := m.Called(key)
+	return args.Get(0).(time.Duration), args.Error(1)
+}
+
+func (m *Storage) AddToGroup(group, key string, ttl time.Duration) error {
+	args := m.Called(group, key, ttl)
+	return args.Error(0)
+}
+
+func (m *Storage) DeleteGroup(group string) (int, error) {
+	args := m.Called(group)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *Storage) DeleteCreatedBefore(before time.Time) (int, error) {
+	args := m.Called(before)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *Storage) PurgeOrphans() (storage.PurgeStats, error) {
+	args := m.Called()
+	return args.Get(0).(storage.PurgeStats), args.Error(1)
+}
+
+func (m *Storage) Ping(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *Storage) Stats(now time.Time) (storage.Stats, error) {
+	args := m.Called(now)
+	return args.Get(0).(storage.Stats), args.Error(1)
+}
+
+func (m *Storage) SetGrace(key, holder string, value []byte, ttl time.Duration) error {
+	args := m.Called(key, holder, value, ttl)
+	return args.Error(0)
+}
+
+func (m *Storage) Grace(key, holder string) ([]byte, error) {
+	args := m.Called(key, holder)
+	return bytes(args, 0), args.Error(1)
+}
+
+func (m *Storage) Approve(key string, window time.Duration) error {
+	args := m.Called(key, window)
+	return args.Error(0)
+}
+
+func (m *Storage) ConsumeApproval(key string) (bool, error) {
+	args := m.Called(key)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *Storage) RecordRead(key string, read storage.Read, max int) error {
+	args := m.Called(key, read, max)
+	return args.Error(0)
+}
+
+func (m *Storage) CountView(key string) (int, error) {
+	args := m.Called(key)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *Storage) Reads(key string) ([]storage.Read, error) {
+	args := m.Called(key)
+	reads, _ := args.Get(0).([]storage.Read)
+	return reads, args.Error(1)
+}
+
+func (m *Storage) AddDeadLetter(letter storage.DeadLetter, max int) error {
+	args := m.Called(letter, max)
+	return args.Error(0)
+}
+
+func (m *Storage) DeadLetters() ([]storage.DeadLetter, error) {
+	args := m.Called()
+	letters, _ := args.Get(0).([]storage.DeadLetter)
+	return letters, args.Error(1)
+}
+
+func (m *Storage) ClaimExpired(now time.Time, limit int) ([]string, error) {
+	args := m.Called(now, limit)
+	keys, _ := args.Get(0).([]string)
+	return keys, args.Error(1)
+}
+
+func (m *Storage) SetTombstone(key string, ttl time.Duration) error {
+	args := m.Called(key, ttl)
+	return args.Error(0)
+}
+
+func (m *Storage) Tombstoned(key string) (bool, error) {
+	args := m.Called(key)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *Storage) SetMetadata(key string, md storage.Metadata, ttl time.Duration) error {
+	args := m.Called(key, md, ttl)
+	return args.Error(0)
+}
+
+func (m *Storage) Metadata(key string) (storage.Metadata, error) {
+	args := m.Called(key)
+	md, _ := args.Get(0).(storage.Metadata)
+	return md, args.Error(1)
+}
+
+func (m *Storage) Allow(name string, limit int, window time.Duration) (bool, time.Duration, error) {
+	args := m.Called(name, limit, window)
+	return args.Bool(0), args.Get(1).(time.Duration), args.Error(2)
+}
+
+func (m *Storage) Export(fn func(storage.Record) error) error {
+	args := m.Called(fn)
+	return args.Error(0)
+}
+
+// bytes returns argument i as a byte slice, treating an untyped nil as empty.
+func bytes(args mock.Arguments, i int) []byte {
+	if args.Get(i) == nil {
+		return nil
+	}
+	return args.Get(i).([]byte)
+}
@@ -0,0 +1,42 @@
+package storagemock
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"yoopass-api/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestStorageMock(t *testing.T) {
+	m := new(Storage)
+
+	m.On("Set", "alias", []byte("cipher"), time.Hour).Return(nil).Once()
+	m.On("Fetch", "alias").Return([]byte("cipher"), nil).Once()
+	m.On("Fetch", "missing").Return(nil, storage.ErrNotFound).Once()
+	m.On("Consume", "alias").Return(nil, errors.New("boom")).Once()
+	m.On("Replace", "alias", mock.Anything, mock.Anything).Return(storage.ErrNotFound).Once()
+	m.On("Delete", "alias").Return(nil).Once()
+
+	assert.NoError(t, m.Set("alias", []byte("cipher"), time.Hour))
+
+	object, err := m.Fetch("alias")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("cipher"), object)
+
+	// Untyped nil returns come back as a nil slice instead of panicking
+	object, err = m.Fetch("missing")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.Nil(t, object)
+
+	object, err = m.Consume("alias")
+	assert.EqualError(t, err, "boom")
+	assert.Nil(t, object)
+
+	assert.ErrorIs(t, m.Replace("alias", []byte("cipher"), []byte("new")), storage.ErrNotFound)
+	assert.NoError(t, m.Delete("alias"))
+
+	m.AssertExpectations(t)
+}
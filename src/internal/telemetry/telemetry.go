@@ -0,0 +1,69 @@
+// Package telemetry sets up OpenTelemetry tracing. Spans only ever carry
+// routes, operation names, status codes and request ids: never secret
+// content, keys, aliases or URLs, which hold the key on most routes.
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"yoopass-api/internal/storage"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName names the tracer of every span the service starts.
+const TracerName = "yoopass-api"
+
+// Setup installs a global tracer provider exporting to an OTLP/HTTP
+// collector at endpoint, along with W3C trace context propagation. The
+// provider must be shut down to flush pending spans.
+func Setup(ctx context.Context, endpoint string, insecure bool, sampleRatio float64, serviceName string) (*sdktrace.TracerProvider, error) {
+	exporterOpts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+	if insecure {
+		exporterOpts = append(exporterOpts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, exporterOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("telemetry.Setup: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	return provider, nil
+}
+
+// Run calls fn, a single storage operation named op, in a child span of
+// ctx. A missing key is an ordinary outcome and isn't marked as an error.
+// Errors are not recorded, only flagged, as their text may name keys.
+func Run(ctx context.Context, tracer trace.Tracer, op string, fn func() error) error {
+	_, span := tracer.Start(ctx, "storage."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system.name", "redis"),
+			attribute.String("db.operation.name", op),
+		),
+	)
+	defer span.End()
+
+	err := fn()
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		span.SetStatus(codes.Error, "storage operation failed")
+	}
+	return err
+}
@@ -0,0 +1,76 @@
+// Package testutil holds helpers shared by tests across packages.
+package testutil
+
+import (
+	"encoding/json"
+	"testing"
+	"yoopass-api/internal/dto"
+	"yoopass-api/internal/tools/cipher"
+
+	"github.com/stretchr/testify/require"
+)
+
+// CiphertextOption adjusts what BuildCiphertext produces.
+type CiphertextOption func(*ciphertextOptions)
+
+type ciphertextOptions struct {
+	payload  []byte
+	tamper   bool
+	truncate int
+}
+
+// WithPayload encrypts payload as is instead of the marshalled secret, for
+// exercising the unmarshal branch with malformed or foreign JSON.
+func WithPayload(payload []byte) CiphertextOption {
+	return func(o *ciphertextOptions) {
+		o.payload = payload
+	}
+}
+
+// WithTamper flips a bit in the sealed data so authentication fails.
+func WithTamper() CiphertextOption {
+	return func(o *ciphertextOptions) {
+		o.tamper = true
+	}
+}
+
+// WithTruncate keeps only the first n bytes of the ciphertext.
+func WithTruncate(n int) CiphertextOption {
+	return func(o *ciphertextOptions) {
+		o.truncate = n
+	}
+}
+
+// BuildCiphertext returns what storage holds for secret encrypted under key,
+// which may be given in any encoding cipher.DecodeKey accepts and any
+// supported AES key size. New envelope features get a matching option here
+// so handler tests can reach every decode branch.
+func BuildCiphertext(t testing.TB, secret dto.Secret, key string, opts ...CiphertextOption) []byte {
+	t.Helper()
+
+	var o ciphertextOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	keyBytes, err := cipher.DecodeKey(key, cipher.KeyEncodingAuto)
+	require.NoError(t, err)
+
+	payload := o.payload
+	if payload == nil {
+		payload, err = json.Marshal(secret)
+		require.NoError(t, err)
+	}
+
+	object, err := cipher.EncodeWithKey(payload, keyBytes)
+	require.NoError(t, err)
+
+	if o.tamper {
+		object[len(object)-1] ^= 0x01
+	}
+	if o.truncate > 0 && o.truncate < len(object) {
+		object = object[:o.truncate]
+	}
+
+	return object
+}
@@ -0,0 +1,54 @@
+package testutil
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"yoopass-api/internal/dto"
+	"yoopass-api/internal/tools/cipher"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildCiphertext(t *testing.T) {
+	secret := dto.Secret{Message: "hello", OneTime: true}
+
+	testCases := []struct {
+		name      string
+		key       string
+		opts      []CiphertextOption
+		expectErr bool
+		expected  string
+	}{
+		{name: "Hex AES-128 Key", key: "46da5d3577209271242b42882a034c3d", expected: `{"message":"hello","one_time":true}`},
+		{name: "Hex AES-256 Key", key: strings.Repeat("ab", 32), expected: `{"message":"hello","one_time":true}`},
+		{name: "Base64url Key", key: "RtpdNXcgknEkK0KIKgNMPQ", expected: `{"message":"hello","one_time":true}`},
+		{name: "Raw Payload", key: "46da5d3577209271242b42882a034c3d", opts: []CiphertextOption{WithPayload([]byte(`{"message":`))}, expected: `{"message":`},
+		{name: "Tampered", key: "46da5d3577209271242b42882a034c3d", opts: []CiphertextOption{WithTamper()}, expectErr: true},
+		{name: "Truncated", key: "46da5d3577209271242b42882a034c3d", opts: []CiphertextOption{WithTruncate(4)}, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			object := BuildCiphertext(t, secret, tc.key, tc.opts...)
+
+			keyBytes, err := cipher.DecodeKey(tc.key, cipher.KeyEncodingAuto)
+			require.NoError(t, err)
+
+			plain, err := cipher.DecodeWithKey(object, keyBytes)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, string(plain))
+
+			if tc.opts == nil {
+				var got dto.Secret
+				require.NoError(t, json.Unmarshal(plain, &got))
+				assert.Equal(t, secret, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package aliassig
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	const alias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+
+	signer := New([]byte("0123456789abcdef0123456789abcdef"), true)
+	signed := signer.Sign(alias)
+	require.True(t, strings.HasPrefix(signed, alias+"."))
+
+	tampered := []byte(signed)
+	tampered[len(tampered)-1] ^= 0x01
+
+	testCases := []struct {
+		name        string
+		signer      *Signer
+		input       string
+		expected    string
+		expectedErr error
+	}{
+		{name: "Valid Signature", signer: signer, input: signed, expected: alias},
+		{name: "Tampered Signature", signer: signer, input: string(tampered), expectedErr: ErrInvalidSignature},
+		{name: "Tampered Alias", signer: signer, input: "f7ab603e-fbae-4182-8379-8763d9327d52" + signed[len(alias):], expectedErr: ErrInvalidSignature},
+		{name: "Other Key", signer: New([]byte("another key"), true), input: signed, expectedErr: ErrInvalidSignature},
+		{name: "Unsigned Enforced", signer: signer, input: alias, expectedErr: ErrUnsigned},
+		{name: "Unsigned Accepted", signer: New([]byte("0123456789abcdef0123456789abcdef"), false), input: alias, expected: alias},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.signer.Verify(tc.input)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for name, expected := range map[string]Mode{"": ModeOff, "off": ModeOff, "Sign": ModeSign, "enforce": ModeEnforce} {
+		mode, err := ParseMode(name)
+		require.NoError(t, err)
+		assert.Equal(t, expected, mode)
+	}
+
+	_, err := ParseMode("strict")
+	assert.Error(t, err)
+}
@@ -0,0 +1,36 @@
+package cipher
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// ErrBudgetExhausted is returned by Spend once a request used up its decode
+// budget.
+var ErrBudgetExhausted = errors.New("decode budget exhausted")
+
+type budgetKey struct{}
+
+// WithBudget returns a copy of ctx that allows max decrypt or key derivation
+// operations, so a single request can't make the server burn CPU on
+// attempt after attempt. Every expensive operation calls Spend first.
+func WithBudget(ctx context.Context, max int) context.Context {
+	left := new(atomic.Int64)
+	left.Store(int64(max))
+	return context.WithValue(ctx, budgetKey{}, left)
+}
+
+// Spend uses up one operation of the budget in ctx and returns
+// ErrBudgetExhausted when none is left. A context without a budget is
+// unlimited.
+func Spend(ctx context.Context) error {
+	left, ok := ctx.Value(budgetKey{}).(*atomic.Int64)
+	if !ok {
+		return nil
+	}
+	if left.Add(-1) < 0 {
+		return ErrBudgetExhausted
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package cipher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBudget(t *testing.T) {
+	ctx := WithBudget(context.Background(), 2)
+
+	require.NoError(t, Spend(ctx))
+	require.NoError(t, Spend(ctx))
+	assert.ErrorIs(t, Spend(ctx), ErrBudgetExhausted)
+	assert.ErrorIs(t, Spend(ctx), ErrBudgetExhausted, "a spent budget stays spent")
+
+	// Budgets are per context, a new request starts afresh
+	require.NoError(t, Spend(WithBudget(context.Background(), 1)))
+
+	for range 100 {
+		require.NoError(t, Spend(context.Background()), "no budget means no limit")
+	}
+}
@@ -4,17 +4,102 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"slices"
+	"strings"
 )
 
+// KeyEncoding selects how a textual key sent by a client is decoded.
+type KeyEncoding string
+
+const (
+	// KeyEncodingAuto accepts hex and unpadded or padded base64url keys.
+	KeyEncodingAuto   KeyEncoding = "auto"
+	KeyEncodingHex    KeyEncoding = "hex"
+	KeyEncodingBase64 KeyEncoding = "base64"
+)
+
+// ErrInvalidKey is returned when a key can't be decoded to a valid AES key.
+var ErrInvalidKey = errors.New("invalid key")
+
+// ErrInvalidKeySize is returned for a key size AES doesn't support, see
+// KeySizes.
+var ErrInvalidKeySize = errors.New("invalid key size")
+
+// ParseKeyEncoding validates a configured key encoding name.
+func ParseKeyEncoding(name string) (KeyEncoding, error) {
+	switch enc := KeyEncoding(strings.ToLower(name)); enc {
+	case KeyEncodingAuto, KeyEncodingHex, KeyEncodingBase64:
+		return enc, nil
+	case "":
+		return KeyEncodingAuto, nil
+	default:
+		return "", fmt.Errorf("unknown key encoding %q", name)
+	}
+}
+
+// DecodeKey turns a textual key into raw AES key bytes. In auto mode a key
+// made only of hex digits with a hex key length is read as hex, anything else
+// as base64url. Strict modes accept only their own encoding.
+func DecodeKey(key string, encoding KeyEncoding) ([]byte, error) {
+	var (
+		keyBytes []byte
+		err      error
+	)
+
+	switch encoding {
+	case KeyEncodingHex:
+		keyBytes, err = hex.DecodeString(key)
+	case KeyEncodingBase64:
+		keyBytes, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(key, "="))
+	default:
+		if looksLikeHexKey(key) {
+			keyBytes, err = hex.DecodeString(key)
+		} else {
+			keyBytes, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(key, "="))
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+
+	if !validKeySize(len(keyBytes)) {
+		return nil, fmt.Errorf("%w: unsupported key size %d", ErrInvalidKey, len(keyBytes))
+	}
+
+	return keyBytes, nil
+}
+
+func looksLikeHexKey(key string) bool {
+	if !validKeySize(len(key)/2) || len(key)%2 != 0 {
+		return false
+	}
+	_, err := hex.DecodeString(key)
+	return err == nil
+}
+
+// KeySizes lists the AES key sizes in bytes that DecodeKey accepts.
+var KeySizes = []int{16, 24, 32}
+
+func validKeySize(size int) bool {
+	return slices.Contains(KeySizes, size)
+}
+
 func Encode(object []byte, key string) ([]byte, error) {
 	keyBytes, err := hex.DecodeString(key)
 	if err != nil {
 		return nil, err
 	}
 
+	return EncodeWithKey(object, keyBytes)
+}
+
+// EncodeWithKey encrypts object with raw key bytes under a fresh random nonce.
+func EncodeWithKey(object []byte, keyBytes []byte) ([]byte, error) {
 	cipherBlock, err := aes.NewCipher(keyBytes)
 	if err != nil {
 		return nil, fmt.Errorf("could not create cipher block: %w", err)
@@ -46,26 +131,31 @@ func Decode(cipherObject []byte, key string) ([]byte, error) {
 		return nil, fmt.Errorf("invalid hex key: %w", err)
 	}
 
-	// 2. Create AES cipher block
+	return DecodeWithKey(cipherObject, keyBytes)
+}
+
+// DecodeWithKey decrypts cipherObject with raw key bytes, see DecodeKey.
+func DecodeWithKey(cipherObject []byte, keyBytes []byte) ([]byte, error) {
+	// 1. Create AES cipher block
 	block, err := aes.NewCipher(keyBytes)
 	if err != nil {
 		return nil, fmt.Errorf("could not create cipher block: %w", err)
 	}
 
-	// 3. Create GCM cipher
+	// 2. Create GCM cipher
 	aesGCM, err := cipher.NewGCM(block)
 	if err != nil {
 		return nil, fmt.Errorf("could not create GCM: %w", err)
 	}
 
-	// 4. Extract nonce and actual ciphertext
+	// 3. Extract nonce and actual ciphertext
 	nonceSize := aesGCM.NonceSize()
 	if len(cipherObject) < nonceSize {
 		return nil, fmt.Errorf("ciphertext too short")
 	}
 	nonce, actualCiphertext := cipherObject[:nonceSize], cipherObject[nonceSize:]
 
-	// 5. Decrypt (Open) the data
+	// 4. Decrypt (Open) the data
 	plaintext, err := aesGCM.Open(nil, nonce, actualCiphertext, nil)
 	if err != nil {
 		// This error can mean the key is wrong, nonce is wrong, or data is corrupt/tampered
@@ -75,8 +165,34 @@ func Decode(cipherObject []byte, key string) ([]byte, error) {
 	return plaintext, nil
 }
 
+// KeySize is the size in bytes of keys made by GenerateRandomHexKey.
+const KeySize = 16
+
+// ValidateKeySize returns ErrInvalidKeySize unless size is one of KeySizes.
+func ValidateKeySize(size int) error {
+	if !validKeySize(size) {
+		return fmt.Errorf("%w: %d bytes, want one of %v", ErrInvalidKeySize, size, KeySizes)
+	}
+	return nil
+}
+
+// Name describes the cipher used with a key of keySize bytes.
+func Name(keySize int) string {
+	return fmt.Sprintf("AES-%d-GCM", keySize*8)
+}
+
 func GenerateRandomHexKey() (string, error) {
-	key := make([]byte, 16) //16, 24, or 32
+	return GenerateRandomHexKeyWithSize(KeySize)
+}
+
+// GenerateRandomHexKeyWithSize returns a random hex encoded key of size
+// bytes, which selects AES-128, AES-192 or AES-256.
+func GenerateRandomHexKeyWithSize(size int) (string, error) {
+	if err := ValidateKeySize(size); err != nil {
+		return "", err
+	}
+
+	key := make([]byte, size)
 	if _, err := io.ReadFull(rand.Reader, key); err != nil {
 		return "", fmt.Errorf("failed to generate random key bytes: %w", err)
 	}
@@ -0,0 +1,120 @@
+package cipher
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	key, err := GenerateRandomHexKey()
+	require.NoError(t, err)
+
+	cipherObject, err := Encode([]byte("round trip"), key)
+	require.NoError(t, err)
+
+	plaintext, err := Decode(cipherObject, key)
+	require.NoError(t, err)
+	assert.Equal(t, "round trip", string(plaintext))
+}
+
+func TestGenerateRandomHexKeyWithSize(t *testing.T) {
+	for _, size := range KeySizes {
+		t.Run(Name(size), func(t *testing.T) {
+			key, err := GenerateRandomHexKeyWithSize(size)
+			require.NoError(t, err)
+			assert.Len(t, key, size*2)
+
+			cipherObject, err := Encode([]byte("round trip"), key)
+			require.NoError(t, err)
+
+			plaintext, err := Decode(cipherObject, key)
+			require.NoError(t, err)
+			assert.Equal(t, "round trip", string(plaintext))
+		})
+	}
+
+	_, err := GenerateRandomHexKeyWithSize(20)
+	assert.ErrorIs(t, err, ErrInvalidKeySize)
+
+	// A key of an unsupported size must not encrypt anything either
+	_, err = Encode([]byte("round trip"), hex.EncodeToString(make([]byte, 20)))
+	assert.Error(t, err)
+}
+
+func TestDecodeKey(t *testing.T) {
+	// A 32-byte key, so its hex form can't also pass as a valid base64url key
+	raw, err := hex.DecodeString("46da5d3577209271242b42882a034c3d46da5d3577209271242b42882a034c3d")
+	require.NoError(t, err)
+
+	hexKey := hex.EncodeToString(raw)
+	base64Key := base64.RawURLEncoding.EncodeToString(raw)
+	paddedBase64Key := base64.URLEncoding.EncodeToString(raw)
+
+	testCases := []struct {
+		name     string
+		key      string
+		encoding KeyEncoding
+		wantErr  bool
+	}{
+		{name: "Auto Hex", key: hexKey, encoding: KeyEncodingAuto},
+		{name: "Auto Base64url", key: base64Key, encoding: KeyEncodingAuto},
+		{name: "Auto Padded Base64url", key: paddedBase64Key, encoding: KeyEncodingAuto},
+		{name: "Empty Encoding Means Auto", key: base64Key, encoding: ""},
+		{name: "Strict Hex Accepts Hex", key: hexKey, encoding: KeyEncodingHex},
+		{name: "Strict Hex Rejects Base64url", key: base64Key, encoding: KeyEncodingHex, wantErr: true},
+		{name: "Strict Base64 Accepts Base64url", key: base64Key, encoding: KeyEncodingBase64},
+		{name: "Strict Base64 Rejects Hex", key: hexKey, encoding: KeyEncodingBase64, wantErr: true},
+		{name: "Auto Rejects Garbage", key: "not a key!", encoding: KeyEncodingAuto, wantErr: true},
+		{name: "Auto Rejects Wrong Size", key: "46da5d35", encoding: KeyEncodingAuto, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			keyBytes, err := DecodeKey(tc.key, tc.encoding)
+			if tc.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidKey)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, raw, keyBytes)
+		})
+	}
+}
+
+func TestParseKeyEncoding(t *testing.T) {
+	enc, err := ParseKeyEncoding("")
+	require.NoError(t, err)
+	assert.Equal(t, KeyEncodingAuto, enc)
+
+	enc, err = ParseKeyEncoding("HEX")
+	require.NoError(t, err)
+	assert.Equal(t, KeyEncodingHex, enc)
+
+	_, err = ParseKeyEncoding("rot13")
+	assert.Error(t, err)
+}
+
+func TestSelfTest(t *testing.T) {
+	names, err := SelfTest(KeySizes...)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"AES-128-GCM", "AES-192-GCM", "AES-256-GCM"}, names)
+
+	testCases := []struct {
+		name     string
+		keySizes []int
+	}{
+		{name: "Invalid Key Size", keySizes: []int{KeySize, 20}},
+		{name: "No Key Sizes", keySizes: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := SelfTest(tc.keySizes...)
+			assert.ErrorIs(t, err, ErrSelfTest)
+		})
+	}
+}
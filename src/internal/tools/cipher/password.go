@@ -0,0 +1,86 @@
+package cipher
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// ErrWrongPassword is returned by OpenWithPassword when the password doesn't
+// open the object.
+var ErrWrongPassword = errors.New("wrong password")
+
+// ErrPasswordBusy is returned by SealWithPassword and OpenWithPassword when
+// MaxPasswordDerivations keys are already being derived.
+var ErrPasswordBusy = errors.New("too many password derivations in progress")
+
+// SaltSize is the size in bytes of the salts made by SealWithPassword.
+const SaltSize = 16
+
+// Argon2id parameters of password keys, the RFC 9106 second recommended
+// option. Deriving a key takes tens of milliseconds, so handlers must Spend
+// before calling OpenWithPassword.
+const (
+	passwordTime    = 3
+	passwordMemory  = 64 * 1024
+	passwordThreads = 4
+	passwordKeySize = 32
+)
+
+// MaxPasswordDerivations caps how many password keys are derived at once.
+// Each derivation holds passwordMemory KiB, so the cap bounds the memory
+// password reveals can take to about half a gigabyte however many requests
+// arrive. Callers past the cap get ErrPasswordBusy instead of queueing.
+const MaxPasswordDerivations = 8
+
+var passwordSlots = make(chan struct{}, MaxPasswordDerivations)
+
+// SealWithPassword encrypts object with a key derived from password under a
+// fresh random salt. The salt must be kept to open the object again.
+func SealWithPassword(object []byte, password string) (sealed, salt []byte, err error) {
+	salt = make([]byte, SaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, nil, fmt.Errorf("could not generate salt: %w", err)
+	}
+
+	key, err := passwordKey(password, salt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sealed, err = EncodeWithKey(object, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sealed, salt, nil
+}
+
+// OpenWithPassword decrypts an object sealed by SealWithPassword. Any failure
+// to open it is reported as ErrWrongPassword, GCM can't tell a wrong key from
+// tampered data. ErrPasswordBusy is returned as is, no password was tried.
+func OpenWithPassword(sealed []byte, password string, salt []byte) ([]byte, error) {
+	key, err := passwordKey(password, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	object, err := DecodeWithKey(sealed, key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWrongPassword, err)
+	}
+	return object, nil
+}
+
+func passwordKey(password string, salt []byte) ([]byte, error) {
+	select {
+	case passwordSlots <- struct{}{}:
+	default:
+		return nil, ErrPasswordBusy
+	}
+	defer func() { <-passwordSlots }()
+
+	return argon2.IDKey([]byte(password), salt, passwordTime, passwordMemory, passwordThreads, passwordKeySize), nil
+}
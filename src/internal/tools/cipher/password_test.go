@@ -0,0 +1,52 @@
+package cipher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealWithPassword(t *testing.T) {
+	sealed, salt, err := SealWithPassword([]byte("secret"), "correct horse")
+	require.NoError(t, err)
+	assert.Len(t, salt, SaltSize)
+	assert.NotContains(t, string(sealed), "secret")
+
+	object, err := OpenWithPassword(sealed, "correct horse", salt)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret"), object)
+
+	_, err = OpenWithPassword(sealed, "battery staple", salt)
+	assert.ErrorIs(t, err, ErrWrongPassword)
+
+	_, otherSalt, err := SealWithPassword([]byte("secret"), "correct horse")
+	require.NoError(t, err)
+	assert.NotEqual(t, salt, otherSalt, "every seal gets a fresh salt")
+}
+
+func TestPasswordDerivationsAreBounded(t *testing.T) {
+	sealed, salt, err := SealWithPassword([]byte("secret"), "correct horse")
+	require.NoError(t, err)
+
+	// Hold every slot as if that many derivations were running
+	for range MaxPasswordDerivations {
+		passwordSlots <- struct{}{}
+	}
+
+	_, err = OpenWithPassword(sealed, "correct horse", salt)
+	assert.ErrorIs(t, err, ErrPasswordBusy)
+	assert.NotErrorIs(t, err, ErrWrongPassword, "a busy server must not look like a wrong password")
+
+	_, _, err = SealWithPassword([]byte("secret"), "correct horse")
+	assert.ErrorIs(t, err, ErrPasswordBusy)
+
+	<-passwordSlots
+	object, err := OpenWithPassword(sealed, "correct horse", salt)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret"), object)
+
+	for range MaxPasswordDerivations - 1 {
+		<-passwordSlots
+	}
+}
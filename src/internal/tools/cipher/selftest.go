@@ -0,0 +1,69 @@
+package cipher
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrSelfTest is returned by SelfTest when a cipher fails its round trip.
+var ErrSelfTest = errors.New("cipher self-test failed")
+
+// selfTestSample is what SelfTest encrypts, long enough to span several
+// AES blocks.
+var selfTestSample = []byte("yoopass cipher self-test sample, spanning more than one block")
+
+// SelfTest checks every key size in keySizes with a fresh random key: the
+// sample has to decrypt back to itself and a tampered ciphertext has to be
+// refused. It returns the names of the checked ciphers, see Name. Run it at
+// startup so a broken configuration fails before any secret is stored.
+func SelfTest(keySizes ...int) ([]string, error) {
+	const op = "cipher.SelfTest"
+
+	if len(keySizes) == 0 {
+		return nil, fmt.Errorf("%s: %w: no key sizes configured", op, ErrSelfTest)
+	}
+
+	names := make([]string, 0, len(keySizes))
+	for _, size := range keySizes {
+		if err := roundTrip(size); err != nil {
+			return nil, fmt.Errorf("%s: %w: %s: %v", op, ErrSelfTest, Name(size), err)
+		}
+		names = append(names, Name(size))
+	}
+
+	return names, nil
+}
+
+func roundTrip(keySize int) error {
+	if !validKeySize(keySize) {
+		return fmt.Errorf("unsupported key size %d", keySize)
+	}
+
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return fmt.Errorf("generate key: %w", err)
+	}
+
+	cipherObject, err := EncodeWithKey(selfTestSample, key)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := DecodeWithKey(cipherObject, key)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(plaintext, selfTestSample) {
+		return errors.New("round trip changed the plaintext")
+	}
+
+	cipherObject[len(cipherObject)-1] ^= 0x01
+	if _, err := DecodeWithKey(cipherObject, key); err == nil {
+		return errors.New("tampered ciphertext was accepted")
+	}
+
+	return nil
+}
@@ -0,0 +1,16 @@
+package netutil
+
+import (
+	"net"
+	"net/http"
+)
+
+// ClientIP is the IP of the peer, without the port a client can change for
+// every connection. A RemoteAddr that isn't host:port is returned as is.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,30 @@
+package netutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIP(t *testing.T) {
+	testCases := []struct {
+		name       string
+		remoteAddr string
+		expected   string
+	}{
+		{name: "IPv4 With Port", remoteAddr: "203.0.113.7:51234", expected: "203.0.113.7"},
+		{name: "IPv6 With Port", remoteAddr: "[2001:db8::1]:443", expected: "2001:db8::1"},
+		{name: "No Port", remoteAddr: "203.0.113.7", expected: "203.0.113.7"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+
+			assert.Equal(t, tc.expected, ClientIP(req))
+		})
+	}
+}
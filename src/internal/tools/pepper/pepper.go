@@ -0,0 +1,42 @@
+package pepper
+
+import (
+	"crypto/hkdf"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// ErrMissingSecret is returned when a feature needs the server secret but
+// none is configured.
+var ErrMissingSecret = errors.New("server secret is not configured")
+
+// Pepper holds the server-wide secret. The secret itself is never used as a
+// key, every feature derives its own subkey with Subkey instead.
+type Pepper struct {
+	secret []byte
+}
+
+func New(secret string) (*Pepper, error) {
+	if secret == "" {
+		return nil, ErrMissingSecret
+	}
+	return &Pepper{secret: []byte(secret)}, nil
+}
+
+// Subkey derives a size byte key for purpose with HKDF-SHA256. The same
+// secret and purpose always give the same key, different purposes give
+// unrelated keys.
+func (p *Pepper) Subkey(purpose string, size int) ([]byte, error) {
+	const op = "pepper.Subkey"
+
+	if purpose == "" {
+		return nil, fmt.Errorf("%s: purpose is required", op)
+	}
+
+	key, err := hkdf.Key(sha256.New, p.secret, nil, purpose, size)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return key, nil
+}
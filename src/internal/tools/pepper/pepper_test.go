@@ -0,0 +1,38 @@
+package pepper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRequiresSecret(t *testing.T) {
+	_, err := New("")
+	assert.ErrorIs(t, err, ErrMissingSecret)
+}
+
+func TestSubkey(t *testing.T) {
+	p, err := New("correct horse battery staple")
+	require.NoError(t, err)
+
+	first, err := p.Subkey("alias-hash", 32)
+	require.NoError(t, err)
+	second, err := p.Subkey("alias-hash", 32)
+	require.NoError(t, err)
+	assert.Len(t, first, 32)
+	assert.Equal(t, first, second, "same purpose must derive the same key")
+
+	other, err := p.Subkey("key-tag", 32)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, other, "purposes must derive different keys")
+
+	q, err := New("another secret")
+	require.NoError(t, err)
+	fromOther, err := q.Subkey("alias-hash", 32)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, fromOther, "secrets must derive different keys")
+
+	_, err = p.Subkey("", 32)
+	assert.Error(t, err)
+}
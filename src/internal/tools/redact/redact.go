@@ -0,0 +1,39 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+)
+
+// AliasKey is the log attribute every handler uses for secret aliases.
+const AliasKey = "alias"
+
+// hashLength is the number of hex characters kept from the hash, enough to
+// correlate log lines without keeping the retrieval identifier.
+const hashLength = 12
+
+// HashAlias returns a short, stable hash of alias that is safe to log.
+func HashAlias(alias string) string {
+	sum := sha256.Sum256([]byte(alias))
+	return hex.EncodeToString(sum[:])[:hashLength]
+}
+
+// HashIP returns a keyed hash of ip. IPv4 addresses are few enough to be
+// brute-forced from a plain hash, so the hash is only useful with a secret
+// key, see pepper.Pepper.Subkey.
+func HashIP(key []byte, ip string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))[:2*hashLength]
+}
+
+// HashAliases is a slog ReplaceAttr func that swaps every alias attribute,
+// at any group depth, for its hash.
+func HashAliases(_ []string, a slog.Attr) slog.Attr {
+	if a.Key == AliasKey && a.Value.Kind() == slog.KindString {
+		return slog.String(AliasKey, HashAlias(a.Value.String()))
+	}
+	return a
+}
@@ -0,0 +1,41 @@
+package redact
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashAlias(t *testing.T) {
+	const alias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+
+	hashed := HashAlias(alias)
+	assert.Len(t, hashed, 12)
+	assert.Equal(t, hashed, HashAlias(alias), "hash must be stable for correlation")
+	assert.NotEqual(t, hashed, HashAlias("another-alias"))
+}
+
+func TestHashAliasesReplacesNestedAttrs(t *testing.T) {
+	const alias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{ReplaceAttr: HashAliases}))
+
+	log.With(slog.String("alias", alias)).WithGroup("req").Info("fetched", slog.String("alias", alias))
+
+	assert.NotContains(t, buf.String(), alias)
+	assert.Equal(t, 2, bytes.Count(buf.Bytes(), []byte(HashAlias(alias))))
+}
+
+func TestHashIP(t *testing.T) {
+	key := []byte("read-history-key")
+
+	hashed := HashIP(key, "203.0.113.7")
+	assert.Len(t, hashed, 24)
+	assert.NotContains(t, hashed, "203.0.113.7")
+	assert.Equal(t, hashed, HashIP(key, "203.0.113.7"), "same reader must hash the same")
+	assert.NotEqual(t, hashed, HashIP(key, "203.0.113.8"))
+	assert.NotEqual(t, hashed, HashIP([]byte("other-key"), "203.0.113.7"), "hash must depend on the key")
+}
@@ -0,0 +1,80 @@
+// Package shamir splits keys into shares with Shamir's secret sharing. The
+// arithmetic is done by the Vault implementation, this package only checks
+// parameters and shares up front so callers can tell misuse apart by
+// ErrInvalidParams and ErrInvalidShares.
+package shamir
+
+import (
+	"errors"
+	"fmt"
+
+	vaultshamir "github.com/hashicorp/vault/shamir"
+)
+
+// MaxShares is the most shares a secret can be split into, every share needs
+// a distinct non-zero x coordinate in GF(2^8).
+const MaxShares = 255
+
+var (
+	// ErrInvalidParams is returned by Split for out of range n or k.
+	ErrInvalidParams = errors.New("invalid split parameters")
+	// ErrInvalidShares is returned by Combine for shares that can't belong
+	// to the same secret.
+	ErrInvalidShares = errors.New("invalid shares")
+)
+
+// Split divides secret into n shares so that any k of them reconstruct it
+// and fewer reveal nothing about it. Each share is one byte longer than the
+// secret, its last byte is the share's x coordinate.
+func Split(secret []byte, n, k int) ([][]byte, error) {
+	const op = "shamir.Split"
+
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("%s: %w: empty secret", op, ErrInvalidParams)
+	}
+	if k < 2 || n < k || n > MaxShares {
+		return nil, fmt.Errorf("%s: %w: need 2 <= k <= n <= %d, got k=%d n=%d", op, ErrInvalidParams, MaxShares, k, n)
+	}
+
+	shares, err := vaultshamir.Split(secret, n, k)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return shares, nil
+}
+
+// Combine reconstructs the secret from shares made by Split. Given fewer
+// shares than the threshold it returns unrelated bytes, which callers detect
+// by the reconstructed secret failing to work.
+func Combine(shares [][]byte) ([]byte, error) {
+	const op = "shamir.Combine"
+
+	if len(shares) < 2 {
+		return nil, fmt.Errorf("%s: %w: need at least 2 shares", op, ErrInvalidShares)
+	}
+
+	size := len(shares[0])
+	if size < 2 {
+		return nil, fmt.Errorf("%s: %w: share too short", op, ErrInvalidShares)
+	}
+
+	// Vault rejects duplicates but not a zero x, which would make the
+	// interpolation at x = 0 return that share's y bytes as the secret
+	seen := make(map[byte]bool, len(shares))
+	for _, share := range shares {
+		if len(share) != size {
+			return nil, fmt.Errorf("%s: %w: shares differ in length", op, ErrInvalidShares)
+		}
+		x := share[size-1]
+		if x == 0 || seen[x] {
+			return nil, fmt.Errorf("%s: %w: duplicate or zero share index", op, ErrInvalidShares)
+		}
+		seen[x] = true
+	}
+
+	secret, err := vaultshamir.Combine(shares)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %v", op, ErrInvalidShares, err)
+	}
+	return secret, nil
+}
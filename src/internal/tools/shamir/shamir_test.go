@@ -0,0 +1,89 @@
+package shamir
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte{0x46, 0xda, 0x5d, 0x35, 0x77, 0x20, 0x92, 0x71, 0x24, 0x2b, 0x42, 0x88, 0x2a, 0x03, 0x4c, 0x3d}
+
+	shares, err := Split(secret, 5, 3)
+	require.NoError(t, err)
+	require.Len(t, shares, 5)
+	for _, share := range shares {
+		assert.Len(t, share, len(secret)+1)
+	}
+
+	testCases := []struct {
+		name    string
+		subset  []int
+		matches bool
+	}{
+		{name: "Exactly K Shares", subset: []int{0, 2, 4}, matches: true},
+		{name: "Other K Shares", subset: []int{3, 1, 0}, matches: true},
+		{name: "All Shares", subset: []int{0, 1, 2, 3, 4}, matches: true},
+		{name: "Fewer Than K Shares", subset: []int{1, 3}, matches: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var subset [][]byte
+			for _, i := range tc.subset {
+				subset = append(subset, shares[i])
+			}
+
+			combined, err := Combine(subset)
+			require.NoError(t, err)
+			if tc.matches {
+				assert.Equal(t, secret, combined)
+			} else {
+				assert.NotEqual(t, secret, combined)
+			}
+		})
+	}
+}
+
+func TestSplitRejectsInvalidParams(t *testing.T) {
+	testCases := []struct {
+		name   string
+		secret []byte
+		n, k   int
+	}{
+		{name: "Empty Secret", secret: nil, n: 3, k: 2},
+		{name: "Threshold Of One", secret: []byte("s"), n: 3, k: 1},
+		{name: "Threshold Above Shares", secret: []byte("s"), n: 2, k: 3},
+		{name: "Too Many Shares", secret: []byte("s"), n: 256, k: 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Split(tc.secret, tc.n, tc.k)
+			assert.ErrorIs(t, err, ErrInvalidParams)
+		})
+	}
+}
+
+func TestCombineRejectsInvalidShares(t *testing.T) {
+	shares, err := Split([]byte("secret"), 3, 2)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name   string
+		shares [][]byte
+	}{
+		{name: "Single Share", shares: shares[:1]},
+		{name: "Duplicate Share", shares: [][]byte{shares[0], shares[0]}},
+		{name: "Length Mismatch", shares: [][]byte{shares[0], shares[1][:3]}},
+		{name: "Zero Index", shares: [][]byte{shares[0], {1, 2, 3, 4, 5, 6, 0}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Combine(tc.shares)
+			assert.ErrorIs(t, err, ErrInvalidShares)
+		})
+	}
+}
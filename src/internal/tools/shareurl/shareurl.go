@@ -0,0 +1,80 @@
+package shareurl
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Build returns the retrieval URL of a secret, taking scheme and host from
+// the request that created it. forceHTTPS always yields https, which is what
+// a server behind a TLS terminating proxy wants: the proxy talks plain http
+// to it, yet links sent to users must never expose the key over http.
+func Build(r *http.Request, forceHTTPS bool, alias, key string) string {
+	u := url.URL{
+		Scheme: scheme(r, forceHTTPS),
+		Host:   r.Host,
+		Path:   "/" + alias + "/" + key,
+	}
+	return u.String()
+}
+
+// ErrInvalidBase is returned by ParseBase for a URL links can't be built on.
+var ErrInvalidBase = errors.New("invalid public base URL")
+
+// ParseBase parses the public base URL of the service, which must be an
+// absolute http or https URL without query or fragment. A path is kept, so
+// the service can be published under a prefix.
+func ParseBase(raw string) (*url.URL, error) {
+	base, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %v", ErrInvalidBase, raw, err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("%w %q: scheme must be http or https", ErrInvalidBase, raw)
+	}
+	if base.Host == "" {
+		return nil, fmt.Errorf("%w %q: missing host", ErrInvalidBase, raw)
+	}
+	if base.RawQuery != "" || base.Fragment != "" {
+		return nil, fmt.Errorf("%w %q: query and fragment are not allowed", ErrInvalidBase, raw)
+	}
+	return base, nil
+}
+
+// BuildFrom returns the retrieval URL of a secret under base, see ParseBase.
+// Unlike Build it doesn't depend on the request, which a proxy in front of
+// the server may have rewritten.
+func BuildFrom(base *url.URL, alias, key string) string {
+	u := url.URL{
+		Scheme: base.Scheme,
+		User:   base.User,
+		Host:   base.Host,
+		Path:   strings.TrimSuffix(base.Path, "/") + "/" + alias + "/" + key,
+	}
+	return u.String()
+}
+
+func scheme(r *http.Request, forceHTTPS bool) string {
+	if forceHTTPS || r.TLS != nil {
+		return "https"
+	}
+	if strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		return "https"
+	}
+	return "http"
+}
+
+// WithTitle appends title to link as a "#title=" fragment. Browsers never
+// send fragments to the server, so the title stays with whoever holds the
+// link. Everything but unreserved characters is percent-encoded, which keeps
+// a title from ending the fragment parameter or adding others. An empty link
+// stays empty.
+func WithTitle(link, title string) string {
+	if link == "" || title == "" {
+		return link
+	}
+	return link + "#title=" + strings.ReplaceAll(url.QueryEscape(title), "+", "%20")
+}
@@ -0,0 +1,129 @@
+package shareurl
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuild(t *testing.T) {
+	const (
+		alias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+		key   = "46da5d3577209271242b42882a034c3d"
+	)
+
+	testCases := []struct {
+		name       string
+		forceHTTPS bool
+		tls        bool
+		proto      string
+		expected   string
+	}{
+		{name: "Plain HTTP", expected: "http://secrets.example.com/" + alias + "/" + key},
+		{name: "Forced HTTPS", forceHTTPS: true, expected: "https://secrets.example.com/" + alias + "/" + key},
+		{name: "Direct TLS", tls: true, expected: "https://secrets.example.com/" + alias + "/" + key},
+		{name: "Forwarded HTTPS", proto: "https", expected: "https://secrets.example.com/" + alias + "/" + key},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/add", nil)
+			req.Host = "secrets.example.com"
+			if tc.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			if tc.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tc.proto)
+			}
+
+			assert.Equal(t, tc.expected, Build(req, tc.forceHTTPS, alias, key))
+		})
+	}
+}
+
+func TestParseBase(t *testing.T) {
+	testCases := []struct {
+		name  string
+		raw   string
+		valid bool
+	}{
+		{name: "Host Only", raw: "https://secrets.example.com", valid: true},
+		{name: "With Prefix", raw: "https://example.com/yoopass/", valid: true},
+		{name: "Plain HTTP", raw: "http://localhost:8080", valid: true},
+		{name: "No Scheme", raw: "secrets.example.com"},
+		{name: "Other Scheme", raw: "ftp://secrets.example.com"},
+		{name: "No Host", raw: "https:///path"},
+		{name: "Query", raw: "https://secrets.example.com/?a=b"},
+		{name: "Fragment", raw: "https://secrets.example.com/#x"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseBase(tc.raw)
+			if tc.valid {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrInvalidBase)
+		})
+	}
+}
+
+func TestBuildFrom(t *testing.T) {
+	testCases := []struct {
+		name     string
+		base     string
+		expected string
+	}{
+		{name: "Host Only", base: "https://secrets.example.com", expected: "https://secrets.example.com/alias/key"},
+		{name: "Trailing Slash", base: "https://secrets.example.com/", expected: "https://secrets.example.com/alias/key"},
+		{name: "Path Prefix", base: "https://example.com/yoopass", expected: "https://example.com/yoopass/alias/key"},
+		{name: "Port", base: "http://localhost:8080/", expected: "http://localhost:8080/alias/key"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			base, err := ParseBase(tc.base)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, BuildFrom(base, "alias", "key"))
+		})
+	}
+}
+
+func TestWithTitle(t *testing.T) {
+	const link = "https://secrets.example.com/alias/key"
+
+	testCases := []struct {
+		name     string
+		title    string
+		expected string
+	}{
+		{name: "No Title", title: "", expected: link},
+		{name: "Plain Title", title: "Deploy", expected: link + "#title=Deploy"},
+		{name: "Spaces Not Plus", title: "Deploy keys", expected: link + "#title=Deploy%20keys"},
+		{name: "Fragment Syntax Escaped", title: "a&b=c#d", expected: link + "#title=a%26b%3Dc%23d"},
+		{name: "Plus Kept Literal", title: "1+1", expected: link + "#title=1%2B1"},
+		{name: "Unicode", title: "Ключи", expected: link + "#title=%D0%9A%D0%BB%D1%8E%D1%87%D0%B8"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := WithTitle(link, tc.title)
+			assert.Equal(t, tc.expected, got)
+
+			if tc.title != "" {
+				u, err := url.Parse(got)
+				assert.NoError(t, err)
+				assert.Equal(t, "title="+tc.title, u.Fragment, "the fragment must decode back to the title")
+			}
+		})
+	}
+}
+
+func TestWithTitleWithoutLink(t *testing.T) {
+	assert.Empty(t, WithTitle("", "Deploy"))
+}
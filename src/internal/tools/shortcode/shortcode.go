@@ -0,0 +1,37 @@
+package shortcode
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"strings"
+)
+
+// Size is how many characters a code has. At five bits a character codes
+// tell about a billion aliases apart: plenty to find the secret a customer
+// reads out, but not enough to be unique, so a code is never an alias.
+const Size = 6
+
+// Coder derives short reference codes from aliases. Codes are keyed, so they
+// reveal nothing about the alias, and are never stored: the same alias
+// always gets the same code.
+type Coder struct {
+	key []byte
+}
+
+// New returns a Coder using key.
+func New(key []byte) *Coder {
+	return &Coder{key: key}
+}
+
+// Code returns the short code of alias, Size uppercase base32 characters.
+func (c *Coder) Code(alias string) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(alias))
+	return base32.StdEncoding.EncodeToString(mac.Sum(nil)[:4])[:Size]
+}
+
+// Normalize tidies a code as typed in by a person.
+func Normalize(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
@@ -0,0 +1,23 @@
+package shortcode
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCode(t *testing.T) {
+	const alias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+
+	coder := New([]byte("0123456789abcdef0123456789abcdef"))
+	code := coder.Code(alias)
+
+	assert.Regexp(t, regexp.MustCompile(`^[A-Z2-7]{6}$`), code)
+	assert.Equal(t, code, coder.Code(alias), "the same alias must always get the same code")
+	assert.Equal(t, code, New([]byte("0123456789abcdef0123456789abcdef")).Code(alias), "codes must survive restarts")
+	assert.NotEqual(t, code, coder.Code("f7ab603e-fbae-4182-8379-8763d9327d52"))
+	assert.NotEqual(t, code, New([]byte("another key")).Code(alias))
+	assert.Equal(t, code, Normalize(" "+strings.ToLower(code)+"\n"), "codes read out may come back in any case")
+}
@@ -0,0 +1,97 @@
+package tenant
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Mode selects how secrets are scoped to tenants.
+type Mode string
+
+const (
+	// ModeOff stores every secret under its bare alias.
+	ModeOff Mode = "off"
+	// ModePublic stores the secrets of authenticated users under their
+	// tenant prefix, but anyone holding the full alias can still read them.
+	ModePublic Mode = "public"
+	// ModeEnforce also lets only the owning tenant read them.
+	ModeEnforce Mode = "enforce"
+)
+
+// prefix starts every scoped alias, as "tenant:{userHash}:{alias}".
+const prefix = "tenant:"
+
+// hashSize is how many bytes of the HMAC a tenant prefix carries, enough to
+// keep tenants apart while keeping links short.
+const hashSize = 8
+
+// ErrForeignTenant is returned by Check for an alias scoped to another tenant.
+var ErrForeignTenant = errors.New("alias belongs to another tenant")
+
+// ParseMode validates a configured tenant scoping mode name.
+func ParseMode(name string) (Mode, error) {
+	switch mode := Mode(strings.ToLower(name)); mode {
+	case ModeOff, ModePublic, ModeEnforce:
+		return mode, nil
+	case "":
+		return ModeOff, nil
+	default:
+		return "", fmt.Errorf("unknown tenant scope %q", name)
+	}
+}
+
+// Scoper derives tenant prefixes from authenticated users. The user name is
+// hashed with a server key, so aliases don't reveal who saved them.
+type Scoper struct {
+	key     []byte
+	enforce bool
+}
+
+// New returns a Scoper using key. With enforce, Check rejects aliases of
+// other tenants.
+func New(key []byte, enforce bool) *Scoper {
+	return &Scoper{key: key, enforce: enforce}
+}
+
+// Prefix returns the prefix the aliases of user's secrets start with, or an
+// empty string for anonymous users.
+func (s *Scoper) Prefix(user string) string {
+	if user == "" {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(user))
+	return prefix + hex.EncodeToString(mac.Sum(nil)[:hashSize]) + ":"
+}
+
+// Check returns ErrForeignTenant when the Scoper enforces scoping and alias
+// is scoped to a tenant other than user. Bare aliases are open to everyone.
+func (s *Scoper) Check(alias, user string) error {
+	scope := Of(alias)
+	if !s.enforce || scope == "" {
+		return nil
+	}
+	if !hmac.Equal([]byte(scope), []byte(s.Prefix(user))) {
+		return ErrForeignTenant
+	}
+	return nil
+}
+
+// Of returns the tenant prefix alias starts with, or an empty string for a
+// bare alias.
+func Of(alias string) string {
+	rest, found := strings.CutPrefix(alias, prefix)
+	if !found {
+		return ""
+	}
+	hash, _, found := strings.Cut(rest, ":")
+	if !found {
+		return ""
+	}
+	return prefix + hash + ":"
+}
@@ -0,0 +1,65 @@
+package tenant
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefix(t *testing.T) {
+	scoper := New([]byte("0123456789abcdef0123456789abcdef"), true)
+
+	alice := scoper.Prefix("alice")
+	require.True(t, strings.HasPrefix(alice, "tenant:"))
+	assert.Equal(t, alice, scoper.Prefix("alice"), "the same user must always get the same prefix")
+	assert.NotEqual(t, alice, scoper.Prefix("bob"))
+	assert.NotContains(t, alice, "alice")
+	assert.Empty(t, scoper.Prefix(""))
+	assert.Equal(t, alice, Of(alice+"f7ab603e-fbae-4182-8379-8763d9327d51"))
+}
+
+func TestCheck(t *testing.T) {
+	const alias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	scoper := New(key, true)
+	owned := scoper.Prefix("alice") + alias
+
+	testCases := []struct {
+		name        string
+		scoper      *Scoper
+		alias       string
+		user        string
+		expectedErr error
+	}{
+		{name: "Owner", scoper: scoper, alias: owned, user: "alice"},
+		{name: "Other Tenant", scoper: scoper, alias: owned, user: "bob", expectedErr: ErrForeignTenant},
+		{name: "Anonymous", scoper: scoper, alias: owned, expectedErr: ErrForeignTenant},
+		{name: "Bare Alias", scoper: scoper, alias: alias, user: "bob"},
+		{name: "Not Enforced", scoper: New(key, false), alias: owned, user: "bob"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.scoper.Check(tc.alias, tc.user)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for name, expected := range map[string]Mode{"": ModeOff, "off": ModeOff, "Public": ModePublic, "enforce": ModeEnforce} {
+		mode, err := ParseMode(name)
+		require.NoError(t, err)
+		assert.Equal(t, expected, mode)
+	}
+
+	_, err := ParseMode("strict")
+	assert.Error(t, err)
+}
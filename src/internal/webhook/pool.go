@@ -0,0 +1,202 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+	"yoopass-api/internal/health"
+	"yoopass-api/internal/storage"
+)
+
+// Policy decides what happens to an event submitted while the queue is full.
+type Policy string
+
+const (
+	// PolicyDropNew rejects the new event and keeps the queue as it is.
+	PolicyDropNew Policy = "drop_new"
+	// PolicyDropOldest evicts the oldest queued event to make room.
+	PolicyDropOldest Policy = "drop_oldest"
+)
+
+// ParsePolicy validates the overflow_policy config value. An empty value
+// means PolicyDropNew.
+func ParsePolicy(s string) (Policy, error) {
+	switch Policy(s) {
+	case "", PolicyDropNew:
+		return PolicyDropNew, nil
+	case PolicyDropOldest:
+		return PolicyDropOldest, nil
+	default:
+		return "", fmt.Errorf("unknown webhook overflow policy %q", s)
+	}
+}
+
+// Pool delivers events with at most maxConcurrent deliveries in flight and
+// at most queueSize events waiting, so a burst of reads can never turn into
+// an unbounded number of outbound requests.
+type Pool struct {
+	log       *slog.Logger
+	deliverer Deliverer
+	policy    Policy
+	queue     chan Event
+	wg        sync.WaitGroup
+
+	// mu guards closed and makes evict-then-enqueue atomic for
+	// PolicyDropOldest
+	mu     sync.Mutex
+	closed bool
+
+	heartbeat         *health.Heartbeat
+	heartbeatInterval time.Duration
+
+	deadLetters    DeadLetterStore
+	maxDeadLetters int
+}
+
+// PoolOption configures optional behaviour of a Pool.
+type PoolOption func(*Pool)
+
+// WithHeartbeat makes idle workers beat every interval and busy ones after
+// every delivery. The beat stops once every worker hangs in a delivery.
+func WithHeartbeat(heartbeat *health.Heartbeat, interval time.Duration) PoolOption {
+	return func(p *Pool) {
+		p.heartbeat = heartbeat
+		p.heartbeatInterval = interval
+	}
+}
+
+// DeadLetterStore keeps undeliverable events, see WithDeadLetters.
+type DeadLetterStore interface {
+	// this matches call in storage
+	AddDeadLetter(letter storage.DeadLetter, max int) error
+}
+
+// WithDeadLetters records every event that failed to deliver in store,
+// keeping the last max, so operators can see what downstream missed.
+func WithDeadLetters(store DeadLetterStore, max int) PoolOption {
+	return func(p *Pool) {
+		p.deadLetters = store
+		p.maxDeadLetters = max
+	}
+}
+
+func NewPool(log *slog.Logger, deliverer Deliverer, maxConcurrent, queueSize int, policy Policy, opts ...PoolOption) *Pool {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	p := &Pool{
+		log:       log.With(slog.String("op", "webhook.Pool")),
+		deliverer: deliverer,
+		policy:    policy,
+		queue:     make(chan Event, queueSize),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	p.wg.Add(maxConcurrent)
+	for range maxConcurrent {
+		go p.work()
+	}
+
+	return p
+}
+
+// Publish queues event for delivery without blocking. It reports whether
+// the event was queued.
+func (p *Pool) Publish(event Event) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return false
+	}
+
+	select {
+	case p.queue <- event:
+		return true
+	default:
+	}
+
+	if p.policy == PolicyDropOldest {
+		select {
+		case dropped := <-p.queue:
+			p.log.Warn("Webhook queue full, dropped oldest event", slog.String("type", dropped.Type))
+		default:
+		}
+
+		select {
+		case p.queue <- event:
+			return true
+		default:
+		}
+	}
+
+	p.log.Warn("Webhook queue full, dropped event", slog.String("type", event.Type))
+	return false
+}
+
+// Close stops accepting events and waits for queued ones to be delivered.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+
+	// A nil channel never fires, so without a heartbeat only the queue counts
+	var tick <-chan time.Time
+	if p.heartbeat != nil && p.heartbeatInterval > 0 {
+		ticker := time.NewTicker(p.heartbeatInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-tick:
+			p.heartbeat.Beat()
+		case event, ok := <-p.queue:
+			if !ok {
+				return
+			}
+			if err := p.deliverer.Deliver(context.Background(), event); err != nil {
+				p.log.Warn("Failed to deliver webhook", slog.String("type", event.Type), slog.Any("error", err))
+				p.deadLetter(event, err)
+			}
+			p.heartbeat.Beat()
+		}
+	}
+}
+
+// deadLetter records event, which failed to deliver with err, when dead
+// letters are kept.
+func (p *Pool) deadLetter(event Event, err error) {
+	if p.deadLetters == nil {
+		return
+	}
+
+	letter := storage.DeadLetter{
+		Type:       event.Type,
+		Alias:      event.Alias,
+		OccurredAt: event.OccurredAt,
+		FailedAt:   time.Now().UTC(),
+		Error:      err.Error(),
+	}
+	if err := p.deadLetters.AddDeadLetter(letter, p.maxDeadLetters); err != nil {
+		p.log.Error("Failed to record dead letter", slog.String("type", event.Type), slog.Any("error", err))
+	}
+}
@@ -0,0 +1,213 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"yoopass-api/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingDeliverer records deliveries and holds each one until released
+type blockingDeliverer struct {
+	release  chan struct{}
+	started  chan string
+	inFlight atomic.Int32
+	maxSeen  atomic.Int32
+
+	mu        sync.Mutex
+	delivered []string
+}
+
+func newBlockingDeliverer() *blockingDeliverer {
+	return &blockingDeliverer{
+		release: make(chan struct{}),
+		started: make(chan string, 100),
+	}
+}
+
+func (d *blockingDeliverer) Deliver(_ context.Context, event Event) error {
+	n := d.inFlight.Add(1)
+	for {
+		seen := d.maxSeen.Load()
+		if n <= seen || d.maxSeen.CompareAndSwap(seen, n) {
+			break
+		}
+	}
+	d.started <- event.Alias
+
+	<-d.release
+	d.inFlight.Add(-1)
+
+	d.mu.Lock()
+	d.delivered = append(d.delivered, event.Alias)
+	d.mu.Unlock()
+	return nil
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestPoolBoundsConcurrency(t *testing.T) {
+	d := newBlockingDeliverer()
+	pool := NewPool(discardLogger(), d, 3, 20, PolicyDropNew)
+
+	for i := range 10 {
+		require.True(t, pool.Publish(NewEvent(EventSecretRead, string(rune('a'+i)))))
+	}
+
+	// Exactly maxConcurrent deliveries start, the rest wait in the queue
+	for range 3 {
+		<-d.started
+	}
+	select {
+	case <-d.started:
+		require.FailNow(t, "more deliveries started than allowed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(d.release)
+	pool.Close()
+
+	assert.Equal(t, int32(3), d.maxSeen.Load())
+	assert.Len(t, d.delivered, 10)
+}
+
+func TestPoolOverflowPolicy(t *testing.T) {
+	testCases := []struct {
+		name              string
+		policy            Policy
+		expectThirdQueued bool
+		expectedDelivered []string
+	}{
+		{name: "Drop New", policy: PolicyDropNew, expectThirdQueued: false, expectedDelivered: []string{"first", "second"}},
+		{name: "Drop Oldest", policy: PolicyDropOldest, expectThirdQueued: true, expectedDelivered: []string{"first", "third"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := newBlockingDeliverer()
+			pool := NewPool(discardLogger(), d, 1, 1, tc.policy)
+
+			// first occupies the only worker, second fills the queue
+			require.True(t, pool.Publish(NewEvent(EventSecretRead, "first")))
+			<-d.started
+			require.True(t, pool.Publish(NewEvent(EventSecretRead, "second")))
+
+			assert.Equal(t, tc.expectThirdQueued, pool.Publish(NewEvent(EventSecretRead, "third")))
+
+			close(d.release)
+			pool.Close()
+			assert.Equal(t, tc.expectedDelivered, d.delivered)
+		})
+	}
+}
+
+func TestPoolRejectsAfterClose(t *testing.T) {
+	d := newBlockingDeliverer()
+	close(d.release)
+	pool := NewPool(discardLogger(), d, 1, 1, PolicyDropNew)
+	pool.Close()
+
+	assert.False(t, pool.Publish(NewEvent(EventSecretRead, "late")))
+}
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy("")
+	require.NoError(t, err)
+	assert.Equal(t, PolicyDropNew, policy)
+
+	policy, err = ParsePolicy("drop_oldest")
+	require.NoError(t, err)
+	assert.Equal(t, PolicyDropOldest, policy)
+
+	_, err = ParsePolicy("block")
+	assert.Error(t, err)
+}
+
+func TestHTTPDeliverer(t *testing.T) {
+	var got Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	event := NewEvent(EventSecretExpired, "f7ab603e-fbae-4182-8379-8763d9327d51")
+	require.NoError(t, NewHTTPDeliverer(server.URL, time.Second).Deliver(context.Background(), event))
+	assert.Equal(t, event.Type, got.Type)
+	assert.Equal(t, event.Alias, got.Alias)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	assert.Error(t, NewHTTPDeliverer(failing.URL, time.Second).Deliver(context.Background(), event))
+}
+
+// deadLetterRecorder keeps dead letters in memory
+type deadLetterRecorder struct {
+	mu      sync.Mutex
+	letters []storage.DeadLetter
+	max     int
+}
+
+func (r *deadLetterRecorder) AddDeadLetter(letter storage.DeadLetter, max int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.letters = append(r.letters, letter)
+	r.max = max
+	return nil
+}
+
+func TestPoolRecordsDeadLetters(t *testing.T) {
+	var calls atomic.Int32
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+
+	recorder := &deadLetterRecorder{}
+	pool := NewPool(discardLogger(), NewHTTPDeliverer(failing.URL, time.Second), 1, 10, PolicyDropNew,
+		WithDeadLetters(recorder, 50))
+
+	event := NewEvent(EventSecretRead, "f7ab603e")
+	require.True(t, pool.Publish(event))
+	pool.Close()
+
+	require.EqualValues(t, 1, calls.Load())
+	require.Len(t, recorder.letters, 1)
+	letter := recorder.letters[0]
+	assert.Equal(t, EventSecretRead, letter.Type)
+	assert.Equal(t, "f7ab603e", letter.Alias)
+	assert.True(t, event.OccurredAt.Equal(letter.OccurredAt))
+	assert.False(t, letter.FailedAt.IsZero())
+	assert.Contains(t, letter.Error, "unexpected status 502")
+	assert.Equal(t, 50, recorder.max)
+}
+
+func TestPoolSkipsDeadLettersOnSuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	recorder := &deadLetterRecorder{}
+	pool := NewPool(discardLogger(), NewHTTPDeliverer(server.URL, time.Second), 1, 10, PolicyDropNew,
+		WithDeadLetters(recorder, 50))
+
+	require.True(t, pool.Publish(NewEvent(EventSecretRead, "f7ab603e")))
+	pool.Close()
+
+	assert.Empty(t, recorder.letters)
+}
@@ -0,0 +1,76 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	EventSecretRead    = "secret.read"
+	EventSecretExpired = "secret.expired"
+)
+
+// Event is the JSON body posted to the webhook URL. It never carries the
+// secret or its key.
+type Event struct {
+	Type       string    `json:"type"`
+	Alias      string    `json:"alias"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+func NewEvent(eventType, alias string) Event {
+	return Event{
+		Type:       eventType,
+		Alias:      alias,
+		OccurredAt: time.Now().UTC(),
+	}
+}
+
+// Deliverer sends one event downstream.
+type Deliverer interface {
+	Deliver(ctx context.Context, event Event) error
+}
+
+// HTTPDeliverer posts events as JSON to a fixed URL.
+type HTTPDeliverer struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPDeliverer(url string, timeout time.Duration) *HTTPDeliverer {
+	return &HTTPDeliverer{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (d *HTTPDeliverer) Deliver(ctx context.Context, event Event) error {
+	const op = "webhook.HTTPDeliverer.Deliver"
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("%s: unexpected status %d", op, res.StatusCode)
+	}
+
+	return nil
+}
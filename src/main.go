@@ -1,59 +1,746 @@
-package main
-
-import (
-	"log/slog"
-	"net/http"
-	"os"
-	"yoopass-api/internal/config"
-	"yoopass-api/internal/http-server/handlers/fetch"
-	"yoopass-api/internal/http-server/handlers/save"
-	redis "yoopass-api/internal/storage"
-
-	"github.com/go-chi/chi"
-)
-
-const (
-	envLocal = "local"
-	envDev   = "dev"
-	envProd  = "prod"
-)
-
-func main() {
-	log := setupLogger()
-
-	cfg := config.MustLoad(log)
-
-	redis, err := redis.New(cfg.StoragePath)
-	if err != nil {
-		log.Error("Failed to initialize storage", slog.Any("error", err))
-		os.Exit(1)
-	}
-
-	router := chi.NewRouter()
-
-	router.Get("/{alias}/{key}", fetch.New(log, redis))
-	router.Post("/add", save.New(log, redis))
-
-	log.Info("Server started on ", slog.String("address", cfg.HTTPServer.Address))
-
-	srv := &http.Server{
-		Addr:         cfg.Address,
-		Handler:      router,
-		ReadTimeout:  cfg.HTTPServer.Timeout,
-		WriteTimeout: cfg.HTTPServer.Timeout,
-		IdleTimeout:  cfg.HTTPServer.IdleTimeout,
-	}
-
-	if err := srv.ListenAndServe(); err != nil {
-		log.Error("failed to start server", slog.Any("error", err))
-	}
-
-	log.Error("server stopped")
-}
-
-func setupLogger() *slog.Logger {
-	return slog.New(
-		slog.NewJSONHandler(
-			os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}),
-	)
-}
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+	"yoopass-api/internal/config"
+	"yoopass-api/internal/expiry"
+	"yoopass-api/internal/health"
+	"yoopass-api/internal/http-server/handlers/approve"
+	"yoopass-api/internal/http-server/handlers/backup"
+	"yoopass-api/internal/http-server/handlers/burn"
+	"yoopass-api/internal/http-server/handlers/cleanup"
+	"yoopass-api/internal/http-server/handlers/deadletters"
+	"yoopass-api/internal/http-server/handlers/diagnose"
+	"yoopass-api/internal/http-server/handlers/extend"
+	"yoopass-api/internal/http-server/handlers/fetch"
+	"yoopass-api/internal/http-server/handlers/group"
+	healthz "yoopass-api/internal/http-server/handlers/health"
+	"yoopass-api/internal/http-server/handlers/limits"
+	"yoopass-api/internal/http-server/handlers/meta"
+	"yoopass-api/internal/http-server/handlers/opaque"
+	"yoopass-api/internal/http-server/handlers/prometheus"
+	"yoopass-api/internal/http-server/handlers/reads"
+	"yoopass-api/internal/http-server/handlers/ready"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/http-server/handlers/revoke"
+	"yoopass-api/internal/http-server/handlers/save"
+	"yoopass-api/internal/http-server/handlers/share"
+	"yoopass-api/internal/http-server/handlers/stats"
+	"yoopass-api/internal/http-server/handlers/ui"
+	"yoopass-api/internal/http-server/middleware/aliascheck"
+	"yoopass-api/internal/http-server/middleware/bodylimit"
+	"yoopass-api/internal/http-server/middleware/connlimit"
+	"yoopass-api/internal/http-server/middleware/csrf"
+	"yoopass-api/internal/http-server/middleware/decodelimit"
+	"yoopass-api/internal/http-server/middleware/fragment"
+	"yoopass-api/internal/http-server/middleware/hostcheck"
+	"yoopass-api/internal/http-server/middleware/identity"
+	"yoopass-api/internal/http-server/middleware/logger"
+	"yoopass-api/internal/http-server/middleware/recoverer"
+	"yoopass-api/internal/http-server/middleware/requestid"
+	"yoopass-api/internal/http-server/middleware/tenantcheck"
+	"yoopass-api/internal/http-server/middleware/tracing"
+	"yoopass-api/internal/metrics"
+	"yoopass-api/internal/notify"
+	"yoopass-api/internal/storage"
+	"yoopass-api/internal/storage/keyring"
+	"yoopass-api/internal/storage/memstore"
+	"yoopass-api/internal/storage/redis"
+	"yoopass-api/internal/storage/retry"
+	"yoopass-api/internal/telemetry"
+	"yoopass-api/internal/tools/aliassig"
+	"yoopass-api/internal/tools/cipher"
+	"yoopass-api/internal/tools/pepper"
+	"yoopass-api/internal/tools/redact"
+	"yoopass-api/internal/tools/shareurl"
+	"yoopass-api/internal/tools/shortcode"
+	"yoopass-api/internal/tools/tenant"
+	"yoopass-api/internal/webhook"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	envLocal = "local"
+	envDev   = "dev"
+	envProd  = "prod"
+)
+
+// workerHeartbeat is how often idle background workers report they are alive.
+const workerHeartbeat = 10 * time.Second
+
+// workerStaleAfter is how many missed beats or runs make /readyz report a
+// worker as stuck.
+const workerStaleAfter = 3
+
+func main() {
+	log := setupLogger()
+
+	cfg := config.MustLoad(log)
+	if err := cfg.Validate(); err != nil {
+		log.Error("Invalid config", slog.Any("error", err))
+		os.Exit(1)
+	}
+	if cfg.HashAliasesInLogs {
+		log = newLogger(os.Stdout, true)
+	}
+
+	algorithms, err := cipher.SelfTest(cipher.KeySizes...)
+	if err != nil {
+		log.Error("Cipher self-test failed", slog.Any("error", err))
+		os.Exit(1)
+	}
+	log.Info("Cipher self-test passed", slog.Any("algorithms", algorithms))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	backend, err := openStorage(cfg)
+	if err != nil {
+		log.Error("Failed to initialize storage", slog.Any("error", err))
+		os.Exit(1)
+	}
+	if cfg.StorageDriver == "memory" {
+		log.Warn("Secrets are kept in memory and lost on restart")
+	}
+
+	var store storage.Storage = backend
+	// Retries sit below the keyring, so a retried write is wrapped only once
+	if cfg.StorageRetryAttempts > 1 {
+		store = retry.Wrap(store, cfg.StorageRetryAttempts, cfg.StorageRetryBackoff)
+	}
+	if len(cfg.Keyring) > 0 {
+		ring, err := newKeyring(cfg.Keyring, cfg.KeyringActive)
+		if err != nil {
+			log.Error("Invalid keyring", slog.Any("error", err))
+			os.Exit(1)
+		}
+		store = keyring.Wrap(store, ring)
+	}
+
+	if cfg.Tracing.Enabled {
+		provider, err := telemetry.Setup(context.Background(), cfg.Tracing.Endpoint, cfg.Tracing.Insecure,
+			cfg.Tracing.SampleRatio, cfg.Tracing.ServiceName)
+		if err != nil {
+			log.Error("Failed to set up tracing", slog.Any("error", err))
+			os.Exit(1)
+		}
+		defer func() {
+			if err := provider.Shutdown(context.Background()); err != nil {
+				log.Error("Failed to flush traces", slog.Any("error", err))
+			}
+		}()
+	}
+
+	workers := health.NewRegistry()
+
+	var events *webhook.Pool
+	if cfg.Webhook.URL != "" {
+		policy, err := webhook.ParsePolicy(cfg.Webhook.OverflowPolicy)
+		if err != nil {
+			log.Error("Invalid webhook config", slog.Any("error", err))
+			os.Exit(1)
+		}
+		deliverer := webhook.NewHTTPDeliverer(cfg.Webhook.URL, cfg.Webhook.Timeout)
+		// A delivery may take up to the webhook timeout before a worker beats again
+		heartbeat := workers.Register("webhooks", workerStaleAfter*workerHeartbeat+cfg.Webhook.Timeout)
+		poolOpts := []webhook.PoolOption{webhook.WithHeartbeat(heartbeat, workerHeartbeat)}
+		if cfg.Webhook.DeadLetters > 0 {
+			poolOpts = append(poolOpts, webhook.WithDeadLetters(store, cfg.Webhook.DeadLetters))
+		}
+		events = webhook.NewPool(log, deliverer, cfg.Webhook.MaxConcurrent, cfg.Webhook.QueueSize, policy, poolOpts...)
+	}
+
+	router, err := newRouter(log, cfg, store, events, workers)
+	if err != nil {
+		log.Error("Failed to set up router", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	var background sync.WaitGroup
+	if cfg.ExpiryInterval > 0 {
+		processor := expiry.New(log, store, cfg.TombstoneTTL)
+		processor.Heartbeat = workers.Register("expiry", workerStaleAfter*cfg.ExpiryInterval)
+		if events != nil {
+			processor.OnExpired = func(alias string) {
+				events.Publish(webhook.NewEvent(webhook.EventSecretExpired, alias))
+			}
+		}
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			processor.Run(ctx, cfg.ExpiryInterval)
+		}()
+	}
+
+	log.Info("Server started on ", slog.String("address", cfg.HTTPServer.Address))
+
+	srv := &http.Server{
+		Addr:         cfg.Address,
+		Handler:      router,
+		ReadTimeout:  cfg.HTTPServer.Timeout,
+		WriteTimeout: cfg.HTTPServer.Timeout,
+		IdleTimeout:  cfg.HTTPServer.IdleTimeout,
+	}
+
+	serve := srv.ListenAndServe
+	if cfg.HTTPServer.TLSCertFile != "" {
+		srv.TLSConfig, err = newTLSConfig(cfg.HTTPServer)
+		if err != nil {
+			log.Error("Invalid TLS config", slog.Any("error", err))
+			os.Exit(1)
+		}
+		serve = func() error {
+			return srv.ListenAndServeTLS(cfg.HTTPServer.TLSCertFile, cfg.HTTPServer.TLSKeyFile)
+		}
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- serve()
+	}()
+
+	var listenErr error
+	select {
+	case listenErr = <-served:
+		log.Error("Failed to start server", slog.Any("error", listenErr))
+	case <-ctx.Done():
+		log.Info("Shutting down", slog.Duration("timeout", cfg.HTTPServer.ShutdownTimeout))
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTPServer.ShutdownTimeout)
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Error("Failed to drain connections", slog.Any("error", err))
+		}
+		cancel()
+	}
+	stop()
+
+	// Nothing publishes or touches storage once requests and workers are done
+	background.Wait()
+	if events != nil {
+		events.Close()
+	}
+	if err := backend.Close(); err != nil {
+		log.Error("Failed to close storage", slog.Any("error", err))
+	}
+
+	if listenErr != nil {
+		log.Error("Server stopped after failing to listen")
+		return
+	}
+	log.Info("Server stopped gracefully")
+}
+
+// newRouter wires the middleware stack and routes on top of store. events
+// may be nil when no webhook is configured, workers when no background
+// worker runs.
+func newRouter(log *slog.Logger, cfg *config.Config, store storage.Storage, events *webhook.Pool, workers *health.Registry) (http.Handler, error) {
+	if workers == nil {
+		workers = health.NewRegistry()
+	}
+
+	keyEncoding, err := cipher.ParseKeyEncoding(cfg.KeyEncoding)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key encoding: %w", err)
+	}
+
+	keySize := cipher.KeySize
+	if cfg.KeySize != 0 {
+		if err := cipher.ValidateKeySize(cfg.KeySize); err != nil {
+			return nil, err
+		}
+		keySize = cfg.KeySize
+	}
+
+	// Links carry the key, in production they never go out over http
+	forceHTTPS := cfg.ForceHTTPSURLs || cfg.Env == envProd
+
+	var publicBaseURL *url.URL
+	if cfg.PublicBaseURL != "" {
+		if publicBaseURL, err = shareurl.ParseBase(cfg.PublicBaseURL); err != nil {
+			return nil, err
+		}
+	}
+
+	errorFormat, err := resp.ParseFormat(cfg.ErrorFormat)
+	if err != nil {
+		return nil, fmt.Errorf("invalid error format: %w", err)
+	}
+
+	aliasMode, err := aliassig.ParseMode(cfg.AliasSignatures)
+	if err != nil {
+		return nil, fmt.Errorf("invalid alias signatures: %w", err)
+	}
+	var signer *aliassig.Signer
+	if aliasMode != aliassig.ModeOff {
+		p, err := pepper.New(cfg.ServerSecret)
+		if err != nil {
+			return nil, fmt.Errorf("alias signatures: %w", err)
+		}
+		sigKey, err := p.Subkey("alias-signature", 32)
+		if err != nil {
+			return nil, fmt.Errorf("alias signatures: %w", err)
+		}
+		signer = aliassig.New(sigKey, aliasMode == aliassig.ModeEnforce)
+	}
+
+	tenantMode, err := tenant.ParseMode(cfg.TenantScope)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tenant scope: %w", err)
+	}
+	var scoper *tenant.Scoper
+	if tenantMode != tenant.ModeOff {
+		p, err := pepper.New(cfg.ServerSecret)
+		if err != nil {
+			return nil, fmt.Errorf("tenant scope: %w", err)
+		}
+		tenantKey, err := p.Subkey("tenant-prefix", 32)
+		if err != nil {
+			return nil, fmt.Errorf("tenant scope: %w", err)
+		}
+		scoper = tenant.New(tenantKey, tenantMode == tenant.ModeEnforce)
+	}
+
+	var coder *shortcode.Coder
+	if cfg.ShortCodes {
+		p, err := pepper.New(cfg.ServerSecret)
+		if err != nil {
+			return nil, fmt.Errorf("short codes: %w", err)
+		}
+		codeKey, err := p.Subkey("short-code", 32)
+		if err != nil {
+			return nil, fmt.Errorf("short codes: %w", err)
+		}
+		coder = shortcode.New(codeKey)
+	}
+
+	router := chi.NewRouter()
+	router.Use(fragment.Strip)
+	router.Use(middleware.RequestID)
+	// Only the global provider is used, so tests can install their own
+	var tracer trace.Tracer
+	if cfg.Tracing.Enabled {
+		tracer = otel.Tracer(telemetry.TracerName)
+		router.Use(tracing.New(tracer, otel.GetTextMapPropagator()))
+	}
+	router.Use(resp.WithFormat(errorFormat))
+	if !cfg.VerboseValidationErrors {
+		router.Use(resp.WithTerseValidation(log))
+	}
+	if cfg.MinimalInternalErrors {
+		router.Use(resp.WithMinimalInternalErrors())
+	}
+	router.Use(logger.New(log))
+	router.Use(recoverer.New(log))
+	router.Use(connlimit.New(log, cfg.HTTPServer.MaxConnPerIP))
+	// Probes reach the pod directly by its IP, never through the gateway
+	// stamping ids
+	probes := []string{"/readyz", "/healthz", "/metrics"}
+	router.Use(hostcheck.New(log, cfg.HTTPServer.AllowedHosts, probes...))
+	router.Use(requestid.New(log, cfg.RequireRequestID, probes...))
+	// Tenants are known like the admin, they just can't reach admin routes
+	credentials := maps.Clone(cfg.Tenants)
+	if cfg.HTTPServer.User != "" {
+		if credentials == nil {
+			credentials = make(map[string]string)
+		}
+		credentials[cfg.HTTPServer.User] = cfg.HTTPServer.Password
+	}
+	if len(credentials) > 0 {
+		router.Use(identity.New(log, credentials))
+	}
+	// identity must run first for authenticated requests to get their limit
+	router.Use(bodylimit.New(log, cfg.HTTPServer.MaxBodyBytes,
+		bodylimit.WithAuthenticatedLimit(cfg.HTTPServer.AuthenticatedMaxBodyBytes)))
+	router.Use(decodelimit.New(cfg.MaxDecodeAttempts))
+	// Copy-pasted links often gain a trailing slash, which would otherwise
+	// miss every route
+	router.Use(middleware.StripSlashes)
+
+	router.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		resp.RenderError(w, r, http.StatusNotFound, "Not found")
+	})
+
+	counters := metrics.New()
+
+	fetchOpts := []fetch.Option{
+		fetch.WithMaxSegmentLength(cfg.MaxAliasLength, cfg.MaxKeyLength),
+		fetch.WithKeyEncoding(keyEncoding),
+		fetch.WithPostFetchHooks(postFetchHooks...),
+		fetch.WithMetrics(counters),
+	}
+	if cfg.RotateNonce {
+		fetchOpts = append(fetchOpts, fetch.WithNonceRotation())
+	}
+	if events != nil {
+		fetchOpts = append(fetchOpts, fetch.WithEventPublisher(events))
+	}
+	// Tombstones are only written while the expiry processor runs
+	if cfg.ExpiryInterval > 0 {
+		fetchOpts = append(fetchOpts, fetch.WithTombstones())
+	}
+	if cfg.SMTP.Host != "" {
+		sender := notify.NewSMTPSender(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.Username, cfg.SMTP.Password, cfg.SMTP.From)
+		notifier := notify.New(sender, cfg.SMTP.Timeout, cfg.SMTP.IncludeClientIP)
+		fetchOpts = append(fetchOpts, fetch.WithReadNotifier(notifier))
+	}
+	if cfg.ReadHistoryLength > 0 {
+		// Without a secret key the IP hashes could be reversed by brute force
+		p, err := pepper.New(cfg.ServerSecret)
+		if err != nil {
+			return nil, fmt.Errorf("read history: %w", err)
+		}
+		ipKey, err := p.Subkey("read-history-ip", 32)
+		if err != nil {
+			return nil, fmt.Errorf("read history: %w", err)
+		}
+		fetchOpts = append(fetchOpts, fetch.WithReadHistory(cfg.ReadHistoryLength, ipKey))
+	}
+
+	if cfg.OneTimeGracePeriod > 0 {
+		p, err := pepper.New(cfg.ServerSecret)
+		if err != nil {
+			return nil, fmt.Errorf("one-time grace period: %w", err)
+		}
+		holderKey, err := p.Subkey("grace-holder", 32)
+		if err != nil {
+			return nil, fmt.Errorf("one-time grace period: %w", err)
+		}
+		fetchOpts = append(fetchOpts, fetch.WithGracePeriod(cfg.OneTimeGracePeriod, holderKey))
+		if cfg.OneTimeGraceSession {
+			fetchOpts = append(fetchOpts, fetch.WithSessionGrace())
+		}
+	}
+
+	// Every route taking an alias from the path checks its signature first
+	aliasCheck := aliascheck.New(log, nil)
+	if signer != nil {
+		fetchOpts = append(fetchOpts, fetch.WithAliasVerifier(signer))
+		aliasCheck = aliascheck.New(log, signer)
+	}
+	// Public routes taking an alias from the path keep tenants apart, admins
+	// reach every tenant
+	tenantCheck := tenantcheck.New(log, nil)
+	if scoper != nil {
+		fetchOpts = append(fetchOpts, fetch.WithTenantChecker(scoper))
+		tenantCheck = tenantcheck.New(log, scoper)
+	}
+
+	// Without server managed keys no route may take a key to decrypt with
+	withKey := func(h http.HandlerFunc) http.HandlerFunc {
+		if cfg.ServerManagedKeys {
+			return h
+		}
+		return func(w http.ResponseWriter, r *http.Request) {
+			resp.RenderError(w, r, http.StatusForbidden, "Server-side decryption is disabled")
+		}
+	}
+
+	router.With(aliasCheck, tenantCheck).Get("/{alias}", opaque.New(log, store))
+	router.With(aliasCheck, tenantCheck).Get("/{alias}/meta", meta.New(log, store))
+	router.With(aliasCheck, tenantCheck).Delete("/{alias}", burn.New(log, store))
+	if cfg.UUIDAliasesOnly {
+		fetchOpts = append(fetchOpts, fetch.WithUUIDAliases())
+	}
+	if tracer != nil {
+		fetchOpts = append(fetchOpts, fetch.WithTracer(tracer))
+	}
+	if cfg.PasswordAttemptsPerHour > 0 {
+		fetchOpts = append(fetchOpts, fetch.WithPasswordAttemptLimit(store, cfg.PasswordAttemptsPerHour, time.Hour))
+	}
+	router.With(aliasCheck, tenantCheck).Get("/{alias}/{key}", withKey(fetch.New(log, store, fetchOpts...)))
+	router.Post("/fetch", withKey(fetch.NewPost(log, store, fetchOpts...)))
+	downloadOpts := append(slices.Clone(fetchOpts), fetch.WithDownloadContentTypes(cfg.DownloadContentTypes...))
+	router.With(aliasCheck, tenantCheck).Get("/{alias}/{key}/download", withKey(fetch.NewDownload(log, store, downloadOpts...)))
+	router.With(aliasCheck, tenantCheck).Get("/{alias}/{key}/stream", withKey(fetch.NewStream(log, store, fetchOpts...)))
+	if cfg.ContentQR {
+		qrOpts := append(slices.Clone(fetchOpts), fetch.WithContentQRMaxBytes(cfg.ContentQRMaxBytes))
+		router.With(aliasCheck, tenantCheck).Get("/{alias}/{key}/content-qr", withKey(fetch.NewContentQR(log, store, qrOpts...)))
+	}
+	// No secret may be saved for longer than it may ever live
+	maxExpirationHours := cfg.MaxExpirationHours
+	if cfg.AbsoluteMaxLifetimeHours > 0 && (maxExpirationHours <= 0 || cfg.AbsoluteMaxLifetimeHours < maxExpirationHours) {
+		maxExpirationHours = cfg.AbsoluteMaxLifetimeHours
+	}
+	saveOpts := []save.Option{
+		save.WithMaxExpiration(maxExpirationHours),
+		save.WithPreSaveHooks(preSaveHooks...),
+		save.WithMetrics(counters),
+		save.WithKeySize(keySize),
+	}
+	if forceHTTPS {
+		saveOpts = append(saveOpts, save.WithForceHTTPS())
+	}
+	if publicBaseURL != nil {
+		saveOpts = append(saveOpts, save.WithPublicBaseURL(publicBaseURL))
+	}
+	if cfg.RequestHostURLs {
+		saveOpts = append(saveOpts, save.WithRequestHostURLs())
+	}
+	// Approvals need an operator to approve, which needs admin credentials
+	if cfg.HTTPServer.User != "" {
+		saveOpts = append(saveOpts, save.WithApprovals())
+	}
+	if !cfg.ServerManagedKeys {
+		saveOpts = append(saveOpts, save.WithClientKeysOnly())
+	}
+	if cfg.SaveDedupeWindow > 0 {
+		saveOpts = append(saveOpts, save.WithDedupe(cfg.SaveDedupeWindow))
+	}
+	if cfg.URLTitles {
+		saveOpts = append(saveOpts, save.WithURLTitles())
+	}
+	if cfg.RevealWindows {
+		saveOpts = append(saveOpts, save.WithRevealWindows())
+	}
+	if cfg.TrimMessages {
+		saveOpts = append(saveOpts, save.WithTrimMessage())
+	}
+	if cfg.MaxAbuseTagLength > 0 {
+		saveOpts = append(saveOpts, save.WithAbuseTags(cfg.MaxAbuseTagLength))
+	}
+
+	if signer != nil {
+		saveOpts = append(saveOpts, save.WithAliasSigner(signer))
+	}
+	if scoper != nil {
+		saveOpts = append(saveOpts, save.WithTenantPrefix(scoper))
+	}
+	if coder != nil {
+		saveOpts = append(saveOpts, save.WithShortCodes(coder))
+	}
+	if cfg.MaxSavesPerMinute > 0 {
+		saveOpts = append(saveOpts, save.WithGlobalRateLimit(store, cfg.MaxSavesPerMinute))
+	}
+	if cfg.HighEntropyThreshold > 0 {
+		saveOpts = append(saveOpts, save.WithHighEntropyCheck(cfg.HighEntropyThreshold, cfg.RejectHighEntropy))
+	}
+
+	if cfg.ContentSignals {
+		patterns := maps.Clone(save.DefaultSignalPatterns)
+		for name, expr := range cfg.ContentSignalPatterns {
+			pattern, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("content signal pattern %q: %w", name, err)
+			}
+			patterns[name] = pattern
+		}
+		saveOpts = append(saveOpts, save.WithContentSignals(patterns))
+	}
+
+	if cfg.ExplicitNoExpiry {
+		saveOpts = append(saveOpts, save.WithExplicitNoExpiry(cfg.DefaultExpirationHours))
+	}
+	if cfg.MaxSecretBytes > 0 || len(cfg.PrivilegedMaxSecretBytes) > 0 {
+		saveOpts = append(saveOpts, save.WithMaxSecretBytes(cfg.MaxSecretBytes, cfg.PrivilegedMaxSecretBytes))
+	}
+	if cfg.MaxMetadataBytes > 0 {
+		saveOpts = append(saveOpts, save.WithMaxMetadataBytes(cfg.MaxMetadataBytes))
+	}
+	if tracer != nil {
+		saveOpts = append(saveOpts, save.WithTracer(tracer))
+	}
+	// Only browser requests are checked, API clients save as before
+	var uiCSRF []func(http.Handler) http.Handler
+	if cfg.EnableUI && cfg.UICSRF {
+		uiCSRF = append(uiCSRF, csrf.New(log))
+	}
+	router.With(uiCSRF...).Post("/add", save.New(log, store, saveOpts...))
+	shareOpts := []share.Option{share.WithKeyEncoding(keyEncoding), share.WithKeySize(keySize)}
+	if forceHTTPS {
+		shareOpts = append(shareOpts, share.WithForceHTTPS())
+	}
+	if publicBaseURL != nil {
+		shareOpts = append(shareOpts, share.WithPublicBaseURL(publicBaseURL))
+	}
+	if cfg.RequestHostURLs {
+		shareOpts = append(shareOpts, share.WithRequestHostURLs())
+	}
+	if signer != nil {
+		shareOpts = append(shareOpts, share.WithAliasSigner(signer))
+	}
+	router.With(aliasCheck, tenantCheck).Post("/{alias}/{key}/share", withKey(share.New(log, store, shareOpts...)))
+	extendOpts := []extend.Option{
+		extend.WithKeyEncoding(keyEncoding),
+		extend.WithMaxExpiration(maxExpirationHours),
+	}
+	if cfg.ExtendRequireIfMatch {
+		extendOpts = append(extendOpts, extend.WithRequireIfMatch())
+	}
+	if cfg.AbsoluteMaxLifetimeHours > 0 {
+		extendOpts = append(extendOpts, extend.WithMaxLifetime(cfg.AbsoluteMaxLifetimeHours))
+	}
+	router.With(aliasCheck, tenantCheck).Post("/{alias}/{key}/extend", withKey(extend.New(log, store, extendOpts...)))
+	// Public limits, privileged users may have larger secrets
+	maxSecretBytes := cfg.HTTPServer.MaxBodyBytes
+	if cfg.MaxSecretBytes > 0 && (maxSecretBytes <= 0 || int64(cfg.MaxSecretBytes) < maxSecretBytes) {
+		maxSecretBytes = int64(cfg.MaxSecretBytes)
+	}
+	router.Get("/limits", limits.New(log, limits.Limits{
+		MaxSecretBytes: maxSecretBytes,
+		MaxTTLHours:    maxExpirationHours,
+		OneTimeAllowed: true,
+		AllowedCiphers: []string{cipher.Name(keySize)},
+	}))
+
+	router.Get("/readyz", ready.New(log, workers))
+	router.Get("/healthz", healthz.New(log, store))
+	// Scraped from inside the cluster, keep it off the public ingress
+	if cfg.PrometheusMetrics {
+		router.Get("/metrics", prometheus.New(log, counters))
+	}
+
+	// Admin routes are only mounted with credentials, never with an empty login
+	if cfg.HTTPServer.User != "" {
+		adminAuth := middleware.BasicAuth("yoopass", map[string]string{
+			cfg.HTTPServer.User: cfg.HTTPServer.Password,
+		})
+		router.Route("/group", func(r chi.Router) {
+			r.Use(adminAuth)
+			r.Delete("/{id}", group.NewDelete(log, store))
+		})
+		router.With(adminAuth, aliasCheck).Post("/{alias}/approve", approve.New(log, store, cfg.ApprovalWindow))
+		router.With(adminAuth, aliasCheck).Get("/{alias}/reads", reads.New(log, store))
+		router.Route("/admin", func(r chi.Router) {
+			r.Use(adminAuth)
+			r.Post("/revoke", revoke.New(log, store))
+			r.Post("/cleanup", cleanup.New(log, store))
+			r.Get("/stats", stats.New(log, store, counters))
+			r.Get("/webhooks/dead-letters", deadletters.New(log, store))
+			diagnoseOpts := []diagnose.Option{diagnose.WithKeyEncoding(keyEncoding)}
+			if coder != nil {
+				diagnoseOpts = append(diagnoseOpts, diagnose.WithShortCodes(coder))
+			}
+			r.Post("/diagnose", withKey(diagnose.New(log, store, diagnoseOpts...)))
+			r.Get("/export", backup.NewExport(log, store))
+			r.Post("/import", backup.NewImport(log, store))
+			if cfg.ImportCreatedAt {
+				importOpts := append(slices.Clone(saveOpts), save.WithCreatedAtOverride(cfg.CreatedAtSkew))
+				r.Post("/add", save.New(log, store, importOpts...))
+			}
+		})
+	}
+
+	if cfg.EnableUI {
+		if cfg.UICSRF {
+			router.With(csrf.Issue).Get("/", ui.New())
+		} else {
+			router.Get("/", ui.New())
+		}
+	}
+
+	return router, nil
+}
+
+// closableStorage is a storage backend holding resources until closed.
+type closableStorage interface {
+	storage.Storage
+	io.Closer
+}
+
+// openStorage opens the backend cfg.StorageDriver names.
+func openStorage(cfg *config.Config) (closableStorage, error) {
+	switch cfg.StorageDriver {
+	case "", "redis":
+		var opts []redis.Option
+		if cfg.StorageReplicaPath != "" {
+			opts = append(opts, redis.WithReplica(cfg.StorageReplicaPath))
+		}
+		return redis.New(cfg.StoragePath, opts...)
+	case "memory":
+		return memstore.New(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.StorageDriver)
+	}
+}
+
+// newKeyring builds the server-side wrapping keyring from hex or base64url
+// encoded keys by id.
+func newKeyring(keys map[string]string, active string) (*keyring.Keyring, error) {
+	decoded := make(map[string][]byte, len(keys))
+	for id, key := range keys {
+		keyBytes, err := cipher.DecodeKey(key, cipher.KeyEncodingAuto)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		decoded[id] = keyBytes
+	}
+	return keyring.New(decoded, active)
+}
+
+// defaultCipherSuites are the TLS 1.2 suites served unless configured
+// otherwise: forward secret AEAD suites only. TLS 1.3 suites aren't
+// configurable and are all strong.
+var defaultCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// newTLSConfig builds the TLS settings for serving HTTPS directly. Versions
+// below 1.2 and suites Go considers insecure are refused rather than
+// served, so a policy can't be weakened by a typo.
+func newTLSConfig(cfg config.HTTPServer) (*tls.Config, error) {
+	tlsConfig := &tls.Config{CipherSuites: defaultCipherSuites}
+
+	switch cfg.MinTLSVersion {
+	case "", "1.2":
+		tlsConfig.MinVersion = tls.VersionTLS12
+	case "1.3":
+		tlsConfig.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("unsupported minimum TLS version %q", cfg.MinTLSVersion)
+	}
+
+	if len(cfg.CipherSuites) > 0 {
+		secure := make(map[string]uint16)
+		for _, suite := range tls.CipherSuites() {
+			secure[suite.Name] = suite.ID
+		}
+
+		tlsConfig.CipherSuites = make([]uint16, 0, len(cfg.CipherSuites))
+		for _, name := range cfg.CipherSuites {
+			id, ok := secure[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+			}
+			tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+func setupLogger() *slog.Logger {
+	return newLogger(os.Stdout, false)
+}
+
+// newLogger builds the JSON logger, optionally logging alias hashes instead
+// of the aliases themselves.
+func newLogger(w io.Writer, hashAliases bool) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	if hashAliases {
+		opts.ReplaceAttr = redact.HashAliases
+	}
+
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
@@ -0,0 +1,1750 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"yoopass-api/internal/config"
+	"yoopass-api/internal/dto"
+	"yoopass-api/internal/health"
+	"yoopass-api/internal/http-server/handlers/limits"
+	resp "yoopass-api/internal/http-server/handlers/response"
+	"yoopass-api/internal/storage"
+	"yoopass-api/internal/storage/redis"
+	"yoopass-api/internal/storage/storagemock"
+	"yoopass-api/internal/tools/cipher"
+	"yoopass-api/internal/tools/pepper"
+	"yoopass-api/internal/tools/redact"
+	"yoopass-api/internal/tools/shareurl"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const (
+	testAlias = "f7ab603e-fbae-4182-8379-8763d9327d51"
+	testKey   = "46da5d3577209271242b42882a034c3d"
+)
+
+func newTestRouter(t *testing.T, store storage.Storage) http.Handler {
+	t.Helper()
+
+	cfg := &config.Config{ServerManagedKeys: true, KeyEncoding: "auto", ErrorFormat: "simple"}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+	return router
+}
+
+func TestRouterPathSegments(t *testing.T) {
+	testCases := []struct {
+		name          string
+		path          string
+		expectFetch   bool
+		expectedError string
+	}{
+		{name: "Exact Path", path: "/" + testAlias + "/" + testKey, expectFetch: true, expectedError: "Secret not found"},
+		{name: "Trailing Slash", path: "/" + testAlias + "/" + testKey + "/", expectFetch: true, expectedError: "Secret not found"},
+		{name: "Extra Segment", path: "/" + testAlias + "/" + testKey + "/extra", expectedError: "Not found"},
+		{name: "Extra Segments With Trailing Slash", path: "/" + testAlias + "/" + testKey + "/extra/more/", expectedError: "Not found"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := new(storagemock.Storage)
+			if tc.expectFetch {
+				store.On("Fetch", testAlias).Return(nil, storage.ErrNotFound).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			rr := httptest.NewRecorder()
+			newTestRouter(t, store).ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusNotFound, rr.Code)
+			assert.Contains(t, rr.Header().Get("Content-Type"), "application/json")
+
+			var body resp.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+			assert.Equal(t, resp.Error(tc.expectedError), body)
+
+			store.AssertExpectations(t)
+			if !tc.expectFetch {
+				store.AssertNotCalled(t, "Fetch", testAlias)
+			}
+		})
+	}
+}
+
+func TestRouterRejectsInvalidConfig(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	_, err := newRouter(log, &config.Config{KeyEncoding: "base32"}, new(storagemock.Storage), nil, nil)
+	assert.Error(t, err)
+
+	_, err = newRouter(log, &config.Config{ErrorFormat: "xml"}, new(storagemock.Storage), nil, nil)
+	assert.Error(t, err)
+}
+
+func TestRouterUI(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	testCases := []struct {
+		name           string
+		enableUI       bool
+		expectedStatus int
+	}{
+		{name: "Enabled", enableUI: true, expectedStatus: http.StatusOK},
+		{name: "Disabled By Default", enableUI: false, expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router, err := newRouter(log, &config.Config{ServerManagedKeys: true, EnableUI: tc.enableUI}, new(storagemock.Storage), nil, nil)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			if tc.enableUI {
+				assert.Contains(t, rr.Header().Get("Content-Type"), "text/html")
+				assert.True(t, strings.Contains(rr.Body.String(), `<form id="save">`))
+			}
+		})
+	}
+}
+
+func TestRouterUICSRF(t *testing.T) {
+	store := new(storagemock.Storage)
+	store.On("Set", mock.Anything, mock.Anything, time.Hour).Return(nil)
+
+	cfg := &config.Config{KeyEncoding: "auto", ServerManagedKeys: true, EnableUI: true, UICSRF: true}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	cookies := rr.Result().Cookies()
+	require.Len(t, cookies, 1)
+
+	save := func(origin string, cookie *http.Cookie, token string) int {
+		req := httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"message":"m","expiration":1}`))
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		if token != "" {
+			req.Header.Set("X-CSRF-Token", token)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusOK, save("http://example.com", cookies[0], cookies[0].Value), "the UI echoes its token")
+	assert.Equal(t, http.StatusForbidden, save("http://example.com", cookies[0], ""), "a form without the token is rejected")
+	assert.Equal(t, http.StatusForbidden, save("https://evil.example", nil, "guessed"), "a forged token is rejected")
+	assert.Equal(t, http.StatusOK, save("", nil, ""), "API clients are not checked")
+}
+
+func TestRouterRejectsOversizedBody(t *testing.T) {
+	cfg := &config.Config{ServerManagedKeys: true, HTTPServer: config.HTTPServer{MaxBodyBytes: 64}}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, new(storagemock.Storage), nil, nil)
+	require.NoError(t, err)
+
+	body := `{"message":"` + strings.Repeat("x", 128) + `"}`
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	var got resp.Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, resp.Error("Request body is too large"), got)
+}
+
+func TestRouterGroupDeleteRequiresAuth(t *testing.T) {
+	cfg := &config.Config{ServerManagedKeys: true, HTTPServer: config.HTTPServer{User: "admin", Password: "s3cret"}}
+
+	testCases := []struct {
+		name           string
+		user, password string
+		expectDelete   bool
+		expectedStatus int
+	}{
+		{name: "No Credentials", expectedStatus: http.StatusUnauthorized},
+		{name: "Wrong Password", user: "admin", password: "nope", expectedStatus: http.StatusUnauthorized},
+		{name: "Valid Credentials", user: "admin", password: "s3cret", expectDelete: true, expectedStatus: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := new(storagemock.Storage)
+			if tc.expectDelete {
+				store.On("DeleteGroup", "team").Return(2, nil).Once()
+			}
+
+			router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodDelete, "/group/team", nil)
+			if tc.user != "" {
+				req.SetBasicAuth(tc.user, tc.password)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			store.AssertExpectations(t)
+			if !tc.expectDelete {
+				store.AssertNotCalled(t, "DeleteGroup", "team")
+			}
+		})
+	}
+}
+
+func TestRouterLimitsReflectConfig(t *testing.T) {
+	cfg := &config.Config{ServerManagedKeys: true, HTTPServer: config.HTTPServer{MaxBodyBytes: 2048}}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, new(storagemock.Storage), nil, nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/limits", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	var body limits.Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.Equal(t, int64(2048), body.MaxSecretBytes)
+	assert.True(t, body.OneTimeAllowed)
+	assert.Equal(t, []string{"AES-128-GCM"}, body.AllowedCiphers)
+}
+
+func TestRouterHashesAliasesInLogs(t *testing.T) {
+	testCases := []struct {
+		name        string
+		hashAliases bool
+	}{
+		{name: "Raw Aliases By Default", hashAliases: false},
+		{name: "Hashed Aliases", hashAliases: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			store := new(storagemock.Storage)
+			store.On("Fetch", testAlias).Return(nil, storage.ErrNotFound).Once()
+			store.On("TTL", testAlias).Return(time.Duration(0), storage.ErrNotFound).Once()
+
+			router, err := newRouter(newLogger(&logs, tc.hashAliases), &config.Config{ServerManagedKeys: true}, store, nil, nil)
+			require.NoError(t, err)
+
+			for _, path := range []string{"/" + testAlias + "/" + testKey, "/" + testAlias + "/meta"} {
+				rr := httptest.NewRecorder()
+				router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+				require.Equal(t, http.StatusNotFound, rr.Code)
+			}
+
+			if tc.hashAliases {
+				assert.NotContains(t, logs.String(), testAlias)
+				assert.Contains(t, logs.String(), `"alias":"`+redact.HashAlias(testAlias)+`"`)
+			} else {
+				assert.Contains(t, logs.String(), `"alias":"`+testAlias+`"`)
+			}
+		})
+	}
+}
+
+func TestRouterRevokingOneShareKeepsOthers(t *testing.T) {
+	store, err := redis.New(miniredis.RunT(t).Addr())
+	require.NoError(t, err)
+	router := newTestRouter(t, store)
+
+	type link struct {
+		Alias string `json:"alias"`
+		Key   string `json:"key"`
+	}
+	post := func(path, body string) link {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var out link
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &out))
+		return out
+	}
+	fetchStatus := func(alias, key string) int {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+alias+"/"+key, nil))
+		return rr.Code
+	}
+
+	saved := post("/add", `{"message":"s","expiration":1}`)
+	first := post("/"+saved.Alias+"/"+saved.Key+"/share", "")
+	second := post("/"+saved.Alias+"/"+saved.Key+"/share", "")
+
+	require.NoError(t, store.Delete(first.Alias))
+
+	assert.Equal(t, http.StatusNotFound, fetchStatus(first.Alias, first.Key))
+	assert.Equal(t, http.StatusOK, fetchStatus(second.Alias, second.Key))
+	assert.Equal(t, http.StatusOK, fetchStatus(saved.Alias, saved.Key))
+}
+
+func TestRouterAdminRevoke(t *testing.T) {
+	store, err := redis.New(miniredis.RunT(t).Addr())
+	require.NoError(t, err)
+
+	cfg := &config.Config{ServerManagedKeys: true, KeyEncoding: "auto", HTTPServer: config.HTTPServer{User: "admin", Password: "s3cret"}}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+
+	save := func(body string) string {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var out struct {
+			Alias string `json:"alias"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &out))
+		return out.Alias
+	}
+	revoke := func(body string, user string) (int, int) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/admin/revoke", strings.NewReader(body))
+		req.SetBasicAuth(user, "s3cret")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var out struct {
+			Deleted int `json:"deleted"`
+		}
+		_ = json.Unmarshal(rr.Body.Bytes(), &out)
+		return rr.Code, out.Deleted
+	}
+	exists := func(alias string) bool {
+		_, err := store.TTL(alias)
+		return err == nil
+	}
+
+	first := save(`{"message":"a","expiration":1,"group_id":"incident"}`)
+	second := save(`{"message":"b","expiration":1,"group_id":"incident"}`)
+	other := save(`{"message":"c","expiration":1}`)
+
+	status, _ := revoke(`{"group_id":"incident"}`, "intruder")
+	require.Equal(t, http.StatusUnauthorized, status)
+	assert.True(t, exists(first))
+
+	status, deleted := revoke(`{"group_id":"incident"}`, "admin")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 2, deleted)
+	assert.False(t, exists(first))
+	assert.False(t, exists(second))
+	assert.True(t, exists(other))
+
+	before := time.Now().Add(time.Second).UTC().Format(time.RFC3339)
+	status, deleted = revoke(`{"created_before":"`+before+`"}`, "admin")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 1, deleted)
+	assert.False(t, exists(other))
+}
+
+func TestRouterSplitKey(t *testing.T) {
+	store, err := redis.New(miniredis.RunT(t).Addr())
+	require.NoError(t, err)
+	router := newTestRouter(t, store)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/add",
+		strings.NewReader(`{"message":"launch codes","expiration":1,"split":{"n":3,"k":2}}`)))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var saved struct {
+		Alias  string   `json:"alias"`
+		Key    string   `json:"key"`
+		Shares []string `json:"shares"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &saved))
+	require.Len(t, saved.Shares, 3)
+	assert.Empty(t, saved.Key, "a split secret must not hand out the whole key")
+
+	testCases := []struct {
+		name           string
+		shares         []string
+		expectedStatus int
+	}{
+		{name: "Exactly K Shares", shares: []string{saved.Shares[0], saved.Shares[2]}, expectedStatus: http.StatusOK},
+		{name: "All Shares", shares: saved.Shares, expectedStatus: http.StatusOK},
+		{name: "Fewer Than K Shares", shares: saved.Shares[1:2], expectedStatus: http.StatusBadRequest},
+		{name: "Duplicate Shares", shares: []string{saved.Shares[1], saved.Shares[1]}, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body, err := json.Marshal(map[string]any{"alias": saved.Alias, "shares": tc.shares})
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/fetch", bytes.NewReader(body)))
+
+			require.Equal(t, tc.expectedStatus, rr.Code, rr.Body.String())
+			if tc.expectedStatus == http.StatusOK {
+				assert.Contains(t, rr.Body.String(), "launch codes")
+			} else {
+				assert.NotContains(t, rr.Body.String(), "launch codes")
+			}
+		})
+	}
+}
+
+func TestRouterApprovalWindow(t *testing.T) {
+	server := miniredis.RunT(t)
+	store, err := redis.New(server.Addr())
+	require.NoError(t, err)
+
+	cfg := &config.Config{
+		KeyEncoding:       "auto",
+		ServerManagedKeys: true,
+		ApprovalWindow:    10 * time.Minute,
+		HTTPServer:        config.HTTPServer{User: "admin", Password: "s3cret"},
+	}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/add",
+		strings.NewReader(`{"message":"break glass","expiration":24,"require_approval":true}`)))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var saved struct {
+		Alias string `json:"alias"`
+		Key   string `json:"key"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &saved))
+
+	fetch := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+saved.Alias+"/"+saved.Key, nil))
+		return rr
+	}
+	approve := func() {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/"+saved.Alias+"/approve", nil)
+		req.SetBasicAuth("admin", "s3cret")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	rr = fetch()
+	assert.Equal(t, http.StatusForbidden, rr.Code, "denied before approval")
+	assert.NotContains(t, rr.Body.String(), "break glass")
+
+	approve()
+	rr = fetch()
+	assert.Equal(t, http.StatusOK, rr.Code, "allowed within the window")
+	assert.Contains(t, rr.Body.String(), "break glass")
+	assert.Equal(t, http.StatusForbidden, fetch().Code, "an approval admits one reveal")
+
+	approve()
+	server.FastForward(11 * time.Minute)
+	assert.Equal(t, http.StatusForbidden, fetch().Code, "denied once the window closed")
+
+	// Approving takes credentials
+	req := httptest.NewRequest(http.MethodPost, "/"+saved.Alias+"/approve", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestRouterReadHistory(t *testing.T) {
+	store, err := redis.New(miniredis.RunT(t).Addr())
+	require.NoError(t, err)
+
+	cfg := &config.Config{
+		KeyEncoding:       "auto",
+		ServerManagedKeys: true,
+		ServerSecret:      "pepper",
+		ReadHistoryLength: 2,
+		HTTPServer:        config.HTTPServer{User: "admin", Password: "s3cret"},
+	}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"message":"s","expiration":1}`)))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var saved struct {
+		Alias string `json:"alias"`
+		Key   string `json:"key"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &saved))
+
+	for _, ip := range []string{"203.0.113.7", "198.51.100.1", "198.51.100.2"} {
+		req := httptest.NewRequest(http.MethodGet, "/"+saved.Alias+"/"+saved.Key, nil)
+		req.RemoteAddr = ip + ":40000"
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/"+saved.Alias+"/reads", nil)
+	req.SetBasicAuth("admin", "s3cret")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var history struct {
+		Reads []storage.Read `json:"reads"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &history))
+	require.Len(t, history.Reads, 2, "history is capped")
+	assert.NotEqual(t, history.Reads[0].IPHash, history.Reads[1].IPHash)
+	for _, ip := range []string{"203.0.113.7", "198.51.100.1", "198.51.100.2"} {
+		assert.NotContains(t, rr.Body.String(), ip, "raw IPs must never be exposed")
+	}
+
+	// The history is for operators only
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+saved.Alias+"/reads", nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	// Without a secret key the IP hashes could be brute-forced
+	cfg.ServerSecret = ""
+	_, err = newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	assert.ErrorIs(t, err, pepper.ErrMissingSecret)
+}
+
+func TestRouterConcurrentExtend(t *testing.T) {
+	store, err := redis.New(miniredis.RunT(t).Addr())
+	require.NoError(t, err)
+
+	cfg := &config.Config{ServerManagedKeys: true, KeyEncoding: "auto", RotateNonce: true, ExtendRequireIfMatch: true}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"message":"s","expiration":1}`)))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var saved struct {
+		Alias string `json:"alias"`
+		Key   string `json:"key"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &saved))
+
+	extend := func(ifMatch, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/"+saved.Alias+"/"+saved.Key+"/extend", strings.NewReader(body))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Two clients that both read version 0, only the first one wins
+	rr = extend(`W/"0"`, `{"expiration":5}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, `W/"1"`, rr.Header().Get("ETag"))
+
+	rr = extend(`W/"0"`, `{"expiration":3}`)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Equal(t, `W/"1"`, rr.Header().Get("ETag"))
+
+	rr = extend("", `{"expiration":3}`)
+	assert.Equal(t, http.StatusPreconditionRequired, rr.Code)
+
+	// A view rotating the nonce must keep the extended expiry
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+saved.Alias+"/"+saved.Key, nil))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	ttl, err := store.TTL(saved.Alias)
+	require.NoError(t, err)
+	assert.Greater(t, ttl, 4*time.Hour)
+}
+
+func TestRouterServerManagedKeysDisabled(t *testing.T) {
+	store, err := redis.New(miniredis.RunT(t).Addr())
+	require.NoError(t, err)
+
+	cfg := &config.Config{KeyEncoding: "auto", ServerManagedKeys: false}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"message":"s","expiration":1}`)))
+	assert.Equal(t, http.StatusForbidden, rr.Code, "the server must not generate keys")
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"ciphertext":"c2VhbGVk","expiration":1,"one_time":true}`)))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var saved struct {
+		Alias string `json:"alias"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &saved))
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+saved.Alias+"/"+testKey, nil))
+	assert.Equal(t, http.StatusForbidden, rr.Code, "keys are not accepted for decryption")
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+saved.Alias, nil))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), `"ciphertext":"c2VhbGVk"`)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+saved.Alias, nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code, "one-time ciphertexts are burned on read")
+}
+
+func TestRouterIgnoresURLFragment(t *testing.T) {
+	store, err := redis.New(miniredis.RunT(t).Addr())
+	require.NoError(t, err)
+
+	cfg := &config.Config{KeyEncoding: "auto", ServerManagedKeys: true, URLTitles: true, PublicBaseURL: "https://secrets.example.com"}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"message":"s","expiration":1,"title":"Deploy keys"}`)))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var saved struct {
+		URL string `json:"url"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &saved))
+	require.Contains(t, saved.URL, "#title=Deploy%20keys")
+
+	// A client that wrongly sends the whole link still reaches the secret
+	u, err := url.Parse(saved.URL)
+	require.NoError(t, err)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, u.Path+"#"+u.EscapedFragment(), nil))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), `"message":"s"`)
+}
+
+func TestRouterSignedAliases(t *testing.T) {
+	store, err := redis.New(miniredis.RunT(t).Addr())
+	require.NoError(t, err)
+
+	cfg := &config.Config{KeyEncoding: "auto", ServerManagedKeys: true, ServerSecret: "pepper", AliasSignatures: "enforce", PublicBaseURL: "https://secrets.example.com"}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"message":"s","expiration":1}`)))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var saved struct {
+		Alias string `json:"alias"`
+		Key   string `json:"key"`
+		URL   string `json:"url"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &saved))
+	bare, _, signed := strings.Cut(saved.Alias, ".")
+	require.True(t, signed, "alias %q is not signed", saved.Alias)
+	assert.Contains(t, saved.URL, "/"+saved.Alias+"/")
+
+	tampered := bare[:len(bare)-1] + "0" + saved.Alias[len(bare):]
+	if tampered == saved.Alias {
+		tampered = bare[:len(bare)-1] + "1" + saved.Alias[len(bare):]
+	}
+
+	// Rejected aliases never reach storage, so the one-time secret survives
+	for _, alias := range []string{bare, tampered} {
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+alias+"/"+saved.Key, nil))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, alias)
+		assert.Contains(t, rr.Body.String(), "Invalid alias signature")
+
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/fetch", strings.NewReader(`{"alias":"`+alias+`","key":"`+saved.Key+`"}`)))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, alias)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+saved.Alias+"/"+saved.Key, nil))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), `"message":"s"`)
+
+	// Signing needs a secret key
+	cfg.ServerSecret = ""
+	_, err = newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	assert.ErrorIs(t, err, pepper.ErrMissingSecret)
+}
+
+func TestRouterGlobalSaveRateLimit(t *testing.T) {
+	store, err := redis.New(miniredis.RunT(t).Addr())
+	require.NoError(t, err)
+
+	cfg := &config.Config{KeyEncoding: "auto", ServerManagedKeys: true, MaxSavesPerMinute: 2}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+
+	// The budget is global, spreading saves over clients doesn't help
+	save := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"message":"s","expiration":1}`))
+		req.RemoteAddr = ip + ":40000"
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	require.Equal(t, http.StatusOK, save("203.0.113.7").Code)
+	require.Equal(t, http.StatusOK, save("198.51.100.1").Code)
+
+	rr := save("198.51.100.2")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+	assert.Contains(t, rr.Body.String(), "Too many secrets are being created")
+
+	// A second instance shares the budget through storage
+	other, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+	rr = httptest.NewRecorder()
+	other.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"message":"s","expiration":1}`)))
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+}
+
+func TestRouterLogsCryptoWithoutSecrets(t *testing.T) {
+	store, err := redis.New(miniredis.RunT(t).Addr())
+	require.NoError(t, err)
+
+	var logs bytes.Buffer
+	router, err := newRouter(newLogger(&logs, true), &config.Config{KeyEncoding: "auto", ServerManagedKeys: true}, store, nil, nil)
+	require.NoError(t, err)
+
+	const plaintext = "correct horse battery staple"
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"message":"`+plaintext+`","expiration":1}`)))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var saved struct {
+		Alias string `json:"alias"`
+		Key   string `json:"key"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &saved))
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/fetch", strings.NewReader(`{"alias":"`+saved.Alias+`","key":"`+saved.Key+`"}`)))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	entries := map[string]map[string]any{}
+	for line := range strings.Lines(logs.String()) {
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		if msg, _ := entry["msg"].(string); msg == "Secret encrypted" || msg == "Secret decrypted" {
+			entries[msg] = entry
+		}
+	}
+	for _, msg := range []string{"Secret encrypted", "Secret decrypted"} {
+		entry, ok := entries[msg]
+		require.True(t, ok, "no %q entry", msg)
+		assert.Equal(t, "DEBUG", entry["level"])
+		assert.Equal(t, cipher.Name(cipher.KeySize), entry["cipher"])
+		assert.EqualValues(t, cipher.KeySize*8, entry["key_bits"])
+		assert.Equal(t, redact.HashAlias(saved.Alias), entry["alias"])
+		assert.NotEmpty(t, entry["request_id"])
+	}
+
+	assert.NotContains(t, logs.String(), saved.Key)
+	assert.NotContains(t, logs.String(), plaintext)
+	assert.NotContains(t, logs.String(), saved.Alias)
+}
+
+func TestRouterReadyzReportsStaleWorkers(t *testing.T) {
+	workers := health.NewRegistry()
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), &config.Config{ServerManagedKeys: true}, new(storagemock.Storage), nil, workers)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rr.Code, "no workers, nothing to wait for")
+
+	workers.Register("webhooks", time.Hour)
+	stuck := workers.Register("expiry", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Contains(t, rr.Body.String(), "stale: expiry")
+
+	stuck.Beat()
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+}
+
+func TestRouterHealthzPingsRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	store, err := redis.New(server.Addr())
+	require.NoError(t, err)
+
+	// Probes carry no request id, even where one is required
+	cfg := &config.Config{ServerManagedKeys: true, RequireRequestID: true}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.JSONEq(t, `{"status":"OK"}`, rr.Body.String())
+
+	server.Close()
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Contains(t, rr.Body.String(), "redis unreachable")
+}
+
+func TestRouterProbesSkipHostCheck(t *testing.T) {
+	store, err := redis.New(miniredis.RunT(t).Addr())
+	require.NoError(t, err)
+
+	cfg := &config.Config{ServerManagedKeys: true, HTTPServer: config.HTTPServer{AllowedHosts: []string{"secrets.example.com"}}}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+
+	// Kubelet probes send the pod IP as host
+	get := func(path string) int {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Host = "10.0.0.7:8082"
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusOK, get("/healthz"))
+	assert.Equal(t, http.StatusOK, get("/readyz"))
+	assert.Equal(t, http.StatusBadRequest, get("/"+testAlias+"/"+testKey), "other routes still check the host")
+}
+
+func TestRouterCapsDecodeAttempts(t *testing.T) {
+	store, err := redis.New(miniredis.RunT(t).Addr())
+	require.NoError(t, err)
+
+	// Rebuilding a split key and decrypting with it are two operations
+	cfg := &config.Config{KeyEncoding: "auto", ServerManagedKeys: true, MaxDecodeAttempts: 1}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+
+	save := func(body string) map[string]any {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var saved map[string]any
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &saved))
+		return saved
+	}
+	fetch := func(body map[string]any) *httptest.ResponseRecorder {
+		b, err := json.Marshal(body)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/fetch", bytes.NewReader(b)))
+		return rr
+	}
+
+	plain := save(`{"message":"s","expiration":1}`)
+	rr := fetch(map[string]any{"alias": plain["alias"], "key": plain["key"]})
+	assert.Equal(t, http.StatusOK, rr.Code, "one decrypt fits the budget")
+
+	split := save(`{"message":"launch codes","expiration":1,"one_time":true,"split":{"n":2,"k":2}}`)
+	rr = fetch(map[string]any{"alias": split["alias"], "shares": split["shares"]})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Too many decode attempts")
+	assert.NotContains(t, rr.Body.String(), "launch codes")
+
+	// The budget is per request and the rejected attempt burned nothing
+	cfg.MaxDecodeAttempts = 2
+	router, err = newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+	rr = fetch(map[string]any{"alias": split["alias"], "shares": split["shares"]})
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), "launch codes")
+}
+
+func TestRouterExportImportRoundTrip(t *testing.T) {
+	newInstance := func() http.Handler {
+		store, err := redis.New(miniredis.RunT(t).Addr())
+		require.NoError(t, err)
+		cfg := &config.Config{
+			KeyEncoding:       "auto",
+			ServerManagedKeys: true,
+			HTTPServer:        config.HTTPServer{User: "admin", Password: "s3cret"},
+		}
+		router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+		require.NoError(t, err)
+		return router
+	}
+	source, target := newInstance(), newInstance()
+
+	type saved struct {
+		Alias string `json:"alias"`
+		Key   string `json:"key"`
+	}
+	var secrets []saved
+	for _, body := range []string{`{"message":"first","expiration":1}`, `{"message":"second","expiration":2,"one_time":true}`} {
+		rr := httptest.NewRecorder()
+		source.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var s saved
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &s))
+		secrets = append(secrets, s)
+	}
+	rr := httptest.NewRecorder()
+	source.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"ciphertext":"b3BhcXVl","expiration":1}`)))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var opaque saved
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &opaque))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/export", nil)
+	req.SetBasicAuth("admin", "s3cret")
+	rr = httptest.NewRecorder()
+	source.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+	backup := rr.Body.String()
+	assert.Len(t, strings.Split(strings.TrimSpace(backup), "\n"), 3)
+	for _, plaintext := range []string{"first", "second"} {
+		assert.NotContains(t, backup, plaintext, "backups stay encrypted")
+	}
+
+	// Backups are for operators only
+	rr = httptest.NewRecorder()
+	target.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/import", strings.NewReader(backup)))
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/import", strings.NewReader(backup))
+	req.SetBasicAuth("admin", "s3cret")
+	rr = httptest.NewRecorder()
+	target.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.JSONEq(t, `{"status":"OK","imported":3,"skipped":0}`, rr.Body.String())
+
+	for i, s := range secrets {
+		rr := httptest.NewRecorder()
+		target.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+s.Alias+"/"+s.Key, nil))
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Contains(t, rr.Body.String(), []string{"first", "second"}[i])
+	}
+
+	// Plaintext metadata comes along, the opaque secret is still served as such
+	rr = httptest.NewRecorder()
+	target.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+opaque.Alias, nil))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), `"ciphertext":"b3BhcXVl"`)
+}
+
+func TestRouterValidationErrorVerbosity(t *testing.T) {
+	testCases := []struct {
+		name         string
+		verbose      bool
+		expectedBody string
+	}{
+		{name: "Verbose", verbose: true, expectedBody: `{"status":"ERROR","type":"validation","errors":[{"field":"message","error":"This field is required"}]}`},
+		{name: "Terse", verbose: false, expectedBody: `{"status":"ERROR","type":"error","error":"Invalid request"}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.Config{ServerManagedKeys: true, VerboseValidationErrors: tc.verbose}
+			router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, new(storagemock.Storage), nil, nil)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"expiration":1}`)))
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestRouterMinimalInternalErrors(t *testing.T) {
+	store := new(storagemock.Storage)
+	store.On("Metadata", "alias").Return(storage.Metadata{}, errors.New("dial tcp 10.0.0.7:6379: connection refused"))
+
+	cfg := &config.Config{ServerManagedKeys: true, MinimalInternalErrors: true}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/alias", nil)
+	req.Header.Set("X-Request-Id", "req-42")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	// Nothing of the storage error, just what it takes to find it in the logs
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.Equal(t, map[string]string{
+		"status":     resp.StatusError,
+		"code":       resp.CodeInternal,
+		"request_id": "req-42",
+	}, body)
+}
+
+func TestRouterAdminAddKeepsCreatedAt(t *testing.T) {
+	server := miniredis.RunT(t)
+	store, err := redis.New(server.Addr())
+	require.NoError(t, err)
+	cfg := &config.Config{
+		KeyEncoding:       "auto",
+		ServerManagedKeys: true,
+		ImportCreatedAt:   true,
+		CreatedAtSkew:     time.Minute,
+		HTTPServer:        config.HTTPServer{User: "admin", Password: "s3cret"},
+	}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+
+	const body = `{"message":"s","expiration":1,"created_at":"2021-03-04T05:06:07Z"}`
+	createdAt := func(req *http.Request) time.Time {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var saved struct {
+			Alias string `json:"alias"`
+			Key   string `json:"key"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &saved))
+
+		object, err := store.Fetch(saved.Alias)
+		require.NoError(t, err)
+		plain, err := cipher.Decode(object, saved.Key)
+		require.NoError(t, err)
+		var secret dto.Secret
+		require.NoError(t, json.Unmarshal(plain, &secret))
+		return secret.CreatedAt
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/add", strings.NewReader(body))
+	req.SetBasicAuth("admin", "s3cret")
+	assert.Equal(t, time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC), createdAt(req))
+
+	// The public route keeps the server's time whatever the client sends
+	before := time.Now()
+	assert.WithinRange(t, createdAt(httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(body))), before.Add(-time.Second), time.Now())
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/add", strings.NewReader(body)))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestRouterOneTimeGracePeriod(t *testing.T) {
+	server := miniredis.RunT(t)
+	store, err := redis.New(server.Addr())
+	require.NoError(t, err)
+	cfg := &config.Config{
+		KeyEncoding:        "auto",
+		ServerManagedKeys:  true,
+		ServerSecret:       "test-secret",
+		OneTimeGracePeriod: 10 * time.Second,
+	}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"message":"once","expiration":1,"one_time":true}`)))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var saved struct {
+		Alias string `json:"alias"`
+		Key   string `json:"key"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &saved))
+	path := "/" + saved.Alias + "/" + saved.Key
+
+	fetch := func(remoteAddr string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr = fetch("198.51.100.7:1234", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	cookies := rr.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.True(t, cookies[0].HttpOnly)
+
+	// The same client may read it again within the grace period
+	rr = fetch("198.51.100.7:4321", cookies)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.JSONEq(t, `{"status":"OK","message":"once","consumed":true}`, rr.Body.String())
+
+	// Nobody else may, with or without the cookie
+	assert.Equal(t, http.StatusNotFound, fetch("203.0.113.9:1234", nil).Code)
+	assert.Equal(t, http.StatusNotFound, fetch("203.0.113.9:1234", cookies).Code)
+	assert.Equal(t, http.StatusNotFound, fetch("198.51.100.7:1234", nil).Code)
+
+	// After the grace period it's gone for good
+	server.FastForward(10 * time.Second)
+	assert.Equal(t, http.StatusNotFound, fetch("198.51.100.7:1234", cookies).Code)
+}
+
+func TestRouterOneTimeSessionGrace(t *testing.T) {
+	store, err := redis.New(miniredis.RunT(t).Addr())
+	require.NoError(t, err)
+	cfg := &config.Config{
+		KeyEncoding:         "auto",
+		ServerManagedKeys:   true,
+		ServerSecret:        "test-secret",
+		OneTimeGracePeriod:  time.Minute,
+		OneTimeGraceSession: true,
+	}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"message":"once","expiration":1,"one_time":true}`)))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var saved struct {
+		Alias string `json:"alias"`
+		Key   string `json:"key"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &saved))
+
+	fetch := func(remoteAddr string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/fetch", strings.NewReader(`{"alias":"`+saved.Alias+`","key":"`+saved.Key+`"}`))
+		req.RemoteAddr = remoteAddr
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr = fetch("198.51.100.7:1234", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	cookies := rr.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.True(t, cookies[0].Secure)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.Zero(t, cookies[0].MaxAge, "a session cookie")
+
+	// The session may re-read it from another address, nobody without it may
+	rr = fetch("203.0.113.9:1234", cookies)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.JSONEq(t, `{"status":"OK","message":"once","consumed":true}`, rr.Body.String())
+	assert.Equal(t, http.StatusNotFound, fetch("198.51.100.7:1234", nil).Code)
+}
+
+func TestRouterAdminStats(t *testing.T) {
+	store, err := redis.New(miniredis.RunT(t).Addr())
+	require.NoError(t, err)
+	cfg := &config.Config{
+		KeyEncoding:       "auto",
+		ServerManagedKeys: true,
+		HTTPServer:        config.HTTPServer{User: "admin", Password: "s3cret"},
+	}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	stats := func() map[string]any {
+		req := httptest.NewRequest(http.MethodGet, "/admin/stats", nil)
+		req.SetBasicAuth("admin", "s3cret")
+		rr := serve(req)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var body map[string]any
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+		return body
+	}
+
+	before := stats()
+	for _, field := range []string{"total_secrets", "active_secrets", "saves", "fetches", "burns", "decode_failures"} {
+		assert.EqualValues(t, 0, before[field], field)
+	}
+
+	type secret struct {
+		Alias string `json:"alias"`
+		Key   string `json:"key"`
+	}
+	var saved []secret
+	for _, body := range []string{`{"message":"kept","expiration":1}`, `{"message":"once","expiration":1,"one_time":true}`} {
+		rr := serve(httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var s secret
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &s))
+		saved = append(saved, s)
+	}
+	for _, s := range saved {
+		require.Equal(t, http.StatusOK, serve(httptest.NewRequest(http.MethodGet, "/"+s.Alias+"/"+s.Key, nil)).Code)
+	}
+	wrongKey := "00000000000000000000000000000000"
+	require.Equal(t, http.StatusInternalServerError, serve(httptest.NewRequest(http.MethodGet, "/"+saved[0].Alias+"/"+wrongKey, nil)).Code)
+
+	after := stats()
+	assert.EqualValues(t, 1, after["total_secrets"], "the one-time secret is gone")
+	assert.EqualValues(t, 1, after["active_secrets"])
+	assert.EqualValues(t, 2, after["saves"])
+	assert.EqualValues(t, 2, after["fetches"])
+	assert.EqualValues(t, 1, after["burns"])
+	assert.EqualValues(t, 1, after["decode_failures"])
+
+	assert.Equal(t, http.StatusUnauthorized, serve(httptest.NewRequest(http.MethodGet, "/admin/stats", nil)).Code)
+}
+
+func TestRouterRequireRequestID(t *testing.T) {
+	testCases := []struct {
+		name           string
+		required       bool
+		requestID      string
+		expectedStatus int
+	}{
+		{name: "Off Without Header", expectedStatus: http.StatusNotFound},
+		{name: "On With Header", required: true, requestID: "gw-7", expectedStatus: http.StatusNotFound},
+		{name: "On Without Header", required: true, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := new(storagemock.Storage)
+			store.On("Metadata", "alias").Return(storage.Metadata{}, storage.ErrNotFound).Maybe()
+
+			cfg := &config.Config{ServerManagedKeys: true, RequireRequestID: tc.required}
+			router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "/alias", nil)
+			if tc.requestID != "" {
+				req.Header.Set("X-Request-Id", tc.requestID)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+		})
+	}
+}
+
+func TestRouterTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	server := miniredis.RunT(t)
+	store, err := redis.New(server.Addr())
+	require.NoError(t, err)
+	cfg := &config.Config{
+		KeyEncoding:       "auto",
+		ServerManagedKeys: true,
+		Tracing:           config.Tracing{Enabled: true},
+	}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+
+	const message = "traced secret"
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"message":"`+message+`","expiration":1,"one_time":true}`)))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var saved struct {
+		Alias string `json:"alias"`
+		Key   string `json:"key"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &saved))
+
+	// The reveal continues the trace of the caller
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/"+saved.Alias+"/"+saved.Key, nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	spans := exporter.GetSpans()
+	parents := map[string]string{}
+	for _, span := range spans {
+		parents[span.Name] = span.Parent.SpanID().String()
+		for _, attr := range span.Attributes {
+			value := attr.Value.Emit()
+			assert.NotContains(t, value, message, "span %s leaks the message", span.Name)
+			assert.NotContains(t, value, saved.Key, "span %s leaks the key", span.Name)
+			assert.NotContains(t, value, saved.Alias, "span %s leaks the alias", span.Name)
+		}
+		assert.NotContains(t, span.Name, saved.Key)
+	}
+
+	byName := func(name string) tracetest.SpanStub {
+		for _, span := range spans {
+			if span.Name == name {
+				return span
+			}
+		}
+		t.Fatalf("no span %q in %v", name, parents)
+		return tracetest.SpanStub{}
+	}
+	save := byName("POST /add")
+	assert.Equal(t, save.SpanContext.SpanID(), byName("storage.Set").Parent.SpanID())
+	assert.Contains(t, save.Attributes, attribute.Int("http.response.status_code", http.StatusOK))
+
+	fetch := byName("GET /{alias}/{key}")
+	assert.Equal(t, traceID, fetch.SpanContext.TraceID().String())
+	assert.Contains(t, fetch.Attributes, attribute.String("http.route", "/{alias}/{key}"))
+	for _, op := range []string{"storage.Fetch", "storage.Consume"} {
+		span := byName(op)
+		assert.Equal(t, fetch.SpanContext.SpanID(), span.Parent.SpanID(), op)
+		assert.Contains(t, span.Attributes, attribute.String("db.system.name", "redis"), op)
+	}
+}
+
+func TestRouterPrivilegedSecretSize(t *testing.T) {
+	store := new(storagemock.Storage)
+	store.On("Set", mock.Anything, mock.Anything, time.Hour).Return(nil)
+
+	cfg := &config.Config{
+		KeyEncoding:              "auto",
+		ServerManagedKeys:        true,
+		MaxSecretBytes:           16,
+		PrivilegedMaxSecretBytes: map[string]int{"admin": 1024},
+		HTTPServer:               config.HTTPServer{User: "admin", Password: "s3cret"},
+	}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+
+	body := `{"message":"` + strings.Repeat("a", 100) + `","expiration":1}`
+	save := func(user, password string) int {
+		req := httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(body))
+		if user != "" {
+			req.SetBasicAuth(user, password)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, save("", ""), "anonymous saves keep the default cap")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, save("admin", "guess"), "wrong credentials are anonymous")
+	assert.Equal(t, http.StatusOK, save("admin", "s3cret"))
+}
+
+func TestRouterTenantIsolation(t *testing.T) {
+	server := miniredis.RunT(t)
+	store, err := redis.New(server.Addr())
+	require.NoError(t, err)
+	cfg := &config.Config{
+		KeyEncoding:       "auto",
+		ServerManagedKeys: true,
+		ServerSecret:      "test-secret",
+		UUIDAliasesOnly:   true,
+		TenantScope:       "enforce",
+		Tenants:           map[string]string{"alice": "alice-pw", "bob": "bob-pw"},
+	}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+
+	serve := func(req *http.Request, user string) *httptest.ResponseRecorder {
+		if user != "" {
+			req.SetBasicAuth(user, user+"-pw")
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve(httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"message":"alice only","expiration":1}`)), "alice")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var saved struct {
+		Alias string `json:"alias"`
+		Key   string `json:"key"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &saved))
+	require.True(t, strings.HasPrefix(saved.Alias, "tenant:"), saved.Alias)
+	assert.NotContains(t, saved.Alias, "alice")
+
+	path := "/" + saved.Alias + "/" + saved.Key
+	postBody := `{"alias":"` + saved.Alias + `","key":"` + saved.Key + `"}`
+
+	for _, user := range []string{"bob", ""} {
+		rr = serve(httptest.NewRequest(http.MethodGet, path, nil), user)
+		assert.Equal(t, http.StatusNotFound, rr.Code, "GET as %q", user)
+		rr = serve(httptest.NewRequest(http.MethodPost, "/fetch", strings.NewReader(postBody)), user)
+		assert.Equal(t, http.StatusNotFound, rr.Code, "POST as %q", user)
+	}
+
+	rr = serve(httptest.NewRequest(http.MethodGet, path, nil), "alice")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), "alice only")
+
+	// Anonymous secrets stay public
+	rr = serve(httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"message":"anyone","expiration":1}`)), "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &saved))
+	assert.False(t, strings.HasPrefix(saved.Alias, "tenant:"))
+	rr = serve(httptest.NewRequest(http.MethodGet, "/"+saved.Alias+"/"+saved.Key, nil), "bob")
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+}
+
+func TestRouterPasswordProtectedSecret(t *testing.T) {
+	store, err := redis.New(miniredis.RunT(t).Addr())
+	require.NoError(t, err)
+	cfg := &config.Config{KeyEncoding: "auto", ServerManagedKeys: true, MaxDecodeAttempts: 4}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"message":"vault code","expiration":1,"one_time":true,"password":"correct horse"}`)))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var saved struct {
+		Alias string `json:"alias"`
+		Key   string `json:"key"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &saved))
+
+	reveal := func(password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/"+saved.Alias+"/"+saved.Key, nil)
+		if password != "" {
+			req.Header.Set("X-Secret-Password", password)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// The link alone opens nothing, and failed attempts don't burn the secret
+	rr = reveal("")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, rr.Body.String())
+	rr = reveal("battery staple")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), "Wrong password")
+
+	rr = reveal("correct horse")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), "vault code")
+
+	rr = reveal("correct horse")
+	assert.Equal(t, http.StatusNotFound, rr.Code, "one-time secrets stay one-time")
+}
+
+func TestRouterPrometheusMetrics(t *testing.T) {
+	store, err := redis.New(miniredis.RunT(t).Addr())
+	require.NoError(t, err)
+
+	scrape := func(router http.Handler) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		return rr
+	}
+
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), &config.Config{ServerManagedKeys: true}, store, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, scrape(router).Code, "only mounted when enabled")
+
+	cfg := &config.Config{KeyEncoding: "auto", ServerManagedKeys: true, PrometheusMetrics: true}
+	router, err = newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+
+	rr := scrape(router)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "\nyoopass_secrets_created_total 0\n")
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"message":"counted","expiration":1}`)))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/f7ab603e-fbae-4182-8379-8763d9327d51/46da5d3577209271242b42882a034c3d", nil))
+	require.Equal(t, http.StatusNotFound, rr.Code, rr.Body.String())
+
+	body := scrape(router).Body.String()
+	assert.Contains(t, body, "\nyoopass_secrets_created_total 1\n")
+	assert.Contains(t, body, "\nyoopass_fetch_not_found_total 1\n")
+	assert.Contains(t, body, `yoopass_handler_duration_seconds_count{handler="save"} 1`)
+	assert.Contains(t, body, `yoopass_handler_duration_seconds_count{handler="fetch"} 1`)
+}
+
+func TestRouterBurnsSecretWithoutKey(t *testing.T) {
+	store, err := redis.New(miniredis.RunT(t).Addr())
+	require.NoError(t, err)
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), &config.Config{KeyEncoding: "auto", ServerManagedKeys: true}, store, nil, nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"message":"shared by mistake","expiration":1}`)))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var saved struct {
+		Alias string `json:"alias"`
+		Key   string `json:"key"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &saved))
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/"+saved.Alias, nil))
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+saved.Alias+"/"+saved.Key, nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code, "the key opens nothing once burned")
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/"+saved.Alias, nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code, rr.Body.String())
+}
+
+func TestRouterShortCodes(t *testing.T) {
+	server := miniredis.RunT(t)
+	store, err := redis.New(server.Addr())
+	require.NoError(t, err)
+	cfg := &config.Config{
+		KeyEncoding:       "auto",
+		ServerManagedKeys: true,
+		ServerSecret:      "test-secret",
+		ShortCodes:        true,
+		HTTPServer:        config.HTTPServer{User: "admin", Password: "s3cret"},
+	}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"message":"support me","expiration":1}`)))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var saved struct {
+		Alias     string `json:"alias"`
+		Key       string `json:"key"`
+		ShortCode string `json:"short_code"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &saved))
+	require.Len(t, saved.ShortCode, 6)
+
+	// The code names the secret but opens nothing, not even with the key
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+saved.ShortCode+"/"+saved.Key, nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code, rr.Body.String())
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/fetch", strings.NewReader(`{"alias":"`+saved.ShortCode+`","key":"`+saved.Key+`"}`)))
+	assert.Equal(t, http.StatusNotFound, rr.Code, rr.Body.String())
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/diagnose", strings.NewReader(`{"short_code":"`+strings.ToLower(saved.ShortCode)+`","key":"`+saved.Key+`"}`))
+	req.SetBasicAuth("admin", "s3cret")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.NotContains(t, rr.Body.String(), "support me")
+	var diagnosed struct {
+		Alias    string `json:"alias"`
+		KeyValid bool   `json:"key_valid"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &diagnosed))
+	assert.Equal(t, saved.Alias, diagnosed.Alias)
+	assert.True(t, diagnosed.KeyValid)
+}
+
+func TestRouterRejectsInvalidKeySize(t *testing.T) {
+	_, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), &config.Config{KeySize: 20}, new(storagemock.Storage), nil, nil)
+	assert.ErrorIs(t, err, cipher.ErrInvalidKeySize)
+}
+
+func TestOpenStorage(t *testing.T) {
+	addr := miniredis.RunT(t).Addr()
+
+	testCases := []struct {
+		name        string
+		cfg         config.Config
+		expectedErr string
+	}{
+		{name: "Redis By Default", cfg: config.Config{StoragePath: addr}},
+		{name: "Redis", cfg: config.Config{StorageDriver: "redis", StoragePath: addr}},
+		{name: "Redis Without Path", cfg: config.Config{StorageDriver: "redis"}, expectedErr: "storage_path is empty"},
+		{name: "Memory Needs No Path", cfg: config.Config{StorageDriver: "memory"}},
+		{name: "Unknown Driver", cfg: config.Config{StorageDriver: "etcd"}, expectedErr: `unknown storage driver "etcd"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store, err := openStorage(&tc.cfg)
+			if tc.expectedErr != "" {
+				assert.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.NoError(t, store.Close())
+		})
+	}
+}
+
+func TestRouterMemoryStorage(t *testing.T) {
+	store, err := openStorage(&config.Config{StorageDriver: "memory"})
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	router := newTestRouter(t, store)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"message":"in memory","expiration":1,"one_time":true}`)))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var saved struct {
+		Alias string `json:"alias"`
+		Key   string `json:"key"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &saved))
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+saved.Alias+"/"+saved.Key, nil))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), "in memory")
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+saved.Alias+"/"+saved.Key, nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code, rr.Body.String())
+}
+
+func TestRouterPublicBaseURL(t *testing.T) {
+	store, err := redis.New(miniredis.RunT(t).Addr())
+	require.NoError(t, err)
+	cfg := &config.Config{KeyEncoding: "auto", ServerManagedKeys: true, PublicBaseURL: "https://share.example.org/yoopass"}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+
+	type link struct {
+		Alias string `json:"alias"`
+		Key   string `json:"key"`
+		URL   string `json:"url"`
+	}
+	post := func(path, body string) link {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var out link
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &out))
+		return out
+	}
+
+	saved := post("/add", `{"message":"s","expiration":1}`)
+	assert.Equal(t, "https://share.example.org/yoopass/"+saved.Alias+"/"+saved.Key, saved.URL)
+
+	shared := post("/"+saved.Alias+"/"+saved.Key+"/share", "")
+	assert.Equal(t, "https://share.example.org/yoopass/"+shared.Alias+"/"+shared.Key, shared.URL)
+
+	cfg.PublicBaseURL = "share.example.org"
+	_, err = newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	assert.ErrorIs(t, err, shareurl.ErrInvalidBase)
+}
+
+func TestRouterForcesHTTPSURLsInProd(t *testing.T) {
+	store, err := redis.New(miniredis.RunT(t).Addr())
+	require.NoError(t, err)
+
+	// Behind a TLS terminating proxy that doesn't set X-Forwarded-Proto
+	cfg := &config.Config{Env: envProd, ServerSecret: "pepper", KeyEncoding: "auto", ServerManagedKeys: true, RequestHostURLs: true}
+	router, err := newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store, nil, nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "http://secrets.example.com/add", strings.NewReader(`{"message":"s","expiration":1}`)))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var saved struct {
+		URL string `json:"url"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &saved))
+	assert.True(t, strings.HasPrefix(saved.URL, "https://secrets.example.com/"), saved.URL)
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	testCases := []struct {
+		name            string
+		cfg             config.HTTPServer
+		expectedVersion uint16
+		expectedSuites  []uint16
+		expectedError   bool
+	}{
+		{
+			name:            "Defaults",
+			cfg:             config.HTTPServer{},
+			expectedVersion: tls.VersionTLS12,
+			expectedSuites:  defaultCipherSuites,
+		},
+		{
+			name:            "TLS 1.3",
+			cfg:             config.HTTPServer{MinTLSVersion: "1.3"},
+			expectedVersion: tls.VersionTLS13,
+			expectedSuites:  defaultCipherSuites,
+		},
+		{
+			name: "Configured Suites",
+			cfg: config.HTTPServer{
+				MinTLSVersion: "1.2",
+				CipherSuites:  []string{"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"},
+			},
+			expectedVersion: tls.VersionTLS12,
+			expectedSuites:  []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384},
+		},
+		{
+			name:          "Weak Version",
+			cfg:           config.HTTPServer{MinTLSVersion: "1.0"},
+			expectedError: true,
+		},
+		{
+			name:          "Insecure Suite",
+			cfg:           config.HTTPServer{CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+			expectedError: true,
+		},
+		{
+			name:          "Unknown Suite",
+			cfg:           config.HTTPServer{CipherSuites: []string{"TLS_MADE_UP"}},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tlsConfig, err := newTLSConfig(tc.cfg)
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedVersion, tlsConfig.MinVersion)
+			assert.Equal(t, tc.expectedSuites, tlsConfig.CipherSuites)
+		})
+	}
+}
+
+func TestTLSConfigRejectsWeakHandshake(t *testing.T) {
+	tlsConfig, err := newTLSConfig(config.HTTPServer{MinTLSVersion: "1.3"})
+	require.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = tlsConfig
+	server.StartTLS()
+	defer server.Close()
+
+	client := server.Client()
+	transport := client.Transport.(*http.Transport)
+	transport.TLSClientConfig.MaxVersion = tls.VersionTLS12
+	_, err = client.Get(server.URL)
+	require.Error(t, err, "a TLS 1.2 client must be refused")
+
+	transport.TLSClientConfig.MaxVersion = 0
+	res, err := client.Get(server.URL)
+	require.NoError(t, err)
+	res.Body.Close()
+}